@@ -0,0 +1,148 @@
+// Package api serves the blog as JSON under `/api/v1/`, for clients other
+// than browsers.  It goes through the same models and authorization rules
+// as the pages of package ctlr.
+//
+// Resources are answered as `{"data": ...}`, lists along with their
+// pagination, and errors as `{"error": {"status": ..., "message": ...}}`.
+package api
+
+import (
+	"encoding/json"
+	"github.com/aybabtme/goblog/ctlr"
+	"github.com/aybabtme/goblog/model"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// Prefix is the path the API is served under.
+const Prefix = "/api/v1"
+
+// How many items a page lists, unless asked for with `per_page`.
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// Pipeline is the pipeline of the pages, answering its errors in JSON.
+func Pipeline(conn *model.DBConnection) []ctlr.Middleware {
+	return append([]ctlr.Middleware{ctlr.JSONErrors}, ctlr.Pipeline(conn)...)
+}
+
+// NotFoundHandler answers requests under Prefix that no controller matches.
+func NotFoundHandler() http.Handler {
+	return ctlr.Chain(func(rw http.ResponseWriter, req *http.Request) {
+		ctlr.RenderError(rw, req, ctlr.NotFound("There's no such resource", nil))
+	}, ctlr.JSONErrors)
+}
+
+/*
+ * Answers
+ */
+
+type single struct {
+	Data interface{} `json:"data"`
+}
+
+type list struct {
+	Data       interface{} `json:"data"`
+	Pagination pagination  `json:"pagination"`
+}
+
+// Which part of a list is answered, from the `page` and `per_page` query
+// parameters.
+type pagination struct {
+	Page    int `json:"page"`
+	PerPage int `json:"per_page"`
+	Total   int `json:"total"`
+	Pages   int `json:"pages"`
+}
+
+func parsePagination(req *http.Request) (pagination, error) {
+	p := pagination{Page: 1, PerPage: defaultPerPage}
+	if page := req.FormValue("page"); page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			return p, ctlr.BadRequest("page must be a number from 1", err)
+		}
+		p.Page = n
+	}
+	if perPage := req.FormValue("per_page"); perPage != "" {
+		n, err := strconv.Atoi(perPage)
+		if err != nil || n < 1 || n > maxPerPage {
+			return p, ctlr.BadRequest("per_page must be a number from 1 to "+strconv.Itoa(maxPerPage), err)
+		}
+		p.PerPage = n
+	}
+	return p, nil
+}
+
+// Bounds of the page in a list of total items, which are also kept in the
+// pagination.
+func (p *pagination) bounds(total int) (from, to int) {
+	p.Total = total
+	p.Pages = (total + p.PerPage - 1) / p.PerPage
+	from = (p.Page - 1) * p.PerPage
+	if from > total {
+		from = total
+	}
+	to = from + p.PerPage
+	if to > total {
+		to = total
+	}
+	return from, to
+}
+
+// The LIMIT and OFFSET of the page in a list of total items, for the model
+// to find only those.
+func (p *pagination) window(total int) (limit, offset int) {
+	from, to := p.bounds(total)
+	return to - from, from
+}
+
+func writeJSON(rw http.ResponseWriter, status int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		log.Println("api.writeJSON:", err)
+	}
+}
+
+/*
+ * Helpers
+ */
+
+// Turns a controller func returning an error into a handler, answering the
+// error in JSON.
+func handle(h func(http.ResponseWriter, *http.Request) error) func(http.ResponseWriter, *http.Request) {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if err := h(rw, req); err != nil {
+			ctlr.RenderError(rw, req, err)
+		}
+	}
+}
+
+// Reads the JSON body of the request into v.
+func decode(req *http.Request, v interface{}) error {
+	if req.Body == nil {
+		return ctlr.BadRequest("Expected a JSON body", nil)
+	}
+	if err := json.NewDecoder(req.Body).Decode(v); err != nil {
+		return ctlr.BadRequest("Expected a JSON body", err)
+	}
+	return nil
+}
+
+// Reads the id named in the path.
+func pathId(req *http.Request, name, what string) (int64, error) {
+	id, err := strconv.ParseInt(mux.Vars(req)[name], 10, 64)
+	if err != nil {
+		return 0, ctlr.BadRequest("That's not "+what+" id", err)
+	}
+	return id, nil
+}
+
+func idPath(resource string, id int64) string {
+	return Prefix + "/" + resource + "/" + strconv.FormatInt(id, 10)
+}
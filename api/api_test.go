@@ -0,0 +1,239 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/aybabtme/goblog/ctlr"
+	"github.com/aybabtme/goblog/model"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPaginationBounds(t *testing.T) {
+	cases := []struct {
+		page, perPage, total int
+		from, to, pages      int
+	}{
+		{1, 20, 0, 0, 0, 0},
+		{1, 20, 5, 0, 5, 1},
+		{1, 2, 5, 0, 2, 3},
+		{3, 2, 5, 4, 5, 3},
+		{4, 2, 5, 5, 5, 3},
+		{10, 20, 5, 5, 5, 1},
+	}
+
+	for i, c := range cases {
+		p := pagination{Page: c.page, PerPage: c.perPage}
+		from, to := p.bounds(c.total)
+		if from != c.from || to != c.to || p.Pages != c.pages || p.Total != c.total {
+			t.Errorf("Case #%d, expected [%d:%d] of %d pages, was [%d:%d] of %d pages",
+				i, c.from, c.to, c.pages, from, to, p.Pages)
+		}
+	}
+}
+
+func TestParsePagination(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/api/v1/posts?page=3&per_page=50", nil)
+	p, err := parsePagination(req)
+	if err != nil || p.Page != 3 || p.PerPage != 50 {
+		t.Errorf("Expected page 3 of 50, was %+v, %v", p, err)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/v1/posts", nil)
+	p, err = parsePagination(req)
+	if err != nil || p.Page != 1 || p.PerPage != defaultPerPage {
+		t.Errorf("Expected the first page by default, was %+v, %v", p, err)
+	}
+
+	for _, query := range []string{"page=0", "page=first", "per_page=0", "per_page=1000"} {
+		req, _ := http.NewRequest("GET", "/api/v1/posts?"+query, nil)
+		_, err := parsePagination(req)
+		if ctlrErr, ok := err.(*ctlr.Error); !ok || ctlrErr.Status != http.StatusBadRequest {
+			t.Errorf("Expected a bad request for <%s>, was %v", query, err)
+		}
+	}
+}
+
+func TestNotFoundIsJSON(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/nothing", nil)
+	NotFoundHandler().ServeHTTP(rec, req)
+
+	body := decodeError(t, rec)
+	if rec.Code != http.StatusNotFound || body.Error.Status != http.StatusNotFound {
+		t.Errorf("Expected a 404, was %d", rec.Code)
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	cases := []struct {
+		c       ctlr.Controller
+		method  string
+		path    string
+		allowed string
+	}{
+		{NewLabelListController(), "POST", "/api/v1/labels", "GET"},
+		{NewAuthorListController(), "DELETE", "/api/v1/authors", "GET"},
+		{NewPostListController(), "DELETE", "/api/v1/posts", "GET, POST"},
+	}
+
+	for i, c := range cases {
+		router := mux.NewRouter()
+		router.HandleFunc(c.c.Path(), ctlr.Chain(c.c.Controller(nil), ctlr.JSONErrors, ctlr.LoadAuth(nil)))
+
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(c.method, c.path, nil)
+		router.ServeHTTP(rec, req)
+
+		decodeError(t, rec)
+		if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != c.allowed {
+			t.Errorf("Case #%d, expected a 405 allowing <%s>, was %d allowing <%s>",
+				i, c.allowed, rec.Code, rec.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	var input postInput
+	req, _ := http.NewRequest("POST", "/api/v1/posts", strings.NewReader(`{"title": "Go"}`))
+	if err := decode(req, &input); err != nil || *input.Title != "Go" || input.Content != nil {
+		t.Errorf("Expected only a title, was %+v, %v", input, err)
+	}
+
+	req, _ = http.NewRequest("POST", "/api/v1/posts", strings.NewReader(`<html>`))
+	if ctlrErr, ok := decode(req, &input).(*ctlr.Error); !ok || ctlrErr.Status != http.StatusBadRequest {
+		t.Error("Expected a bad request for a body that isn't JSON")
+	}
+}
+
+func TestAPI(t *testing.T) {
+	apiRequests(t, setupPGConnection())
+}
+
+func apiRequests(t *testing.T, conn *model.DBConnection) {
+	defer conn.DeleteConnection()
+	router := setupRouter(conn)
+
+	user := conn.NewUser("api", time.Now().UTC(), 0, "api-oauth", "", "", "api@example.com")
+	if err := user.Save(); err != nil {
+		t.Fatal("Couldn't save user", err)
+	}
+	author := conn.NewAuthor(user)
+	if err := author.Save(); err != nil {
+		t.Fatal("Couldn't save author", err)
+	}
+	for i := 0; i < 3; i++ {
+		post := conn.NewPost(author, "Title", "Content", "", time.Now().UTC())
+		if err := post.Save(); err != nil {
+			t.Fatal("Couldn't save post", err)
+		}
+	}
+
+	var posts struct {
+		Data       []postJSON `json:"data"`
+		Pagination pagination `json:"pagination"`
+	}
+	rec := serve(router, "GET", "/api/v1/posts?per_page=2&page=2", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected a 200 listing posts, was %d", rec.Code)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &posts); err != nil {
+		t.Fatal("Invalid JSON", err)
+	}
+	if len(posts.Data) != 1 || posts.Pagination.Total != 3 || posts.Pagination.Pages != 2 {
+		t.Errorf("Expected the last of 3 posts, was %+v", posts)
+	}
+
+	// Visitors read, but don't write
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"GET", "/api/v1/posts/424242", "", http.StatusNotFound},
+		{"GET", "/api/v1/comments/424242", "", http.StatusNotFound},
+		{"GET", "/api/v1/labels/424242", "", http.StatusNotFound},
+		{"GET", "/api/v1/authors/424242", "", http.StatusNotFound},
+		{"GET", "/api/v1/users/424242", "", http.StatusNotFound},
+		{"GET", "/api/v1/posts?page=0", "", http.StatusBadRequest},
+		{"POST", "/api/v1/posts", `{"title": "Nope"}`, http.StatusForbidden},
+		{"GET", "/api/v1/users", "", http.StatusForbidden},
+		{"GET", "/api/v1/user", "", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		rec := serve(router, c.method, c.path, c.body)
+		decodeError(t, rec)
+		if rec.Code != c.status {
+			t.Errorf("%s %s, expected status %d but was %d", c.method, c.path, c.status, rec.Code)
+		}
+	}
+
+	// Emails are private
+	rec = serve(router, "GET", idPath("users", user.Id()), "")
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "api@example.com") {
+		t.Errorf("Expected the user without their email, was %d:\n%s", rec.Code, rec.Body.String())
+	}
+}
+
+//
+// Helpers
+//
+
+func setupPGConnection() *model.DBConnection {
+	modelurl := "user=antoine dbname=test sslmode=disable"
+	conn, _ := model.NewConnection(model.NewPostgreser(modelurl))
+	return conn
+}
+
+// Routes like the blog does, without the CSRF checks.
+func setupRouter(conn *model.DBConnection) *mux.Router {
+	controllers := []ctlr.Controller{
+		NewPostListController(),
+		NewPostController(),
+		NewPostCommentListController(),
+		NewPostLabelListController(),
+		NewCommentController(),
+		NewLabelListController(),
+		NewLabelController(),
+		NewLabelPostListController(),
+		NewAuthorListController(),
+		NewAuthorController(),
+		NewAuthorPostListController(),
+		NewUserListController(),
+		NewUserController(),
+		NewCurrentUserController()}
+
+	pipeline := []ctlr.Middleware{ctlr.JSONErrors, ctlr.Recover, ctlr.LoadAuth(conn)}
+	router := mux.NewRouter()
+	for _, c := range controllers {
+		router.HandleFunc(c.Path(), ctlr.Handler(conn, c, pipeline))
+	}
+	router.PathPrefix(Prefix + "/").Handler(NotFoundHandler())
+	return router
+}
+
+func serve(router http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+type errorBody struct {
+	Error struct {
+		Status  int    `json:"status"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) errorBody {
+	var body errorBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error.Status != rec.Code {
+		t.Errorf("Expected a JSON error body, was:\n%s", rec.Body.String())
+	}
+	return body
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"github.com/aybabtme/goblog/auth"
+	"github.com/aybabtme/goblog/ctlr"
+	"github.com/aybabtme/goblog/model"
+	"net/http"
+)
+
+type author struct {
+	path string
+}
+
+func NewAuthorListController() ctlr.Controller {
+	return author{Prefix + "/authors"}
+}
+
+func NewAuthorController() ctlr.Controller {
+	return author{Prefix + "/authors/{id:[0-9]+}"}
+}
+
+func NewAuthorPostListController() ctlr.Controller {
+	return author{Prefix + "/authors/{id:[0-9]+}/posts"}
+}
+
+func (a author) Path() string {
+	return a.path
+}
+
+func (a author) Scope() string {
+	return "users"
+}
+
+func (a author) Middlewares() []ctlr.Middleware {
+	return []ctlr.Middleware{ctlr.Require(auth.Read, (*model.Author)(nil), "You can't see authors")}
+}
+
+func (a author) Controller(conn *model.DBConnection) func(http.ResponseWriter, *http.Request) {
+	return handle(func(rw http.ResponseWriter, req *http.Request) error {
+		if req.Method != "GET" && req.Method != "HEAD" {
+			return ctlr.MethodNotAllowed(rw, "GET")
+		}
+
+		if a.path == Prefix+"/authors" {
+			return a.forListing(conn, rw, req)
+		}
+
+		id, err := pathId(req, "id", "an author")
+		if err != nil {
+			return err
+		}
+		author, err := conn.FindAuthorById(id)
+		if err != nil {
+			return ctlr.NotFoundOr("There's no such author", err)
+		}
+		if !auth.Can(ctlr.CurrentUser(req), auth.Read, author) {
+			return ctlr.Forbidden("You can't see this author")
+		}
+
+		if a.path == Prefix+"/authors/{id:[0-9]+}/posts" {
+			return writePosts(rw, req, author.PostCount, author.PostsPage)
+		}
+		writeJSON(rw, http.StatusOK, single{newAuthorJSON(author)})
+		return nil
+	})
+}
+
+func (a author) forListing(conn *model.DBConnection,
+	rw http.ResponseWriter,
+	req *http.Request) error {
+
+	page, err := parsePagination(req)
+	if err != nil {
+		return err
+	}
+	total, err := conn.CountAuthors()
+	if err != nil {
+		return ctlr.InternalError(err)
+	}
+	var authors []model.Author
+	if limit, offset := page.window(total); limit > 0 {
+		if authors, err = conn.FindAuthorsPage(limit, offset); err != nil {
+			return ctlr.InternalError(err)
+		}
+	}
+
+	data := []authorJSON{}
+	for i := range authors {
+		data = append(data, newAuthorJSON(&authors[i]))
+	}
+	writeJSON(rw, http.StatusOK, list{data, page})
+	return nil
+}
+
+/*
+ * JSON
+ */
+
+type authorJSON struct {
+	Id       int64  `json:"id"`
+	UserId   int64  `json:"user_id"`
+	Username string `json:"username"`
+}
+
+func newAuthorJSON(a *model.Author) authorJSON {
+	data := authorJSON{Id: a.Id()}
+	if a.User() != nil {
+		data.UserId = a.User().Id()
+		data.Username = a.User().Username()
+	}
+	return data
+}
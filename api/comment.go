@@ -0,0 +1,253 @@
+package api
+
+import (
+	"github.com/aybabtme/goblog/auth"
+	"github.com/aybabtme/goblog/ctlr"
+	"github.com/aybabtme/goblog/model"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type comment struct {
+	path string
+}
+
+func NewPostCommentListController() ctlr.Controller {
+	return comment{Prefix + "/posts/{postId:[0-9]+}/comments"}
+}
+
+func NewCommentController() ctlr.Controller {
+	return comment{Prefix + "/comments/{id:[0-9]+}"}
+}
+
+func (c comment) Path() string {
+	return c.path
+}
+
+func (c comment) Scope() string {
+	return "comments"
+}
+
+func (c comment) Middlewares() []ctlr.Middleware {
+	return nil
+}
+
+func (c comment) Controller(conn *model.DBConnection) func(http.ResponseWriter, *http.Request) {
+	return handle(func(rw http.ResponseWriter, req *http.Request) error {
+		if c.path != Prefix+"/comments/{id:[0-9]+}" {
+			postId, err := pathId(req, "postId", "a post")
+			if err != nil {
+				return err
+			}
+			post, err := conn.FindPostById(postId)
+			if err != nil {
+				return ctlr.NotFoundOr("There's no such post", err)
+			}
+
+			switch req.Method {
+			case "GET", "HEAD":
+				return c.forListing(rw, req, post)
+			case "POST":
+				return c.forCreate(conn, rw, req, post)
+			}
+			return ctlr.MethodNotAllowed(rw, "GET, POST")
+		}
+
+		id, err := pathId(req, "id", "a comment")
+		if err != nil {
+			return err
+		}
+		comment, err := conn.FindCommentById(id)
+		if err != nil {
+			return ctlr.NotFoundOr("There's no such comment", err)
+		}
+
+		switch req.Method {
+		case "GET", "HEAD":
+			return c.forId(rw, req, comment)
+		case "PUT", "PATCH":
+			return c.forUpdate(rw, req, comment)
+		case "DELETE":
+			return c.forDestroy(rw, req, comment)
+		}
+		return ctlr.MethodNotAllowed(rw, "GET, PUT, PATCH, DELETE")
+	})
+}
+
+func (c comment) forListing(rw http.ResponseWriter, req *http.Request, post *model.Post) error {
+	if !auth.Can(ctlr.CurrentUser(req), auth.Read, (*model.Comment)(nil)) {
+		return ctlr.Forbidden("You can't read comments")
+	}
+
+	// ?pending=true lists those waiting for moderation instead
+	approved := true
+	if req.FormValue("pending") == "true" {
+		if !auth.Can(ctlr.CurrentUser(req), auth.Update, post) {
+			return ctlr.Forbidden("You can't moderate the comments of this post")
+		}
+		approved = false
+	}
+
+	page, err := parsePagination(req)
+	if err != nil {
+		return err
+	}
+	total, err := post.CommentCount(approved)
+	if err != nil {
+		return ctlr.InternalError(err)
+	}
+	var comments []model.Comment
+	if limit, offset := page.window(total); limit > 0 {
+		if comments, err = post.CommentsPage(approved, limit, offset); err != nil {
+			return ctlr.InternalError(err)
+		}
+	}
+
+	data := []commentJSON{}
+	for i := range comments {
+		data = append(data, newCommentJSON(&comments[i]))
+	}
+	writeJSON(rw, http.StatusOK, list{data, page})
+	return nil
+}
+
+func (c comment) forId(rw http.ResponseWriter, req *http.Request, comment *model.Comment) error {
+	currentUser := ctlr.CurrentUser(req)
+	if !auth.Can(currentUser, auth.Read, comment) {
+		return ctlr.Forbidden("You can't read this comment")
+	}
+	// until it's approved, only who wrote it and who moderates it see it
+	if !comment.Approved() && (currentUser == nil || currentUser.Id() != comment.UserId()) &&
+		!canModerate(currentUser, comment) {
+		return ctlr.NotFound("There's no such comment", nil)
+	}
+	writeJSON(rw, http.StatusOK, single{newCommentJSON(comment)})
+	return nil
+}
+
+func (c comment) forCreate(conn *model.DBConnection,
+	rw http.ResponseWriter,
+	req *http.Request,
+	post *model.Post) error {
+
+	currentUser := ctlr.CurrentUser(req)
+	if currentUser == nil || !auth.Can(currentUser, auth.Create, (*model.Comment)(nil)) {
+		return ctlr.Forbidden("You can't comment")
+	}
+
+	var input commentInput
+	if err := decode(req, &input); err != nil {
+		return err
+	}
+	if strings.TrimSpace(input.Content) == "" {
+		return ctlr.BadRequest("A comment needs some content", nil)
+	}
+
+	comment := conn.NewComment(currentUser.Id(), post.Id(), input.Content, time.Now().UTC())
+	if input.ParentId != 0 {
+		parent, err := conn.FindCommentById(input.ParentId)
+		if err != nil || parent.PostId() != post.Id() || !parent.Approved() {
+			return ctlr.BadRequest("There's no such comment to reply to on this post", err)
+		}
+		comment.SetParentId(parent.Id())
+	}
+	if err := comment.Save(); err != nil {
+		return ctlr.InternalError(err)
+	}
+
+	rw.Header().Set("Location", idPath("comments", comment.Id()))
+	writeJSON(rw, http.StatusCreated, single{newCommentJSON(comment)})
+	return nil
+}
+
+func (c comment) forUpdate(rw http.ResponseWriter, req *http.Request, comment *model.Comment) error {
+	currentUser := ctlr.CurrentUser(req)
+	var input commentInput
+	if err := decode(req, &input); err != nil {
+		return err
+	}
+
+	if input.Approved != nil {
+		if !canModerate(currentUser, comment) {
+			return ctlr.Forbidden("You can't moderate this comment")
+		}
+		comment.SetApproved(*input.Approved)
+	}
+	if input.Content != "" || input.Approved == nil {
+		if !auth.Can(currentUser, auth.Update, comment) {
+			return ctlr.Forbidden("You can't edit this comment")
+		}
+		if strings.TrimSpace(input.Content) == "" {
+			return ctlr.BadRequest("A comment needs some content", nil)
+		}
+		comment.SetContent(input.Content)
+	}
+	if err := comment.Update(); err != nil {
+		return ctlr.InternalError(err)
+	}
+	writeJSON(rw, http.StatusOK, single{newCommentJSON(comment)})
+	return nil
+}
+
+func (c comment) forDestroy(rw http.ResponseWriter, req *http.Request, comment *model.Comment) error {
+	if !auth.Can(ctlr.CurrentUser(req), auth.Delete, comment) {
+		return ctlr.Forbidden("You can't delete this comment")
+	}
+
+	if err := comment.Destroy(); err != nil {
+		return ctlr.InternalError(err)
+	}
+	rw.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+/*
+ * JSON
+ */
+
+type commentJSON struct {
+	Id          int64     `json:"id"`
+	PostId      int64     `json:"post_id"`
+	UserId      int64     `json:"user_id"`
+	Content     string    `json:"content"`
+	ContentHTML string    `json:"content_html"`
+	Date        time.Time `json:"date"`
+	UpVote      int64     `json:"up_vote"`
+	DownVote    int64     `json:"down_vote"`
+	ParentId    int64     `json:"parent_id,omitempty"`
+	Approved    bool      `json:"approved"`
+}
+
+func newCommentJSON(c *model.Comment) commentJSON {
+	return commentJSON{
+		Id:          c.Id(),
+		PostId:      c.PostId(),
+		UserId:      c.UserId(),
+		Content:     c.Content(),
+		ContentHTML: string(c.ContentMarkdown()),
+		Date:        c.Date(),
+		UpVote:      c.UpVote(),
+		DownVote:    c.DownVote(),
+		ParentId:    c.ParentId(),
+		Approved:    c.Approved(),
+	}
+}
+
+// What clients send to comment, or to edit a comment.
+type commentInput struct {
+	Content string `json:"content"`
+	// the comment it replies to, when commenting
+	ParentId int64 `json:"parent_id"`
+	// to approve the comment, or put it back in moderation
+	Approved *bool `json:"approved"`
+}
+
+// Whoever may edit a post moderates its comments.
+func canModerate(user *model.User, comment *model.Comment) bool {
+	if user == nil {
+		return false
+	}
+	post, err := comment.Post()
+	return err == nil && auth.Can(user, auth.Update, post)
+}
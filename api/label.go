@@ -0,0 +1,246 @@
+package api
+
+import (
+	"github.com/aybabtme/goblog/auth"
+	"github.com/aybabtme/goblog/ctlr"
+	"github.com/aybabtme/goblog/model"
+	"net/http"
+	"strings"
+)
+
+type label struct {
+	path string
+}
+
+func NewLabelListController() ctlr.Controller {
+	return label{Prefix + "/labels"}
+}
+
+func NewLabelController() ctlr.Controller {
+	return label{Prefix + "/labels/{id:[0-9]+}"}
+}
+
+func NewLabelPostListController() ctlr.Controller {
+	return label{Prefix + "/labels/{id:[0-9]+}/posts"}
+}
+
+func NewPostLabelListController() ctlr.Controller {
+	return label{Prefix + "/posts/{postId:[0-9]+}/labels"}
+}
+
+func (l label) Path() string {
+	return l.path
+}
+
+func (l label) Scope() string {
+	return "labels"
+}
+
+func (l label) Middlewares() []ctlr.Middleware {
+	return nil
+}
+
+func (l label) Controller(conn *model.DBConnection) func(http.ResponseWriter, *http.Request) {
+	return handle(func(rw http.ResponseWriter, req *http.Request) error {
+		switch l.path {
+		case Prefix + "/labels":
+			if req.Method != "GET" && req.Method != "HEAD" {
+				return ctlr.MethodNotAllowed(rw, "GET")
+			}
+			return l.forListing(conn, rw, req)
+
+		case Prefix + "/posts/{postId:[0-9]+}/labels":
+			postId, err := pathId(req, "postId", "a post")
+			if err != nil {
+				return err
+			}
+			post, err := conn.FindPostById(postId)
+			if err != nil {
+				return ctlr.NotFoundOr("There's no such post", err)
+			}
+			switch req.Method {
+			case "GET", "HEAD":
+				return l.forPost(rw, req, post)
+			case "POST":
+				return l.forTag(rw, req, post)
+			}
+			return ctlr.MethodNotAllowed(rw, "GET, POST")
+		}
+
+		id, err := pathId(req, "id", "a label")
+		if err != nil {
+			return err
+		}
+		label, err := conn.FindLabelById(id)
+		if err != nil {
+			return ctlr.NotFoundOr("There's no such label", err)
+		}
+
+		if l.path == Prefix+"/labels/{id:[0-9]+}/posts" {
+			if req.Method != "GET" && req.Method != "HEAD" {
+				return ctlr.MethodNotAllowed(rw, "GET")
+			}
+			return l.forPosts(rw, req, label)
+		}
+
+		switch req.Method {
+		case "GET", "HEAD":
+			return l.forId(rw, req, label)
+		case "PUT", "PATCH":
+			return l.forRename(rw, req, label)
+		case "DELETE":
+			return l.forDestroy(rw, req, label)
+		}
+		return ctlr.MethodNotAllowed(rw, "GET, PUT, PATCH, DELETE")
+	})
+}
+
+func (l label) forListing(conn *model.DBConnection,
+	rw http.ResponseWriter,
+	req *http.Request) error {
+
+	if !auth.Can(ctlr.CurrentUser(req), auth.Read, (*model.Label)(nil)) {
+		return ctlr.Forbidden("You can't read labels")
+	}
+
+	return writeLabels(rw, req, conn.CountLabels, conn.FindLabelsPage)
+}
+
+func (l label) forId(rw http.ResponseWriter, req *http.Request, label *model.Label) error {
+	if !auth.Can(ctlr.CurrentUser(req), auth.Read, label) {
+		return ctlr.Forbidden("You can't read this label")
+	}
+	writeJSON(rw, http.StatusOK, single{newLabelJSON(label)})
+	return nil
+}
+
+func (l label) forPosts(rw http.ResponseWriter, req *http.Request, label *model.Label) error {
+	if !auth.Can(ctlr.CurrentUser(req), auth.Read, label) {
+		return ctlr.Forbidden("You can't read this label")
+	}
+
+	return writePosts(rw, req, label.PostCount, label.PostsPage)
+}
+
+func (l label) forPost(rw http.ResponseWriter, req *http.Request, post *model.Post) error {
+	if !auth.Can(ctlr.CurrentUser(req), auth.Read, post) {
+		return ctlr.Forbidden("You can't read this post")
+	}
+
+	labels, err := post.Labels()
+	if err != nil {
+		return ctlr.InternalError(err)
+	}
+	// a post has a few labels, paged as they are
+	return writeLabels(rw, req,
+		func() (int, error) { return len(labels), nil },
+		func(limit, offset int) ([]model.Label, error) { return labels[offset : offset+limit], nil })
+}
+
+// Labels are created by tagging posts with them.
+func (l label) forTag(rw http.ResponseWriter, req *http.Request, post *model.Post) error {
+	currentUser := ctlr.CurrentUser(req)
+	if !auth.Can(currentUser, auth.Update, post) ||
+		!auth.Can(currentUser, auth.Create, (*model.Label)(nil)) {
+		return ctlr.Forbidden("You can't label this post")
+	}
+
+	var input labelInput
+	if err := decode(req, &input); err != nil {
+		return err
+	}
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return ctlr.BadRequest("A label needs a name", nil)
+	}
+
+	label, err := post.AddLabel(name)
+	if err != nil {
+		return ctlr.InternalError(err)
+	}
+
+	rw.Header().Set("Location", idPath("labels", label.Id()))
+	writeJSON(rw, http.StatusCreated, single{newLabelJSON(&label)})
+	return nil
+}
+
+func (l label) forRename(rw http.ResponseWriter, req *http.Request, label *model.Label) error {
+	if !auth.Can(ctlr.CurrentUser(req), auth.Update, label) {
+		return ctlr.Forbidden("You can't rename this label")
+	}
+
+	var input labelInput
+	if err := decode(req, &input); err != nil {
+		return err
+	}
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return ctlr.BadRequest("A label needs a name", nil)
+	}
+
+	label.SetName(name)
+	if err := label.Save(); err != nil {
+		return ctlr.InternalError(err)
+	}
+	writeJSON(rw, http.StatusOK, single{newLabelJSON(label)})
+	return nil
+}
+
+func (l label) forDestroy(rw http.ResponseWriter, req *http.Request, label *model.Label) error {
+	if !auth.Can(ctlr.CurrentUser(req), auth.Delete, label) {
+		return ctlr.Forbidden("You can't delete this label")
+	}
+
+	if err := label.Destroy(); err != nil {
+		return ctlr.InternalError(err)
+	}
+	rw.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+/*
+ * JSON
+ */
+
+type labelJSON struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func newLabelJSON(l *model.Label) labelJSON {
+	return labelJSON{Id: l.Id(), Name: l.Name()}
+}
+
+// Answers a page of labels, of the total count tells, found by find.
+func writeLabels(rw http.ResponseWriter,
+	req *http.Request,
+	count func() (int, error),
+	find func(limit, offset int) ([]model.Label, error)) error {
+
+	page, err := parsePagination(req)
+	if err != nil {
+		return err
+	}
+	total, err := count()
+	if err != nil {
+		return ctlr.InternalError(err)
+	}
+	var labels []model.Label
+	if limit, offset := page.window(total); limit > 0 {
+		if labels, err = find(limit, offset); err != nil {
+			return ctlr.InternalError(err)
+		}
+	}
+
+	data := []labelJSON{}
+	for i := range labels {
+		data = append(data, newLabelJSON(&labels[i]))
+	}
+	writeJSON(rw, http.StatusOK, list{data, page})
+	return nil
+}
+
+// What clients send to tag a post, or to rename a label.
+type labelInput struct {
+	Name string `json:"name"`
+}
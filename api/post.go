@@ -0,0 +1,273 @@
+package api
+
+import (
+	"github.com/aybabtme/goblog/auth"
+	"github.com/aybabtme/goblog/ctlr"
+	"github.com/aybabtme/goblog/model"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type post struct {
+	path string
+}
+
+func NewPostListController() ctlr.Controller {
+	return post{Prefix + "/posts"}
+}
+
+func NewPostController() ctlr.Controller {
+	return post{Prefix + "/posts/{id:[0-9]+}"}
+}
+
+func (p post) Path() string {
+	return p.path
+}
+
+func (p post) Scope() string {
+	return "posts"
+}
+
+func (p post) Middlewares() []ctlr.Middleware {
+	return nil
+}
+
+func (p post) Controller(conn *model.DBConnection) func(http.ResponseWriter, *http.Request) {
+	return handle(func(rw http.ResponseWriter, req *http.Request) error {
+		if p.path == Prefix+"/posts" {
+			switch req.Method {
+			case "GET", "HEAD":
+				return p.forListing(conn, rw, req)
+			case "POST":
+				return p.forCreate(conn, rw, req)
+			}
+			return ctlr.MethodNotAllowed(rw, "GET, POST")
+		}
+
+		id, err := pathId(req, "id", "a post")
+		if err != nil {
+			return err
+		}
+		post, err := conn.FindPostById(id)
+		if err != nil {
+			return ctlr.NotFoundOr("There's no such post", err)
+		}
+
+		switch req.Method {
+		case "GET", "HEAD":
+			return p.forId(rw, req, post)
+		case "PUT", "PATCH":
+			return p.forUpdate(rw, req, post)
+		case "DELETE":
+			return p.forDestroy(rw, req, post)
+		}
+		return ctlr.MethodNotAllowed(rw, "GET, PUT, PATCH, DELETE")
+	})
+}
+
+func (p post) forListing(conn *model.DBConnection,
+	rw http.ResponseWriter,
+	req *http.Request) error {
+
+	if !auth.Can(ctlr.CurrentUser(req), auth.Read, (*model.Post)(nil)) {
+		return ctlr.Forbidden("You can't read posts")
+	}
+
+	return writePosts(rw, req, conn.CountPosts, conn.FindPostsPage)
+}
+
+func (p post) forId(rw http.ResponseWriter, req *http.Request, post *model.Post) error {
+	if !auth.Can(ctlr.CurrentUser(req), auth.Read, post) {
+		return ctlr.Forbidden("You can't read this post")
+	}
+
+	data, err := newPostJSON(post)
+	if err != nil {
+		return ctlr.InternalError(err)
+	}
+	writeJSON(rw, http.StatusOK, single{data})
+	return nil
+}
+
+func (p post) forCreate(conn *model.DBConnection,
+	rw http.ResponseWriter,
+	req *http.Request) error {
+
+	currentUser, currentAuthor := ctlr.CurrentUser(req), ctlr.CurrentAuthor(req)
+	if currentAuthor == nil || !auth.Can(currentUser, auth.Create, (*model.Post)(nil)) {
+		return ctlr.Forbidden("You can't write posts")
+	}
+
+	var input postInput
+	if err := decode(req, &input); err != nil {
+		return err
+	}
+	if input.Title == nil || strings.TrimSpace(*input.Title) == "" {
+		return ctlr.BadRequest("A post needs a title", nil)
+	}
+
+	post := conn.NewPost(currentAuthor, "", "", "", time.Now().UTC())
+	input.applyTo(post)
+	if err := post.Save(); err != nil {
+		return ctlr.InternalError(err)
+	}
+	addLabels(currentUser, post, input.Labels)
+
+	data, err := newPostJSON(post)
+	if err != nil {
+		return ctlr.InternalError(err)
+	}
+	rw.Header().Set("Location", idPath("posts", post.Id()))
+	writeJSON(rw, http.StatusCreated, single{data})
+	return nil
+}
+
+func (p post) forUpdate(rw http.ResponseWriter, req *http.Request, post *model.Post) error {
+	currentUser := ctlr.CurrentUser(req)
+	if !auth.Can(currentUser, auth.Update, post) {
+		return ctlr.Forbidden("You can't edit this post")
+	}
+
+	var input postInput
+	if err := decode(req, &input); err != nil {
+		return err
+	}
+	if input.Title != nil && strings.TrimSpace(*input.Title) == "" {
+		return ctlr.BadRequest("A post needs a title", nil)
+	}
+
+	input.applyTo(post)
+	if err := post.Update(); err != nil {
+		return ctlr.InternalError(err)
+	}
+	addLabels(currentUser, post, input.Labels)
+
+	data, err := newPostJSON(post)
+	if err != nil {
+		return ctlr.InternalError(err)
+	}
+	writeJSON(rw, http.StatusOK, single{data})
+	return nil
+}
+
+func (p post) forDestroy(rw http.ResponseWriter, req *http.Request, post *model.Post) error {
+	if !auth.Can(ctlr.CurrentUser(req), auth.Delete, post) {
+		return ctlr.Forbidden("You can't delete this post")
+	}
+
+	if err := post.Destroy(); err != nil {
+		return ctlr.InternalError(err)
+	}
+	rw.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+/*
+ * JSON
+ */
+
+type postJSON struct {
+	Id          int64     `json:"id"`
+	AuthorId    int64     `json:"author_id"`
+	Title       string    `json:"title"`
+	Content     string    `json:"content"`
+	ContentHTML string    `json:"content_html"`
+	ImageURL    string    `json:"image_url"`
+	Date        time.Time `json:"date"`
+	Updated     time.Time `json:"updated"`
+	Labels      []string  `json:"labels"`
+}
+
+func newPostJSON(p *model.Post) (postJSON, error) {
+	data := postJSON{
+		Id:          p.Id(),
+		Title:       p.Title(),
+		Content:     p.Content(),
+		ContentHTML: string(p.ContentMarkdown()),
+		ImageURL:    p.ImageURL(),
+		Date:        p.Date(),
+		Updated:     p.Updated(),
+		Labels:      []string{},
+	}
+	if p.Author() != nil {
+		data.AuthorId = p.Author().Id()
+	}
+
+	labels, err := p.Labels()
+	if err != nil {
+		return data, err
+	}
+	for _, label := range labels {
+		data.Labels = append(data.Labels, label.Name())
+	}
+	return data, nil
+}
+
+// Answers a page of posts, of the total count tells, found by find.
+func writePosts(rw http.ResponseWriter,
+	req *http.Request,
+	count func() (int, error),
+	find func(limit, offset int) ([]model.Post, error)) error {
+
+	page, err := parsePagination(req)
+	if err != nil {
+		return err
+	}
+	total, err := count()
+	if err != nil {
+		return ctlr.InternalError(err)
+	}
+	var posts []model.Post
+	if limit, offset := page.window(total); limit > 0 {
+		if posts, err = find(limit, offset); err != nil {
+			return ctlr.InternalError(err)
+		}
+	}
+
+	data := []postJSON{}
+	for i := range posts {
+		post, err := newPostJSON(&posts[i])
+		if err != nil {
+			return ctlr.InternalError(err)
+		}
+		data = append(data, post)
+	}
+	writeJSON(rw, http.StatusOK, list{data, page})
+	return nil
+}
+
+// What clients send to create or update a post.  Fields left out are left
+// unchanged.
+type postInput struct {
+	Title    *string  `json:"title"`
+	Content  *string  `json:"content"`
+	ImageURL *string  `json:"image_url"`
+	Labels   []string `json:"labels"`
+}
+
+func (in postInput) applyTo(post *model.Post) {
+	if in.Title != nil {
+		post.SetTitle(strings.Title(*in.Title))
+	}
+	if in.Content != nil {
+		post.SetContent(*in.Content)
+	}
+	if in.ImageURL != nil {
+		post.SetImageURL(*in.ImageURL)
+	}
+}
+
+// Tags the post with the labels, if the user may create labels.
+func addLabels(user *model.User, post *model.Post, labels []string) {
+	if len(labels) == 0 || !auth.Can(user, auth.Create, (*model.Label)(nil)) {
+		return
+	}
+	for _, label := range labels {
+		if _, err := post.AddLabel(label); err != nil {
+			log.Printf("Couldn't add label <%s> to Post id<%d>\n", label, post.Id())
+			log.Println(err)
+		}
+	}
+}
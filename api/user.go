@@ -0,0 +1,133 @@
+package api
+
+import (
+	"github.com/aybabtme/goblog/auth"
+	"github.com/aybabtme/goblog/ctlr"
+	"github.com/aybabtme/goblog/model"
+	"net/http"
+	"time"
+)
+
+type user struct {
+	path string
+}
+
+func NewUserListController() ctlr.Controller {
+	return user{Prefix + "/users"}
+}
+
+func NewUserController() ctlr.Controller {
+	return user{Prefix + "/users/{id:[0-9]+}"}
+}
+
+// Answers the user logged in, along with the CSRF token their changes must
+// carry.
+func NewCurrentUserController() ctlr.Controller {
+	return user{Prefix + "/user"}
+}
+
+func (u user) Path() string {
+	return u.path
+}
+
+func (u user) Scope() string {
+	return "users"
+}
+
+func (u user) Middlewares() []ctlr.Middleware {
+	switch u.path {
+	case Prefix + "/users":
+		// the list shows emails, only admins manage users
+		return []ctlr.Middleware{ctlr.Require(auth.Update, (*model.User)(nil), "Only admins can list users")}
+	case Prefix + "/user":
+		return []ctlr.Middleware{ctlr.RequireLogin("Log in first")}
+	}
+	return nil
+}
+
+func (u user) Controller(conn *model.DBConnection) func(http.ResponseWriter, *http.Request) {
+	return handle(func(rw http.ResponseWriter, req *http.Request) error {
+		if req.Method != "GET" && req.Method != "HEAD" {
+			return ctlr.MethodNotAllowed(rw, "GET")
+		}
+
+		currentUser := ctlr.CurrentUser(req)
+		switch u.path {
+		case Prefix + "/users":
+			return u.forListing(conn, rw, req)
+		case Prefix + "/user":
+			rw.Header().Set(auth.CSRFHeader, auth.CSRFToken(rw, req))
+			writeJSON(rw, http.StatusOK, single{newUserJSON(currentUser, currentUser)})
+			return nil
+		}
+
+		id, err := pathId(req, "id", "a user")
+		if err != nil {
+			return err
+		}
+		user, err := conn.FindUserById(id)
+		if err != nil {
+			return ctlr.NotFoundOr("There's no such user", err)
+		}
+		if !auth.Can(currentUser, auth.Read, user) {
+			return ctlr.Forbidden("You can't see this user")
+		}
+		writeJSON(rw, http.StatusOK, single{newUserJSON(currentUser, user)})
+		return nil
+	})
+}
+
+func (u user) forListing(conn *model.DBConnection,
+	rw http.ResponseWriter,
+	req *http.Request) error {
+
+	page, err := parsePagination(req)
+	if err != nil {
+		return err
+	}
+	total, err := conn.CountUsers()
+	if err != nil {
+		return ctlr.InternalError(err)
+	}
+	var users []model.User
+	if limit, offset := page.window(total); limit > 0 {
+		if users, err = conn.FindUsersPage(limit, offset); err != nil {
+			return ctlr.InternalError(err)
+		}
+	}
+
+	currentUser := ctlr.CurrentUser(req)
+	data := []userJSON{}
+	for i := range users {
+		data = append(data, newUserJSON(currentUser, &users[i]))
+	}
+	writeJSON(rw, http.StatusOK, list{data, page})
+	return nil
+}
+
+/*
+ * JSON
+ */
+
+type userJSON struct {
+	Id               int64     `json:"id"`
+	Username         string    `json:"username"`
+	RegistrationDate time.Time `json:"registration_date"`
+	// Only shown to the user and to admins
+	Email string     `json:"email,omitempty"`
+	Role  model.Role `json:"role,omitempty"`
+}
+
+// The user as seen by viewer, who may be nil.
+func newUserJSON(viewer, u *model.User) userJSON {
+	data := userJSON{
+		Id:               u.Id(),
+		Username:         u.Username(),
+		RegistrationDate: u.RegistrationDate(),
+	}
+	if viewer != nil && auth.Can(viewer, auth.Update, u) {
+		data.Email = u.Email()
+		data.Role, _ = u.Role()
+	}
+	return data
+}
@@ -0,0 +1,249 @@
+// Package archive writes a whole blog in a JSON archive and reads it back,
+// to back a blog up or to move it to another database.
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aybabtme/goblog/model"
+	"io"
+	"time"
+)
+
+// Format names the kind of file an archive is.
+const Format = "goblog-archive"
+
+// Version is that of the archives written, bumped when their fields change
+// so that older blogs refuse archives they don't understand.  Version 2
+// added the replies and approval of comments, version 3 when posts were
+// last changed, and version 4 their slugs.
+const Version = 4
+
+// What's in each section of an archive, for the people and tools reading
+// it without goblog.  Ids are those of the exported blog; they're only
+// used to tie the sections together.
+var sections = map[string]string{
+	"users":       "Everyone who logged in: username, registration date, timezone offset in hours, OAuth id, email, role and ban. OAuth tokens aren't kept.",
+	"authors":     "Users who write posts, user_id being an id of users.",
+	"posts":       "Posts in Markdown, author_id being an id of authors, updated when they were last changed, slug the name of their file, if any.",
+	"labels":      "Labels by name.",
+	"post_labels": "Which post (an id of posts) has which label (an id of labels).",
+	"comments":    "Comments in Markdown with their votes, user_id and post_id being ids of users and posts, parent_id the id of the comment they reply to, if any. Those not approved wait for moderation.",
+}
+
+// Sections are the names of the sections of an archive and what's in them.
+func Sections() map[string]string {
+	return sections
+}
+
+// Archive is a whole blog.
+type Archive struct {
+	Format     string            `json:"format"`
+	Version    int               `json:"version"`
+	Exported   time.Time         `json:"exported"`
+	Sections   map[string]string `json:"sections"`
+	Users      []User            `json:"users"`
+	Authors    []Author          `json:"authors"`
+	Posts      []Post            `json:"posts"`
+	Labels     []Label           `json:"labels"`
+	PostLabels []PostLabel       `json:"post_labels"`
+	Comments   []Comment         `json:"comments"`
+}
+
+type User struct {
+	Id           int64     `json:"id"`
+	Username     string    `json:"username"`
+	Registration time.Time `json:"registration_date"`
+	Timezone     int       `json:"timezone"`
+	OauthId      string    `json:"oauth_id"`
+	Email        string    `json:"email"`
+	Role         string    `json:"role"`
+	// why the user was banned, if it was
+	Ban string `json:"ban,omitempty"`
+}
+
+type Author struct {
+	Id     int64 `json:"id"`
+	UserId int64 `json:"user_id"`
+}
+
+type Post struct {
+	Id       int64     `json:"id"`
+	AuthorId int64     `json:"author_id"`
+	Title    string    `json:"title"`
+	Content  string    `json:"content"`
+	ImageURL string    `json:"image_url"`
+	Date     time.Time `json:"date"`
+	Updated  time.Time `json:"updated"`
+	// the name of the post outside of the blog, like its file
+	Slug string `json:"slug,omitempty"`
+}
+
+type Label struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type PostLabel struct {
+	PostId  int64 `json:"post_id"`
+	LabelId int64 `json:"label_id"`
+}
+
+type Comment struct {
+	Id       int64     `json:"id"`
+	UserId   int64     `json:"user_id"`
+	PostId   int64     `json:"post_id"`
+	Content  string    `json:"content"`
+	Date     time.Time `json:"date"`
+	UpVote   int64     `json:"up_vote"`
+	DownVote int64     `json:"down_vote"`
+	ParentId int64     `json:"parent_id,omitempty"`
+	Approved bool      `json:"approved"`
+}
+
+// Export reads the whole blog from conn.
+func Export(conn *model.DBConnection) (*Archive, error) {
+	a := &Archive{
+		Format:   Format,
+		Version:  Version,
+		Exported: time.Now().UTC(),
+		Sections: sections,
+	}
+
+	users, err := conn.FindAllUsers()
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		u := &users[i]
+		role, err := u.Role()
+		if err != nil {
+			return nil, err
+		}
+		banned, reason, err := u.Banned()
+		if err != nil {
+			return nil, err
+		}
+		user := User{
+			Id:           u.Id(),
+			Username:     u.Username(),
+			Registration: u.RegistrationDate(),
+			Timezone:     u.Timezone(),
+			OauthId:      u.OauthId(),
+			Email:        u.Email(),
+			Role:         string(role),
+		}
+		if banned {
+			user.Ban = reason
+			// there's always a reason, even when none was given
+			if user.Ban == "" {
+				user.Ban = "banned"
+			}
+		}
+		a.Users = append(a.Users, user)
+	}
+
+	authors, err := conn.FindAllAuthors()
+	if err != nil {
+		return nil, err
+	}
+	for _, author := range authors {
+		a.Authors = append(a.Authors, Author{author.Id(), author.User().Id()})
+	}
+
+	labels, err := conn.FindAllLabels()
+	if err != nil {
+		return nil, err
+	}
+	for _, label := range labels {
+		a.Labels = append(a.Labels, Label{label.Id(), label.Name()})
+	}
+
+	posts, err := conn.FindAllPosts()
+	if err != nil {
+		return nil, err
+	}
+	slugs, err := conn.PostSlugs()
+	if err != nil {
+		return nil, err
+	}
+	for i := range posts {
+		post := &posts[i]
+		var authorId int64
+		if post.Author() != nil {
+			authorId = post.Author().Id()
+		}
+		a.Posts = append(a.Posts, Post{
+			Id:       post.Id(),
+			AuthorId: authorId,
+			Title:    post.Title(),
+			Content:  post.Content(),
+			ImageURL: post.ImageURL(),
+			Date:     post.Date(),
+			Updated:  post.Updated(),
+			Slug:     slugs[post.Id()],
+		})
+		postLabels, err := post.Labels()
+		if err != nil {
+			return nil, err
+		}
+		for _, label := range postLabels {
+			a.PostLabels = append(a.PostLabels, PostLabel{post.Id(), label.Id()})
+		}
+	}
+
+	comments, err := conn.FindAllComments()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range comments {
+		a.Comments = append(a.Comments, Comment{
+			Id:       c.Id(),
+			UserId:   c.UserId(),
+			PostId:   c.PostId(),
+			Content:  c.Content(),
+			Date:     c.Date(),
+			UpVote:   c.UpVote(),
+			DownVote: c.DownVote(),
+			ParentId: c.ParentId(),
+			Approved: c.Approved(),
+		})
+	}
+	return a, nil
+}
+
+// Write writes the archive as indented JSON.
+func (a *Archive) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(a)
+}
+
+// Read reads an archive, refusing files that aren't archives or that are
+// of a version this blog doesn't understand.
+func Read(r io.Reader) (*Archive, error) {
+	var a Archive
+	if err := json.NewDecoder(r).Decode(&a); err != nil {
+		return nil, fmt.Errorf("archive: not JSON: %v", err)
+	}
+	if a.Format != Format {
+		return nil, fmt.Errorf("archive: not a goblog archive, format is %q", a.Format)
+	}
+	if a.Version < 1 || a.Version > Version {
+		return nil, fmt.Errorf("archive: version %d isn't supported, only up to %d", a.Version, Version)
+	}
+	if a.Version < 2 {
+		// comments were all shown
+		for i := range a.Comments {
+			a.Comments[i].Approved = true
+		}
+	}
+	if a.Version < 3 {
+		// posts were last changed when they were written, as far as the
+		// archive tells
+		for i := range a.Posts {
+			a.Posts[i].Updated = a.Posts[i].Date
+		}
+	}
+	return &a, nil
+}
@@ -0,0 +1,207 @@
+package archive
+
+import (
+	"bytes"
+	"github.com/aybabtme/goblog/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadRefusesOtherFiles(t *testing.T) {
+	cases := []struct {
+		content string
+		err     string
+	}{
+		{`not json`, "not JSON"},
+		{`{"format": "wordpress", "version": 1}`, "not a goblog archive"},
+		{`{"format": "goblog-archive", "version": 5}`, "version 5"},
+		{`{"format": "goblog-archive"}`, "version 0"},
+	}
+	for _, c := range cases {
+		_, err := Read(strings.NewReader(c.content))
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("Expected an error about %q, was %v", c.err, err)
+		}
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	date := time.Date(2014, 3, 2, 10, 0, 0, 0, time.UTC)
+	a := &Archive{
+		Format:     Format,
+		Version:    Version,
+		Exported:   date,
+		Sections:   Sections(),
+		Users:      []User{{Id: 4, Username: "antoine", Registration: date, OauthId: "g+1", Email: "a@b.com", Role: "author"}},
+		Authors:    []Author{{Id: 2, UserId: 4}},
+		Posts:      []Post{{Id: 7, AuthorId: 2, Title: "Title", Content: "**Hi**", Date: date, Updated: date.Add(time.Hour), Slug: "title"}},
+		Labels:     []Label{{Id: 3, Name: "go"}},
+		PostLabels: []PostLabel{{PostId: 7, LabelId: 3}},
+		Comments: []Comment{
+			{Id: 1, UserId: 4, PostId: 7, Content: "Nice", Date: date, UpVote: 2, Approved: true},
+			{Id: 2, UserId: 4, PostId: 7, Content: "Indeed", Date: date, ParentId: 1},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := a.Write(&buf); err != nil {
+		t.Fatal("Couldn't write archive", err)
+	}
+	for _, section := range []string{`"users"`, `"post_labels"`, `"sections"`, `"up_vote": 2`} {
+		if !strings.Contains(buf.String(), section) {
+			t.Errorf("Expected %s in the archive", section)
+		}
+	}
+
+	read, err := Read(&buf)
+	if err != nil {
+		t.Fatal("Couldn't read archive back", err)
+	}
+	if read.Users[0] != a.Users[0] || read.Posts[0] != a.Posts[0] || read.Comments[0] != a.Comments[0] ||
+		read.Comments[1] != a.Comments[1] || read.PostLabels[0] != a.PostLabels[0] {
+		t.Errorf("Expected the same archive, was %+v", read)
+	}
+}
+
+func TestReadVersion1(t *testing.T) {
+	a, err := Read(strings.NewReader(`{"format": "goblog-archive", "version": 1, "comments": [{"id": 1, "content": "Nice"}]}`))
+	if err != nil {
+		t.Fatal("Couldn't read archive", err)
+	}
+	if !a.Comments[0].Approved {
+		t.Errorf("Expected the comments of older archives to be approved, was %+v", a.Comments[0])
+	}
+}
+
+func TestReadVersion2(t *testing.T) {
+	a, err := Read(strings.NewReader(`{"format": "goblog-archive", "version": 2, "posts": [{"id": 1, "date": "2014-03-02T10:00:00Z"}]}`))
+	if err != nil {
+		t.Fatal("Couldn't read archive", err)
+	}
+	if !a.Posts[0].Updated.Equal(a.Posts[0].Date) {
+		t.Errorf("Expected the posts of older archives updated at their date, was %+v", a.Posts[0])
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	exportImport(t, setupPGConnection())
+}
+
+func exportImport(t *testing.T, conn *model.DBConnection) {
+	defer func() { conn.DeleteConnection() }()
+
+	date := time.Date(2014, 3, 2, 10, 0, 0, 0, time.UTC)
+	user := conn.NewUser("writer", date, -5, "g+writer", "access", "refresh", "writer@example.com")
+	author := conn.NewAuthor(user)
+	if err := author.Save(); err != nil {
+		t.Fatal("Couldn't save author", err)
+	}
+	troll := conn.NewUser("troll", date, 0, "g+troll", "", "", "troll@example.com")
+	if err := troll.Save(); err != nil {
+		t.Fatal("Couldn't save user", err)
+	}
+	troll.Ban("spam", date)
+	post := conn.NewPost(author, "Title", "Content", "", date)
+	if err := post.Save(); err != nil {
+		t.Fatal("Couldn't save post", err)
+	}
+	post.AddLabel("go")
+	if err := post.SetSlug("title"); err != nil {
+		t.Fatal("Couldn't set slug", err)
+	}
+	post.SetContent("Edited")
+	if err := post.Update(); err != nil {
+		t.Fatal("Couldn't update post", err)
+	}
+	comment := conn.NewComment(troll.Id(), post.Id(), "First", date.Add(time.Hour))
+	comment.SetUpVote(3)
+	if err := comment.Save(); err != nil {
+		t.Fatal("Couldn't save comment", err)
+	}
+	question := conn.NewComment(user.Id(), post.Id(), "Anyone?", date.Add(2*time.Hour))
+	question.SetApproved(false)
+	if err := question.Save(); err != nil {
+		t.Fatal("Couldn't save comment", err)
+	}
+	answer := conn.NewComment(user.Id(), post.Id(), "Me", date.Add(3*time.Hour))
+	answer.SetParentId(question.Id())
+	if err := answer.Save(); err != nil {
+		t.Fatal("Couldn't save reply", err)
+	}
+
+	a, err := Export(conn)
+	if err != nil {
+		t.Fatal("Couldn't export", err)
+	}
+	if len(a.Users) != 2 || len(a.Authors) != 1 || len(a.Posts) != 1 || len(a.PostLabels) != 1 || len(a.Comments) != 3 {
+		t.Fatalf("Expected the whole blog, was %+v", a)
+	}
+
+	// everything's there already
+	report, err := Import(conn, a, false)
+	if err != nil {
+		t.Fatal("Couldn't import", err)
+	}
+	if len(report.Created) != 0 || report.Existing["users"] != 2 || report.Existing["posts"] != 1 ||
+		report.Existing["comments"] != 3 {
+		t.Errorf("Expected nothing to be created, was %+v", report)
+	}
+
+	// into an empty database
+	conn.DeleteConnection()
+	conn = setupPGConnection()
+	// a user took the username of the troll
+	conn.NewUser("troll", date, 0, "g+other", "", "", "other@example.com").Save()
+
+	report, err = Import(conn, a, true)
+	if err != nil {
+		t.Fatal("Couldn't dry run import", err)
+	}
+	if report.Created["users"] != 1 || report.Created["posts"] != 1 || report.Created["labels"] != 1 {
+		t.Errorf("Expected the dry run to tell what's created, was %+v", report)
+	}
+	if posts, _ := conn.FindAllPosts(); len(posts) != 0 {
+		t.Error("Expected a dry run not to write anything")
+	}
+
+	report, err = Import(conn, a, false)
+	if err != nil {
+		t.Fatal("Couldn't import", err)
+	}
+	// the troll and its comment are conflicts
+	if len(report.Conflicts) != 2 || !strings.Contains(report.Conflicts[0], `username "troll" is taken`) {
+		t.Errorf("Expected the troll to conflict, was %v", report.Conflicts)
+	}
+	posts, _ := conn.FindAllPosts()
+	if len(posts) != 1 || posts[0].Title() != "Title" || !posts[0].Date().Equal(date) {
+		t.Fatalf("Expected the post to be imported, was %+v", posts)
+	}
+	if slug, err := posts[0].Slug(); err != nil || slug != "title" {
+		t.Errorf("Expected the post to keep its slug, was %q, %v", slug, err)
+	}
+	if !posts[0].Updated().Equal(a.Posts[0].Updated) || posts[0].Updated().Equal(date) {
+		t.Errorf("Expected the post updated when it was, <%v>, was <%v>", a.Posts[0].Updated, posts[0].Updated())
+	}
+	if labels, _ := posts[0].Labels(); len(labels) != 1 || labels[0].Name() != "go" {
+		t.Errorf("Expected the label to be imported, was %+v", labels)
+	}
+	imported, err := conn.FindUserByOAuthId("g+writer")
+	if err != nil || imported.Email() != "writer@example.com" {
+		t.Errorf("Expected the writer to be imported, was %v, %v", imported, err)
+	}
+	if role, _ := imported.Role(); role != model.RoleAuthor {
+		t.Errorf("Expected the writer to be an author, was %s", role)
+	}
+	comments, _ := conn.FindAllComments()
+	if len(comments) != 2 || comments[0].Approved() || comments[1].ParentId() != comments[0].Id() ||
+		!comments[1].Approved() {
+		t.Errorf("Expected the pending comment and its reply, was %+v", comments)
+	}
+}
+
+func setupPGConnection() *model.DBConnection {
+	modelurl := "user=antoine dbname=test sslmode=disable"
+	conn, _ := model.NewConnection(model.NewPostgreser(modelurl))
+	return conn
+}
@@ -0,0 +1,353 @@
+package archive
+
+import (
+	"fmt"
+	"github.com/aybabtme/goblog/model"
+	"strconv"
+	"time"
+)
+
+// Report tells what an import did, or would do in a dry run, by section
+// of the archive.
+type Report struct {
+	// rows added to the database
+	Created map[string]int
+	// rows that were there already and were kept as they are
+	Existing map[string]int
+	// rows that couldn't be imported, and why
+	Conflicts []string
+}
+
+func (r *Report) conflict(format string, args ...interface{}) {
+	r.Conflicts = append(r.Conflicts, fmt.Sprintf(format, args...))
+}
+
+// Import restores the archive in conn, next to what's there already.
+//
+// Rows already in the database are kept as they are: users are matched by
+// OAuth id, authors by user, labels by name, posts by author, title and
+// date, and comments by user, post, date and content.  Others get the ids
+// the database gives them, replies being tied to the comments they reply to
+// as they are then.  Rows that can't be imported, like users whose
+// username is taken or the posts of those users, are reported as
+// conflicts.  With dryRun, nothing is written and the report tells what
+// would be.
+func Import(conn *model.DBConnection, a *Archive, dryRun bool) (*Report, error) {
+	im := &importer{
+		conn:   conn,
+		dryRun: dryRun,
+		report: &Report{
+			Created:  make(map[string]int),
+			Existing: make(map[string]int),
+		},
+		users:        make(map[int64]*model.User),
+		authors:      make(map[int64]*model.Author),
+		posts:        make(map[int64]*model.Post),
+		comments:     make(map[int64]*model.Comment),
+		labels:       make(map[int64]string),
+		createdUsers: make(map[int64]bool),
+		createdPosts: make(map[int64]bool),
+		labelsSeen:   make(map[string]bool),
+	}
+	if err := im.loadExisting(); err != nil {
+		return nil, err
+	}
+
+	im.importUsers(a)
+	im.importAuthors(a)
+	im.importRoles(a)
+	im.importPosts(a)
+	im.importLabels(a)
+	im.importComments(a)
+	return im.report, nil
+}
+
+type importer struct {
+	conn   *model.DBConnection
+	dryRun bool
+	report *Report
+
+	// rows of the database by id in the archive
+	users    map[int64]*model.User
+	authors  map[int64]*model.Author
+	posts    map[int64]*model.Post
+	comments map[int64]*model.Comment
+	labels   map[int64]string
+	// users and posts created by the import, the only ones given the
+	// roles and labels of the archive
+	createdUsers map[int64]bool
+	createdPosts map[int64]bool
+	labelsSeen   map[string]bool
+
+	// rows already in the database
+	usersByOAuth  map[string]*model.User
+	usernames     map[string]bool
+	emails        map[string]bool
+	authorsByUser map[int64]*model.Author
+	labelNames    map[string]bool
+	postKeys      map[string]*model.Post
+	commentKeys   map[string]*model.Comment
+}
+
+func (im *importer) loadExisting() error {
+	im.usersByOAuth = make(map[string]*model.User)
+	im.usernames = make(map[string]bool)
+	im.emails = make(map[string]bool)
+	im.authorsByUser = make(map[int64]*model.Author)
+	im.labelNames = make(map[string]bool)
+	im.postKeys = make(map[string]*model.Post)
+	im.commentKeys = make(map[string]*model.Comment)
+
+	users, err := im.conn.FindAllUsers()
+	if err != nil {
+		return err
+	}
+	for i := range users {
+		im.usersByOAuth[users[i].OauthId()] = &users[i]
+		im.usernames[users[i].Username()] = true
+		im.emails[users[i].Email()] = true
+	}
+
+	authors, err := im.conn.FindAllAuthors()
+	if err != nil {
+		return err
+	}
+	for i := range authors {
+		im.authorsByUser[authors[i].User().Id()] = &authors[i]
+	}
+
+	labels, err := im.conn.FindAllLabels()
+	if err != nil {
+		return err
+	}
+	for _, label := range labels {
+		im.labelNames[label.Name()] = true
+	}
+
+	posts, err := im.conn.FindAllPosts()
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		if posts[i].Author() != nil {
+			im.postKeys[postKey(posts[i].Author().Id(), posts[i].Title(), posts[i].Date())] = &posts[i]
+		}
+	}
+
+	comments, err := im.conn.FindAllComments()
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		c := &comments[i]
+		im.commentKeys[commentKey(c.UserId(), c.PostId(), c.Date(), c.Content())] = c
+	}
+	return nil
+}
+
+func (im *importer) importUsers(a *Archive) {
+	for _, u := range a.Users {
+		if existing, ok := im.usersByOAuth[u.OauthId]; ok {
+			im.users[u.Id] = existing
+			im.report.Existing["users"]++
+			continue
+		}
+		if im.usernames[u.Username] {
+			im.report.conflict("user %d: username %q is taken by another user", u.Id, u.Username)
+			continue
+		}
+		if im.emails[u.Email] {
+			im.report.conflict("user %d: email %q is taken by another user", u.Id, u.Email)
+			continue
+		}
+
+		user := im.conn.NewUser(u.Username, u.Registration, u.Timezone, u.OauthId, "", "", u.Email)
+		if !im.dryRun {
+			if err := user.Save(); err != nil {
+				im.report.conflict("user %d: %v", u.Id, err)
+				continue
+			}
+		}
+		im.usersByOAuth[u.OauthId] = user
+		im.usernames[u.Username] = true
+		im.emails[u.Email] = true
+		im.users[u.Id] = user
+		im.createdUsers[u.Id] = true
+		im.report.Created["users"]++
+	}
+}
+
+func (im *importer) importAuthors(a *Archive) {
+	for _, au := range a.Authors {
+		user, ok := im.users[au.UserId]
+		if !ok {
+			im.report.conflict("author %d: its user %d wasn't imported", au.Id, au.UserId)
+			continue
+		}
+		if existing, ok := im.authorsByUser[user.Id()]; ok && !im.createdUsers[au.UserId] {
+			im.authors[au.Id] = existing
+			im.report.Existing["authors"]++
+			continue
+		}
+
+		author := im.conn.NewAuthor(user)
+		if !im.dryRun {
+			if err := author.Save(); err != nil {
+				im.report.conflict("author %d: %v", au.Id, err)
+				continue
+			}
+		}
+		im.authors[au.Id] = author
+		im.report.Created["authors"]++
+	}
+}
+
+// Gives their role and ban to the users that were created, those already
+// there keeping theirs.
+func (im *importer) importRoles(a *Archive) {
+	for _, u := range a.Users {
+		if !im.createdUsers[u.Id] {
+			continue
+		}
+		role := model.Role(u.Role)
+		if !role.Valid() {
+			im.report.conflict("user %d: unknown role %q", u.Id, u.Role)
+			continue
+		}
+		if im.dryRun {
+			continue
+		}
+		user := im.users[u.Id]
+		if current, err := user.Role(); err != nil || current != role {
+			if err := user.SetRole(role); err != nil {
+				im.report.conflict("user %d: couldn't give role %q: %v", u.Id, u.Role, err)
+			}
+		}
+		if u.Ban != "" {
+			if err := user.Ban(u.Ban, a.Exported); err != nil {
+				im.report.conflict("user %d: couldn't ban: %v", u.Id, err)
+			}
+		}
+	}
+}
+
+func (im *importer) importPosts(a *Archive) {
+	for _, p := range a.Posts {
+		author, ok := im.authors[p.AuthorId]
+		if !ok {
+			im.report.conflict("post %d: its author %d wasn't imported", p.Id, p.AuthorId)
+			continue
+		}
+		if existing, ok := im.postKeys[postKey(author.Id(), p.Title, p.Date)]; ok && author.Id() != -1 {
+			im.posts[p.Id] = existing
+			im.report.Existing["posts"]++
+			continue
+		}
+
+		post := im.conn.NewPost(author, p.Title, p.Content, p.ImageURL, p.Date)
+		if !p.Updated.IsZero() {
+			post.SetUpdated(p.Updated)
+		}
+		if !im.dryRun {
+			if err := post.Save(); err != nil {
+				im.report.conflict("post %d: %v", p.Id, err)
+				continue
+			}
+			// its file is found by its slug, its id being another one now
+			if p.Slug != "" {
+				if err := post.SetSlug(p.Slug); err != nil {
+					im.report.conflict("post %d: couldn't give slug %q: %v", p.Id, p.Slug, err)
+				}
+			}
+		}
+		im.posts[p.Id] = post
+		im.createdPosts[p.Id] = true
+		im.report.Created["posts"]++
+	}
+}
+
+// Labels only exist on posts, they're created along with their relations
+// to the posts that were created.
+func (im *importer) importLabels(a *Archive) {
+	for _, l := range a.Labels {
+		im.labels[l.Id] = l.Name
+	}
+	for _, pl := range a.PostLabels {
+		name, ok := im.labels[pl.LabelId]
+		if !ok {
+			im.report.conflict("label %d of post %d: there's no such label", pl.LabelId, pl.PostId)
+			continue
+		}
+		if !im.createdPosts[pl.PostId] {
+			continue
+		}
+
+		if !im.dryRun {
+			if _, err := im.posts[pl.PostId].AddLabel(name); err != nil {
+				im.report.conflict("label %q of post %d: %v", name, pl.PostId, err)
+				continue
+			}
+		}
+		if !im.labelsSeen[name] {
+			im.labelsSeen[name] = true
+			if im.labelNames[name] {
+				im.report.Existing["labels"]++
+			} else {
+				im.report.Created["labels"]++
+			}
+		}
+		im.report.Created["post_labels"]++
+	}
+}
+
+func (im *importer) importComments(a *Archive) {
+	for _, c := range a.Comments {
+		user, ok := im.users[c.UserId]
+		if !ok {
+			im.report.conflict("comment %d: its user %d wasn't imported", c.Id, c.UserId)
+			continue
+		}
+		post, ok := im.posts[c.PostId]
+		if !ok {
+			im.report.conflict("comment %d: its post %d wasn't imported", c.Id, c.PostId)
+			continue
+		}
+		if existing, ok := im.commentKeys[commentKey(user.Id(), post.Id(), c.Date, c.Content)]; ok {
+			im.comments[c.Id] = existing
+			im.report.Existing["comments"]++
+			continue
+		}
+
+		comment := im.conn.NewComment(user.Id(), post.Id(), c.Content, c.Date)
+		comment.SetUpVote(c.UpVote)
+		comment.SetDownVote(c.DownVote)
+		comment.SetApproved(c.Approved)
+		// replies come after what they reply to, archives being in the
+		// order comments were made
+		if c.ParentId != 0 {
+			parent, ok := im.comments[c.ParentId]
+			if !ok {
+				im.report.conflict("comment %d: the comment %d it replies to wasn't imported", c.Id, c.ParentId)
+				continue
+			}
+			comment.SetParentId(parent.Id())
+		}
+		if !im.dryRun {
+			if err := comment.Save(); err != nil {
+				im.report.conflict("comment %d: %v", c.Id, err)
+				continue
+			}
+		}
+		im.comments[c.Id] = comment
+		im.report.Created["comments"]++
+	}
+}
+
+func postKey(authorId int64, title string, date time.Time) string {
+	return strconv.FormatInt(authorId, 10) + "\x00" + title + "\x00" + date.UTC().Format(time.RFC3339Nano)
+}
+
+func commentKey(userId, postId int64, date time.Time, content string) string {
+	return strconv.FormatInt(userId, 10) + "\x00" + strconv.FormatInt(postId, 10) + "\x00" +
+		date.UTC().Format(time.RFC3339Nano) + "\x00" + content
+}
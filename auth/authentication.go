@@ -8,7 +8,11 @@ import (
 	"strconv"
 )
 
-var store = sessions.NewCookieStore([]byte("auth key avec de la mayo"))
+// Fail answers requests auth refuses.  It writes plain text unless the
+// router swaps it for something nicer, like the error pages of ctlr.
+var Fail = func(w http.ResponseWriter, r *http.Request, status int, message string) {
+	http.Error(w, message, status)
+}
 
 func Login(conn *model.DBConnection, w http.ResponseWriter, r *http.Request) (*model.User, *model.Author) {
 	// Get a session. We're ignoring the error resulted from decoding an
@@ -16,6 +20,21 @@ func Login(conn *model.DBConnection, w http.ResponseWriter, r *http.Request) (*m
 	user := getUser(conn, store, r)
 	author := getAuthor(conn, store, r)
 
+	// Users made authors since they logged in are authors right away.
+	if user != nil && author == nil {
+		if found, err := conn.FindAuthorByUserId(user.Id()); err == nil && found != nil {
+			author = found
+			session, _ := store.Get(r, "user-session")
+			session.Values["authorId"] = strconv.FormatInt(author.Id(), 10)
+		}
+	}
+
+	// Only users allowed to write posts act as authors, even if they
+	// were authors once.
+	if author != nil && (user == nil || !Can(user, Create, (*model.Post)(nil))) {
+		author = nil
+	}
+
 	if user != nil {
 		if author != nil {
 			log.Printf("LOGIN: Author id(%d)<%v>",
@@ -28,17 +47,29 @@ func Login(conn *model.DBConnection, w http.ResponseWriter, r *http.Request) (*m
 		}
 	}
 
-	// Save it.
-	sessions.Save(r, w)
+	// Keeps the session of logged in users alive.  Visitors get none until
+	// they need one, so that crawlers and feed readers don't each make one.
+	if user != nil {
+		sessions.Save(r, w)
+	}
 
 	return user, author
 }
 
+// Logs the user out.  Only answers to POST, so that a link or an image on
+// another site can't log users out.
 func Logout(conn *model.DBConnection) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			Fail(w, r, http.StatusMethodNotAllowed, "Logout needs a POST")
+			return
+		}
 		session, _ := store.Get(r, "user-session")
 		session.Values["userId"] = ""
 		session.Values["authorId"] = ""
+		// forget the session altogether, wherever it's kept
+		session.Options.MaxAge = -1
 		sessions.Save(r, w)
 		http.Redirect(w, r, "/", http.StatusFound)
 	}
@@ -63,6 +94,10 @@ func getUser(conn *model.DBConnection,
 		log.Printf("auth.getUser. Couldn't find user with id <%d>", id)
 		return nil
 	}
+	if banned, _, _ := user.Banned(); banned {
+		log.Printf("auth.getUser. User id<%d> is banned", id)
+		return nil
+	}
 	return user
 }
 
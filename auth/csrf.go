@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"io"
+	"log"
+	"net/http"
+)
+
+// Name of the form field, or header, carrying the CSRF token.
+const (
+	CSRFField  = "csrf_token"
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// Returns the CSRF token of the session of this request, creating one if the
+// session doesn't have any yet.  Forms doing changes must send it back in the
+// CSRFField field.  Visitors reading pages without a session get none, as
+// they can't change anything: a session isn't made for each of them.
+func CSRFToken(w http.ResponseWriter, r *http.Request) string {
+	session, _ := store.Get(r, "user-session")
+	if token, ok := session.Values["csrfToken"].(string); ok && token != "" {
+		return token
+	}
+	if session.IsNew && (r.Method == "GET" || r.Method == "HEAD") {
+		return ""
+	}
+
+	buf := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		log.Println("auth.CSRFToken. Couldn't read random bytes:", err)
+		return ""
+	}
+	token := base64.URLEncoding.EncodeToString(buf)
+	session.Values["csrfToken"] = token
+	if err := session.Save(r, w); err != nil {
+		log.Println("auth.CSRFToken. Couldn't save session:", err)
+	}
+	return token
+}
+
+// Tells if the request carries the CSRF token of its session.
+func ValidCSRF(r *http.Request) bool {
+	session, _ := store.Get(r, "user-session")
+	expected, ok := session.Values["csrfToken"].(string)
+	if !ok || expected == "" {
+		return false
+	}
+
+	actual := r.Header.Get(CSRFHeader)
+	if actual == "" {
+		actual = r.FormValue(CSRFField)
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) == 1
+}
+
+// Wraps a handler so that requests that may change something are refused
+// with a 403 unless they carry the CSRF token of their session.
+func CheckCSRF(h func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET", "HEAD", "OPTIONS":
+		default:
+			if !ValidCSRF(r) {
+				log.Printf("CSRF: Refused %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+				Fail(w, r, http.StatusForbidden, "Invalid or missing CSRF token")
+				return
+			}
+		}
+		h(w, r)
+	}
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCheckCSRF(t *testing.T) {
+	called := false
+	handler := CheckCSRF(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	// A GET of a logged in user gets a token and goes through without one
+	req := withSession(t)
+	rec := httptest.NewRecorder()
+	token := CSRFToken(rec, req)
+	if token == "" {
+		t.Fatal("Expected a CSRF token")
+	}
+	cookies := (&http.Response{Header: rec.Header()}).Cookies()
+
+	handler(httptest.NewRecorder(), req)
+	if !called {
+		t.Error("GET requests shouldn't need a CSRF token")
+	}
+
+	cases := []struct {
+		token    string
+		expected int
+	}{
+		{"", http.StatusForbidden},
+		{"not the token", http.StatusForbidden},
+		{token, http.StatusOK},
+	}
+
+	for i, c := range cases {
+		called = false
+		form := url.Values{}
+		if c.token != "" {
+			form.Set(CSRFField, c.token)
+		}
+		req, _ := http.NewRequest("POST", "/post/save", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != c.expected {
+			t.Errorf("Case #%d, expected <%d> but was <%d>", i, c.expected, rec.Code)
+		}
+		if called != (c.expected == http.StatusOK) {
+			t.Errorf("Case #%d, handler called is <%v>", i, called)
+		}
+	}
+}
+
+func TestCSRFTokenIsPerSession(t *testing.T) {
+	first := CSRFToken(httptest.NewRecorder(), withSession(t))
+	second := CSRFToken(httptest.NewRecorder(), withSession(t))
+
+	if first == "" || first == second {
+		t.Error("Two sessions shouldn't share a CSRF token")
+	}
+}
+
+func TestNoSessionForVisitors(t *testing.T) {
+	for _, method := range []string{"GET", "HEAD"} {
+		req, _ := http.NewRequest(method, "/feed.atom", nil)
+		rec := httptest.NewRecorder()
+		if user, _ := Login(nil, rec, req); user != nil {
+			t.Fatal("Expected a visitor")
+		}
+		if token := CSRFToken(rec, req); token != "" {
+			t.Errorf("Expected no CSRF token for a %s of a visitor, was %q", method, token)
+		}
+		if cookie := rec.Header().Get("Set-Cookie"); cookie != "" {
+			t.Errorf("Expected no session for a %s of a visitor, was %q", method, cookie)
+		}
+	}
+}
+
+// A GET with the cookie of a session of a logged in user, but no token yet.
+func withSession(t *testing.T) *http.Request {
+	req, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	session, _ := store.Get(req, "user-session")
+	session.Values["userId"] = "1"
+	if err := session.Save(req, rec); err != nil {
+		t.Fatal("Couldn't save session", err)
+	}
+	return withResponseCookies(rec)
+}
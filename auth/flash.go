@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"github.com/gorilla/sessions"
+	"net/http"
+)
+
+// AddFlash keeps a message to show on the next page the user sees.
+func AddFlash(w http.ResponseWriter, r *http.Request, message string) {
+	session, _ := store.Get(r, "user-session")
+	flashes, _ := session.Values["flashes"].([]string)
+	session.Values["flashes"] = append(flashes, message)
+	sessions.Save(r, w)
+}
+
+// Flashes returns the messages kept for the user, and forgets them.
+func Flashes(w http.ResponseWriter, r *http.Request) []string {
+	session, _ := store.Get(r, "user-session")
+	flashes, _ := session.Values["flashes"].([]string)
+	if len(flashes) == 0 {
+		return nil
+	}
+	delete(session.Values, "flashes")
+	sessions.Save(r, w)
+	return flashes
+}
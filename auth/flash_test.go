@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestFlashesShowOnce(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/post/save", nil)
+	rec := httptest.NewRecorder()
+	AddFlash(rec, req, "Post published")
+	AddFlash(rec, req, "Labels added")
+
+	// The next page shows them
+	next := withResponseCookies(rec)
+	rec = httptest.NewRecorder()
+	flashes := Flashes(rec, next)
+	expected := []string{"Post published", "Labels added"}
+	if !reflect.DeepEqual(flashes, expected) {
+		t.Errorf("Expected <%v> but was <%v>", expected, flashes)
+	}
+
+	// The one after doesn't
+	if flashes := Flashes(httptest.NewRecorder(), withResponseCookies(rec)); len(flashes) != 0 {
+		t.Errorf("Expected no more flashes but was <%v>", flashes)
+	}
+}
+
+// A request with the cookies a browser would keep from rec: when a cookie
+// was set more than once, the last one.
+func withResponseCookies(rec *httptest.ResponseRecorder) *http.Request {
+	last := make(map[string]*http.Cookie)
+	for _, cookie := range (&http.Response{Header: rec.Header()}).Cookies() {
+		last[cookie.Name] = cookie
+	}
+	req, _ := http.NewRequest("GET", "/", nil)
+	for _, cookie := range last {
+		req.AddCookie(cookie)
+	}
+	return req
+}
@@ -1,8 +1,6 @@
 package auth
 
 import (
-	"code.google.com/p/goauth2/oauth"
-	"encoding/json"
 	"fmt"
 	"github.com/aybabtme/goblog/model"
 	"log"
@@ -16,8 +14,8 @@ func InteractiveOauth(conn *model.DBConnection, port string) {
 Welcome to GoBlog!
 
 In order to get started with your blog, we need to first create a user with
-Author access! From this Author account, you will then be able to assign Author
-access to other user accounts.
+Admin access! From this Admin account, you will then be able to assign roles
+to other user accounts.
 
 Let's get started! Please open the following URL in your browser:`)
 
@@ -74,27 +72,25 @@ func interactiveOAuth2Callback(conn *model.DBConnection, lis *net.Listener) func
 			return
 		}
 
-		t := &oauth.Transport{oauth.Config: oauthCfg}
-
-		// Exchange the received code for a token
-		t.Exchange(code)
-
-		//now get user data based on the Transport which has the token
-		resp, _ := t.Client().Get(profileInfoURL)
-		dec := json.NewDecoder(resp.Body)
+		gUser, tok, err := fetchGoogleUser(code)
+		if err != nil {
+			log.Println("Couldn't get OAuth profile:", err)
+			os.Exit(0)
+			return
+		}
 
-		var gUser map[string]interface{}
-		if err := dec.Decode(&gUser); err != nil {
-			log.Println("Couldn't decode json OAuth answer:", err)
+		user, err := loginGoogleUser(conn, gUser, tok)
+		if err != nil {
+			log.Println("Couldn't save user from Google account:", err)
 			os.Exit(0)
 			return
 		}
 
-		log.Println("Creating new user")
-		user := createAuthUser(conn, gUser, t)
-		author := conn.NewAuthor(user)
-		if err := author.Save(); err != nil {
-			log.Println("Coudln't save author from user!")
+		// The first author administers the blog.  Giving the admin role
+		// also makes the user an author.
+		log.Println("Making user an admin")
+		if err := user.SetRole(model.RoleAdmin); err != nil {
+			log.Println("Coudln't make user an admin!", err)
 			os.Exit(0)
 			return
 		}
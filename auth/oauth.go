@@ -3,7 +3,9 @@ package auth
 import (
 	"code.google.com/p/goauth2/oauth"
 	"encoding/json"
+	"errors"
 	"github.com/aybabtme/goblog/model"
+	"github.com/aybabtme/goblog/wxr"
 	"log"
 	"net/http"
 	"strconv"
@@ -23,10 +25,43 @@ var oauthCfg = &oauth.Config{
 	Scope:        "https://www.googleapis.com/auth/userinfo.email profile",
 }
 
-var (
-	code  = ""
-	token = ""
-)
+// The profile of a Google account, as answered by the userinfo endpoint.
+// Id is Google's stable identifier for the account, the one users are
+// keyed on.
+type googleUser struct {
+	Id            string `json:"id"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	VerifiedEmail bool   `json:"verified_email"`
+}
+
+// Exchanges the code for a token and fetches the profile of the account
+// that granted it.  It's a variable so tests can stand in for Google.
+var fetchGoogleUser = func(code string) (*googleUser, *oauth.Token, error) {
+	t := &oauth.Transport{Config: oauthCfg}
+
+	// Exchange the received code for a token
+	tok, err := t.Exchange(code)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	//now get user data based on the Transport which has the token
+	resp, err := t.Client().Get(profileInfoURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	var gUser googleUser
+	if err := json.NewDecoder(resp.Body).Decode(&gUser); err != nil {
+		return nil, nil, err
+	}
+	if gUser.Id == "" {
+		return nil, nil, errors.New("profile answer has no account id")
+	}
+	return &gUser, tok, nil
+}
 
 func AuthorizeOauth(w http.ResponseWriter, r *http.Request) {
 	//Get the Google URL which shows the Authentication page to the user
@@ -45,63 +80,56 @@ func GetHandleOAuth2Callback(conn *model.DBConnection) func(http.ResponseWriter,
 		errResp := r.FormValue("error")
 
 		if "" != errResp {
-			http.Error(w, "Access to account was denied", http.StatusExpectationFailed)
+			Fail(w, r, http.StatusForbidden, "Access to account was denied")
 			return
 		}
 
-		t := &oauth.Transport{oauth.Config: oauthCfg}
-
-		// Exchange the received code for a token
-		t.Exchange(code)
-
-		//now get user data based on the Transport which has the token
-		resp, _ := t.Client().Get(profileInfoURL)
-		dec := json.NewDecoder(resp.Body)
+		gUser, tok, err := fetchGoogleUser(code)
+		if err != nil {
+			log.Println("Couldn't get OAuth profile:", err)
+			Fail(w, r, http.StatusInternalServerError, "Error getting your Google profile.")
+			return
+		}
 
-		var gUser map[string]interface{}
-		if err := dec.Decode(&gUser); err != nil {
-			log.Println("Couldn't decode json OAuth answer:", err)
-			http.Error(w, "Error decoding JSON answer.", http.StatusInternalServerError)
+		if !gUser.VerifiedEmail {
+			Fail(w, r, http.StatusForbidden, "Your Google account needs a verified email")
 			return
 		}
 
-		if val, ok := gUser["verified_email"].(bool); !ok || !val {
-			http.Error(w, "Need verified email", http.StatusNotAcceptable)
+		user, err := loginGoogleUser(conn, gUser, tok)
+		if err != nil {
+			log.Printf("Couldn't login Google account <%s>: %v\n", gUser.Id, err)
+			Fail(w, r, http.StatusInternalServerError, "Couldn't save user")
 			return
 		}
 
-		// If user exists, retrieve it.
-		user := recoverAuthUser(conn, t.ClientId)
-		// Otherwise save create a new one and save it
-		if user == nil {
-			log.Println("Creating new user")
-			user = createAuthUser(conn, gUser, t)
-			if err := user.Save(); err != nil {
-				log.Printf("Couldn't save new user <%v>\n", user)
-				http.Error(w, "Couldn't save user", http.StatusInternalServerError)
-				return
-			}
+		if banned, reason, _ := user.Banned(); banned {
+			log.Printf("LOGIN: Refused banned user id(%d)", user.Id())
+			Fail(w, r, http.StatusForbidden, "Your account is banned: "+reason)
+			return
 		}
 
 		session, _ := store.Get(r, "user-session")
+		renewSession(session)
 		session.Values["userId"] = strconv.FormatInt(user.Id(), 10)
 
-		author, err := conn.FindAuthorById(user.Id())
+		// Always overwrite the author, otherwise the author of a previous
+		// login in this browser would stick to the new user.
+		session.Values["authorId"] = ""
+		author, err := conn.FindAuthorByUserId(user.Id())
 		if author != nil && err == nil {
 			authId := strconv.FormatInt(author.Id(), 10)
 			session.Values["authorId"] = authId
 		}
 
-		if user != nil {
-			if author != nil {
-				log.Printf("LOGIN: Author id(%d)<%v>",
-					author.User().Id(),
-					author.User().Username())
-			} else {
-				log.Printf("LOGIN: User id(%d)<%v>",
-					user.Id(),
-					user.Username())
-			}
+		if author != nil {
+			log.Printf("LOGIN: Author id(%d)<%v>",
+				author.User().Id(),
+				author.User().Username())
+		} else {
+			log.Printf("LOGIN: User id(%d)<%v>",
+				user.Id(),
+				user.Username())
 		}
 
 		session.Save(r, w)
@@ -109,40 +137,76 @@ func GetHandleOAuth2Callback(conn *model.DBConnection) func(http.ResponseWriter,
 	}
 }
 
-func recoverAuthUser(conn *model.DBConnection,
-	oauthId string) *model.User {
-	user, err := conn.FindUserByOAuthId(oauthId)
-	if err != nil {
-		log.Printf("Couldn't find user with id <%v>\n", oauthId)
-		log.Println(err)
-		return nil
+// Finds the user owning the Google account, or creates it if it's the first
+// time we see that account.  The stored tokens are refreshed in both cases.
+func loginGoogleUser(conn *model.DBConnection,
+	gUser *googleUser,
+	tok *oauth.Token) (*model.User, error) {
+
+	// If user exists, retrieve it.
+	user := recoverAuthUser(conn, gUser)
+
+	// Otherwise save create a new one and save it
+	if user == nil {
+		log.Println("Creating new user")
+		user = createAuthUser(conn, gUser, tok)
+		if err := user.Save(); err != nil {
+			log.Printf("Couldn't save new user <%v>\n", user)
+			return nil, err
+		}
+		return user, nil
 	}
-	return user
 
+	refresh := tok.RefreshToken
+	if refresh == "" {
+		// Google only hands a refresh token on the first consent
+		refresh = user.RefreshToken()
+	}
+	user.SetToken(tok.AccessToken, refresh)
+	if err := user.Update(); err != nil {
+		log.Printf("Couldn't refresh tokens of user id<%d>\n", user.Id())
+		return nil, err
+	}
+	return user, nil
 }
 
-func createAuthUser(conn *model.DBConnection,
-	gUser map[string]interface{},
-	t *oauth.Transport) *model.User {
+func recoverAuthUser(conn *model.DBConnection,
+	gUser *googleUser) *model.User {
+	user, err := conn.FindUserByOAuthId(gUser.Id)
+	if err == nil {
+		return user
+	}
 
-	username, ok := gUser["name"].(string)
-	if !ok {
-		log.Println("HandleOAuth2: username.")
+	// Users used to be saved with the client id of this application as
+	// their OAuth id, and those imported from WordPress have one made up.
+	// Those are recognized by their verified email and keyed on their real
+	// account id from now on.
+	user, err = conn.FindUserByEmail(gUser.Email)
+	if err != nil {
+		log.Printf("Couldn't find user with id <%v>\n", gUser.Id)
 		return nil
 	}
-	email, ok := gUser["email"].(string)
-	if !ok {
-		log.Println("HandleOAuth2: email.")
+	if user.OauthId() != oauthCfg.ClientId && !wxr.Imported(user.OauthId()) {
+		log.Printf("User id<%d> has email <%s> but another OAuth id\n",
+			user.Id(), gUser.Email)
 		return nil
 	}
+	log.Printf("Migrating OAuth id of user id<%d>\n", user.Id())
+	user.SetOauthId(gUser.Id)
+	return user
+}
+
+func createAuthUser(conn *model.DBConnection,
+	gUser *googleUser,
+	tok *oauth.Token) *model.User {
 
-	user := conn.NewUser(username,
+	user := conn.NewUser(gUser.Name,
 		time.Now().UTC(),
 		-5,
-		t.ClientId,
-		t.AccessToken,
-		t.RefreshToken,
-		email)
+		gUser.Id,
+		tok.AccessToken,
+		tok.RefreshToken,
+		gUser.Email)
 
 	return user
 }
@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"code.google.com/p/goauth2/oauth"
+	"github.com/aybabtme/goblog/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Google accounts known to the fake OAuth exchange, by authorization code.
+var googleAccounts = map[string]*googleUser{
+	"code-alice": &googleUser{
+		Id:            "100000000000000000001",
+		Name:          "Alice",
+		Email:         "alice@example.com",
+		VerifiedEmail: true,
+	},
+	"code-bob": &googleUser{
+		Id:            "100000000000000000002",
+		Name:          "Bob",
+		Email:         "bob@example.com",
+		VerifiedEmail: true,
+	},
+}
+
+func fakeGoogle(tokens map[string]*oauth.Token) func(string) (*googleUser, *oauth.Token, error) {
+	return func(code string) (*googleUser, *oauth.Token, error) {
+		return googleAccounts[code], tokens[code], nil
+	}
+}
+
+func TestTwoAccountsSameBrowser(t *testing.T) {
+	twoAccountsSameBrowser(t, setupPGConnection())
+}
+
+func twoAccountsSameBrowser(t *testing.T, conn *model.DBConnection) {
+	defer conn.DeleteConnection()
+	fetchGoogleUser = fakeGoogle(map[string]*oauth.Token{
+		"code-alice": &oauth.Token{AccessToken: "alice-access", RefreshToken: "alice-refresh"},
+		"code-bob":   &oauth.Token{AccessToken: "bob-access", RefreshToken: "bob-refresh"},
+	})
+
+	// Alice logs in first and is made an author
+	cookies := callback(t, conn, "code-alice", nil)
+	alice, _ := Login(conn, httptest.NewRecorder(), withCookies(cookies))
+	if alice == nil || alice.Username() != "Alice" {
+		t.Fatalf("Expected to be logged in as Alice, was <%v>", alice)
+	}
+	if err := conn.NewAuthor(alice).Save(); err != nil {
+		t.Fatal("Couldn't make Alice an author", err)
+	}
+	// without logging in again
+	_, author := Login(conn, httptest.NewRecorder(), withCookies(cookies))
+	if author == nil {
+		t.Fatal("Alice should be an author as soon as the account is made one")
+	}
+	cookies = callback(t, conn, "code-alice", cookies)
+	_, author = Login(conn, httptest.NewRecorder(), withCookies(cookies))
+	if author == nil {
+		t.Fatal("Alice should be logged in as an author")
+	}
+
+	// Then Bob logs in from the same browser
+	cookies = callback(t, conn, "code-bob", cookies)
+	bob, author := Login(conn, httptest.NewRecorder(), withCookies(cookies))
+	if bob == nil || bob.Username() != "Bob" {
+		t.Fatalf("Expected to be logged in as Bob, was <%v>", bob)
+	}
+	if bob.Id() == alice.Id() {
+		t.Errorf("Bob and Alice share the same user id<%d>", bob.Id())
+	}
+	if author != nil {
+		t.Errorf("Bob isn't an author but is logged in as author id<%d>", author.Id())
+	}
+
+	users, err := conn.FindAllUsers()
+	if err != nil {
+		t.Fatal("Couldn't query users", err)
+	}
+	if len(users) != 2 {
+		t.Errorf("Expected <2> users but was <%d>", len(users))
+	}
+}
+
+func TestLoginRefreshesTokens(t *testing.T) {
+	loginRefreshesTokens(t, setupPGConnection())
+}
+
+func loginRefreshesTokens(t *testing.T, conn *model.DBConnection) {
+	defer conn.DeleteConnection()
+
+	tokens := map[string]*oauth.Token{
+		"code-alice": &oauth.Token{AccessToken: "first-access", RefreshToken: "first-refresh"},
+	}
+	fetchGoogleUser = fakeGoogle(tokens)
+	callback(t, conn, "code-alice", nil)
+
+	// Google doesn't send the refresh token again on later logins
+	tokens["code-alice"] = &oauth.Token{AccessToken: "second-access"}
+	callback(t, conn, "code-alice", nil)
+
+	user, err := conn.FindUserByOAuthId(googleAccounts["code-alice"].Id)
+	if err != nil {
+		t.Fatal("Couldn't find user by Google id", err)
+	}
+	if user.AccessToken() != "second-access" {
+		t.Errorf("Expected access token <second-access> but was <%s>", user.AccessToken())
+	}
+	if user.RefreshToken() != "first-refresh" {
+		t.Errorf("Expected refresh token <first-refresh> but was <%s>", user.RefreshToken())
+	}
+}
+
+func TestLoginMigratesClientIdUser(t *testing.T) {
+	loginMigratesClientIdUser(t, setupPGConnection())
+}
+
+func loginMigratesClientIdUser(t *testing.T, conn *model.DBConnection) {
+	defer conn.DeleteConnection()
+
+	gAlice := googleAccounts["code-alice"]
+	legacy := conn.NewUser(gAlice.Name, time.Now().UTC(), -5,
+		oauthCfg.ClientId, "old-access", "old-refresh", gAlice.Email)
+	if err := legacy.Save(); err != nil {
+		t.Fatal("Couldn't save legacy user", err)
+	}
+
+	fetchGoogleUser = fakeGoogle(map[string]*oauth.Token{
+		"code-alice": &oauth.Token{AccessToken: "new-access"},
+	})
+	callback(t, conn, "code-alice", nil)
+
+	user, err := conn.FindUserByOAuthId(gAlice.Id)
+	if err != nil {
+		t.Fatal("Legacy user wasn't migrated to its Google id", err)
+	}
+	if user.Id() != legacy.Id() {
+		t.Errorf("Expected user id<%d> but was <%d>", legacy.Id(), user.Id())
+	}
+}
+
+func TestLoginClaimsImportedUser(t *testing.T) {
+	loginClaimsImportedUser(t, setupPGConnection())
+}
+
+func loginClaimsImportedUser(t *testing.T, conn *model.DBConnection) {
+	defer conn.DeleteConnection()
+
+	// an author of a WordPress export, with the email of Alice's account
+	gAlice := googleAccounts["code-alice"]
+	imported := conn.NewUser("alice", time.Now().UTC(), 0,
+		"wordpress:alice", "", "", gAlice.Email)
+	if err := imported.Save(); err != nil {
+		t.Fatal("Couldn't save imported user", err)
+	}
+	// and someone else's account, which Alice can't take
+	other := conn.NewUser("bob", time.Now().UTC(), 0,
+		"100000000000000000009", "", "", googleAccounts["code-bob"].Email)
+	if err := other.Save(); err != nil {
+		t.Fatal("Couldn't save user", err)
+	}
+
+	fetchGoogleUser = fakeGoogle(map[string]*oauth.Token{
+		"code-alice": &oauth.Token{AccessToken: "alice-access"},
+	})
+	callback(t, conn, "code-alice", nil)
+
+	user, err := conn.FindUserByOAuthId(gAlice.Id)
+	if err != nil || user.Id() != imported.Id() {
+		t.Errorf("Expected Alice to claim user id<%d>, was %v, %v", imported.Id(), user, err)
+	}
+	if recoverAuthUser(conn, googleAccounts["code-bob"]) != nil {
+		t.Error("Expected the account of another Google id to stay its own")
+	}
+}
+
+//
+// Helpers
+//
+
+func setupPGConnection() *model.DBConnection {
+	modelurl := "user=antoine dbname=test sslmode=disable"
+	conn, _ := model.NewConnection(model.NewPostgreser(modelurl))
+	return conn
+}
+
+// Calls the OAuth callback with the given code and cookies, the way a
+// browser would, and returns the cookies the browser would then hold.
+func callback(t *testing.T,
+	conn *model.DBConnection,
+	code string,
+	cookies []*http.Cookie) []*http.Cookie {
+
+	req, _ := http.NewRequest("GET", "/oauth2callback?code="+code, nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	GetHandleOAuth2Callback(conn)(rec, req)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("Callback for <%s> answered <%d>: %s", code, rec.Code, rec.Body)
+	}
+	resp := http.Response{Header: rec.Header()}
+	return resp.Cookies()
+}
+
+func withCookies(cookies []*http.Cookie) *http.Request {
+	req, _ := http.NewRequest("GET", "/", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	return req
+}
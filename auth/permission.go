@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"github.com/aybabtme/goblog/model"
+	"log"
+)
+
+// Something a user attempts to do on a resource.
+type Action int
+
+const (
+	Read Action = iota
+	Create
+	Update
+	Delete
+)
+
+func (a Action) String() string {
+	switch a {
+	case Read:
+		return "read"
+	case Create:
+		return "create"
+	case Update:
+		return "update"
+	case Delete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// Ranks the roles, anonymous visitors being below any role.
+var roleRank = map[model.Role]int{
+	model.RoleCommenter:   1,
+	model.RoleContributor: 2,
+	model.RoleAuthor:      3,
+	model.RoleEditor:      4,
+	model.RoleAdmin:       5,
+}
+
+const anonymousRank = 0
+
+// Tells if the user is allowed to perform the action on the resource.  A nil
+// user is an anonymous visitor.  The resource is one of *model.Post,
+// *model.Comment, *model.Label, *model.Media, *model.Author or *model.User.
+// When the resource doesn't exist yet, like when creating one, pass a nil
+// pointer of its type, such as (*model.Post)(nil).
+func Can(user *model.User, action Action, resource interface{}) bool {
+	rank := anonymousRank
+	if user != nil {
+		role, err := user.Role()
+		if err != nil {
+			log.Printf("auth.Can. Couldn't get role of user id<%d>: %v\n", user.Id(), err)
+			role = model.RoleCommenter
+		}
+		rank = roleRank[role]
+	}
+
+	allowed := can(user, rank, action, resource)
+	if !allowed && user != nil {
+		log.Printf("DENIED: User id(%d) can't %v %T", user.Id(), action, resource)
+	}
+	return allowed
+}
+
+func can(user *model.User, rank int, action Action, resource interface{}) bool {
+	if action == Read {
+		return true
+	}
+	if rank == anonymousRank {
+		return false
+	}
+	if rank >= roleRank[model.RoleAdmin] {
+		return true
+	}
+
+	switch r := resource.(type) {
+	case *model.Post:
+		switch action {
+		case Create:
+			return rank >= roleRank[model.RoleContributor]
+		case Update:
+			return rank >= roleRank[model.RoleEditor] ||
+				(rank >= roleRank[model.RoleContributor] && ownsPost(user, r))
+		case Delete:
+			return rank >= roleRank[model.RoleEditor] ||
+				(rank >= roleRank[model.RoleAuthor] && ownsPost(user, r))
+		}
+
+	case *model.Comment:
+		switch action {
+		case Create:
+			return true
+		case Update, Delete:
+			return rank >= roleRank[model.RoleEditor] ||
+				(r != nil && r.UserId() == user.Id())
+		}
+
+	case *model.Label:
+		switch action {
+		case Create:
+			// labels are created when tagging posts
+			return rank >= roleRank[model.RoleContributor]
+		case Update, Delete:
+			return rank >= roleRank[model.RoleEditor]
+		}
+
+	case *model.Media:
+		switch action {
+		case Create:
+			// whoever writes posts uploads their pictures
+			return rank >= roleRank[model.RoleContributor]
+		case Update, Delete:
+			return rank >= roleRank[model.RoleEditor] ||
+				(rank >= roleRank[model.RoleContributor] && r != nil && r.UserId() == user.Id())
+		}
+
+	case *model.User:
+		// users can edit their own account, only admins manage others
+		return action == Update && r != nil && r.Id() == user.Id()
+
+	case *model.Author:
+		// only admins decide who is an author
+		return false
+	}
+
+	return false
+}
+
+func ownsPost(user *model.User, post *model.Post) bool {
+	if post == nil || post.Author() == nil || post.Author().User() == nil {
+		return false
+	}
+	return post.Author().User().Id() == user.Id()
+}
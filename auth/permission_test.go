@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"fmt"
+	"github.com/aybabtme/goblog/model"
+	"testing"
+	"time"
+)
+
+func userWithRole(t *testing.T, conn *model.DBConnection, role model.Role) *model.User {
+	user := conn.NewUser(string(role), time.Now().UTC(), -5,
+		"oauth-"+string(role), "access", "refresh", string(role)+"@example.com")
+	if err := user.Save(); err != nil {
+		t.Fatal("Couldn't save user", err)
+	}
+	if err := user.SetRole(role); err != nil {
+		t.Fatal("Couldn't set role", err)
+	}
+	return user
+}
+
+func postBy(t *testing.T, conn *model.DBConnection, user *model.User) *model.Post {
+	author, err := conn.FindAuthorByUserId(user.Id())
+	if err != nil {
+		t.Fatal("Couldn't find author of user", err)
+	}
+	post := conn.NewPost(author, "A title", "Some content", "", time.Now().UTC())
+	if err := post.Save(); err != nil {
+		t.Fatal("Couldn't save post", err)
+	}
+	return post
+}
+
+func TestCanOnPosts(t *testing.T) {
+	canOnPosts(t, setupPGConnection())
+}
+
+func canOnPosts(t *testing.T, conn *model.DBConnection) {
+	defer conn.DeleteConnection()
+
+	users := make(map[model.Role]*model.User)
+	for _, role := range model.Roles {
+		users[role] = userWithRole(t, conn, role)
+	}
+	other := userWithRole(t, conn, model.RoleAuthor)
+	othersPost := postBy(t, conn, other)
+	contribPost := postBy(t, conn, users[model.RoleContributor])
+	authorPost := postBy(t, conn, users[model.RoleAuthor])
+
+	cases := []struct {
+		user     *model.User
+		action   Action
+		post     *model.Post
+		expected bool
+	}{
+		{nil, Read, othersPost, true},
+		{nil, Create, nil, false},
+		{users[model.RoleCommenter], Create, nil, false},
+		{users[model.RoleContributor], Create, nil, true},
+		{users[model.RoleContributor], Update, contribPost, true},
+		{users[model.RoleContributor], Delete, contribPost, false},
+		{users[model.RoleContributor], Update, othersPost, false},
+		{users[model.RoleAuthor], Update, authorPost, true},
+		{users[model.RoleAuthor], Delete, authorPost, true},
+		{users[model.RoleAuthor], Update, othersPost, false},
+		{users[model.RoleAuthor], Delete, othersPost, false},
+		{users[model.RoleEditor], Update, othersPost, true},
+		{users[model.RoleEditor], Delete, othersPost, true},
+		{users[model.RoleAdmin], Delete, othersPost, true},
+	}
+
+	for i, c := range cases {
+		if actual := Can(c.user, c.action, c.post); actual != c.expected {
+			t.Errorf("Case #%d, %s, expected <%v> but was <%v>",
+				i, describe(c.user, c.action), c.expected, actual)
+		}
+	}
+}
+
+func TestCanOnUsers(t *testing.T) {
+	canOnUsers(t, setupPGConnection())
+}
+
+func canOnUsers(t *testing.T, conn *model.DBConnection) {
+	defer conn.DeleteConnection()
+
+	editor := userWithRole(t, conn, model.RoleEditor)
+	admin := userWithRole(t, conn, model.RoleAdmin)
+
+	if !Can(editor, Update, editor) {
+		t.Error("Users should be able to update their own account")
+	}
+	if Can(editor, Update, admin) {
+		t.Error("Editors shouldn't be able to update other accounts")
+	}
+	if Can(editor, Create, (*model.Author)(nil)) {
+		t.Error("Editors shouldn't be able to make authors")
+	}
+	if !Can(admin, Delete, editor) {
+		t.Error("Admins should be able to delete other accounts")
+	}
+}
+
+func TestCanOnMedia(t *testing.T) {
+	canOnMedia(t, setupPGConnection())
+}
+
+func canOnMedia(t *testing.T, conn *model.DBConnection) {
+	defer conn.DeleteConnection()
+
+	commenter := userWithRole(t, conn, model.RoleCommenter)
+	contributor := userWithRole(t, conn, model.RoleContributor)
+	author := userWithRole(t, conn, model.RoleAuthor)
+	editor := userWithRole(t, conn, model.RoleEditor)
+	media := conn.NewMedia(contributor.Id(), "a1-cat.png", "/media/a1-cat.png", "image/png", 1, time.Now())
+
+	if Can(commenter, Create, (*model.Media)(nil)) {
+		t.Error("Commenters shouldn't be able to upload")
+	}
+	if !Can(contributor, Create, (*model.Media)(nil)) {
+		t.Error("Contributors should be able to upload")
+	}
+	if !Can(contributor, Delete, media) {
+		t.Error("Users should be able to delete what they uploaded")
+	}
+	if Can(author, Delete, media) {
+		t.Error("Authors shouldn't be able to delete what others uploaded")
+	}
+	if !Can(editor, Delete, media) {
+		t.Error("Editors should be able to delete any media")
+	}
+}
+
+func describe(user *model.User, action Action) string {
+	if user == nil {
+		return fmt.Sprintf("anonymous %v", action)
+	}
+	role, _ := user.Role()
+	return fmt.Sprintf("%s %v", role, action)
+}
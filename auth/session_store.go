@@ -0,0 +1,316 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/aybabtme/goblog/model"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// A session not used for that long is over.
+	SessionIdleTimeout = 7 * 24 * time.Hour
+	// A session is over after that long, however active it is.
+	SessionMaxAge = 30 * 24 * time.Hour
+	// How often sessions past their timeouts are removed from the database.
+	sessionCleanupInterval = time.Hour
+)
+
+// Until SetupSessions is called, sessions are kept in cookies signed with a
+// key that only lives as long as the process.
+var store sessions.Store = sessions.NewCookieStore(randomKey())
+
+// Keeps sessions in the database.  The cookie only holds the signed id of
+// the session, so sessions can be revoked and stop at their timeouts.
+type dbStore struct {
+	conn    *model.DBConnection
+	codecs  []securecookie.Codec
+	options *sessions.Options
+}
+
+// Keeps the sessions in the database from now on.  The keys sign (and
+// optionally encrypt) the cookies, in pairs of authentication and
+// encryption keys.  To rotate keys, put the new pair first and keep the old
+// ones after it until the cookies they signed have expired.  Sessions past
+// their timeouts are removed until the returned func is called.
+func SetupSessions(conn *model.DBConnection, keyPairs ...[]byte) (stop func()) {
+	if len(keyPairs) == 0 {
+		log.Println("auth.SetupSessions. No session keys given, sessions " +
+			"won't survive a restart.")
+		keyPairs = [][]byte{randomKey()}
+	}
+	store = &dbStore{
+		conn:   conn,
+		codecs: securecookie.CodecsFromPairs(keyPairs...),
+		options: &sessions.Options{
+			Path:     "/",
+			MaxAge:   int(SessionMaxAge / time.Second),
+			HttpOnly: true,
+		},
+	}
+
+	ticker := time.NewTicker(sessionCleanupInterval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case now := <-ticker.C:
+				now = now.UTC()
+				conn.DestroyExpiredSessions(now, now.Add(-SessionIdleTimeout))
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+	}
+}
+
+// Parses session keys from a string such as "new-auth:new-enc old-auth",
+// newest pair first.  The encryption key of a pair is optional.
+func ParseSessionKeys(keys string) [][]byte {
+	var pairs [][]byte
+	for _, pair := range strings.Fields(keys) {
+		parts := strings.SplitN(pair, ":", 2)
+		pairs = append(pairs, []byte(parts[0]))
+		if len(parts) == 2 {
+			pairs = append(pairs, []byte(parts[1]))
+		} else {
+			pairs = append(pairs, nil)
+		}
+	}
+	return pairs
+}
+
+func (s *dbStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+func (s *dbStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.options
+	session.Options = &opts
+	session.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+
+	var id string
+	if err := securecookie.DecodeMulti(name, c.Value, &id, s.codecs...); err != nil {
+		// signed with a retired key, or forged
+		return session, nil
+	}
+
+	row, err := s.conn.FindSessionById(hashSessionId(id))
+	if err != nil {
+		// revoked or expired
+		return session, nil
+	}
+
+	now := time.Now().UTC()
+	if now.After(row.Expiry()) || now.Sub(row.LastSeen()) > SessionIdleTimeout {
+		log.Printf("SESSION: Session of user id(%d) timed out", row.UserId())
+		row.Destroy()
+		return session, nil
+	}
+
+	if err := securecookie.DecodeMulti(name, row.Data(), &session.Values, s.codecs...); err != nil {
+		log.Println("auth.dbStore. Couldn't decode session values:", err)
+		return session, err
+	}
+	session.ID = id
+	session.IsNew = false
+	return session, nil
+}
+
+func (s *dbStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if row, err := s.conn.FindSessionById(hashSessionId(session.ID)); err == nil {
+				row.Destroy()
+			}
+		}
+		http.SetCookie(w, sessionCookie(session.Name(), "", session.Options, -1))
+		return nil
+	}
+
+	data, err := securecookie.EncodeMulti(session.Name(), session.Values, s.codecs...)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	var row *model.Session
+	if session.ID != "" {
+		row, _ = s.conn.FindSessionById(hashSessionId(session.ID))
+	}
+
+	if row != nil {
+		row.SetUserId(sessionUserId(session))
+		row.SetData(data)
+		row.SetLastSeen(now)
+		// the cookie holds the same id, and expires when it did
+		return row.Update()
+	}
+
+	id, err := newSessionId()
+	if err != nil {
+		return err
+	}
+	session.ID = id
+	row = s.conn.NewSession(hashSessionId(id),
+		truncate(r.UserAgent(), 255),
+		now,
+		now.Add(time.Duration(session.Options.MaxAge)*time.Second))
+	row.SetUserId(sessionUserId(session))
+	row.SetData(data)
+	if err := row.Save(); err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecs...)
+	if err != nil {
+		return err
+	}
+	maxAge := int(row.Expiry().Sub(now) / time.Second)
+	http.SetCookie(w, sessionCookie(session.Name(), encoded, session.Options, maxAge))
+	return nil
+}
+
+// Gives a new id to the session, dropping the old one.  Done when users log
+// in, so that an id planted in their browser before isn't worth anything.
+func renewSession(session *sessions.Session) {
+	s, ok := store.(*dbStore)
+	if !ok || session.ID == "" {
+		return
+	}
+	if row, err := s.conn.FindSessionById(hashSessionId(session.ID)); err == nil {
+		row.Destroy()
+	}
+	session.ID = ""
+}
+
+// Returns the id of the session of this request, as known to the database.
+// It's empty if sessions aren't kept in the database.
+func CurrentSessionId(r *http.Request) string {
+	if _, ok := store.(*dbStore); !ok {
+		return ""
+	}
+	session, _ := store.Get(r, "user-session")
+	if session.ID == "" {
+		return ""
+	}
+	return hashSessionId(session.ID)
+}
+
+// Ends a session of the user, wherever it is used.
+func RevokeSession(conn *model.DBConnection, user *model.User, id string) error {
+	row, err := conn.FindSessionById(id)
+	if err != nil {
+		return err
+	}
+	if row.UserId() != user.Id() {
+		return errors.New("session belongs to another user")
+	}
+	log.Printf("SESSION: User id(%d) revoked a session", user.Id())
+	return row.Destroy()
+}
+
+// Ends all the sessions of the user, but the one of this request.
+func RevokeOtherSessions(conn *model.DBConnection, user *model.User, r *http.Request) error {
+	current := CurrentSessionId(r)
+	sessions, err := conn.FindSessionsByUserId(user.Id())
+	if err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		if s.Id() == current {
+			continue
+		}
+		if err := s.Destroy(); err != nil {
+			return err
+		}
+	}
+	log.Printf("SESSION: User id(%d) revoked %d other sessions", user.Id(), len(sessions)-1)
+	return nil
+}
+
+func sessionUserId(session *sessions.Session) int64 {
+	idStr, _ := session.Values["userId"].(string)
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+func sessionCookie(name, value string, opts *sessions.Options, maxAge int) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     opts.Path,
+		Domain:   opts.Domain,
+		MaxAge:   maxAge,
+		Secure:   opts.Secure,
+		HttpOnly: opts.HttpOnly,
+	}
+	if maxAge > 0 {
+		cookie.Expires = time.Now().Add(time.Duration(maxAge) * time.Second)
+	} else if maxAge < 0 {
+		cookie.Expires = time.Unix(1, 0)
+	}
+	return cookie
+}
+
+func newSessionId() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(buf), nil
+}
+
+func hashSessionId(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomKey() []byte {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// Cuts s to at most max bytes of valid UTF-8, which the database
+// requires, without splitting a character.
+func truncate(s string, max int) string {
+	s = strings.ToValidUTF8(s, "")
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"code.google.com/p/goauth2/oauth"
+	"github.com/aybabtme/goblog/model"
+	"github.com/gorilla/sessions"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRevokedSessionIsLoggedOut(t *testing.T) {
+	revokedSessionIsLoggedOut(t, setupPGConnection())
+}
+
+func revokedSessionIsLoggedOut(t *testing.T, conn *model.DBConnection) {
+	defer conn.DeleteConnection()
+	defer func(s sessions.Store) { store = s }(store)
+	defer SetupSessions(conn, []byte("a session key"))()
+
+	fetchGoogleUser = fakeGoogle(map[string]*oauth.Token{
+		"code-alice": &oauth.Token{AccessToken: "alice-access"},
+	})
+
+	laptop := callback(t, conn, "code-alice", nil)
+	phone := callback(t, conn, "code-alice", nil)
+
+	alice, _ := Login(conn, httptest.NewRecorder(), withCookies(phone))
+	if alice == nil {
+		t.Fatal("Alice should be logged in on her phone")
+	}
+
+	sessions, err := conn.FindSessionsByUserId(alice.Id())
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("Expected <2> sessions but was <%d>, %v", len(sessions), err)
+	}
+
+	// From her laptop, Alice logs out her phone
+	if err := RevokeOtherSessions(conn, alice, withCookies(laptop)); err != nil {
+		t.Fatal("Couldn't revoke other sessions", err)
+	}
+
+	if user, _ := Login(conn, httptest.NewRecorder(), withCookies(phone)); user != nil {
+		t.Error("Revoked session should be logged out")
+	}
+	if user, _ := Login(conn, httptest.NewRecorder(), withCookies(laptop)); user == nil {
+		t.Error("Session revoking the others should stay logged in")
+	}
+}
+
+func TestIdleSessionIsLoggedOut(t *testing.T) {
+	idleSessionIsLoggedOut(t, setupPGConnection())
+}
+
+func idleSessionIsLoggedOut(t *testing.T, conn *model.DBConnection) {
+	defer conn.DeleteConnection()
+	defer func(s sessions.Store) { store = s }(store)
+	defer SetupSessions(conn, []byte("a session key"))()
+
+	fetchGoogleUser = fakeGoogle(map[string]*oauth.Token{
+		"code-alice": &oauth.Token{AccessToken: "alice-access"},
+	})
+	cookies := callback(t, conn, "code-alice", nil)
+
+	defer func(idle time.Duration) { SessionIdleTimeout = idle }(SessionIdleTimeout)
+	SessionIdleTimeout = time.Nanosecond
+	time.Sleep(time.Millisecond)
+
+	if user, _ := Login(conn, httptest.NewRecorder(), withCookies(cookies)); user != nil {
+		t.Error("Idle session should be logged out")
+	}
+}
+
+func TestRotatedKeysStillValid(t *testing.T) {
+	rotatedKeysStillValid(t, setupPGConnection())
+}
+
+func rotatedKeysStillValid(t *testing.T, conn *model.DBConnection) {
+	defer conn.DeleteConnection()
+	defer func(s sessions.Store) { store = s }(store)
+	defer SetupSessions(conn, ParseSessionKeys("old-key")...)()
+
+	fetchGoogleUser = fakeGoogle(map[string]*oauth.Token{
+		"code-alice": &oauth.Token{AccessToken: "alice-access"},
+	})
+	cookies := callback(t, conn, "code-alice", nil)
+
+	defer SetupSessions(conn, ParseSessionKeys("new-key old-key")...)()
+	if user, _ := Login(conn, httptest.NewRecorder(), withCookies(cookies)); user == nil {
+		t.Error("Cookies signed with an old key should stay valid")
+	}
+
+	defer SetupSessions(conn, ParseSessionKeys("new-key")...)()
+	if user, _ := Login(conn, httptest.NewRecorder(), withCookies(cookies)); user != nil {
+		t.Error("Cookies signed with a retired key shouldn't be valid")
+	}
+}
+
+func TestSetupSessionsStops(t *testing.T) {
+	defer func(s sessions.Store) { store = s }(store)
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		stop := SetupSessions(nil, []byte("a session key"))
+		stop()
+		stop()
+	}
+	time.Sleep(10 * time.Millisecond)
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Expected the cleanups to stop, %d goroutines were left", after-before)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	cases := []struct {
+		s        string
+		max      int
+		expected string
+	}{
+		{"Mozilla", 255, "Mozilla"},
+		{"Mozilla", 3, "Moz"},
+		// é is two bytes, it's kept whole or not at all
+		{"café", 4, "caf"},
+		{"café", 5, "café"},
+		{"bad \xff byte", 255, "bad  byte"},
+	}
+	for _, c := range cases {
+		if actual := truncate(c.s, c.max); actual != c.expected {
+			t.Errorf("Expected %q for %q cut at %d, was %q", c.expected, c.s, c.max, actual)
+		}
+	}
+}
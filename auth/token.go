@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/aybabtme/goblog/model"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Every token starts with it, so that leaked tokens are easy to spot.
+const TokenPrefix = "gb_"
+
+// What a token may be used for.  A scope is a resource followed by `:read`
+// or `:write`, writing allowing to read as well.
+var Scopes = []string{
+	"posts:read",
+	"posts:write",
+	"comments:read",
+	"comments:write",
+	"labels:read",
+	"labels:write",
+	"users:read",
+}
+
+// How often the last use of a token is written down, at most.
+const tokenTouchInterval = time.Minute
+
+var (
+	// The request has no `Authorization: Bearer` header.
+	ErrNoToken = errors.New("no API token")
+	// The token doesn't exist, has expired or its user is banned.
+	ErrInvalidToken = errors.New("invalid API token")
+)
+
+// Creates a token for the user, and returns it along with what's kept of it.
+// The token itself is only known to the caller, only its hash is saved.
+func NewToken(conn *model.DBConnection, user *model.User, name string,
+	scopes []string, expiry time.Time) (string, *model.ApiToken, error) {
+
+	if strings.TrimSpace(name) == "" {
+		return "", nil, errors.New("a token needs a name")
+	}
+	if len(scopes) == 0 {
+		return "", nil, errors.New("a token needs at least a scope")
+	}
+	for _, scope := range scopes {
+		if !validScope(scope) {
+			return "", nil, fmt.Errorf("no such scope %q", scope)
+		}
+	}
+
+	buf := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return "", nil, err
+	}
+	token := TokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	row := conn.NewApiToken(user.Id(), strings.TrimSpace(name), hashToken(token),
+		scopes, time.Now().UTC(), expiry)
+	if err := row.Save(); err != nil {
+		return "", nil, err
+	}
+	log.Printf("TOKEN: User id(%d) created token id(%d) %v", user.Id(), row.Id(), scopes)
+	return token, row, nil
+}
+
+// Finds the user of the bearer token the request carries.  Returns
+// ErrNoToken if there's none, ErrInvalidToken if it can't be used.
+func TokenLogin(conn *model.DBConnection, r *http.Request) (*model.User, *model.ApiToken, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, nil, ErrNoToken
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+
+	row, err := conn.FindApiTokenByHash(hashToken(token))
+	if err != nil {
+		log.Printf("auth.TokenLogin. Unknown token from %s", r.RemoteAddr)
+		return nil, nil, ErrInvalidToken
+	}
+	now := time.Now().UTC()
+	if row.Expired(now) {
+		log.Printf("auth.TokenLogin. Token id<%d> has expired", row.Id())
+		return nil, nil, ErrInvalidToken
+	}
+
+	user, err := conn.FindUserById(row.UserId())
+	if err != nil {
+		log.Printf("auth.TokenLogin. Couldn't find user with id <%d>", row.UserId())
+		return nil, nil, ErrInvalidToken
+	}
+	if banned, _, _ := user.Banned(); banned {
+		log.Printf("auth.TokenLogin. User id<%d> is banned", user.Id())
+		return nil, nil, ErrInvalidToken
+	}
+
+	if now.Sub(row.LastUsed()) > tokenTouchInterval {
+		if err := row.Touch(now); err != nil {
+			log.Printf("auth.TokenLogin. Couldn't touch token id<%d>: %v", row.Id(), err)
+		}
+	}
+	log.Printf("LOGIN: User id(%d)<%v> with token id(%d)", user.Id(), user.Username(), row.Id())
+	return user, row, nil
+}
+
+// The author of the user, if the user may write posts.
+func AuthorOf(conn *model.DBConnection, user *model.User) *model.Author {
+	if !Can(user, Create, (*model.Post)(nil)) {
+		return nil
+	}
+	author, err := conn.FindAuthorByUserId(user.Id())
+	if err != nil {
+		return nil
+	}
+	return author
+}
+
+// Tells if the token was given the scope.  Writing a resource allows to
+// read it.
+func HasScope(token *model.ApiToken, scope string) bool {
+	resource := strings.TrimSuffix(scope, ":read")
+	for _, given := range token.Scopes() {
+		if given == scope || (resource != scope && given == resource+":write") {
+			return true
+		}
+	}
+	return false
+}
+
+// Revokes a token of the user.
+func RevokeToken(conn *model.DBConnection, user *model.User, id int64) error {
+	row, err := conn.FindApiTokenById(id)
+	if err != nil {
+		return err
+	}
+	if row.UserId() != user.Id() {
+		return errors.New("token belongs to another user")
+	}
+	log.Printf("TOKEN: User id(%d) revoked token id(%d)", user.Id(), row.Id())
+	return row.Destroy()
+}
+
+func validScope(scope string) bool {
+	for _, valid := range Scopes {
+		if scope == valid {
+			return true
+		}
+	}
+	return false
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"github.com/aybabtme/goblog/model"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHasScope(t *testing.T) {
+	var conn *model.DBConnection
+	token := conn.NewApiToken(1, "CI", "", []string{"posts:write", "users:read"},
+		time.Now(), time.Time{})
+
+	cases := []struct {
+		scope string
+		has   bool
+	}{
+		{"posts:write", true},
+		// writing allows to read
+		{"posts:read", true},
+		{"users:read", true},
+		{"comments:read", false},
+		{"comments:write", false},
+		{"users:write", false},
+	}
+	for _, c := range cases {
+		if HasScope(token, c.scope) != c.has {
+			t.Errorf("Expected HasScope(%s) to be %v", c.scope, c.has)
+		}
+	}
+}
+
+func TestTokenLoginWithoutToken(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/api/v1/posts", nil)
+	if _, _, err := TokenLogin(nil, req); err != ErrNoToken {
+		t.Errorf("Expected ErrNoToken, was %v", err)
+	}
+
+	// other schemes are left to someone else
+	req.Header.Set("Authorization", "Basic YWxpY2U6c2VjcmV0")
+	if _, _, err := TokenLogin(nil, req); err != ErrNoToken {
+		t.Errorf("Expected ErrNoToken for basic auth, was %v", err)
+	}
+}
+
+func TestTokenLogin(t *testing.T) {
+	tokenLogin(t, setupPGConnection())
+}
+
+func tokenLogin(t *testing.T, conn *model.DBConnection) {
+	defer conn.DeleteConnection()
+	author := userWithRole(t, conn, model.RoleAuthor)
+	conn.NewAuthor(author).Save()
+
+	if _, _, err := NewToken(conn, author, "CI", []string{"posts:admin"}, time.Time{}); err == nil {
+		t.Error("Tokens can't have made up scopes")
+	}
+
+	token, row, err := NewToken(conn, author, "CI", []string{"posts:write"}, time.Time{})
+	if err != nil {
+		t.Fatal("Couldn't create token", err)
+	}
+	if !strings.HasPrefix(token, TokenPrefix) || row.Hash() == token || strings.Contains(row.Hash(), token) {
+		t.Errorf("Expected only the hash of the token to be kept, was <%s> for <%s>", row.Hash(), token)
+	}
+
+	req, _ := http.NewRequest("POST", "/api/v1/posts", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	user, used, err := TokenLogin(conn, req)
+	if err != nil || user.Id() != author.Id() || used.Id() != row.Id() {
+		t.Fatalf("Expected to log in as the author, was %v, %v", user, err)
+	}
+	if AuthorOf(conn, user) == nil {
+		t.Error("Expected the author of the user")
+	}
+	used, _ = conn.FindApiTokenById(row.Id())
+	if used.LastUsed().IsZero() {
+		t.Error("Expected the use of the token to be recorded")
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token+"nope")
+	if _, _, err := TokenLogin(conn, req); err != ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken for a wrong token, was %v", err)
+	}
+
+	expired, _, _ := NewToken(conn, author, "Old", []string{"posts:read"}, time.Now().UTC().Add(-time.Minute))
+	req.Header.Set("Authorization", "Bearer "+expired)
+	if _, _, err := TokenLogin(conn, req); err != ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken for an expired token, was %v", err)
+	}
+
+	other := userWithRole(t, conn, model.RoleCommenter)
+	if err := RevokeToken(conn, other, row.Id()); err == nil {
+		t.Error("Users can't revoke the tokens of others")
+	}
+	if err := RevokeToken(conn, author, row.Id()); err != nil {
+		t.Fatal("Couldn't revoke token", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if _, _, err := TokenLogin(conn, req); err != ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken for a revoked token, was %v", err)
+	}
+}
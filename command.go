@@ -0,0 +1,372 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/aybabtme/goblog/archive"
+	"github.com/aybabtme/goblog/export"
+	"github.com/aybabtme/goblog/frontmatter"
+	"github.com/aybabtme/goblog/gitsync"
+	"github.com/aybabtme/goblog/view"
+	"github.com/aybabtme/goblog/wxr"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// Runs the command named on the command line instead of the blog, i.e.
+// `goblog theme validate themes/dark`.
+func runCommand(args []string) error {
+	switch args[0] {
+	case "theme":
+		return themeCommand(args[1:])
+	case "export-static":
+		return exportStaticCommand(args[1:])
+	case "export":
+		return exportCommand(args[1:])
+	case "import":
+		return importCommand(args[1:])
+	case "import-wxr":
+		return importWXRCommand(args[1:])
+	case "import-posts":
+		return importPostsCommand(args[1:])
+	case "export-posts":
+		return exportPostsCommand(args[1:])
+	case "git-sync":
+		return gitSyncCommand(args[1:])
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// Checks the theme given as argument, or else the one in $THEME.
+func themeCommand(args []string) error {
+	usage := fmt.Errorf("usage: goblog theme validate [theme directory]")
+	if len(args) == 0 || args[0] != "validate" {
+		return usage
+	}
+	dir := os.Getenv("THEME")
+	if len(args) > 1 {
+		dir = args[1]
+	}
+	if dir == "" {
+		return usage
+	}
+
+	theme, err := view.LoadTheme(dir)
+	if err != nil {
+		return err
+	}
+	errs := theme.Validate(view.DefaultTemplates())
+	for _, err := range errs {
+		fmt.Println(" -", err)
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("theme %q has %d problem(s)", theme.Name, len(errs))
+	}
+	fmt.Printf("Theme %q is valid.\n", theme.Name)
+	return nil
+}
+
+// Writes the blog as static files, i.e.
+// `goblog export-static --out site --url https://blog.example.com`.
+func exportStaticCommand(args []string) error {
+	flags := flag.NewFlagSet("export-static", flag.ContinueOnError)
+	out := flags.String("out", "", "the directory to write the blog in")
+	url := flags.String("url", os.Getenv("SITE_URL"), "where the files will be hosted, $SITE_URL by default")
+	full := flags.Bool("full", false, "write every file again, not only those that changed")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" || *url == "" {
+		return fmt.Errorf("usage: goblog export-static --out dir [--url https://blog.example.com] [--full]")
+	}
+
+	conn, err := setupDatabase(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	// links leaving the pages, like those of feeds, go where the files are
+	cfg.Site.URL = *url
+	cfg.Dev = false
+
+	var r Router
+	handler, assets, stop, err := r.Handler(conn, cfg)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	report, err := export.Static(conn, handler, assets.Names(), export.Options{
+		Out:     *out,
+		BaseURL: *url,
+		Full:    *full,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Exported to %s: %d file(s) written, %d unchanged, %d removed.\n",
+		*out, report.Written, report.Skipped, report.Removed)
+	return nil
+}
+
+// Writes the whole blog in a JSON archive, i.e.
+// `goblog export --out backup.json`, or on the standard output.
+func exportCommand(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	out := flags.String("out", "", "the file to write the archive in, the standard output by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	conn, err := setupDatabase(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return err
+	}
+	a, err := archive.Export(conn)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		return a.Write(os.Stdout)
+	}
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := a.Write(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d user(s), %d post(s) and %d comment(s) to %s.\n",
+		len(a.Users), len(a.Posts), len(a.Comments), *out)
+	return nil
+}
+
+// Restores an archive in the database, i.e.
+// `goblog import --dry-run backup.json`.
+func importCommand(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only tell what would be imported")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: goblog import [--dry-run] archive.json")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	a, err := archive.Read(file)
+	if err != nil {
+		return err
+	}
+
+	conn, err := setupDatabase(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return err
+	}
+	report, err := archive.Import(conn, a, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	return printImportReport(report, *dryRun)
+}
+
+// Imports a WordPress export in the database, i.e.
+// `goblog import-wxr --dry-run wordpress.xml`.
+func importWXRCommand(args []string) error {
+	flags := flag.NewFlagSet("import-wxr", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only tell what would be imported")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: goblog import-wxr [--dry-run] wordpress.xml")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	a, skipped, err := wxr.Read(file)
+	if err != nil {
+		return err
+	}
+
+	conn, err := setupDatabase(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return err
+	}
+	report, err := archive.Import(conn, a, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	if len(skipped) != 0 {
+		fmt.Printf("Skipped %d item(s) of the export:\n", len(skipped))
+		for _, why := range skipped {
+			fmt.Println(" -", why)
+		}
+	}
+	return printImportReport(report, *dryRun)
+}
+
+// Tells what an import did by section, and fails if rows couldn't be
+// imported.
+func printImportReport(report *archive.Report, dryRun bool) error {
+	if dryRun {
+		fmt.Println("Dry run, nothing was written.")
+	}
+	var sections []string
+	for section := range archive.Sections() {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+	for _, section := range sections {
+		fmt.Printf("%-12s %d created, %d already there\n",
+			section+":", report.Created[section], report.Existing[section])
+	}
+	for _, conflict := range report.Conflicts {
+		fmt.Println(" -", conflict)
+	}
+	if len(report.Conflicts) != 0 {
+		return fmt.Errorf("%d row(s) couldn't be imported", len(report.Conflicts))
+	}
+	return nil
+}
+
+// Reads a directory of Markdown files as posts, i.e.
+// `goblog import-posts --sync --author me@example.com posts`.
+func importPostsCommand(args []string) error {
+	flags := flag.NewFlagSet("import-posts", flag.ContinueOnError)
+	sync := flags.Bool("sync", false, "update the posts already on the blog to what their file says")
+	dryRun := flags.Bool("dry-run", false, "only tell what would be imported")
+	email := flags.String("author", "", "the email of the author of new posts")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: goblog import-posts [--sync] [--dry-run] [--author email] directory")
+	}
+
+	conn, err := setupDatabase(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return err
+	}
+	opts := frontmatter.Options{Sync: *sync, DryRun: *dryRun}
+	if *email != "" {
+		user, err := conn.FindUserByEmail(*email)
+		if err != nil {
+			return fmt.Errorf("no user has email %q", *email)
+		}
+		if opts.Author, err = conn.FindAuthorByUserId(user.Id()); err != nil {
+			return fmt.Errorf("%s isn't an author", *email)
+		}
+	}
+
+	report, err := frontmatter.Import(conn, flags.Arg(0), opts)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		fmt.Println("Dry run, nothing was written.")
+	}
+	fmt.Printf("%d created, %d updated, %d unchanged, %d skipped\n",
+		len(report.Created), len(report.Updated), len(report.Unchanged), len(report.Skipped))
+	for _, name := range report.Created {
+		fmt.Println(" + created", name)
+	}
+	for _, name := range report.Updated {
+		fmt.Println(" ~ updated", name)
+	}
+	for _, why := range report.Skipped {
+		fmt.Println(" - skipped", why)
+	}
+	for _, why := range report.Failed {
+		fmt.Println(" ! failed", why)
+	}
+	if len(report.Failed) != 0 {
+		return fmt.Errorf("%d file(s) couldn't be imported", len(report.Failed))
+	}
+	return nil
+}
+
+// Writes every post as a Markdown file, i.e.
+// `goblog export-posts --out posts`.
+func exportPostsCommand(args []string) error {
+	flags := flag.NewFlagSet("export-posts", flag.ContinueOnError)
+	out := flags.String("out", "posts", "the directory to write the posts in")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	conn, err := setupDatabase(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return err
+	}
+	written, err := frontmatter.Export(conn, *out)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d post(s) to %s.\n", written, *out)
+	return nil
+}
+
+// Keeps the posts up to date with the Markdown files of a git repository,
+// i.e. `goblog git-sync --path posts /srv/blog.git`, checking for new
+// commits every minute, or only once with --once, like in a post-receive
+// hook.
+func gitSyncCommand(args []string) error {
+	flags := flag.NewFlagSet("git-sync", flag.ContinueOnError)
+	branch := flags.String("branch", "HEAD", "the branch whose commits are published")
+	path := flags.String("path", "", "the directory of the repository the posts are in, all of it by default")
+	every := flags.Duration("every", time.Minute, "how often to check for new commits")
+	once := flags.Bool("once", false, "sync the new commits and stop")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: goblog git-sync [--branch name] [--path dir] [--every 1m] [--once] repository")
+	}
+
+	conn, err := setupDatabase(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return err
+	}
+	repo := gitsync.Repo{Dir: flags.Arg(0), Branch: *branch, Path: *path}
+	for {
+		commits, err := gitsync.Sync(conn, repo)
+		for _, commit := range commits {
+			r := commit.Report
+			log.Printf("git-sync: %.7s by %s: %d created, %d updated, %d deleted",
+				commit.Hash, commit.Email, len(r.Created), len(r.Updated), len(r.Deleted))
+			for _, why := range r.Skipped {
+				log.Println("git-sync: skipped", why)
+			}
+			for _, why := range r.Failed {
+				log.Println("git-sync: failed", why)
+			}
+		}
+		if *once {
+			return err
+		}
+		if err != nil {
+			log.Println("git-sync:", err)
+		}
+		time.Sleep(*every)
+	}
+}
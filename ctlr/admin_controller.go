@@ -0,0 +1,329 @@
+package ctlr
+
+import (
+	"fmt"
+	"github.com/aybabtme/goblog/auth"
+	"github.com/aybabtme/goblog/model"
+	"github.com/aybabtme/goblog/view"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type admin struct {
+	path string
+	view *view.Page
+}
+
+func NewAdminUserListController() Controller {
+	var a admin
+	a.path = "/admin/users"
+	a.view = view.GetAdminUserListTemplate()
+	return a
+}
+
+func NewAdminUserController() Controller {
+	var a admin
+	a.path = "/admin/users/{userId:[0-9]+}"
+	a.view = view.GetAdminUserTemplate()
+	return a
+}
+
+func NewAdminUserActionController() Controller {
+	var a admin
+	a.path = "/admin/users/{actionId:[0-9]+}/{action:role|ban|unban|merge|delete}"
+	a.view = view.GetAdminConfirmTemplate()
+	return a
+}
+
+func NewAdminAuditController() Controller {
+	var a admin
+	a.path = "/admin/audit"
+	a.view = view.GetAdminAuditTemplate()
+	return a
+}
+
+// What the admin pages know of a user, beside the user itself.
+type adminUserRow struct {
+	User     *model.User
+	Role     model.Role
+	IsAuthor bool
+	// the posts they wrote, which keep them from being deleted
+	Posts     int
+	Banned    bool
+	BanReason string
+}
+
+func (a admin) Path() string {
+	return a.path
+}
+
+// Only those who may manage any user get in the admin pages
+func (a admin) Middlewares() []Middleware {
+	return []Middleware{Require(auth.Update, (*model.User)(nil), "Only admins can manage users")}
+}
+
+func (a admin) Controller(conn *model.DBConnection) func(http.ResponseWriter, *http.Request) {
+	return handle(func(rw http.ResponseWriter, req *http.Request) error {
+		currentUser := CurrentUser(req)
+
+		vars := mux.Vars(req)
+		userId := vars["userId"]
+		actionId := vars["actionId"]
+
+		if a.path == "/admin/audit" {
+			return a.forAudit(conn, rw, req, currentUser)
+		} else if actionId != "" {
+			return a.forAction(conn, rw, req, currentUser, actionId, vars["action"])
+		} else if userId != "" {
+			return a.forUser(conn, rw, req, currentUser, userId)
+		}
+		return a.forListing(conn, rw, req, currentUser)
+	})
+}
+
+func (a *admin) forListing(conn *model.DBConnection,
+	rw http.ResponseWriter,
+	req *http.Request,
+	currentUser *model.User) error {
+
+	query := strings.TrimSpace(req.FormValue("q"))
+
+	var users []model.User
+	var err error
+	if query == "" {
+		users, err = conn.FindAllUsers()
+	} else {
+		users, err = conn.SearchUsers(query)
+	}
+	if err != nil {
+		return InternalError(err)
+	}
+
+	var rows []adminUserRow
+	for i := range users {
+		rows = append(rows, describeUser(conn, &users[i]))
+	}
+
+	data := struct {
+		PageContext
+		Query string
+		Users []adminUserRow
+	}{
+		NewPageContext(rw, req),
+		query,
+		rows,
+	}
+
+	if err := a.view.Execute(rw, data); nil != err {
+		log.Println("AdminController for listing, execute:", err)
+	}
+	return nil
+}
+
+func (a *admin) forUser(conn *model.DBConnection,
+	rw http.ResponseWriter,
+	req *http.Request,
+	currentUser *model.User,
+	id string) error {
+
+	user, err := findAdminTarget(conn, id)
+	if err != nil {
+		return err
+	}
+
+	entries, err := conn.FindAuditEntriesByTargetId(user.Id())
+	if err != nil {
+		log.Println("AdminController for user, audit trail:", err)
+	}
+
+	data := struct {
+		PageContext
+		Target  adminUserRow
+		Roles   []model.Role
+		Entries []model.AuditEntry
+	}{
+		NewPageContext(rw, req),
+		describeUser(conn, user),
+		model.Roles,
+		entries,
+	}
+
+	if err := a.view.Execute(rw, data); nil != err {
+		log.Println("AdminController for user, execute:", err)
+	}
+	return nil
+}
+
+// Shows a confirmation page on GET, does the action on POST.
+func (a *admin) forAction(conn *model.DBConnection,
+	rw http.ResponseWriter,
+	req *http.Request,
+	currentUser *model.User,
+	id string,
+	action string) error {
+
+	user, err := findAdminTarget(conn, id)
+	if err != nil {
+		return err
+	}
+
+	if user.Id() == currentUser.Id() {
+		return BadRequest("You can't "+action+" your own account", nil)
+	}
+
+	if req.Method != "POST" {
+		data := struct {
+			PageContext
+			Target adminUserRow
+			Action string
+			Roles  []model.Role
+			Role   string
+		}{
+			NewPageContext(rw, req),
+			describeUser(conn, user),
+			action,
+			model.Roles,
+			req.FormValue("role"),
+		}
+
+		if err := a.view.Execute(rw, data); nil != err {
+			log.Println("AdminController for action, execute:", err)
+		}
+		return nil
+	}
+
+	var detail string
+	target := user
+
+	switch action {
+	case "role":
+		role := model.Role(req.FormValue("role"))
+		if !role.Valid() {
+			return BadRequest("Unknown role", nil)
+		}
+		err = user.SetRole(role)
+		detail = fmt.Sprintf("role set to %s", role)
+
+	case "ban":
+		reason := strings.TrimSpace(req.FormValue("reason"))
+		err = user.Ban(reason, time.Now().UTC())
+		if err == nil {
+			// log the user out everywhere
+			err = conn.DestroySessionsByUserId(user.Id())
+		}
+		detail = "banned: " + reason
+
+	case "unban":
+		err = user.Unban()
+		detail = "unbanned"
+
+	case "merge":
+		intoId, parseErr := strconv.ParseInt(req.FormValue("into"), 10, 64)
+		if parseErr != nil {
+			return BadRequest("Need the id of the user to merge into", parseErr)
+		}
+		into, findErr := conn.FindUserById(intoId)
+		if findErr != nil {
+			return BadRequest("No user to merge into", findErr)
+		}
+		err = into.Merge(user)
+		target = into
+		detail = fmt.Sprintf("merged duplicate user id<%d> (%s, %s)",
+			user.Id(), user.Username(), user.Email())
+
+	case "delete":
+		if !auth.Can(currentUser, auth.Delete, user) {
+			return Forbidden("You can't delete this user")
+		}
+		err = user.Destroy()
+		if err == model.ErrUserHasPosts || err == model.ErrUserHasMedia {
+			return BadRequest(fmt.Sprintf("User id<%d> can't be deleted, %v", user.Id(), err), err)
+		}
+		detail = fmt.Sprintf("deleted user (%s, %s)", user.Username(), user.Email())
+	}
+
+	if err != nil {
+		return InternalError(err)
+	}
+
+	entry := conn.NewAuditEntry(currentUser, action, user.Id(), detail, time.Now().UTC())
+	if err := entry.Save(); err != nil {
+		log.Printf("AdminController, couldn't audit %s of user id<%d>: %v\n",
+			action, user.Id(), err)
+	}
+
+	auth.AddFlash(rw, req, fmt.Sprintf("User id<%d> %s.", user.Id(), detail))
+
+	if action == "delete" {
+		http.Redirect(rw, req, "/admin/users", http.StatusFound)
+		return nil
+	}
+	http.Redirect(rw, req, "/admin/users/"+strconv.FormatInt(target.Id(), 10), http.StatusFound)
+	return nil
+}
+
+func (a *admin) forAudit(conn *model.DBConnection,
+	rw http.ResponseWriter,
+	req *http.Request,
+	currentUser *model.User) error {
+
+	entries, err := conn.FindAllAuditEntries()
+	if err != nil {
+		return InternalError(err)
+	}
+
+	data := struct {
+		PageContext
+		Entries []model.AuditEntry
+	}{
+		NewPageContext(rw, req),
+		entries,
+	}
+
+	if err := a.view.Execute(rw, data); nil != err {
+		log.Println("AdminController for audit, execute:", err)
+	}
+	return nil
+}
+
+func findAdminTarget(conn *model.DBConnection, id string) (*model.User, error) {
+	intId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, BadRequest("Bad user id", err)
+	}
+
+	user, err := conn.FindUserById(intId)
+	if err != nil {
+		return nil, NotFoundOr("No such user", err)
+	}
+	return user, nil
+}
+
+func describeUser(conn *model.DBConnection, user *model.User) adminUserRow {
+	row := adminUserRow{User: user}
+
+	role, err := user.Role()
+	if err != nil {
+		log.Printf("AdminController, role of user id<%d>: %v\n", user.Id(), err)
+	}
+	row.Role = role
+
+	author, _ := conn.FindAuthorByUserId(user.Id())
+	row.IsAuthor = author != nil
+	if row.IsAuthor {
+		row.Posts, err = user.PostCount()
+		if err != nil {
+			log.Printf("AdminController, posts of user id<%d>: %v\n", user.Id(), err)
+		}
+	}
+
+	row.Banned, row.BanReason, err = user.Banned()
+	if err != nil {
+		log.Printf("AdminController, ban of user id<%d>: %v\n", user.Id(), err)
+	}
+	return row
+}
@@ -8,7 +8,6 @@ import (
 	"log"
 	"net/http"
 	"strconv"
-	"text/template"
 )
 
 func NewAuthorController() Controller {
@@ -27,90 +26,95 @@ func NewAuthorListController() Controller {
 
 type author struct {
 	path string
-	view *template.Template
+	view *view.Page
 }
 
 func (a author) Path() string {
 	return a.path
 }
 
+func (a author) Scope() string {
+	return "users"
+}
+
+func (a author) Middlewares() []Middleware {
+	return []Middleware{Require(auth.Read, (*model.Author)(nil), "You can't see authors")}
+}
+
 func (a author) Controller(conn *model.DBConnection) func(http.ResponseWriter,
 	*http.Request) {
-	return func(rw http.ResponseWriter, req *http.Request) {
+	return handle(func(rw http.ResponseWriter, req *http.Request) error {
 		vars := mux.Vars(req)
 		id := vars["id"]
 
 		if id == "" {
-			a.authorIndex(conn, rw, req)
-		} else {
-			a.authorId(conn, rw, req, id)
+			return a.authorIndex(conn, rw, req)
 		}
-	}
+		return a.authorId(conn, rw, req, id)
+	})
 }
 
 func (a author) authorIndex(conn *model.DBConnection,
 	rw http.ResponseWriter,
-	req *http.Request) {
+	req *http.Request) error {
 
 	authors, err := conn.FindAllAuthors()
 	if err != nil {
-		log.Printf("AuthorController, find all authors: ", err)
+		return InternalError(err)
 	}
 
-	currentUser, currentAuthor := auth.Login(conn, rw, req)
-
 	data := struct {
-		CurrentUser   *model.User
-		CurrentAuthor *model.Author
-		Authors       []model.Author
+		PageContext
+		Authors []model.Author
 	}{
-		currentUser,
-		currentAuthor,
+		NewPageContext(rw, req),
 		authors,
 	}
 
 	if err := a.view.Execute(rw, data); nil != err {
-		log.Printf("AuthorController for Listing", err)
+		log.Println("AuthorController for Listing", err)
 	}
+	return nil
 }
 
 func (a author) authorId(conn *model.DBConnection,
-	rw http.ResponseWriter, req *http.Request, id string) {
+	rw http.ResponseWriter, req *http.Request, id string) error {
 
 	intId, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
-		log.Printf("AuthorController, parse id: ", err)
-		return
+		return BadRequest("That's not an author id", err)
 	}
 
 	author, err := conn.FindAuthorById(intId)
 	if err != nil {
-		log.Printf("AuthorController, author db search: ", err)
-		return
+		return NotFoundOr("There's no such author", err)
+	}
+
+	if !auth.Can(CurrentUser(req), auth.Read, author) {
+		return Forbidden("You can't see this author")
 	}
 
 	posts, err := author.Posts()
 	if err != nil {
-		log.Printf("AuthorController, posts db search: ", err)
-		return
+		return InternalError(err)
 	}
 
-	currentUser, currentAuthor := auth.Login(conn, rw, req)
+	page := NewPageContext(rw, req)
+	page.Alternates = append(page.Alternates,
+		feedAlternates("/author/"+id, site.Title+": "+author.User().Username())...)
 
 	data := struct {
-		CurrentUser   *model.User
-		CurrentAuthor *model.Author
-		Author        *model.Author
-		Posts         []model.Post
+		PageContext
+		Author *model.Author
+		Posts  []model.Post
 	}{
-		currentUser,
-		currentAuthor,
+		page,
 		author,
 		posts,
 	}
 
 	if err := a.view.Execute(rw, data); nil != err {
-		log.Printf("AuthorController for Posts: ", err)
-		return
+		log.Println("AuthorController for Posts: ", err)
 	}
+	return nil
 }
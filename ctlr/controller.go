@@ -8,4 +8,12 @@ import (
 type Controller interface {
 	Path() string
 	Controller(*model.DBConnection) func(http.ResponseWriter, *http.Request)
+	// Wrap the controller, after the pipeline of the router
+	Middlewares() []Middleware
+}
+
+// Scoped controllers accept API tokens having the scope of their resource,
+// i.e. "posts".  Tokens are refused by controllers that aren't scoped.
+type Scoped interface {
+	Scope() string
 }
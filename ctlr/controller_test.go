@@ -0,0 +1,140 @@
+package ctlr
+
+import (
+	"github.com/aybabtme/goblog/model"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFailureStatus(t *testing.T) {
+	failureStatus(t, setupPGConnection())
+}
+
+func failureStatus(t *testing.T, conn *model.DBConnection) {
+	defer conn.DeleteConnection()
+	router := setupRouter(conn)
+
+	// Ids matching the routes, but too big for an int64
+	tooBig := "99999999999999999999"
+
+	cases := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{"GET", "/no/such/page", http.StatusNotFound},
+
+		{"GET", "/post/424242", http.StatusNotFound},
+		{"GET", "/post/" + tooBig, http.StatusBadRequest},
+		{"GET", "/post/edit/424242", http.StatusNotFound},
+		{"GET", "/post/edit/" + tooBig, http.StatusBadRequest},
+		{"GET", "/post/destroy/424242", http.StatusNotFound},
+		{"GET", "/post/save", http.StatusMethodNotAllowed},
+		{"POST", "/post/save", http.StatusForbidden},
+		{"GET", "/post/save/424242", http.StatusMethodNotAllowed},
+		{"POST", "/post/save/424242", http.StatusNotFound},
+		{"GET", "/post/comment/424242", http.StatusMethodNotAllowed},
+		{"POST", "/post/comment/424242", http.StatusForbidden},
+
+		{"GET", "/label/424242", http.StatusNotFound},
+		{"GET", "/label/" + tooBig, http.StatusBadRequest},
+		{"GET", "/user/424242", http.StatusNotFound},
+		{"GET", "/user/" + tooBig, http.StatusBadRequest},
+		{"GET", "/author/424242", http.StatusNotFound},
+		{"GET", "/author/" + tooBig, http.StatusBadRequest},
+		{"GET", "/label/424242/feed.atom", http.StatusNotFound},
+		{"GET", "/author/424242/feed.rss", http.StatusNotFound},
+		{"GET", "/author/" + tooBig + "/feed.rss", http.StatusBadRequest},
+		{"POST", "/feed.atom", http.StatusMethodNotAllowed},
+		{"GET", "/feed.json?limit=0", http.StatusBadRequest},
+		{"GET", "/feed.json?content=everything", http.StatusBadRequest},
+		{"GET", "/sitemap-0.xml", http.StatusNotFound},
+		{"GET", "/sitemap-2.xml", http.StatusNotFound},
+		{"POST", "/robots.txt", http.StatusMethodNotAllowed},
+
+		{"GET", "/media", http.StatusForbidden},
+		{"POST", "/media", http.StatusForbidden},
+		{"GET", "/media/destroy/424242", http.StatusForbidden},
+		{"GET", "/media/no-such-file.png", http.StatusNotFound},
+		{"GET", "/media/thumb/no-such-file.png", http.StatusNotFound},
+		{"POST", "/media/large/no-such-file.png", http.StatusMethodNotAllowed},
+
+		{"GET", "/admin/users", http.StatusForbidden},
+		{"GET", "/admin/users/424242", http.StatusForbidden},
+		{"GET", "/admin/audit", http.StatusForbidden},
+		{"GET", "/sessions", http.StatusForbidden},
+		{"POST", "/sessions/revoke/others", http.StatusForbidden},
+		{"GET", "/tokens", http.StatusForbidden},
+		{"POST", "/tokens/revoke/1", http.StatusForbidden},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest(c.method, c.path, nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != c.status {
+			t.Errorf("%s %s, expected status %d but was %d",
+				c.method, c.path, c.status, rec.Code)
+		}
+		if rec.Header().Get("X-Request-Id") == "" {
+			t.Errorf("%s %s, expected the error page", c.method, c.path)
+		}
+	}
+}
+
+//
+// Helpers
+//
+
+func setupPGConnection() *model.DBConnection {
+	modelurl := "user=antoine dbname=test sslmode=disable"
+	conn, _ := model.NewConnection(model.NewPostgreser(modelurl))
+	return conn
+}
+
+// Routes like the blog does, without the CSRF checks.
+func setupRouter(conn *model.DBConnection) *mux.Router {
+	controllers := []Controller{
+		NewIndexController(),
+		NewAuthorController(),
+		NewAuthorListController(),
+		NewUserController(),
+		NewLabelController(),
+		NewFeedController(),
+		NewLabelFeedController(),
+		NewAuthorFeedController(),
+		NewSitemapController(),
+		NewSitemapPageController(),
+		NewRobotsController(),
+		NewPostController(),
+		NewPostComposeController(),
+		NewPostSaveController(),
+		NewPostUpdateController(),
+		NewPostDestroyController(),
+		NewPostCommentController(),
+		NewPostEditController(),
+		NewPostIdController(),
+		NewMediaController(),
+		NewMediaDestroyController(),
+		NewMediaVariantController(),
+		NewMediaFileController(),
+		NewAdminUserListController(),
+		NewAdminUserController(),
+		NewAdminUserActionController(),
+		NewAdminAuditController(),
+		NewSessionListController(),
+		NewSessionRevokeController(),
+		NewTokenListController(),
+		NewTokenRevokeController()}
+
+	pipeline := []Middleware{Recover, LoadAuth(conn)}
+	router := mux.NewRouter()
+	router.NotFoundHandler = NotFoundHandler()
+	for _, c := range controllers {
+		router.HandleFunc(c.Path(), Handler(conn, c, pipeline))
+	}
+	return router
+}
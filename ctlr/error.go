@@ -0,0 +1,172 @@
+package ctlr
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/aybabtme/goblog/view"
+	"log"
+	"net/http"
+	"regexp"
+)
+
+// Error is what a controller answers when it can't serve a request: the
+// status code and the message shown to the visitor.  The cause is only
+// logged.
+type Error struct {
+	Status  int
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%d %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("%d %s: %v", e.Status, e.Message, e.Err)
+}
+
+func BadRequest(message string, err error) *Error {
+	return &Error{http.StatusBadRequest, message, err}
+}
+
+// Unauthorized asks the client to authenticate with an API token.
+func Unauthorized(rw http.ResponseWriter, message string) *Error {
+	rw.Header().Set("WWW-Authenticate", `Bearer realm="goblog"`)
+	return &Error{http.StatusUnauthorized, message, nil}
+}
+
+func Forbidden(message string) *Error {
+	return &Error{http.StatusForbidden, message, nil}
+}
+
+func NotFound(message string, err error) *Error {
+	return &Error{http.StatusNotFound, message, err}
+}
+
+func MethodNotAllowed(rw http.ResponseWriter, allowed string) *Error {
+	rw.Header().Set("Allow", allowed)
+	return &Error{http.StatusMethodNotAllowed, "This page only answers to " + allowed, nil}
+}
+
+func InternalError(err error) *Error {
+	return &Error{http.StatusInternalServerError, "Something went wrong on our side", err}
+}
+
+// NotFoundOr tells a missing row apart from a failing database.
+func NotFoundOr(message string, err error) *Error {
+	if err == sql.ErrNoRows {
+		return NotFound(message, err)
+	}
+	return InternalError(err)
+}
+
+// Turns a controller func returning an error into a handler, which renders
+// the error page for the error returned.
+func handle(h func(http.ResponseWriter, *http.Request) error) func(http.ResponseWriter, *http.Request) {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if err := h(rw, req); err != nil {
+			RenderError(rw, req, err)
+		}
+	}
+}
+
+// RenderError logs err along with the request id, and answers with the error
+// page of the theme.  Errors other than *Error are answered with a 500.
+func RenderError(rw http.ResponseWriter, req *http.Request, err error) {
+	ctlrErr, ok := err.(*Error)
+	if !ok {
+		ctlrErr = InternalError(err)
+	}
+	id := RequestId(req)
+	log.Printf("[%s] %s %s: %v", id, req.Method, req.URL.Path, ctlrErr)
+
+	if wantsJSONErrors(req) {
+		renderJSONError(rw, ctlrErr, id)
+		return
+	}
+
+	data := struct {
+		PageContext
+		Status    int
+		Title     string
+		Message   string
+		RequestId string
+	}{
+		NewPageContext(rw, req),
+		ctlrErr.Status,
+		http.StatusText(ctlrErr.Status),
+		ctlrErr.Message,
+		id,
+	}
+
+	rw.Header().Set("X-Request-Id", id)
+	var page bytes.Buffer
+	if err := errorView.Execute(&page, data); err != nil {
+		log.Printf("[%s] rendering error page: %v", id, err)
+		http.Error(rw, ctlrErr.Message, ctlrErr.Status)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.WriteHeader(ctlrErr.Status)
+	page.WriteTo(rw)
+}
+
+// The body of the errors answered to JSONErrors requests.
+type jsonError struct {
+	Error struct {
+		Status    int    `json:"status"`
+		Title     string `json:"title"`
+		Message   string `json:"message"`
+		RequestId string `json:"request_id"`
+	} `json:"error"`
+}
+
+func renderJSONError(rw http.ResponseWriter, ctlrErr *Error, id string) {
+	var body jsonError
+	body.Error.Status = ctlrErr.Status
+	body.Error.Title = http.StatusText(ctlrErr.Status)
+	body.Error.Message = ctlrErr.Message
+	body.Error.RequestId = id
+
+	rw.Header().Set("X-Request-Id", id)
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.WriteHeader(ctlrErr.Status)
+	if err := json.NewEncoder(rw).Encode(body); err != nil {
+		log.Printf("[%s] writing error body: %v", id, err)
+	}
+}
+
+// NotFoundHandler answers requests that no controller matches.
+func NotFoundHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		RenderError(rw, req, NotFound("There's no such page", nil))
+	})
+}
+
+// Fail renders an error page for the auth package.
+func Fail(rw http.ResponseWriter, req *http.Request, status int, message string) {
+	RenderError(rw, req, &Error{status, message, nil})
+}
+
+// RequestId is the id of a request in the logs and on error pages.  It's
+// taken from the X-Request-Id header, like a proxy's, and made up when
+// there's none or when it isn't a plain id, as anyone can send one.
+func RequestId(req *http.Request) string {
+	if id := req.Header.Get("X-Request-Id"); validRequestId.MatchString(id) {
+		return id
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+	req.Header.Set("X-Request-Id", id)
+	return id
+}
+
+// Ids like UUIDs, short enough for a log line.
+var validRequestId = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
+var errorView = view.GetErrorTemplate()
@@ -0,0 +1,134 @@
+package ctlr
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRenderError(t *testing.T) {
+	cases := []struct {
+		err     error
+		status  int
+		message string
+	}{
+		{BadRequest("That's not a post id", errors.New("strconv")), http.StatusBadRequest, "That&#39;s not a post id"},
+		{Forbidden("You can't read posts"), http.StatusForbidden, "You can&#39;t read posts"},
+		{NotFound("There's no such post", nil), http.StatusNotFound, "There&#39;s no such post"},
+		{InternalError(errors.New("db is down")), http.StatusInternalServerError, "Something went wrong"},
+		{errors.New("not a controller error"), http.StatusInternalServerError, "Something went wrong"},
+	}
+
+	for i, c := range cases {
+		req, _ := http.NewRequest("GET", "/post/1", nil)
+		rec := httptest.NewRecorder()
+		RenderError(rec, req, c.err)
+
+		if rec.Code != c.status {
+			t.Errorf("Case #%d, expected status %d but was %d", i, c.status, rec.Code)
+		}
+		body := rec.Body.String()
+		if !strings.Contains(body, c.message) {
+			t.Errorf("Case #%d, expected <%s> in the page:\n%s", i, c.message, body)
+		}
+		id := rec.Header().Get("X-Request-Id")
+		if id == "" || !strings.Contains(body, id) {
+			t.Errorf("Case #%d, expected request id <%s> in the page", i, id)
+		}
+		// the cause is logged, never shown
+		if strings.Contains(body, "db is down") || strings.Contains(body, "strconv") {
+			t.Errorf("Case #%d, cause leaked in the page", i)
+		}
+	}
+}
+
+func TestRenderErrorKeepsRequestId(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-Id", "from-the-proxy")
+	rec := httptest.NewRecorder()
+	RenderError(rec, req, Forbidden("No"))
+
+	if rec.Header().Get("X-Request-Id") != "from-the-proxy" {
+		t.Errorf("Expected the request id of the proxy, was <%s>", rec.Header().Get("X-Request-Id"))
+	}
+}
+
+func TestRenderErrorRefusesForgedRequestId(t *testing.T) {
+	for _, forged := range []string{
+		"a\n2026/10/19 [admin] GET /admin 200",
+		"<script>alert(1)</script>",
+		strings.Repeat("a", 65),
+	} {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-Id", forged)
+		rec := httptest.NewRecorder()
+		RenderError(rec, req, Forbidden("No"))
+
+		id := rec.Header().Get("X-Request-Id")
+		if id == forged || len(id) != 16 || strings.Contains(rec.Body.String(), forged) {
+			t.Errorf("Expected an id made up in place of %q, was <%s>", forged, id)
+		}
+	}
+}
+
+func TestRenderJSONError(t *testing.T) {
+	var body jsonError
+	h := Chain(func(rw http.ResponseWriter, req *http.Request) {
+		RenderError(rw, req, NotFound("There's no such post", errors.New("no rows")))
+	}, JSONErrors)
+
+	rec := httptest.NewRecorder()
+	h(rec, newRequest("GET", "/api/v1/posts/1"))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d but was %d", http.StatusNotFound, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Expected a JSON body, was <%s>", ct)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Invalid JSON body, %v:\n%s", err, rec.Body.String())
+	}
+	if body.Error.Status != http.StatusNotFound ||
+		body.Error.Message != "There's no such post" ||
+		body.Error.RequestId != rec.Header().Get("X-Request-Id") {
+		t.Errorf("Unexpected error body %+v", body.Error)
+	}
+	if strings.Contains(rec.Body.String(), "no rows") {
+		t.Error("Cause leaked in the body")
+	}
+}
+
+func TestNotFoundPage(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/nothing/here", nil)
+	rec := httptest.NewRecorder()
+	NotFoundHandler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d but was %d", http.StatusNotFound, rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "NOOOooOoOoOoOO") {
+		t.Errorf("Expected the 404 template in the page:\n%s", rec.Body.String())
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	err := MethodNotAllowed(rec, "POST")
+	if err.Status != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "POST" {
+		t.Errorf("Unexpected %v, Allow <%s>", err, rec.Header().Get("Allow"))
+	}
+}
+
+func TestNotFoundOr(t *testing.T) {
+	if err := NotFoundOr("No post", sql.ErrNoRows); err.Status != http.StatusNotFound {
+		t.Errorf("Expected a 404 for a missing row, was %d", err.Status)
+	}
+	if err := NotFoundOr("No post", errors.New("connection refused")); err.Status != http.StatusInternalServerError {
+		t.Errorf("Expected a 500 for a failing database, was %d", err.Status)
+	}
+}
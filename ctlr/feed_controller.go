@@ -0,0 +1,178 @@
+package ctlr
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/aybabtme/goblog/auth"
+	"github.com/aybabtme/goblog/feed"
+	"github.com/aybabtme/goblog/model"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// How many posts a feed has, the most recent ones, unless readers ask for
+// more with ?limit=, up to maxFeedItems.
+const (
+	feedItems    = 20
+	maxFeedItems = 100
+)
+
+type syndication struct {
+	path string
+}
+
+func NewFeedController() Controller {
+	return syndication{"/feed.{format:atom|rss|json}"}
+}
+
+func NewLabelFeedController() Controller {
+	return syndication{"/label/{labelId:[0-9]+}/feed.{format:atom|rss|json}"}
+}
+
+func NewAuthorFeedController() Controller {
+	return syndication{"/author/{authorId:[0-9]+}/feed.{format:atom|rss|json}"}
+}
+
+func (s syndication) Path() string {
+	return s.path
+}
+
+func (s syndication) Scope() string {
+	return "posts"
+}
+
+func (s syndication) Middlewares() []Middleware {
+	return nil
+}
+
+func (s syndication) Controller(conn *model.DBConnection) func(http.ResponseWriter, *http.Request) {
+	return handle(func(rw http.ResponseWriter, req *http.Request) error {
+		if req.Method != "GET" && req.Method != "HEAD" {
+			return MethodNotAllowed(rw, "GET")
+		}
+		if !auth.Can(CurrentUser(req), auth.Read, (*model.Post)(nil)) {
+			return Forbidden("You can't read posts")
+		}
+
+		opts, err := feedOptions(req)
+		if err != nil {
+			return err
+		}
+
+		vars := mux.Vars(req)
+		base := BaseURL(req)
+		title, description, link := site.Title, site.Description, base+"/"
+
+		var posts []model.Post
+		switch {
+		case vars["labelId"] != "":
+			id, err := strconv.ParseInt(vars["labelId"], 10, 64)
+			if err != nil {
+				return BadRequest("That's not a label id", err)
+			}
+			label, err := conn.FindLabelById(id)
+			if err != nil {
+				return NotFoundOr("There's no such label", err)
+			}
+			title = site.Title + ": " + label.Name()
+			description = "Posts labeled " + label.Name()
+			link = base + "/label/" + vars["labelId"]
+			posts, err = label.Posts()
+			if err != nil {
+				return InternalError(err)
+			}
+
+		case vars["authorId"] != "":
+			id, err := strconv.ParseInt(vars["authorId"], 10, 64)
+			if err != nil {
+				return BadRequest("That's not an author id", err)
+			}
+			author, err := conn.FindAuthorById(id)
+			if err != nil {
+				return NotFoundOr("There's no such author", err)
+			}
+			title = site.Title + ": " + author.User().Username()
+			description = "Posts by " + author.User().Username()
+			link = base + "/author/" + vars["authorId"]
+			posts, err = author.Posts()
+			if err != nil {
+				return InternalError(err)
+			}
+
+		default:
+			posts, err = conn.FindAllPosts()
+			if err != nil {
+				return InternalError(err)
+			}
+		}
+
+		f, err := feed.FromPosts(title, description, link, base+req.URL.RequestURI(), base, posts, opts)
+		if err != nil {
+			return InternalError(err)
+		}
+
+		var body bytes.Buffer
+		var contentType string
+		switch vars["format"] {
+		case "rss":
+			contentType = feed.RSSType
+			err = f.WriteRSS(&body)
+		case "json":
+			contentType = feed.JSONType
+			err = f.WriteJSON(&body)
+		default:
+			contentType = feed.AtomType
+			err = f.WriteAtom(&body)
+		}
+		if err != nil {
+			return InternalError(err)
+		}
+
+		serveGenerated(rw, req, contentType, f.Updated, body.Bytes())
+		return nil
+	})
+}
+
+// The options readers ask for: ?limit= posts, and ?content=summary or full.
+func feedOptions(req *http.Request) (feed.Options, error) {
+	opts := feed.Options{Limit: feedItems}
+	query := req.URL.Query()
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxFeedItems {
+			return opts, BadRequest("The limit must be between 1 and "+strconv.Itoa(maxFeedItems), err)
+		}
+		opts.Limit = n
+	}
+	switch query.Get("content") {
+	case "", "full":
+	case "summary":
+		opts.Summary = true
+	default:
+		return opts, BadRequest("The content must be full or summary", nil)
+	}
+	return opts, nil
+}
+
+// Answers a generated document with links from BaseURL, like a feed, or a
+// 304 to readers who have it already.
+func serveGenerated(rw http.ResponseWriter, req *http.Request, contentType string, updated time.Time, body []byte) {
+	sum := sha256.Sum256(body)
+	rw.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	rw.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	cacheBaseURL(rw, 300)
+	http.ServeContent(rw, req, "", updated, bytes.NewReader(body))
+}
+
+// The feeds of a page, linked from its head.  The path is the one of the
+// page, empty for the whole blog.
+func feedAlternates(path, title string) []Alternate {
+	return []Alternate{
+		{feed.AtomType, title + " (Atom)", path + "/feed.atom"},
+		{feed.RSSType, title + " (RSS)", path + "/feed.rss"},
+		{feed.JSONType, title + " (JSON Feed)", path + "/feed.json"},
+	}
+}
@@ -0,0 +1,124 @@
+package ctlr
+
+import (
+	"crypto/tls"
+	"github.com/aybabtme/goblog/feed"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServeGenerated(t *testing.T) {
+	updated := time.Date(2014, 3, 2, 10, 0, 0, 0, time.UTC)
+	body := []byte("<feed></feed>")
+
+	req, _ := http.NewRequest("GET", "/feed.atom", nil)
+	rec := httptest.NewRecorder()
+	serveGenerated(rec, req, feed.AtomType, updated, body)
+	if rec.Code != http.StatusOK || rec.Body.String() != string(body) {
+		t.Fatalf("Expected the feed, was %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Content-Type") != "application/atom+xml; charset=utf-8" {
+		t.Errorf("Wrong content type, was %q", rec.Header().Get("Content-Type"))
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" || rec.Header().Get("Last-Modified") != "Sun, 02 Mar 2014 10:00:00 GMT" {
+		t.Errorf("Expected validators, was %v", rec.Header())
+	}
+
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	serveGenerated(rec, req, feed.AtomType, updated, body)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("Expected 304 for the same ETag, was %d", rec.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/feed.atom", nil)
+	req.Header.Set("If-Modified-Since", "Sun, 02 Mar 2014 10:00:00 GMT")
+	rec = httptest.NewRecorder()
+	serveGenerated(rec, req, feed.AtomType, updated, body)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for an unmodified feed, was %d", rec.Code)
+	}
+
+	req.Header.Set("If-Modified-Since", "Sat, 01 Mar 2014 10:00:00 GMT")
+	rec = httptest.NewRecorder()
+	serveGenerated(rec, req, feed.AtomType, updated, body)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected the feed once modified, was %d", rec.Code)
+	}
+}
+
+func TestFeedOptions(t *testing.T) {
+	cases := []struct {
+		query string
+		opts  feed.Options
+		ok    bool
+	}{
+		{"", feed.Options{Limit: feedItems}, true},
+		{"?limit=5&content=summary", feed.Options{Limit: 5, Summary: true}, true},
+		{"?content=full", feed.Options{Limit: feedItems}, true},
+		{"?limit=0", feed.Options{}, false},
+		{"?limit=1000", feed.Options{}, false},
+		{"?limit=many", feed.Options{}, false},
+		{"?content=some", feed.Options{}, false},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("GET", "/feed.json"+c.query, nil)
+		opts, err := feedOptions(req)
+		if (err == nil) != c.ok {
+			t.Errorf("%q: expected ok to be %v, was %v", c.query, c.ok, err)
+		} else if c.ok && opts != c.opts {
+			t.Errorf("%q: expected %+v, was %+v", c.query, c.opts, opts)
+		}
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	defer func(url string) { site.URL = url }(site.URL)
+	site.URL = ""
+
+	req, _ := http.NewRequest("GET", "http://blog.example.com/feed.atom", nil)
+	if url := BaseURL(req); url != "http://blog.example.com" {
+		t.Errorf("Expected the host of the request, was %q", url)
+	}
+	req.Header.Set("X-Forwarded-Proto", "https")
+	if url := BaseURL(req); url != "https://blog.example.com" {
+		t.Errorf("Expected the scheme of the proxy, was %q", url)
+	}
+	req.Header.Del("X-Forwarded-Proto")
+	req.TLS = &tls.ConnectionState{}
+	if url := BaseURL(req); url != "https://blog.example.com" {
+		t.Errorf("Expected https over TLS, was %q", url)
+	}
+
+	site.URL = "https://example.com/blog"
+	if url := BaseURL(req); url != site.URL {
+		t.Errorf("Expected the configured URL, was %q", url)
+	}
+}
+
+func TestCacheBaseURL(t *testing.T) {
+	defer func(url string) { site.URL = url }(site.URL)
+
+	site.URL = ""
+	rec := httptest.NewRecorder()
+	cacheBaseURL(rec, 300)
+	if cc := rec.Header().Get("Cache-Control"); cc != "private, max-age=300" {
+		t.Errorf("Expected a guessed URL to be kept from shared caches, was %q", cc)
+	}
+	if vary := rec.Header().Get("Vary"); vary != "Host, X-Forwarded-Proto" {
+		t.Errorf("Expected to vary with what the URL is guessed from, was %q", vary)
+	}
+
+	site.URL = "https://blog.example.com"
+	rec = httptest.NewRecorder()
+	cacheBaseURL(rec, 300)
+	if cc := rec.Header().Get("Cache-Control"); cc != "public, max-age=300" {
+		t.Errorf("Expected a configured URL to be cached by all, was %q", cc)
+	}
+	if vary := rec.Header().Get("Vary"); vary != "" {
+		t.Errorf("Expected no Vary with a configured URL, was %q", vary)
+	}
+}
@@ -6,11 +6,10 @@ import (
 	"github.com/aybabtme/goblog/view"
 	"log"
 	"net/http"
-	"text/template"
 )
 
 type index struct {
-	view *template.Template
+	view *view.Page
 }
 
 func NewIndexController() Controller {
@@ -23,38 +22,40 @@ func (i index) Path() string {
 	return "/"
 }
 
+func (i index) Scope() string {
+	return "posts"
+}
+
+func (i index) Middlewares() []Middleware {
+	return []Middleware{Require(auth.Read, (*model.Post)(nil), "You can't read posts")}
+}
+
 func (i index) Controller(conn *model.DBConnection) func(http.ResponseWriter,
 	*http.Request) {
-	return func(rw http.ResponseWriter, req *http.Request) {
+	return handle(func(rw http.ResponseWriter, req *http.Request) error {
 
 		posts, err := conn.FindAllPosts()
 		if err != nil {
-			log.Println("IndexController, list posts: ", err)
-			return
+			return InternalError(err)
 		}
 		labels, err := conn.FindAllLabels()
 		if err != nil {
-			log.Println("IndexController, list labels: ", err)
-			return
+			return InternalError(err)
 		}
 
-		currentUser, currentAuthor := auth.Login(conn, rw, req)
-
 		data := struct {
-			CurrentUser   *model.User
-			CurrentAuthor *model.Author
-			AllPosts      []model.Post
-			AllLabels     []model.Label
+			PageContext
+			AllPosts  []model.Post
+			AllLabels []model.Label
 		}{
-			currentUser,
-			currentAuthor,
+			NewPageContext(rw, req),
 			posts,
 			labels,
 		}
 
 		if err := i.view.Execute(rw, data); nil != err {
 			log.Println("IndexController, execute: ", err)
-			return
 		}
-	}
+		return nil
+	})
 }
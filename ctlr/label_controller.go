@@ -8,7 +8,6 @@ import (
 	"log"
 	"net/http"
 	"strconv"
-	"text/template"
 )
 
 func NewLabelController() Controller {
@@ -18,44 +17,53 @@ func NewLabelController() Controller {
 }
 
 type label struct {
-	view *template.Template
+	view *view.Page
 }
 
 func (l label) Path() string {
 	return "/label/{id:[0-9]+}"
 }
 
+func (l label) Scope() string {
+	return "labels"
+}
+
+func (l label) Middlewares() []Middleware {
+	return nil
+}
+
 func (l label) Controller(conn *model.DBConnection) func(http.ResponseWriter, *http.Request) {
-	return func(rw http.ResponseWriter, req *http.Request) {
+	return handle(func(rw http.ResponseWriter, req *http.Request) error {
 		vars := mux.Vars(req)
 		id, err := strconv.ParseInt(vars["id"], 10, 64)
 		if err != nil {
-			log.Println("LabelController, parse id:", err)
-			return
+			return BadRequest("That's not a label id", err)
 		}
 
 		label, err := conn.FindLabelById(id)
 		if err != nil {
-			log.Printf("LabelController, for id(%d): \n%v\n", id, err)
-			return
+			return NotFoundOr("There's no such label", err)
+		}
+
+		if !auth.Can(CurrentUser(req), auth.Read, label) {
+			return Forbidden("You can't read this label")
 		}
 
 		posts, err := label.Posts()
 		if err != nil {
-			log.Println("LabelController, listing posts.", err)
-			return
+			return InternalError(err)
 		}
 
-		currentUser, currentAuthor := auth.Login(conn, rw, req)
+		page := NewPageContext(rw, req)
+		page.Alternates = append(page.Alternates,
+			feedAlternates("/label/"+vars["id"], site.Title+": "+label.Name())...)
 
 		data := struct {
-			CurrentAuthor *model.Author
-			CurrentUser   *model.User
-			Name          string
-			AllPosts      []model.Post
+			PageContext
+			Name     string
+			AllPosts []model.Post
 		}{
-			currentAuthor,
-			currentUser,
+			page,
 			label.Name(),
 			posts,
 		}
@@ -63,6 +71,6 @@ func (l label) Controller(conn *model.DBConnection) func(http.ResponseWriter, *h
 		if err := l.view.Execute(rw, data); nil != err {
 			log.Println("LabelController, execute:", err)
 		}
-
-	}
+		return nil
+	})
 }
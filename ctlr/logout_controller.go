@@ -0,0 +1,48 @@
+package ctlr
+
+import (
+	"github.com/aybabtme/goblog/auth"
+	"github.com/aybabtme/goblog/model"
+	"github.com/aybabtme/goblog/view"
+	"log"
+	"net/http"
+)
+
+type logout struct {
+	view *view.Page
+}
+
+func NewLogoutController() Controller {
+	var l logout
+	l.view = view.GetLogoutTemplate()
+	return l
+}
+
+func (l logout) Path() string {
+	return "/logout"
+}
+
+func (l logout) Middlewares() []Middleware {
+	return nil
+}
+
+// Asks for a confirmation on GET, logs out on POST.
+func (l logout) Controller(conn *model.DBConnection) func(http.ResponseWriter, *http.Request) {
+	doLogout := auth.Logout(conn)
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method == "POST" {
+			doLogout(rw, req)
+			return
+		}
+
+		data := struct {
+			PageContext
+		}{
+			NewPageContext(rw, req),
+		}
+
+		if err := l.view.Execute(rw, data); nil != err {
+			log.Println("LogoutController, execute:", err)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package ctlr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aybabtme/goblog/auth"
+	"github.com/aybabtme/goblog/media"
+	"github.com/aybabtme/goblog/model"
+	"github.com/aybabtme/goblog/view"
+	"github.com/gorilla/mux"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Where uploads are kept, nil when the blog doesn't take any.
+var mediaStore media.MediaStore
+
+var mediaMaxSize int64 = media.DefaultMaxSize
+
+// What resizes the pictures uploaded, nil to show them as they are.
+var thumbnails *media.Thumbnailer
+
+// SetupMedia keeps uploads in store, refusing those larger than maxSize
+// bytes, and resizes pictures with t.
+func SetupMedia(store media.MediaStore, maxSize int64, t *media.Thumbnailer) {
+	if maxSize <= 0 {
+		maxSize = media.DefaultMaxSize
+	}
+	mediaStore = store
+	mediaMaxSize = maxSize
+	thumbnails = t
+}
+
+// Requests are allowed a bit more than the largest upload, for the rest of
+// the form.
+func maxBodySize() int64 {
+	return mediaMaxSize + 1<<20
+}
+
+type library struct {
+	path string
+	view *view.Page
+}
+
+func NewMediaController() Controller {
+	return library{"/media", view.GetMediaTemplate()}
+}
+
+func NewMediaFileController() Controller {
+	return library{"/media/{name}", nil}
+}
+
+func NewMediaDestroyController() Controller {
+	return library{"/media/destroy/{mediaId:[0-9]+}", nil}
+}
+
+func NewMediaVariantController() Controller {
+	return library{"/media/{size:thumb|medium|large}/{name}", nil}
+}
+
+func (l library) Path() string {
+	return l.path
+}
+
+func (l library) Middlewares() []Middleware {
+	if l.path == "/media/{name}" || l.path == "/media/{size:thumb|medium|large}/{name}" {
+		return nil
+	}
+	return []Middleware{
+		jsonErrorsIfAccepted,
+		Require(auth.Create, (*model.Media)(nil), "Only authors can upload media"),
+	}
+}
+
+// Scripts accepting JSON, like the picker of the compose page, get their
+// errors in JSON as they get the media.
+func jsonErrorsIfAccepted(h http.HandlerFunc) http.HandlerFunc {
+	withJSON := JSONErrors(h)
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if acceptsJSON(req) {
+			withJSON(rw, req)
+			return
+		}
+		h(rw, req)
+	}
+}
+
+func acceptsJSON(req *http.Request) bool {
+	return strings.Contains(req.Header.Get("Accept"), "application/json")
+}
+
+func (l library) Controller(conn *model.DBConnection) func(http.ResponseWriter, *http.Request) {
+	return handle(func(rw http.ResponseWriter, req *http.Request) error {
+		vars := mux.Vars(req)
+		if size := vars["size"]; size != "" {
+			return l.forVariant(rw, req, size, vars["name"])
+		}
+		if name := vars["name"]; name != "" {
+			return l.forFile(rw, req, name)
+		}
+		if mediaId := vars["mediaId"]; mediaId != "" {
+			if req.Method != "POST" {
+				return MethodNotAllowed(rw, "POST")
+			}
+			return l.forDestroy(conn, rw, req, mediaId)
+		}
+		if req.Method == "POST" {
+			return l.forUpload(conn, rw, req)
+		}
+		return l.forListing(conn, rw, req)
+	})
+}
+
+type mediaRow struct {
+	Media     *model.Media
+	CanDelete bool
+}
+
+func (l *library) forListing(conn *model.DBConnection,
+	rw http.ResponseWriter,
+	req *http.Request) error {
+
+	all, err := conn.FindAllMedia()
+	if err != nil {
+		return InternalError(err)
+	}
+
+	currentUser := CurrentUser(req)
+	var rows []mediaRow
+	for i := range all {
+		rows = append(rows, mediaRow{&all[i], auth.Can(currentUser, auth.Delete, &all[i])})
+	}
+
+	data := struct {
+		PageContext
+		Files     []mediaRow
+		MaxSizeMB int64
+	}{
+		NewPageContext(rw, req),
+		rows,
+		mediaMaxSize >> 20,
+	}
+
+	if err := l.view.Execute(rw, data); nil != err {
+		log.Println("MediaController for listing:", err)
+	}
+	return nil
+}
+
+// Takes the file of the "file" field.  Scripts, like the picker of the
+// compose page, get the media in JSON when they accept it.
+func (l *library) forUpload(conn *model.DBConnection,
+	rw http.ResponseWriter,
+	req *http.Request) error {
+
+	if mediaStore == nil {
+		return NotFound("This blog doesn't take uploads", nil)
+	}
+
+	file, header, err := req.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return tooLargeError()
+		}
+		return BadRequest("There's no file to upload", err)
+	}
+	defer file.Close()
+	if header.Size > mediaMaxSize {
+		return tooLargeError()
+	}
+
+	name, contentType, size, err := media.Upload(mediaStore, header.Filename, file, mediaMaxSize)
+	switch err {
+	case nil:
+	case media.ErrTooLarge:
+		return tooLargeError()
+	case media.ErrUnsupportedType:
+		return BadRequest("Only pictures and PDF files can be uploaded", err)
+	default:
+		return InternalError(err)
+	}
+
+	m := conn.NewMedia(CurrentUser(req).Id(), name, mediaStore.URL(name),
+		contentType, size, time.Now().UTC())
+	if err := m.Save(); err != nil {
+		if err := mediaStore.Delete(name); err != nil {
+			log.Printf("MediaController couldn't delete %s: %v", name, err)
+		}
+		return InternalError(err)
+	}
+
+	if thumbnails != nil && m.IsImage() {
+		go func() {
+			if err := thumbnails.Warm(name); err != nil && err != media.ErrNotResizable {
+				log.Printf("MediaController couldn't resize %s: %v", name, err)
+			}
+		}()
+	}
+
+	if acceptsJSON(req) {
+		rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+		rw.WriteHeader(http.StatusCreated)
+		json.NewEncoder(rw).Encode(struct {
+			Id      int64  `json:"id"`
+			Name    string `json:"name"`
+			URL     string `json:"url"`
+			IsImage bool   `json:"isImage"`
+		}{m.Id(), m.Name(), m.URL(), m.IsImage()})
+		return nil
+	}
+
+	auth.AddFlash(rw, req, "Your file is uploaded.")
+	http.Redirect(rw, req, "/media", http.StatusFound)
+	return nil
+}
+
+func tooLargeError() *Error {
+	return &Error{http.StatusRequestEntityTooLarge,
+		fmt.Sprintf("Files can't be larger than %d MB", mediaMaxSize>>20), nil}
+}
+
+// Serves the files of the local store.  Stores of their own, like S3
+// buckets, are never asked for them, their URLs going elsewhere.
+func (l *library) forFile(rw http.ResponseWriter,
+	req *http.Request,
+	name string) error {
+
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return MethodNotAllowed(rw, "GET")
+	}
+	if mediaStore == nil || !media.ValidName(name) {
+		return NotFound("There's no such file", nil)
+	}
+	file, err := mediaStore.Open(name)
+	if err != nil {
+		return NotFound("There's no such file", err)
+	}
+	defer file.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	rw.Header().Set("Content-Type", contentType)
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	// names are never reused
+	rw.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	if req.Method == "GET" {
+		io.Copy(rw, file)
+	}
+	return nil
+}
+
+// Serves the picture name resized to size, in WebP to the browsers that
+// accept it when possible.  Files that can't be resized are found at their
+// own URL.
+func (l *library) forVariant(rw http.ResponseWriter,
+	req *http.Request,
+	sizeName string,
+	name string) error {
+
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return MethodNotAllowed(rw, "GET")
+	}
+	size, ok := media.FindSize(sizeName)
+	if mediaStore == nil || !ok || !media.ValidName(name) {
+		return NotFound("There's no such picture", nil)
+	}
+	if thumbnails == nil {
+		http.Redirect(rw, req, mediaStore.URL(name), http.StatusFound)
+		return nil
+	}
+
+	webp := strings.Contains(req.Header.Get("Accept"), "image/webp")
+	file, contentType, err := thumbnails.Variant(name, size, webp)
+	if err == media.ErrNotResizable {
+		http.Redirect(rw, req, mediaStore.URL(name), http.StatusFound)
+		return nil
+	} else if err != nil {
+		return NotFound("There's no such picture", err)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return InternalError(err)
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return InternalError(err)
+	}
+
+	rw.Header().Set("Content-Type", contentType)
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	if thumbnails.CanWebP() {
+		rw.Header().Add("Vary", "Accept")
+	}
+	rw.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeContent(rw, req, "", stat.ModTime(), f)
+	return nil
+}
+
+func (l *library) forDestroy(conn *model.DBConnection,
+	rw http.ResponseWriter,
+	req *http.Request,
+	id string) error {
+
+	mediaId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return BadRequest("That's not a media id", err)
+	}
+	m, err := conn.FindMediaById(mediaId)
+	if err != nil {
+		return NotFoundOr("There's no such file to delete", err)
+	}
+	if !auth.Can(CurrentUser(req), auth.Delete, m) {
+		return Forbidden("You can't delete this file")
+	}
+
+	if err := m.Destroy(); err != nil {
+		return InternalError(err)
+	}
+	if mediaStore != nil {
+		if err := mediaStore.Delete(m.Name()); err != nil {
+			log.Printf("MediaController couldn't delete %s: %v", m.Name(), err)
+		}
+	}
+	if thumbnails != nil {
+		if err := thumbnails.Forget(m.Name()); err != nil {
+			log.Printf("MediaController couldn't delete the variants of %s: %v", m.Name(), err)
+		}
+	}
+	auth.AddFlash(rw, req, "The file is deleted.")
+
+	http.Redirect(rw, req, "/media", http.StatusFound)
+	return nil
+}
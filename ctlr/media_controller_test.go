@@ -0,0 +1,59 @@
+package ctlr
+
+import (
+	"bytes"
+	"github.com/aybabtme/goblog/media"
+	"github.com/gorilla/mux"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestMediaVariants(t *testing.T) {
+	dir, err := os.MkdirTemp("", "goblog-media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, _ := media.NewLocalStore(dir+"/uploads", "/media/")
+	resizer, _ := media.NewThumbnailer(store, dir+"/cache")
+	defer SetupMedia(mediaStore, mediaMaxSize, thumbnails)
+	SetupMedia(store, 1<<20, resizer)
+
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 400, 100)))
+	name, _, _, err := media.Upload(store, "banner.png", &buf, 1<<20)
+	if err != nil {
+		t.Fatal("Couldn't upload", err)
+	}
+	gif, _, _, _ := media.Upload(store, "dance.gif", bytes.NewReader([]byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;")), 1<<20)
+
+	serve := func(size, name string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(newRequest("GET", "/media/"+size+"/"+name), map[string]string{"size": size, "name": name})
+		rec := httptest.NewRecorder()
+		NewMediaVariantController().Controller(nil)(rec, req)
+		return rec
+	}
+
+	rec := serve("thumb", name)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("Expected the thumbnail, was %d %v", rec.Code, rec.Header())
+	}
+	config, err := png.DecodeConfig(rec.Body)
+	if err != nil || config.Width != 200 || config.Height != 50 {
+		t.Errorf("Expected a 200x50 thumbnail, was %+v, %v", config, err)
+	}
+
+	if rec := serve("thumb", gif); rec.Code != http.StatusFound || rec.Header().Get("Location") != "/media/"+gif {
+		t.Errorf("Expected GIFs at their own URL, was %d %v", rec.Code, rec.Header())
+	}
+	if rec := serve("thumb", "no-such-file.png"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected a 404 for missing pictures, was %d", rec.Code)
+	}
+	if rec := serve("huge", name); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected a 404 for unknown sizes, was %d", rec.Code)
+	}
+}
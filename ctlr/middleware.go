@@ -0,0 +1,342 @@
+package ctlr
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"github.com/aybabtme/goblog/auth"
+	"github.com/aybabtme/goblog/model"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Middleware does something around the handling of every request it wraps.
+type Middleware func(http.HandlerFunc) http.HandlerFunc
+
+// Chain wraps h in the middlewares, the first one being the outermost.
+func Chain(h http.HandlerFunc, middlewares ...Middleware) http.HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// Pipeline is what the router wraps every controller in, before the
+// middlewares of the controller itself.
+func Pipeline(conn *model.DBConnection) []Middleware {
+	return []Middleware{
+		LogRequests,
+		Recover,
+		Timing,
+		Gzip,
+		LimitBody,
+		LoadAuth(conn),
+		CheckCSRF,
+	}
+}
+
+// Handler is the handler of c, wrapped in the pipeline, in the check of the
+// scope of API tokens and in the middlewares of c.
+func Handler(conn *model.DBConnection, c Controller, pipeline []Middleware) http.HandlerFunc {
+	scope := ""
+	if scoped, ok := c.(Scoped); ok {
+		scope = scoped.Scope()
+	}
+	middlewares := append(append([]Middleware{}, pipeline...), RequireScope(scope))
+	middlewares = append(middlewares, c.Middlewares()...)
+	return Chain(c.Controller(conn), middlewares...)
+}
+
+/*
+ * Middlewares
+ */
+
+// LogRequests logs every request once it's answered, with its request id.
+func LogRequests(h http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: rw, status: http.StatusOK}
+		h(rec, req)
+		log.Printf("[%s] %s %s %d %dB %v", RequestId(req), req.Method, req.URL.Path,
+			rec.status, rec.size, time.Since(start))
+	}
+}
+
+// Recover answers with a 500 instead of dropping the connection when a
+// controller panics.
+func Recover(h http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("[%s] panic: %v\n%s", RequestId(req), r, debug.Stack())
+				RenderError(rw, req, InternalError(fmt.Errorf("panic: %v", r)))
+			}
+		}()
+		h(rw, req)
+	}
+}
+
+// Timing tells how long the request took in a Server-Timing header.
+func Timing(h http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		h(&timingWriter{ResponseWriter: rw, start: time.Now()}, req)
+	}
+}
+
+// Gzip compresses the answer for clients that accept it.
+func Gzip(h http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if !strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") {
+			h(rw, req)
+			return
+		}
+		rw.Header().Add("Vary", "Accept-Encoding")
+		gz := &gzipWriter{ResponseWriter: rw}
+		defer gz.Close()
+		h(gz, req)
+	}
+}
+
+// LimitBody refuses bodies larger than the largest upload allows, before
+// anything, like the check of CSRF tokens, reads them.
+func LimitBody(h http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		limit := maxBodySize()
+		if req.ContentLength > limit {
+			RenderError(rw, req, &Error{http.StatusRequestEntityTooLarge, "This is too large to be sent", nil})
+			return
+		}
+		req.Body = http.MaxBytesReader(rw, req.Body, limit)
+		h(rw, req)
+	}
+}
+
+// LoadAuth logs the user in, and keeps them in the request for CurrentUser
+// and CurrentAuthor.  Requests with an `Authorization: Bearer` header are
+// logged in by their API token rather than by their session, and refused if
+// the token can't be used.
+func LoadAuth(conn *model.DBConnection) Middleware {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(rw http.ResponseWriter, req *http.Request) {
+			user, token, err := auth.TokenLogin(conn, req)
+			var author *model.Author
+			switch err {
+			case auth.ErrNoToken:
+				user, author = auth.Login(conn, rw, req)
+			case nil:
+				author = auth.AuthorOf(conn, user)
+			default:
+				RenderError(rw, req, Unauthorized(rw, "Invalid or expired API token"))
+				return
+			}
+			ctx := context.WithValue(req.Context(), userKey, user)
+			ctx = context.WithValue(ctx, authorKey, author)
+			ctx = context.WithValue(ctx, tokenKey, token)
+			h(rw, req.WithContext(ctx))
+		}
+	}
+}
+
+// CheckCSRF refuses requests that may change something unless they carry
+// the CSRF token of their session.  Requests logged in by an API token have
+// no session to forge, and are checked by RequireScope instead.
+func CheckCSRF(h http.HandlerFunc) http.HandlerFunc {
+	checked := auth.CheckCSRF(h)
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if CurrentToken(req) != nil {
+			h(rw, req)
+			return
+		}
+		checked(rw, req)
+	}
+}
+
+// RequireScope refuses requests logged in by an API token unless the token
+// may read the resource, or write it for requests that may change something.
+// Tokens are refused altogether without a resource.
+func RequireScope(resource string) Middleware {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(rw http.ResponseWriter, req *http.Request) {
+			token := CurrentToken(req)
+			if token == nil {
+				h(rw, req)
+				return
+			}
+			if resource == "" {
+				RenderError(rw, req, Forbidden("API tokens can't be used here"))
+				return
+			}
+			scope := resource + ":write"
+			switch req.Method {
+			case "GET", "HEAD", "OPTIONS":
+				scope = resource + ":read"
+			}
+			if !auth.HasScope(token, scope) {
+				RenderError(rw, req, Forbidden("This API token lacks the "+scope+" scope"))
+				return
+			}
+			h(rw, req)
+		}
+	}
+}
+
+// JSONErrors makes RenderError answer with a JSON body instead of the error
+// page, for clients of the API.  It goes before the pipeline, so that the
+// refusals of the pipeline are in JSON as well.
+func JSONErrors(h http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		ctx := context.WithValue(req.Context(), jsonErrorsKey, true)
+		h(rw, req.WithContext(ctx))
+	}
+}
+
+// RequireLogin refuses anonymous visitors.
+func RequireLogin(message string) Middleware {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(rw http.ResponseWriter, req *http.Request) {
+			if CurrentUser(req) == nil {
+				RenderError(rw, req, Forbidden(message))
+				return
+			}
+			h(rw, req)
+		}
+	}
+}
+
+// Require refuses users who can't do action on resource, as auth.Can
+// tells.
+func Require(action auth.Action, resource interface{}, message string) Middleware {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(rw http.ResponseWriter, req *http.Request) {
+			if !auth.Can(CurrentUser(req), action, resource) {
+				RenderError(rw, req, Forbidden(message))
+				return
+			}
+			h(rw, req)
+		}
+	}
+}
+
+/*
+ * Helpers
+ */
+
+type contextKey int
+
+const (
+	userKey contextKey = iota
+	authorKey
+	tokenKey
+	jsonErrorsKey
+)
+
+// CurrentUser is the user LoadAuth logged in, nil for visitors.
+func CurrentUser(req *http.Request) *model.User {
+	user, _ := req.Context().Value(userKey).(*model.User)
+	return user
+}
+
+// CurrentAuthor is the author LoadAuth logged in, nil unless the user may
+// write posts.
+func CurrentAuthor(req *http.Request) *model.Author {
+	author, _ := req.Context().Value(authorKey).(*model.Author)
+	return author
+}
+
+// CurrentToken is the API token LoadAuth logged the user in with, nil for
+// requests logged in by their session.
+func CurrentToken(req *http.Request) *model.ApiToken {
+	token, _ := req.Context().Value(tokenKey).(*model.ApiToken)
+	return token
+}
+
+func wantsJSONErrors(req *http.Request) bool {
+	wants, _ := req.Context().Value(jsonErrorsKey).(bool)
+	return wants
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	n, err := s.ResponseWriter.Write(b)
+	s.size += n
+	return n, err
+}
+
+type timingWriter struct {
+	http.ResponseWriter
+	start       time.Time
+	wroteHeader bool
+}
+
+func (t *timingWriter) WriteHeader(status int) {
+	if !t.wroteHeader {
+		t.wroteHeader = true
+		elapsed := float64(time.Since(t.start)) / float64(time.Millisecond)
+		t.Header().Set("Server-Timing", fmt.Sprintf("app;dur=%.1f", elapsed))
+	}
+	t.ResponseWriter.WriteHeader(status)
+}
+
+func (t *timingWriter) Write(b []byte) (int, error) {
+	if !t.wroteHeader {
+		t.WriteHeader(http.StatusOK)
+	}
+	return t.ResponseWriter.Write(b)
+}
+
+type gzipWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (g *gzipWriter) WriteHeader(status int) {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader = true
+	// Nothing to compress
+	if status == http.StatusNoContent || status == http.StatusNotModified ||
+		g.Header().Get("Content-Encoding") != "" {
+		g.ResponseWriter.WriteHeader(status)
+		return
+	}
+	g.Header().Del("Content-Length")
+	g.Header().Set("Content-Encoding", "gzip")
+	g.gz = gzip.NewWriter(g.ResponseWriter)
+	g.ResponseWriter.WriteHeader(status)
+}
+
+func (g *gzipWriter) Write(b []byte) (int, error) {
+	if !g.wroteHeader {
+		// sniff the type on the plain content, not the compressed one
+		if g.Header().Get("Content-Type") == "" {
+			g.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.gz == nil {
+		return g.ResponseWriter.Write(b)
+	}
+	return g.gz.Write(b)
+}
+
+func (g *gzipWriter) Close() error {
+	if g.gz == nil {
+		return nil
+	}
+	return g.gz.Close()
+}
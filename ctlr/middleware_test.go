@@ -0,0 +1,258 @@
+package ctlr
+
+import (
+	"compress/gzip"
+	"context"
+	"github.com/aybabtme/goblog/auth"
+	"github.com/aybabtme/goblog/model"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	mark := func(name string) Middleware {
+		return func(h http.HandlerFunc) http.HandlerFunc {
+			return func(rw http.ResponseWriter, req *http.Request) {
+				calls = append(calls, name)
+				h(rw, req)
+			}
+		}
+	}
+	h := Chain(func(rw http.ResponseWriter, req *http.Request) {
+		calls = append(calls, "controller")
+	}, mark("first"), mark("second"))
+
+	h(httptest.NewRecorder(), newRequest("GET", "/"))
+	if strings.Join(calls, ",") != "first,second,controller" {
+		t.Errorf("Unexpected order <%v>", calls)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	h := Chain(func(rw http.ResponseWriter, req *http.Request) {
+		panic("boom")
+	}, Recover)
+
+	rec := httptest.NewRecorder()
+	h(rec, newRequest("GET", "/"))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d but was %d", http.StatusInternalServerError, rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "boom") {
+		t.Error("The panic shouldn't be shown to visitors")
+	}
+}
+
+func TestGzip(t *testing.T) {
+	content := strings.Repeat("<p>Hello, gzip!</p>", 100)
+	h := Chain(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(content))
+	}, Gzip)
+
+	// Clients that don't ask for it get plain content
+	rec := httptest.NewRecorder()
+	h(rec, newRequest("GET", "/"))
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != content {
+		t.Error("Expected plain content")
+	}
+
+	req := newRequest("GET", "/")
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	rec = httptest.NewRecorder()
+	h(rec, req)
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("Expected gzip content")
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("Expected type sniffed from the plain content, was <%s>", rec.Header().Get("Content-Type"))
+	}
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal("Couldn't read gzip content", err)
+	}
+	plain, _ := ioutil.ReadAll(gz)
+	if string(plain) != content {
+		t.Errorf("Unexpected content <%s>", plain)
+	}
+}
+
+func TestTiming(t *testing.T) {
+	h := Chain(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("ok"))
+	}, Timing)
+
+	rec := httptest.NewRecorder()
+	h(rec, newRequest("GET", "/"))
+	if !strings.HasPrefix(rec.Header().Get("Server-Timing"), "app;dur=") {
+		t.Errorf("Unexpected Server-Timing <%s>", rec.Header().Get("Server-Timing"))
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	defer SetupMedia(mediaStore, mediaMaxSize, thumbnails)
+	SetupMedia(nil, 10, nil)
+
+	var read []byte
+	var readErr error
+	h := Chain(func(rw http.ResponseWriter, req *http.Request) {
+		read, readErr = ioutil.ReadAll(req.Body)
+	}, LimitBody)
+
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest("POST", "/media", strings.NewReader("small")))
+	if readErr != nil || string(read) != "small" {
+		t.Errorf("Expected the body, was <%s>, %v", read, readErr)
+	}
+
+	tooLarge := strings.Repeat("x", int(maxBodySize())+1)
+	rec = httptest.NewRecorder()
+	h(rec, httptest.NewRequest("POST", "/media", strings.NewReader(tooLarge)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d but was %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+
+	// bodies whose size isn't told are cut short
+	req := httptest.NewRequest("POST", "/media", strings.NewReader(tooLarge))
+	req.ContentLength = -1
+	h(httptest.NewRecorder(), req)
+	if readErr == nil {
+		t.Error("Expected the body to be cut short")
+	}
+}
+
+func TestLogRequestsKeepsStatus(t *testing.T) {
+	h := Chain(func(rw http.ResponseWriter, req *http.Request) {
+		http.Error(rw, "teapot", http.StatusTeapot)
+	}, LogRequests)
+
+	rec := httptest.NewRecorder()
+	h(rec, newRequest("GET", "/"))
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("Expected status %d but was %d", http.StatusTeapot, rec.Code)
+	}
+}
+
+func TestRequirementsRefuseVisitors(t *testing.T) {
+	called := false
+	controller := func(rw http.ResponseWriter, req *http.Request) {
+		called = true
+	}
+
+	middlewares := []Middleware{
+		RequireLogin("Log in first"),
+		Require(auth.Update, (*model.User)(nil), "Only admins"),
+	}
+	for i, m := range middlewares {
+		called = false
+		rec := httptest.NewRecorder()
+		Chain(controller, LoadAuth(nil), m)(rec, newRequest("GET", "/admin/users"))
+		if rec.Code != http.StatusForbidden || called {
+			t.Errorf("Case #%d, expected a 403 but was %d, called <%v>", i, rec.Code, called)
+		}
+	}
+
+	// Reading is for everyone
+	called = false
+	rec := httptest.NewRecorder()
+	Chain(controller, LoadAuth(nil), Require(auth.Read, (*model.Post)(nil), "No"))(rec, newRequest("GET", "/"))
+	if !called {
+		t.Errorf("Visitors should read posts, was %d", rec.Code)
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	var conn *model.DBConnection
+	token := conn.NewApiToken(1, "CI", "", []string{"posts:write"}, time.Now(), time.Time{})
+
+	cases := []struct {
+		resource string
+		method   string
+		token    *model.ApiToken
+		called   bool
+	}{
+		// sessions aren't scoped
+		{"", "POST", nil, true},
+		{"comments", "POST", nil, true},
+		{"posts", "POST", token, true},
+		{"posts", "GET", token, true},
+		{"comments", "GET", token, false},
+		{"comments", "POST", token, false},
+		// controllers without a scope refuse tokens
+		{"", "GET", token, false},
+	}
+
+	for i, c := range cases {
+		called := false
+		h := RequireScope(c.resource)(func(rw http.ResponseWriter, req *http.Request) {
+			called = true
+		})
+		rec := httptest.NewRecorder()
+		h(rec, withToken(newRequest(c.method, "/api/v1/"+c.resource), c.token))
+
+		if called != c.called {
+			t.Errorf("Case #%d, expected called <%v> but was <%v>", i, c.called, called)
+		}
+		if !called && rec.Code != http.StatusForbidden {
+			t.Errorf("Case #%d, expected a 403 but was %d", i, rec.Code)
+		}
+	}
+}
+
+func TestTokensSkipCSRF(t *testing.T) {
+	var conn *model.DBConnection
+	token := conn.NewApiToken(1, "CI", "", []string{"posts:write"}, time.Now(), time.Time{})
+
+	called := false
+	h := CheckCSRF(func(rw http.ResponseWriter, req *http.Request) {
+		called = true
+	})
+
+	rec := httptest.NewRecorder()
+	h(rec, withToken(newRequest("POST", "/api/v1/posts"), nil))
+	if called || rec.Code != http.StatusForbidden {
+		t.Errorf("Sessions need a CSRF token, was %d", rec.Code)
+	}
+
+	h(httptest.NewRecorder(), withToken(newRequest("POST", "/api/v1/posts"), token))
+	if !called {
+		t.Error("Requests with an API token have no CSRF token to send")
+	}
+}
+
+func TestPageContext(t *testing.T) {
+	defer SetupSite(site)
+	SetupSite(Site{Description: "About Go"})
+
+	var page PageContext
+	h := Chain(func(rw http.ResponseWriter, req *http.Request) {
+		page = NewPageContext(rw, req)
+	}, LoadAuth(nil))
+	rec := httptest.NewRecorder()
+	h(rec, newRequest("GET", "/"))
+
+	if page.CurrentUser != nil || page.CurrentAuthor != nil {
+		t.Error("Visitors have no user")
+	}
+	if page.Site.Title != "Go Blog" || page.Site.Description != "About Go" {
+		t.Errorf("Unexpected site %#v", page.Site)
+	}
+	// they can't change anything, they get no session for reading a page
+	if page.CSRFToken != "" || rec.Header().Get("Set-Cookie") != "" {
+		t.Errorf("Expected no session for visitors, was %q", rec.Header().Get("Set-Cookie"))
+	}
+}
+
+func newRequest(method, path string) *http.Request {
+	req, _ := http.NewRequest(method, path, nil)
+	return req
+}
+
+// The request, as if LoadAuth logged it in with the token.
+func withToken(req *http.Request, token *model.ApiToken) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), tokenKey, token))
+}
@@ -0,0 +1,87 @@
+package ctlr
+
+import (
+	"github.com/aybabtme/goblog/auth"
+	"github.com/aybabtme/goblog/model"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Site is what's configured about the blog as a whole.
+type Site struct {
+	Title       string
+	Description string
+	// where the blog is, i.e. "https://blog.example.com", for the links
+	// leaving the blog like those of feeds
+	URL string
+	// more rules for robots.txt, after those keeping crawlers out of forms
+	// and accounts
+	Robots string
+}
+
+var site = Site{Title: "Go Blog"}
+
+// SetupSite sets what pages tell about the blog.
+func SetupSite(s Site) {
+	if s.Title == "" {
+		s.Title = "Go Blog"
+	}
+	s.URL = strings.TrimSuffix(s.URL, "/")
+	site = s
+}
+
+// PageContext is what every template gets, embedded in the data of the
+// page: who's logged in, the site, the messages left for the user by the
+// previous pages and the CSRF token for the forms.
+type PageContext struct {
+	CurrentUser   *model.User
+	CurrentAuthor *model.Author
+	Site          Site
+	Flashes       []string
+	CSRFToken     string
+	// the feeds following the page
+	Alternates []Alternate
+}
+
+// Alternate is another version of a page, like a feed.
+type Alternate struct {
+	Type  string
+	Title string
+	Href  string
+}
+
+func NewPageContext(rw http.ResponseWriter, req *http.Request) PageContext {
+	return PageContext{
+		CurrentUser:   CurrentUser(req),
+		CurrentAuthor: CurrentAuthor(req),
+		Site:          site,
+		Flashes:       auth.Flashes(rw, req),
+		CSRFToken:     auth.CSRFToken(rw, req),
+		Alternates:    feedAlternates("", site.Title),
+	}
+}
+
+// BaseURL is the URL of the blog, as configured or else as requested.
+func BaseURL(req *http.Request) string {
+	if site.URL != "" {
+		return site.URL
+	}
+	scheme := "http"
+	if req.TLS != nil || req.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + req.Host
+}
+
+// Lets a page built with BaseURL be cached for maxAge seconds.  Guessed
+// from the request, the URL could come from a forged Host, so then only
+// the browser that asked keeps the page, not the caches shared by all.
+func cacheBaseURL(rw http.ResponseWriter, maxAge int) {
+	if site.URL == "" {
+		rw.Header().Add("Vary", "Host, X-Forwarded-Proto")
+		rw.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(maxAge))
+		return
+	}
+	rw.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(maxAge))
+}
@@ -9,13 +9,12 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
-	"text/template"
 	"time"
 )
 
 type post struct {
 	path string
-	view *template.Template
+	view *view.Page
 }
 
 func NewPostController() Controller {
@@ -78,8 +77,19 @@ func (p post) Path() string {
 	return p.path
 }
 
+func (p post) Scope() string {
+	if p.path == "/post/comment/{commentId:[0-9]+}" {
+		return "comments"
+	}
+	return "posts"
+}
+
+func (p post) Middlewares() []Middleware {
+	return nil
+}
+
 func (p post) Controller(conn *model.DBConnection) func(http.ResponseWriter, *http.Request) {
-	return func(rw http.ResponseWriter, req *http.Request) {
+	return handle(func(rw http.ResponseWriter, req *http.Request) error {
 		vars := mux.Vars(req)
 		id := vars["id"]
 		destroyId := vars["destroyId"]
@@ -87,134 +97,151 @@ func (p post) Controller(conn *model.DBConnection) func(http.ResponseWriter, *ht
 		editId := vars["editId"]
 		saveId := vars["saveId"]
 
+		// Changes go through POST, where CSRF tokens are checked
+		if p.path == "/post/save" || saveId != "" || commentId != "" {
+			if req.Method != "POST" {
+				return MethodNotAllowed(rw, "POST")
+			}
+		}
+
 		if p.path == "/post/compose" {
-			p.forCompose(conn, rw, req)
+			return p.forCompose(conn, rw, req)
 		} else if p.path == "/post/save" {
-			p.forSave(conn, rw, req)
+			return p.forSave(conn, rw, req)
 		} else if commentId != "" {
-			p.forComment(conn, rw, req, commentId)
+			return p.forComment(conn, rw, req, commentId)
 		} else if destroyId != "" {
-			p.forDestroy(conn, rw, req, destroyId)
+			return p.forDestroy(conn, rw, req, destroyId)
 		} else if editId != "" {
-			p.forEdit(conn, rw, req, editId)
+			return p.forEdit(conn, rw, req, editId)
 		} else if saveId != "" {
-			p.forUpdate(conn, rw, req, saveId)
+			return p.forUpdate(conn, rw, req, saveId)
 		} else if id == "" {
-			p.forListing(conn, rw, req)
-		} else {
-			p.forId(conn, rw, req, id)
+			return p.forListing(conn, rw, req)
 		}
-	}
+		return p.forId(conn, rw, req, id)
+	})
 }
 
 func (p *post) forListing(conn *model.DBConnection,
 	rw http.ResponseWriter,
-	req *http.Request) {
+	req *http.Request) error {
+
+	if !auth.Can(CurrentUser(req), auth.Read, (*model.Post)(nil)) {
+		return Forbidden("You can't read posts")
+	}
 
 	posts, err := conn.FindAllPosts()
 	if err != nil {
-		log.Println("PostController for listing 1:", err)
-		return
+		return InternalError(err)
 	}
 
-	currentUser, currentAuthor := auth.Login(conn, rw, req)
-
 	data := struct {
-		CurrentAuthor *model.Author
-		CurrentUser   *model.User
-		Posts         []model.Post
+		PageContext
+		Posts []model.Post
 	}{
-		currentAuthor,
-		currentUser,
+		NewPageContext(rw, req),
 		posts,
 	}
 
 	if err := p.view.Execute(rw, data); nil != err {
 		log.Println("PostController for listing 2:", err)
-		return
 	}
-
+	return nil
 }
 
 func (p *post) forId(conn *model.DBConnection,
 	rw http.ResponseWriter,
 	req *http.Request,
-	id string) {
+	id string) error {
 
 	intId, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
-		log.Println("PostController for id 1:", err)
-		return
+		return BadRequest("That's not a post id", err)
 	}
 
-	currentUser, currentAuthor := auth.Login(conn, rw, req)
+	currentUser := CurrentUser(req)
 
 	post, err := conn.FindPostById(intId)
+	if err != nil {
+		return NotFoundOr("There's no such post", err)
+	}
+
+	if !auth.Can(currentUser, auth.Read, post) {
+		return Forbidden("You can't read this post")
+	}
 
 	data := struct {
-		CurrentAuthor *model.Author
-		CurrentUser   *model.User
-		Post          *model.Post
+		PageContext
+		Post       *model.Post
+		CanEdit    bool
+		CanDelete  bool
+		CanComment bool
 	}{
-		currentAuthor,
-		currentUser,
+		NewPageContext(rw, req),
 		post,
+		auth.Can(currentUser, auth.Update, post),
+		auth.Can(currentUser, auth.Delete, post),
+		currentUser != nil && auth.Can(currentUser, auth.Create, (*model.Comment)(nil)),
 	}
 
 	if err := p.view.Execute(rw, data); nil != err {
 		log.Println("PostController for id 3:", err)
-		return
 	}
-
+	return nil
 }
 
 func (p *post) forCompose(conn *model.DBConnection,
 	rw http.ResponseWriter,
-	req *http.Request) {
+	req *http.Request) error {
 
 	labels, err := conn.FindAllLabels()
 	if err != nil {
 		log.Println("Couldn't find previous labels for autosuggestion")
 	}
 
-	currentUser, currentAuthor := auth.Login(conn, rw, req)
+	page := NewPageContext(rw, req)
+
+	// The template explains to users without an author why they
+	// can't compose.
+	if !auth.Can(page.CurrentUser, auth.Create, (*model.Post)(nil)) {
+		page.CurrentAuthor = nil
+	}
 
 	data := struct {
-		CurrentAuthor *model.Author
-		CurrentUser   *model.User
-		Labels        []model.Label
-		Post          *model.Post
+		PageContext
+		Labels []model.Label
+		Post   *model.Post
+		Media  []model.Media
 	}{
-		currentAuthor,
-		currentUser,
+		page,
 		labels,
 		nil,
+		pickableMedia(conn),
 	}
 
 	if err := p.view.Execute(rw, data); nil != err {
-		log.Println("PostController for listing 2:", err)
-		return
+		log.Println("PostController for compose:", err)
 	}
+	return nil
 }
 
 func (p *post) forEdit(conn *model.DBConnection,
 	rw http.ResponseWriter,
 	req *http.Request,
-	id string) {
-
-	currentUser, currentAuthor := auth.Login(conn, rw, req)
+	id string) error {
 
-	if currentAuthor == nil {
-		http.Error(rw, "/post/"+id, http.StatusForbidden)
-		return
+	postId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return BadRequest("That's not a post id", err)
 	}
-
-	postId, _ := strconv.ParseInt(id, 10, 64)
 	post, err := conn.FindPostById(postId)
-	if err != nil || post == nil {
-		log.Println("Can't edit, post doesn't exist")
-		http.Error(rw, "/post/"+id, http.StatusBadRequest)
-		return
+	if err != nil {
+		return NotFoundOr("There's no such post to edit", err)
+	}
+
+	if !auth.Can(CurrentUser(req), auth.Update, post) {
+		return Forbidden("You can't edit this post")
 	}
 
 	labels, err := conn.FindAllLabels()
@@ -223,78 +250,70 @@ func (p *post) forEdit(conn *model.DBConnection,
 	}
 
 	data := struct {
-		CurrentAuthor *model.Author
-		CurrentUser   *model.User
-		Labels        []model.Label
-		Post          *model.Post
+		PageContext
+		Labels []model.Label
+		Post   *model.Post
+		Media  []model.Media
 	}{
-		currentAuthor,
-		currentUser,
+		NewPageContext(rw, req),
 		labels,
 		post,
+		pickableMedia(conn),
 	}
 
 	if err := p.view.Execute(rw, data); nil != err {
-		log.Println("PostController for listing 2:", err)
-		return
+		log.Println("PostController for edit:", err)
 	}
+	return nil
 }
 
 func (p *post) forSave(conn *model.DBConnection,
 	rw http.ResponseWriter,
-	req *http.Request) {
+	req *http.Request) error {
 
 	title := strings.Title(req.FormValue("title"))
 	imageUrl := req.FormValue("imageUrl")
 	content := req.FormValue("content")
 	labelString := req.FormValue("label_list")
 
-	currentUser, currentAuthor := auth.Login(conn, rw, req)
+	currentUser, currentAuthor := CurrentUser(req), CurrentAuthor(req)
 
-	if currentUser == nil {
-		http.Redirect(rw, req, "/", http.StatusForbidden)
-		return
-	}
-
-	if currentAuthor == nil {
-		http.Redirect(rw, req, "/", http.StatusForbidden)
-		return
+	if currentAuthor == nil || !auth.Can(currentUser, auth.Create, (*model.Post)(nil)) {
+		return Forbidden("You can't write posts")
 	}
 
 	post := conn.NewPost(currentAuthor, title, content, imageUrl, time.Now().UTC())
 	if err := post.Save(); err != nil {
-		log.Println("Couldn't save post", err)
-		return
+		return InternalError(err)
 	}
 
-	for _, label := range strings.Split(labelString, ",") {
-		if _, err := post.AddLabel(label); err != nil {
-			log.Printf("Couldn't add label <%s> to Post id<%d>\n", label, post.Id())
-		}
-	}
+	addLabels(currentUser, post, labelString)
+	auth.AddFlash(rw, req, "Your post is published.")
 
 	id := strconv.FormatInt(post.Id(), 10)
 	http.Redirect(rw, req, "/post/"+id, http.StatusFound)
+	return nil
 }
 
 func (p *post) forUpdate(conn *model.DBConnection,
 	rw http.ResponseWriter,
 	req *http.Request,
-	postId string) {
+	postId string) error {
 
-	_, currentAuthor := auth.Login(conn, rw, req)
+	currentUser := CurrentUser(req)
 
-	if currentAuthor == nil {
-		http.Redirect(rw, req, "/post/"+postId, http.StatusForbidden)
-		return
+	id, err := strconv.ParseInt(postId, 10, 64)
+	if err != nil {
+		return BadRequest("That's not a post id", err)
 	}
 
-	id, _ := strconv.ParseInt(postId, 10, 64)
-
 	post, err := conn.FindPostById(id)
 	if err != nil {
-		http.Redirect(rw, req, "/post/"+postId, http.StatusForbidden)
-		return
+		return NotFoundOr("There's no such post to update", err)
+	}
+
+	if !auth.Can(currentUser, auth.Update, post) {
+		return Forbidden("You can't edit this post")
 	}
 
 	title := strings.Title(req.FormValue("title"))
@@ -304,43 +323,36 @@ func (p *post) forUpdate(conn *model.DBConnection,
 
 	post.SetTitle(title)
 	post.SetImageURL(imageUrl)
-	post.SetDate(time.Now().UTC())
 	post.SetContent(content)
 	if err := post.Update(); err != nil {
-		log.Println("Couldn't update post", err)
-		return
+		return InternalError(err)
 	}
 
-	for _, label := range strings.Split(labelString, ",") {
-		if _, err := post.AddLabel(label); err != nil {
-			log.Printf("Couldn't add label <%s> to Post id<%d>\n", label, post.Id())
-			log.Println(err)
-		}
-	}
+	addLabels(currentUser, post, labelString)
+	auth.AddFlash(rw, req, "Your changes are saved.")
 
 	http.Redirect(rw, req, "/post/"+postId, http.StatusFound)
+	return nil
 }
 
 func (p *post) forComment(conn *model.DBConnection,
 	rw http.ResponseWriter,
 	req *http.Request,
-	id string) {
+	id string) error {
 
 	content := req.FormValue("content")
 
-	currentUser, _ := auth.Login(conn, rw, req)
-	if currentUser == nil {
-		http.Redirect(rw, req, "/", http.StatusForbidden)
-		return
+	currentUser := CurrentUser(req)
+	if currentUser == nil || !auth.Can(currentUser, auth.Create, (*model.Comment)(nil)) {
+		return Forbidden("You can't comment")
 	}
 
-	postId, _ := strconv.ParseInt(id, 10, 64)
-	post, err := conn.FindPostById(postId)
-	if err != nil || post == nil {
-		log.Printf("Post id<%d> doesn't exist", postId)
-		log.Println(err)
-		http.Redirect(rw, req, "/", http.StatusForbidden)
-		return
+	postId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return BadRequest("That's not a post id", err)
+	}
+	if _, err := conn.FindPostById(postId); err != nil {
+		return NotFoundOr("There's no such post to comment on", err)
 	}
 
 	comment := conn.NewComment(currentUser.Id(),
@@ -349,44 +361,88 @@ func (p *post) forComment(conn *model.DBConnection,
 		time.Now().UTC())
 
 	if err := comment.Save(); err != nil {
-		log.Printf("Error saving comment on post id<%d>\n", postId)
-		log.Println(err)
-		http.Error(rw, "/", http.StatusInternalServerError)
-		return
+		return InternalError(err)
 	}
+	auth.AddFlash(rw, req, "Your comment is posted.")
 
 	http.Redirect(rw, req, "/post/"+id, http.StatusFound)
-
+	return nil
 }
 
 func (p *post) forDestroy(conn *model.DBConnection,
 	rw http.ResponseWriter,
 	req *http.Request,
-	id string) {
+	id string) error {
 
 	intId, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
-		log.Println("PostController for id 1:", err)
-		return
+		return BadRequest("That's not a post id", err)
 	}
 
-	currentUser, currentAuthor := auth.Login(conn, rw, req)
-	if currentUser == nil {
-		http.Redirect(rw, req, "/post/"+id, http.StatusForbidden)
-		return
+	post, err := conn.FindPostById(intId)
+	if err != nil {
+		return NotFoundOr("There's no such post to delete", err)
 	}
 
-	if currentAuthor == nil {
-		http.Redirect(rw, req, "/post/"+id, http.StatusForbidden)
-		return
+	if !auth.Can(CurrentUser(req), auth.Delete, post) {
+		return Forbidden("You can't delete this post")
 	}
 
-	post, err := conn.FindPostById(intId)
+	// Ask for confirmation, the deletion itself needs a POST
+	if req.Method != "POST" {
+		data := struct {
+			PageContext
+			Post *model.Post
+		}{
+			NewPageContext(rw, req),
+			post,
+		}
+
+		if err := p.view.Execute(rw, data); nil != err {
+			log.Println("PostController for destroy:", err)
+		}
+		return nil
+	}
 
 	if err := post.Destroy(); err != nil {
-		log.Println("Couldn't delete post:", err)
+		return InternalError(err)
 	}
+	auth.AddFlash(rw, req, "The post is deleted.")
 
 	http.Redirect(rw, req, "/", http.StatusFound)
+	return nil
+}
+
+// The pictures the picker of the compose page offers, none if the blog
+// doesn't take uploads.
+func pickableMedia(conn *model.DBConnection) []model.Media {
+	if mediaStore == nil {
+		return nil
+	}
+	all, err := conn.FindAllMedia()
+	if err != nil {
+		log.Println("Couldn't find media for the picker")
+		return nil
+	}
+	var images []model.Media
+	for _, m := range all {
+		if m.IsImage() {
+			images = append(images, m)
+		}
+	}
+	return images
+}
 
+// Tags the post with the comma separated labels, if the user may create
+// labels.
+func addLabels(user *model.User, post *model.Post, labelString string) {
+	if !auth.Can(user, auth.Create, (*model.Label)(nil)) {
+		return
+	}
+	for _, label := range strings.Split(labelString, ",") {
+		if _, err := post.AddLabel(label); err != nil {
+			log.Printf("Couldn't add label <%s> to Post id<%d>\n", label, post.Id())
+			log.Println(err)
+		}
+	}
 }
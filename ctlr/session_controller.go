@@ -0,0 +1,99 @@
+package ctlr
+
+import (
+	"github.com/aybabtme/goblog/auth"
+	"github.com/aybabtme/goblog/model"
+	"github.com/aybabtme/goblog/view"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+)
+
+type session struct {
+	path string
+	view *view.Page
+}
+
+func NewSessionListController() Controller {
+	var s session
+	s.path = "/sessions"
+	s.view = view.GetSessionListTemplate()
+	return s
+}
+
+func NewSessionRevokeController() Controller {
+	var s session
+	s.path = "/sessions/revoke/{revokeId:[0-9a-f]+|others}"
+	return s
+}
+
+func (s session) Path() string {
+	return s.path
+}
+
+func (s session) Middlewares() []Middleware {
+	return []Middleware{RequireLogin("You need to log in to see your sessions")}
+}
+
+func (s session) Controller(conn *model.DBConnection) func(http.ResponseWriter, *http.Request) {
+	return handle(func(rw http.ResponseWriter, req *http.Request) error {
+		currentUser := CurrentUser(req)
+
+		revokeId := mux.Vars(req)["revokeId"]
+		if revokeId != "" {
+			return s.forRevoke(conn, rw, req, currentUser, revokeId)
+		}
+		return s.forListing(conn, rw, req, currentUser)
+	})
+}
+
+func (s *session) forListing(conn *model.DBConnection,
+	rw http.ResponseWriter,
+	req *http.Request,
+	currentUser *model.User) error {
+
+	sessions, err := conn.FindSessionsByUserId(currentUser.Id())
+	if err != nil {
+		return InternalError(err)
+	}
+
+	data := struct {
+		PageContext
+		Sessions         []model.Session
+		CurrentSessionId string
+	}{
+		NewPageContext(rw, req),
+		sessions,
+		auth.CurrentSessionId(req),
+	}
+
+	if err := s.view.Execute(rw, data); nil != err {
+		log.Println("SessionController for listing, execute:", err)
+	}
+	return nil
+}
+
+func (s *session) forRevoke(conn *model.DBConnection,
+	rw http.ResponseWriter,
+	req *http.Request,
+	currentUser *model.User,
+	id string) error {
+
+	if req.Method != "POST" {
+		return MethodNotAllowed(rw, "POST")
+	}
+
+	var err error
+	if id == "others" {
+		err = auth.RevokeOtherSessions(conn, currentUser, req)
+	} else {
+		err = auth.RevokeSession(conn, currentUser, id)
+	}
+	if err != nil {
+		return BadRequest("Couldn't revoke the session", err)
+	}
+
+	auth.AddFlash(rw, req, "Revoked.")
+	http.Redirect(rw, req, "/sessions", http.StatusFound)
+	return nil
+}
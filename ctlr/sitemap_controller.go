@@ -0,0 +1,196 @@
+package ctlr
+
+import (
+	"bytes"
+	"github.com/aybabtme/goblog/auth"
+	"github.com/aybabtme/goblog/model"
+	"github.com/aybabtme/goblog/sitemap"
+	"github.com/gorilla/mux"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Pages crawlers are kept out of: forms, accounts and logging in.
+var robotsDisallow = []string{
+	"/post/compose",
+	"/post/edit",
+	"/admin",
+	"/authorize",
+	"/oauth2callback",
+	"/logout",
+	"/sessions",
+	"/tokens",
+}
+
+type sitemapper struct {
+	path string
+}
+
+func NewSitemapController() Controller {
+	return sitemapper{"/sitemap.xml"}
+}
+
+func NewSitemapPageController() Controller {
+	return sitemapper{"/sitemap-{page:[0-9]+}.xml"}
+}
+
+func (s sitemapper) Path() string {
+	return s.path
+}
+
+func (s sitemapper) Scope() string {
+	return "posts"
+}
+
+func (s sitemapper) Middlewares() []Middleware {
+	return nil
+}
+
+func (s sitemapper) Controller(conn *model.DBConnection) func(http.ResponseWriter, *http.Request) {
+	return handle(func(rw http.ResponseWriter, req *http.Request) error {
+		if req.Method != "GET" && req.Method != "HEAD" {
+			return MethodNotAllowed(rw, "GET")
+		}
+		if !auth.Can(CurrentUser(req), auth.Read, (*model.Post)(nil)) {
+			return Forbidden("You can't read posts")
+		}
+
+		base := BaseURL(req)
+		urls, err := siteURLs(conn, base)
+		if err != nil {
+			return InternalError(err)
+		}
+
+		var body bytes.Buffer
+		page := mux.Vars(req)["page"]
+		switch {
+		case page != "":
+			n, _ := strconv.Atoi(page)
+			pageURLs, ok := sitemap.Page(urls, n)
+			if !ok {
+				return NotFound("There's no such sitemap", nil)
+			}
+			urls = pageURLs
+			err = sitemap.WriteURLSet(&body, urls)
+
+		case len(urls) > sitemap.MaxURLs:
+			// too big for one sitemap, this one lists the others
+			var index []sitemap.URL
+			for n := 1; n <= sitemap.Pages(len(urls)); n++ {
+				pageURLs, _ := sitemap.Page(urls, n)
+				index = append(index, sitemap.URL{
+					Loc:     base + "/sitemap-" + strconv.Itoa(n) + ".xml",
+					LastMod: sitemap.LastMod(pageURLs),
+				})
+			}
+			err = sitemap.WriteIndex(&body, index)
+
+		default:
+			err = sitemap.WriteURLSet(&body, urls)
+		}
+		if err != nil {
+			return InternalError(err)
+		}
+
+		serveGenerated(rw, req, sitemap.Type, sitemap.LastMod(urls), body.Bytes())
+		return nil
+	})
+}
+
+// The pages of the blog worth crawling: the index, the posts, and those of
+// the labels and authors, changed when their last post was.
+func siteURLs(conn *model.DBConnection, base string) ([]sitemap.URL, error) {
+	posts, err := conn.FindAllPosts()
+	if err != nil {
+		return nil, err
+	}
+	labels, err := conn.FindAllLabels()
+	if err != nil {
+		return nil, err
+	}
+	labelDates, err := conn.LastPostDateByLabel()
+	if err != nil {
+		return nil, err
+	}
+	authors, err := conn.FindAllAuthors()
+	if err != nil {
+		return nil, err
+	}
+	authorDates, err := conn.LastPostDateByAuthor()
+	if err != nil {
+		return nil, err
+	}
+
+	// in a stable order, so that pages of the index keep their URLs
+	sort.Slice(posts, func(i, j int) bool { return posts[i].Id() < posts[j].Id() })
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Id() < labels[j].Id() })
+	sort.Slice(authors, func(i, j int) bool { return authors[i].Id() < authors[j].Id() })
+
+	urls := []sitemap.URL{{Loc: base + "/"}, {Loc: base + "/author"}}
+	for _, post := range posts {
+		urls = append(urls, sitemap.URL{
+			Loc:     base + "/post/" + strconv.FormatInt(post.Id(), 10),
+			LastMod: post.Updated(),
+		})
+	}
+	for _, label := range labels {
+		urls = append(urls, sitemap.URL{
+			Loc:     base + "/label/" + strconv.FormatInt(label.Id(), 10),
+			LastMod: labelDates[label.Id()],
+		})
+	}
+	for _, author := range authors {
+		urls = append(urls, sitemap.URL{
+			Loc:     base + "/author/" + strconv.FormatInt(author.Id(), 10),
+			LastMod: authorDates[author.Id()],
+		})
+	}
+
+	// the index changes with every post
+	last := sitemap.LastMod(urls)
+	urls[0].LastMod, urls[1].LastMod = last, last
+	return urls, nil
+}
+
+type robots struct{}
+
+func NewRobotsController() Controller {
+	return robots{}
+}
+
+func (r robots) Path() string {
+	return "/robots.txt"
+}
+
+func (r robots) Middlewares() []Middleware {
+	return nil
+}
+
+func (r robots) Controller(conn *model.DBConnection) func(http.ResponseWriter, *http.Request) {
+	return handle(func(rw http.ResponseWriter, req *http.Request) error {
+		if req.Method != "GET" && req.Method != "HEAD" {
+			return MethodNotAllowed(rw, "GET")
+		}
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		cacheBaseURL(rw, 3600)
+		rw.Write([]byte(robotsTxt(BaseURL(req))))
+		return nil
+	})
+}
+
+// The rules for crawlers: the pages they have no business in, then those
+// configured for the site, then where the sitemap is.
+func robotsTxt(base string) string {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	for _, path := range robotsDisallow {
+		b.WriteString("Disallow: " + path + "\n")
+	}
+	if rules := strings.TrimSpace(site.Robots); rules != "" {
+		b.WriteString("\n" + rules + "\n")
+	}
+	b.WriteString("\nSitemap: " + base + "/sitemap.xml\n")
+	return b.String()
+}
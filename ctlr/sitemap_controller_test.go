@@ -0,0 +1,36 @@
+package ctlr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRobots(t *testing.T) {
+	defer func(s Site) { site = s }(site)
+	site.URL = "https://blog.example.com"
+	site.Robots = "User-agent: BadBot\nDisallow: /\n"
+
+	req, _ := http.NewRequest("GET", "/robots.txt", nil)
+	rec := httptest.NewRecorder()
+	NewRobotsController().Controller(nil)(rec, req)
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("Expected robots.txt, was %d %v", rec.Code, rec.Header())
+	}
+
+	body := rec.Body.String()
+	for _, line := range []string{
+		"Disallow: /post/compose\n",
+		"Disallow: /post/edit\n",
+		"Disallow: /admin\n",
+		"Disallow: /authorize\n",
+		"Disallow: /oauth2callback\n",
+		"User-agent: BadBot\nDisallow: /\n",
+		"Sitemap: https://blog.example.com/sitemap.xml\n",
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected %q in robots.txt, was\n%s", line, body)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package ctlr
+
+import (
+	"github.com/aybabtme/goblog/auth"
+	"github.com/aybabtme/goblog/model"
+	"github.com/aybabtme/goblog/view"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type token struct {
+	path string
+	view *view.Page
+}
+
+func NewTokenListController() Controller {
+	var t token
+	t.path = "/tokens"
+	t.view = view.GetTokenListTemplate()
+	return t
+}
+
+func NewTokenRevokeController() Controller {
+	var t token
+	t.path = "/tokens/revoke/{revokeId:[0-9]+}"
+	return t
+}
+
+func (t token) Path() string {
+	return t.path
+}
+
+func (t token) Middlewares() []Middleware {
+	return []Middleware{RequireLogin("You need to log in to manage your API tokens")}
+}
+
+func (t token) Controller(conn *model.DBConnection) func(http.ResponseWriter, *http.Request) {
+	return handle(func(rw http.ResponseWriter, req *http.Request) error {
+		currentUser := CurrentUser(req)
+
+		revokeId := mux.Vars(req)["revokeId"]
+		if revokeId != "" {
+			return t.forRevoke(conn, rw, req, currentUser, revokeId)
+		}
+		if req.Method == "POST" {
+			return t.forCreate(conn, rw, req, currentUser)
+		}
+		return t.forListing(conn, rw, req, currentUser, "")
+	})
+}
+
+func (t *token) forListing(conn *model.DBConnection,
+	rw http.ResponseWriter,
+	req *http.Request,
+	currentUser *model.User,
+	newToken string) error {
+
+	tokens, err := conn.FindApiTokensByUserId(currentUser.Id())
+	if err != nil {
+		return InternalError(err)
+	}
+
+	data := struct {
+		PageContext
+		Tokens   []model.ApiToken
+		Scopes   []string
+		NewToken string
+		Now      time.Time
+	}{
+		NewPageContext(rw, req),
+		tokens,
+		auth.Scopes,
+		newToken,
+		time.Now().UTC(),
+	}
+
+	if err := t.view.Execute(rw, data); nil != err {
+		log.Println("TokenController for listing, execute:", err)
+	}
+	return nil
+}
+
+// Shows the new token right away rather than redirecting, since it's the
+// only time it can be seen.
+func (t *token) forCreate(conn *model.DBConnection,
+	rw http.ResponseWriter,
+	req *http.Request,
+	currentUser *model.User) error {
+
+	if err := req.ParseForm(); err != nil {
+		return BadRequest("Couldn't read the form", err)
+	}
+
+	var expiry time.Time
+	if days := req.FormValue("expiry_days"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 {
+			return BadRequest("The expiry must be a number of days", err)
+		}
+		expiry = time.Now().UTC().AddDate(0, 0, n)
+	}
+
+	newToken, _, err := auth.NewToken(conn, currentUser, req.FormValue("name"),
+		req.Form["scope"], expiry)
+	if err != nil {
+		return BadRequest("Couldn't create the token, "+err.Error(), err)
+	}
+	return t.forListing(conn, rw, req, currentUser, newToken)
+}
+
+func (t *token) forRevoke(conn *model.DBConnection,
+	rw http.ResponseWriter,
+	req *http.Request,
+	currentUser *model.User,
+	id string) error {
+
+	if req.Method != "POST" {
+		return MethodNotAllowed(rw, "POST")
+	}
+
+	tokenId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return BadRequest("That's not a token id", err)
+	}
+	if err := auth.RevokeToken(conn, currentUser, tokenId); err != nil {
+		return BadRequest("Couldn't revoke the token", err)
+	}
+
+	auth.AddFlash(rw, req, "Revoked.")
+	http.Redirect(rw, req, "/tokens", http.StatusFound)
+	return nil
+}
@@ -8,7 +8,6 @@ import (
 	"log"
 	"net/http"
 	"strconv"
-	"text/template"
 )
 
 func NewUserController() Controller {
@@ -18,46 +17,49 @@ func NewUserController() Controller {
 }
 
 type user struct {
-	CurrentUser   *model.User
-	CurrentAuthor *model.Author
-	view          *template.Template
+	view *view.Page
 }
 
 func (u user) Path() string {
 	return "/user/{id:[0-9]+}"
 }
 
-func (u user) Controller(conn *model.DBConnection) func(http.ResponseWriter, *http.Request) {
-	return func(rw http.ResponseWriter, req *http.Request) {
+func (u user) Scope() string {
+	return "users"
+}
 
-		curUser, author := auth.Login(conn, rw, req)
+func (u user) Middlewares() []Middleware {
+	return nil
+}
 
+func (u user) Controller(conn *model.DBConnection) func(http.ResponseWriter, *http.Request) {
+	return handle(func(rw http.ResponseWriter, req *http.Request) error {
 		vars := mux.Vars(req)
 		id, err := strconv.ParseInt(vars["id"], 10, 64)
 		if err != nil {
-			log.Println("UserController, parse id:", err)
-			return
+			return BadRequest("That's not a user id", err)
 		}
 
 		user, err := conn.FindUserById(id)
 		if err != nil {
-			log.Printf("UserController, for id(%d): \n%v\n", id, err)
-			return
+			return NotFoundOr("There's no such user", err)
+		}
+
+		if !auth.Can(CurrentUser(req), auth.Read, user) {
+			return Forbidden("You can't see this user")
 		}
 
 		data := struct {
-			CurrentUser   *model.User
-			CurrentAuthor *model.Author
-			User          *model.User
+			PageContext
+			User *model.User
 		}{
-			curUser,
-			author,
+			NewPageContext(rw, req),
 			user,
 		}
 
 		if err := u.view.Execute(rw, data); nil != err {
 			log.Println("UserController, execute:", err)
 		}
-
-	}
+		return nil
+	})
 }
@@ -0,0 +1,328 @@
+// Package export writes the blog out of its server, as static files that
+// can be hosted on any file storage.
+package export
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aybabtme/goblog/media"
+	"github.com/aybabtme/goblog/model"
+	"github.com/aybabtme/goblog/sitemap"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ManifestName is the file, in the directory of an export, telling what
+// was exported for the next export to only write what changed.
+const ManifestName = ".goblog-export.json"
+
+// Options say where and how to export.
+type Options struct {
+	// the directory the files are written in
+	Out string
+	// where the files will be hosted, i.e. "https://blog.example.com"
+	BaseURL string
+	// write every file again, even those that didn't change
+	Full bool
+}
+
+// Report tells what an export did, in files.
+type Report struct {
+	Written int
+	Skipped int
+	Removed int
+}
+
+type manifest struct {
+	Exported time.Time `json:"exported"`
+	// the SHA-256 of each file when it was exported, by file.  A post
+	// changes with its comments, labels and the titles of the posts it
+	// links to, not only with its own date, so it's what was written that
+	// tells.
+	Hashes map[string]string `json:"hashes"`
+	Files  []string          `json:"files"`
+}
+
+// A page to export, from the path it has on the blog to its file.
+type page struct {
+	path string
+	file string
+}
+
+// Static renders the pages of the blog with handler, as visitors see them,
+// and writes them under opts.Out with the static files served under
+// `/res/`, named by assets.  Links between pages are rewritten to their
+// files.  Unless opts.Full, files that would be written as they were by the
+// previous export in opts.Out are left as they are.  Files of pages gone
+// from the blog since are removed.  Sizes of pictures that can't be resized
+// are their original, or aren't written if it's in a bucket.
+func Static(conn *model.DBConnection, handler http.Handler, assets []string, opts Options) (Report, error) {
+	if opts.Out == "" || opts.BaseURL == "" {
+		return Report{}, errors.New("export: need a directory and the URL of the blog")
+	}
+	pages, err := sitePages(conn)
+	if err != nil {
+		return Report{}, err
+	}
+	for _, name := range assets {
+		pages = append(pages, page{path: "/res/" + name, file: "res/" + name})
+	}
+	return exportPages(handler, pages, opts)
+}
+
+func exportPages(handler http.Handler, pages []page, opts Options) (Report, error) {
+	var report Report
+	base := strings.TrimSuffix(opts.BaseURL, "/")
+
+	previous := manifest{Hashes: map[string]string{}}
+	if err := readManifest(opts.Out, &previous); err != nil {
+		return report, err
+	}
+
+	links := make(map[string]string)
+	for _, p := range pages {
+		if "/"+p.file != p.path {
+			links[p.path] = "/" + p.file
+		}
+	}
+	rewrite := linkRewriter(base, links)
+
+	current := manifest{Exported: time.Now().UTC(), Hashes: map[string]string{}}
+	for _, p := range pages {
+		// uploads never change, their names aren't reused
+		if isUpload(p.path) && !opts.Full && exists(filepath.Join(opts.Out, p.file)) {
+			current.Files = append(current.Files, p.file)
+			report.Skipped++
+			continue
+		}
+
+		body, contentType, err := render(handler, base+p.path)
+		if err == errElsewhere && isUpload(p.path) {
+			// a picture that can't be resized, in a bucket
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+		current.Files = append(current.Files, p.file)
+		if !strings.HasPrefix(p.path, "/res/") && !isUpload(p.path) {
+			body = rewrite(body, contentType)
+		}
+		sum := sha256.Sum256(body)
+		hash := hex.EncodeToString(sum[:])
+		current.Hashes[p.file] = hash
+		if !opts.Full && previous.Hashes[p.file] == hash && exists(filepath.Join(opts.Out, p.file)) {
+			report.Skipped++
+			continue
+		}
+		if err := writeFile(filepath.Join(opts.Out, p.file), body); err != nil {
+			return report, err
+		}
+		report.Written++
+	}
+
+	// pages that are gone from the blog, like deleted posts
+	kept := make(map[string]bool)
+	for _, file := range current.Files {
+		kept[file] = true
+	}
+	for _, file := range previous.Files {
+		if kept[file] {
+			continue
+		}
+		err := os.Remove(filepath.Join(opts.Out, filepath.FromSlash(file)))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return report, err
+		}
+		report.Removed++
+	}
+
+	return report, writeManifest(opts.Out, current)
+}
+
+// The pages of the blog and their files.  Pages that aren't HTML, like
+// feeds, keep their path.
+func sitePages(conn *model.DBConnection) ([]page, error) {
+	posts, err := conn.FindAllPosts()
+	if err != nil {
+		return nil, err
+	}
+	labels, err := conn.FindAllLabels()
+	if err != nil {
+		return nil, err
+	}
+	authors, err := conn.FindAllAuthors()
+	if err != nil {
+		return nil, err
+	}
+	uploads, err := conn.FindAllMedia()
+	if err != nil {
+		return nil, err
+	}
+
+	pages := []page{
+		{path: "/", file: "index.html"},
+		{path: "/author", file: "author.html"},
+		{path: "/robots.txt", file: "robots.txt"},
+	}
+	pages = append(pages, feedPages("")...)
+
+	// the sitemap becomes an index when there are too many pages
+	pages = append(pages, page{path: "/sitemap.xml", file: "sitemap.xml"})
+	if count := 2 + len(posts) + len(labels) + len(authors); count > sitemap.MaxURLs {
+		for n := 1; n <= sitemap.Pages(count); n++ {
+			name := "sitemap-" + strconv.Itoa(n) + ".xml"
+			pages = append(pages, page{path: "/" + name, file: name})
+		}
+	}
+
+	for _, post := range posts {
+		id := strconv.FormatInt(post.Id(), 10)
+		pages = append(pages, page{path: "/post/" + id, file: "post/" + id + ".html"})
+	}
+	for _, label := range labels {
+		path := "/label/" + strconv.FormatInt(label.Id(), 10)
+		pages = append(pages, page{path: path, file: path[1:] + ".html"})
+		pages = append(pages, feedPages(path)...)
+	}
+	for _, author := range authors {
+		path := "/author/" + strconv.FormatInt(author.Id(), 10)
+		pages = append(pages, page{path: path, file: path[1:] + ".html"})
+		pages = append(pages, feedPages(path)...)
+	}
+	// uploads kept by the blog itself, those in a bucket stay there, and
+	// the resized pictures the pages show
+	for _, m := range uploads {
+		if isUpload(m.URL()) {
+			pages = append(pages, page{path: m.URL(), file: m.URL()[1:]})
+		}
+		if !media.Resizable(m.Name()) {
+			continue
+		}
+		for _, size := range media.Sizes {
+			path := "/media/" + size.Name + "/" + m.Name()
+			pages = append(pages, page{path: path, file: path[1:]})
+		}
+	}
+	return pages, nil
+}
+
+func isUpload(path string) bool {
+	return strings.HasPrefix(path, "/media/")
+}
+
+func feedPages(path string) []page {
+	var pages []page
+	for _, format := range []string{"atom", "rss", "json"} {
+		name := path + "/feed." + format
+		pages = append(pages, page{path: name, file: name[1:]})
+	}
+	return pages
+}
+
+// errElsewhere is for pages that redirect off the blog, like pictures that
+// can't be resized, whose original is in a bucket.
+var errElsewhere = errors.New("export: the page is elsewhere")
+
+// Gets the page at url from handler, as a visitor.  Redirects to another
+// page of the blog, like that of a picture that can't be resized to its
+// original, are followed.
+func render(handler http.Handler, url string) ([]byte, string, error) {
+	for redirects := 0; ; redirects++ {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		switch {
+		case rec.Code == http.StatusOK:
+			return rec.Body.Bytes(), rec.Header().Get("Content-Type"), nil
+		case rec.Code == http.StatusFound && redirects < 5:
+			location, err := req.URL.Parse(rec.Header().Get("Location"))
+			if err != nil {
+				return nil, "", err
+			}
+			if location.Host != req.URL.Host {
+				return nil, "", errElsewhere
+			}
+			url = location.String()
+		default:
+			return nil, "", fmt.Errorf("export: %s answered %d", url, rec.Code)
+		}
+	}
+}
+
+// Links start after a quote or a tag, and end before a query, a fragment,
+// a quote or the backslash of an escaped one.
+const linkPattern = `(["'>])(%s)?(/[^"'<>\s?#\\]*)`
+
+// Returns what rewrites the links to the pages of the blog in HTML, XML or
+// JSON documents, relative or not, to links to their files.
+func linkRewriter(base string, links map[string]string) func([]byte, string) []byte {
+	re := regexp.MustCompile(fmt.Sprintf(linkPattern, regexp.QuoteMeta(base)))
+	return func(body []byte, contentType string) []byte {
+		if !strings.Contains(contentType, "html") && !strings.Contains(contentType, "xml") &&
+			!strings.Contains(contentType, "json") {
+			return body
+		}
+		return re.ReplaceAllFunc(body, func(match []byte) []byte {
+			parts := re.FindSubmatch(match)
+			file, ok := links[string(parts[3])]
+			if !ok {
+				return match
+			}
+			return []byte(string(parts[1]) + string(parts[2]) + file)
+		})
+	}
+}
+
+func readManifest(dir string, m *manifest) error {
+	content, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if errors.Is(err, fs.ErrNotExist) {
+		// the first export
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, m); err != nil {
+		return fmt.Errorf("export: bad manifest %s: %v", ManifestName, err)
+	}
+	// manifests of older exports have none, their files are written again
+	if m.Hashes == nil {
+		m.Hashes = map[string]string{}
+	}
+	return nil
+}
+
+func writeManifest(dir string, m manifest) error {
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, ManifestName), content)
+}
+
+func writeFile(name string, content []byte) error {
+	name = filepath.FromSlash(name)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(name, content, 0644)
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(filepath.FromSlash(name))
+	return err == nil
+}
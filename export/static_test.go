@@ -0,0 +1,217 @@
+package export
+
+import (
+	"github.com/aybabtme/goblog/model"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLinkRewriter(t *testing.T) {
+	rewrite := linkRewriter("https://blog.example.com", map[string]string{
+		"/":       "/index.html",
+		"/post/3": "/post/3.html",
+	})
+
+	cases := []struct {
+		contentType string
+		body        string
+		rewritten   string
+	}{
+		{"text/html", `<a href="/post/3">`, `<a href="/post/3.html">`},
+		{"text/html", `<a href='/post/3#comments'>`, `<a href='/post/3.html#comments'>`},
+		{"text/html", `<a href="/">home</a>`, `<a href="/index.html">home</a>`},
+		// other pages and other sites are left alone
+		{"text/html", `<a href="/post/30">`, `<a href="/post/30">`},
+		{"text/html", `<a href="/feed.atom">`, `<a href="/feed.atom">`},
+		{"text/html", `<a href="https://other.example.com/post/3">`, `<a href="https://other.example.com/post/3">`},
+		{"application/atom+xml", `<id>https://blog.example.com/post/3</id>`, `<id>https://blog.example.com/post/3.html</id>`},
+		{"application/feed+json", `{"url":"https://blog.example.com/post/3"}`, `{"url":"https://blog.example.com/post/3.html"}`},
+		{"application/feed+json", `{"content_html":"<a href=\"/post/3\">"}`, `{"content_html":"<a href=\"/post/3.html\">"}`},
+		{"text/plain", `Sitemap: "/post/3"`, `Sitemap: "/post/3"`},
+	}
+	for _, c := range cases {
+		if body := string(rewrite([]byte(c.body), c.contentType)); body != c.rewritten {
+			t.Errorf("Expected %s, was %s", c.rewritten, body)
+		}
+	}
+}
+
+func TestExportPages(t *testing.T) {
+	out := t.TempDir()
+	served := make(map[string]int)
+	comments := 0
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		served[req.URL.Path]++
+		if req.Host != "blog.example.com" {
+			t.Errorf("Expected the host of the blog, was %s", req.Host)
+		}
+		switch req.URL.Path {
+		case "/":
+			rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+			rw.Write([]byte(`<a href="/post/1">One</a> <a href="/post/2">Two</a>`))
+		case "/post/1":
+			rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+			rw.Write([]byte(`<a href="/">Back</a>`))
+		case "/post/2":
+			rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+			rw.Write([]byte(`<a href="/">Back</a> ` + strconv.Itoa(comments) + ` comment(s)`))
+		case "/media/large/huge.jpg":
+			http.Redirect(rw, req, "/media/huge.jpg", http.StatusFound)
+		case "/media/huge.jpg":
+			rw.Header().Set("Content-Type", "image/jpeg")
+			rw.Write([]byte("original"))
+		case "/media/large/bucket.jpg":
+			http.Redirect(rw, req, "https://bucket.example.com/bucket.jpg", http.StatusFound)
+		case "/res/css/site.css":
+			rw.Header().Set("Content-Type", "text/css; charset=utf-8")
+			rw.Write([]byte(`a { background: url("/post/1"); }`))
+		default:
+			http.NotFound(rw, req)
+		}
+	})
+
+	pages := []page{
+		{path: "/", file: "index.html"},
+		{path: "/post/1", file: "post/1.html"},
+		{path: "/post/2", file: "post/2.html"},
+		{path: "/res/css/site.css", file: "res/css/site.css"},
+	}
+	opts := Options{Out: out, BaseURL: "https://blog.example.com/"}
+
+	report, err := exportPages(handler, pages, opts)
+	if err != nil {
+		t.Fatal("Couldn't export", err)
+	}
+	if report != (Report{Written: 4}) {
+		t.Errorf("Expected every page to be written, was %+v", report)
+	}
+	expectFile(t, out, "index.html", `<a href="/post/1.html">One</a> <a href="/post/2.html">Two</a>`)
+	expectFile(t, out, "post/1.html", `<a href="/index.html">Back</a>`)
+	// static files are copied as they are
+	expectFile(t, out, "res/css/site.css", `a { background: url("/post/1"); }`)
+
+	// the second post got a comment, its date is the same; the first was
+	// deleted
+	comments++
+	pages = append(pages[:1], pages[2:]...)
+	report, err = exportPages(handler, pages, opts)
+	if err != nil {
+		t.Fatal("Couldn't export again", err)
+	}
+	// the index links to the deleted post as it is now
+	if report != (Report{Written: 2, Skipped: 1, Removed: 1}) {
+		t.Errorf("Expected only the changed pages to be written, was %+v", report)
+	}
+	expectFile(t, out, "post/2.html", `<a href="/index.html">Back</a> 1 comment(s)`)
+	if _, err := os.Stat(filepath.Join(out, "post", "1.html")); !os.IsNotExist(err) {
+		t.Error("Expected the deleted post to be removed")
+	}
+
+	report, err = exportPages(handler, pages, opts)
+	if err != nil || report != (Report{Skipped: 3}) {
+		t.Errorf("Expected the unchanged pages to be skipped, was %+v, %v", report, err)
+	}
+	opts.Full = true
+	report, err = exportPages(handler, pages, opts)
+	if err != nil || report != (Report{Written: 3}) {
+		t.Errorf("Expected every page to be written, was %+v, %v", report, err)
+	}
+	if served["/post/2"] != 4 {
+		t.Errorf("Expected the post to be rendered 4 times, was %d", served["/post/2"])
+	}
+
+	// a file removed by hand is written again
+	os.Remove(filepath.Join(out, "index.html"))
+	opts.Full = false
+	report, err = exportPages(handler, pages, opts)
+	if err != nil || report != (Report{Written: 1, Skipped: 2}) {
+		t.Errorf("Expected the missing page to be written, was %+v, %v", report, err)
+	}
+
+	// pictures that can't be resized are their original, unless that's in
+	// a bucket
+	pictures := append(pages,
+		page{path: "/media/large/huge.jpg", file: "media/large/huge.jpg"},
+		page{path: "/media/large/bucket.jpg", file: "media/large/bucket.jpg"})
+	report, err = exportPages(handler, pictures, opts)
+	if err != nil || report != (Report{Written: 1, Skipped: 3}) {
+		t.Errorf("Expected the picture that can't be resized to be its original, was %+v, %v", report, err)
+	}
+	expectFile(t, out, "media/large/huge.jpg", "original")
+	if _, err := os.Stat(filepath.Join(out, "media", "large", "bucket.jpg")); !os.IsNotExist(err) {
+		t.Error("Expected the picture in a bucket to be left there")
+	}
+
+	// pages must render
+	pages = append(pages, page{path: "/nope", file: "nope.html"})
+	if _, err := exportPages(handler, pages, opts); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected a missing page to fail the export, was %v", err)
+	}
+}
+
+func TestSitePages(t *testing.T) {
+	sitePagesOf(t, setupPGConnection())
+}
+
+func sitePagesOf(t *testing.T, conn *model.DBConnection) {
+	defer conn.DeleteConnection()
+	user := conn.NewUser("exporter", time.Now().UTC(), 0, "export-oauth", "", "", "export@example.com")
+	author := conn.NewAuthor(user)
+	if err := author.Save(); err != nil {
+		t.Fatal("Couldn't save author", err)
+	}
+	post := conn.NewPost(author, "Title", "Content", "", time.Now().UTC())
+	if err := post.Save(); err != nil {
+		t.Fatal("Couldn't save post", err)
+	}
+	label, err := post.AddLabel("go")
+	if err != nil {
+		t.Fatal("Couldn't label post", err)
+	}
+
+	pages, err := sitePages(conn)
+	if err != nil {
+		t.Fatal("Couldn't list pages", err)
+	}
+	files := make(map[string]string)
+	for _, p := range pages {
+		files[p.path] = p.file
+	}
+	postId := strconv.FormatInt(post.Id(), 10)
+	labelId := strconv.FormatInt(label.Id(), 10)
+	authorId := strconv.FormatInt(author.Id(), 10)
+	expected := map[string]string{
+		"/":                               "index.html",
+		"/feed.json":                      "feed.json",
+		"/sitemap.xml":                    "sitemap.xml",
+		"/post/" + postId:                 "post/" + postId + ".html",
+		"/label/" + labelId:               "label/" + labelId + ".html",
+		"/label/" + labelId + "/feed.rss": "label/" + labelId + "/feed.rss",
+		"/author/" + authorId:             "author/" + authorId + ".html",
+	}
+	for path, file := range expected {
+		if files[path] != file {
+			t.Errorf("Expected %s in %s, was %q", path, file, files[path])
+		}
+	}
+}
+
+func expectFile(t *testing.T, dir, name, content string) {
+	actual, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		t.Errorf("Expected %s to be written: %v", name, err)
+	} else if string(actual) != content {
+		t.Errorf("Expected %s to be %s, was %s", name, content, actual)
+	}
+}
+
+func setupPGConnection() *model.DBConnection {
+	modelurl := "user=antoine dbname=test sslmode=disable"
+	conn, _ := model.NewConnection(model.NewPostgreser(modelurl))
+	return conn
+}
@@ -0,0 +1,146 @@
+// Package feed writes posts as Atom, RSS and JSON feeds, so that readers
+// can subscribe to the blog.
+package feed
+
+import (
+	"github.com/aybabtme/goblog/model"
+	"github.com/microcosm-cc/bluemonday"
+	"html"
+	"mime"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Feed is what's common to every format, links being absolute.
+type Feed struct {
+	Title       string
+	Description string
+	// the page the feed follows
+	Link string
+	// the feed itself
+	Self    string
+	Updated time.Time
+	Items   []Item
+}
+
+// Item is a post of a feed, its content being rendered HTML.  Content is
+// empty in feeds of summaries.
+type Item struct {
+	Id        string
+	Title     string
+	Link      string
+	Content   string
+	Summary   string
+	Author    string
+	AuthorURL string
+	Published time.Time
+	Updated   time.Time
+	ImageURL  string
+	Tags      []string
+}
+
+// Options are what a feed has of each post.
+type Options struct {
+	// How many of the most recent posts, all of them if 0.
+	Limit int
+	// Only a plain text summary of the posts, not their whole content.
+	Summary bool
+}
+
+// How long summaries are, in characters.
+const summaryLength = 280
+
+var stripTags = bluemonday.StrictPolicy()
+
+// FromPosts makes a feed of the most recent posts.  Links are made absolute
+// with baseURL, i.e. "https://blog.example.com".
+func FromPosts(title, description, link, self, baseURL string,
+	posts []model.Post, opts Options) (*Feed, error) {
+
+	sorted := append([]model.Post{}, posts...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Date().After(sorted[j].Date())
+	})
+	if opts.Limit > 0 && len(sorted) > opts.Limit {
+		sorted = sorted[:opts.Limit]
+	}
+
+	f := &Feed{
+		Title:       title,
+		Description: description,
+		Link:        link,
+		Self:        self,
+	}
+	for i := range sorted {
+		item, err := postItem(baseURL, &sorted[i])
+		if err != nil {
+			return nil, err
+		}
+		if opts.Summary {
+			item.Content = ""
+		}
+		if item.Updated.After(f.Updated) {
+			f.Updated = item.Updated
+		}
+		f.Items = append(f.Items, item)
+	}
+	return f, nil
+}
+
+func postItem(baseURL string, post *model.Post) (Item, error) {
+	link := baseURL + "/post/" + strconv.FormatInt(post.Id(), 10)
+	item := Item{
+		Id:        link,
+		Title:     post.Title(),
+		Link:      link,
+		Content:   string(post.ContentMarkdown()),
+		Published: post.Date(),
+		Updated:   post.Updated(),
+		ImageURL:  post.ImageURL(),
+	}
+	item.Summary = summarize(item.Content, summaryLength)
+	if strings.HasPrefix(item.ImageURL, "/") {
+		item.ImageURL = baseURL + item.ImageURL
+	}
+	if author := post.Author(); author != nil {
+		item.AuthorURL = baseURL + "/author/" + strconv.FormatInt(author.Id(), 10)
+		if author.User() != nil {
+			item.Author = author.User().Username()
+		}
+	}
+
+	labels, err := post.Labels()
+	if err != nil {
+		return item, err
+	}
+	for _, label := range labels {
+		item.Tags = append(item.Tags, label.Name())
+	}
+	return item, nil
+}
+
+// The text of rendered HTML, cut after max characters at the end of a word.
+func summarize(content string, max int) string {
+	text := html.UnescapeString(stripTags.Sanitize(content))
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	cut := string(runes[:max])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
+
+// The type of the image at url, guessed from its extension.
+func imageType(url string) string {
+	if t := mime.TypeByExtension(path.Ext(url)); t != "" {
+		return t
+	}
+	return "image/jpeg"
+}
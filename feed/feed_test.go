@@ -0,0 +1,264 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"github.com/aybabtme/goblog/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() *Feed {
+	date := time.Date(2014, 3, 2, 10, 0, 0, 0, time.UTC)
+	return &Feed{
+		Title:       "Blog & friends",
+		Description: "A blog",
+		Link:        "https://blog.example.com/",
+		Self:        "https://blog.example.com/feed.atom",
+		Updated:     date,
+		Items: []Item{{
+			Id:        "https://blog.example.com/post/1",
+			Title:     "First <post>",
+			Link:      "https://blog.example.com/post/1",
+			Content:   "<p>Hello <script>alert(1)</script></p>",
+			Author:    "antoine",
+			AuthorURL: "https://blog.example.com/author/1",
+			Published: date,
+			Updated:   date,
+			ImageURL:  "https://blog.example.com/img/cat.png",
+			Tags:      []string{"go", "cats"},
+		}},
+	}
+}
+
+func TestWriteAtom(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testFeed().WriteAtom(&buf); err != nil {
+		t.Fatal("Couldn't write Atom", err)
+	}
+	if strings.Contains(buf.String(), "<script>") {
+		t.Errorf("Expected the content to be escaped, was %s", buf.String())
+	}
+
+	var doc atomFeed
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal("Atom doesn't parse back", err)
+	}
+	if doc.Title != "Blog & friends" || doc.Updated != "2014-03-02T10:00:00Z" {
+		t.Errorf("Wrong feed, was %+v", doc)
+	}
+	if len(doc.Entries) != 1 {
+		t.Fatalf("Expected 1 entry, was %d", len(doc.Entries))
+	}
+	entry := doc.Entries[0]
+	if entry.Content == nil || entry.Content.Type != "html" || entry.Content.Body != "<p>Hello <script>alert(1)</script></p>" {
+		t.Errorf("Wrong content, was %+v", entry.Content)
+	}
+	if entry.Author == nil || entry.Author.Name != "antoine" || len(entry.Categories) != 2 {
+		t.Errorf("Wrong entry, was %+v", entry)
+	}
+}
+
+func TestWriteRSS(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testFeed().WriteRSS(&buf); err != nil {
+		t.Fatal("Couldn't write RSS", err)
+	}
+	if strings.Contains(buf.String(), "<script>") {
+		t.Errorf("Expected the content to be escaped, was %s", buf.String())
+	}
+
+	var doc struct {
+		Channel struct {
+			Title string `xml:"title"`
+			Items []struct {
+				Title       string   `xml:"title"`
+				Guid        string   `xml:"guid"`
+				PubDate     string   `xml:"pubDate"`
+				Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+				Categories  []string `xml:"category"`
+				Description string   `xml:"description"`
+				Enclosure   struct {
+					URL  string `xml:"url,attr"`
+					Type string `xml:"type,attr"`
+				} `xml:"enclosure"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal("RSS doesn't parse back", err)
+	}
+	if doc.Channel.Title != "Blog & friends" || len(doc.Channel.Items) != 1 {
+		t.Fatalf("Wrong channel, was %+v", doc.Channel)
+	}
+	item := doc.Channel.Items[0]
+	if item.Guid != "https://blog.example.com/post/1" || item.PubDate != "Sun, 02 Mar 2014 10:00:00 +0000" {
+		t.Errorf("Wrong item, was %+v", item)
+	}
+	if item.Creator != "antoine" || len(item.Categories) != 2 {
+		t.Errorf("Wrong author or categories, was %+v", item)
+	}
+	if item.Enclosure.URL != "https://blog.example.com/img/cat.png" || item.Enclosure.Type != "image/png" {
+		t.Errorf("Wrong enclosure, was %+v", item.Enclosure)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testFeed().WriteJSON(&buf); err != nil {
+		t.Fatal("Couldn't write JSON Feed", err)
+	}
+
+	var doc struct {
+		Version     string `json:"version"`
+		Title       string `json:"title"`
+		HomePageURL string `json:"home_page_url"`
+		FeedURL     string `json:"feed_url"`
+		Items       []struct {
+			Id            string `json:"id"`
+			ContentHTML   string `json:"content_html"`
+			ContentText   string `json:"content_text"`
+			BannerImage   string `json:"banner_image"`
+			DatePublished string `json:"date_published"`
+			Authors       []struct {
+				Name string `json:"name"`
+				URL  string `json:"url"`
+			} `json:"authors"`
+			Tags []string `json:"tags"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal("JSON Feed doesn't parse back", err)
+	}
+	if doc.Version != "https://jsonfeed.org/version/1.1" || doc.Title != "Blog & friends" {
+		t.Errorf("Wrong feed, was %+v", doc)
+	}
+	if doc.HomePageURL != "https://blog.example.com/" || doc.FeedURL != "https://blog.example.com/feed.atom" {
+		t.Errorf("Wrong links, was %+v", doc)
+	}
+	if len(doc.Items) != 1 {
+		t.Fatalf("Expected 1 item, was %d", len(doc.Items))
+	}
+	item := doc.Items[0]
+	if item.ContentHTML == "" || item.ContentText != "" {
+		t.Errorf("Expected the HTML content, was %+v", item)
+	}
+	if item.BannerImage != "https://blog.example.com/img/cat.png" || item.DatePublished != "2014-03-02T10:00:00Z" {
+		t.Errorf("Wrong item, was %+v", item)
+	}
+	if len(item.Authors) != 1 || item.Authors[0].Name != "antoine" || len(item.Tags) != 2 {
+		t.Errorf("Wrong authors or tags, was %+v", item)
+	}
+}
+
+func TestSummaries(t *testing.T) {
+	f := testFeed()
+	f.Items[0].Content = ""
+	f.Items[0].Summary = "Hello"
+
+	var buf bytes.Buffer
+	if err := f.WriteJSON(&buf); err != nil {
+		t.Fatal("Couldn't write JSON Feed", err)
+	}
+	if !strings.Contains(buf.String(), `"content_text":"Hello"`) || strings.Contains(buf.String(), "content_html") {
+		t.Errorf("Expected the summary as the content, was %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := f.WriteAtom(&buf); err != nil {
+		t.Fatal("Couldn't write Atom", err)
+	}
+	if !strings.Contains(buf.String(), `<summary type="text">Hello</summary>`) || strings.Contains(buf.String(), "<content") {
+		t.Errorf("Expected only the summary, was %s", buf.String())
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	cases := []struct {
+		content string
+		max     int
+		summary string
+	}{
+		{"<p>Hello <em>world</em></p>", 20, "Hello world"},
+		{"<p>Fish &amp; chips</p>\n<p>and peas</p>", 50, "Fish & chips and peas"},
+		{"<p>one two three</p>", 10, "one two…"},
+		{"<p>ééééé</p>", 3, "ééé…"},
+	}
+	for _, c := range cases {
+		if summary := summarize(c.content, c.max); summary != c.summary {
+			t.Errorf("Expected %q, was %q", c.summary, summary)
+		}
+	}
+}
+
+func TestFromPosts(t *testing.T) {
+	fromPosts(t, setupPGConnection())
+}
+
+func fromPosts(t *testing.T, conn *model.DBConnection) {
+	defer conn.DeleteConnection()
+	user := conn.NewUser("feeder", time.Now().UTC(), 0, "feed-oauth", "", "", "feed@example.com")
+	if err := user.Save(); err != nil {
+		t.Fatal("Couldn't save user", err)
+	}
+	author := conn.NewAuthor(user)
+	if err := author.Save(); err != nil {
+		t.Fatal("Couldn't save author", err)
+	}
+
+	start := time.Date(2014, 3, 2, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		post := conn.NewPost(author, "Post", "**Bold**", "/img/cat.png", start.Add(time.Duration(i)*time.Hour))
+		if err := post.Save(); err != nil {
+			t.Fatal("Couldn't save post", err)
+		}
+		if _, err := post.AddLabel("go"); err != nil {
+			t.Fatal("Couldn't label post", err)
+		}
+	}
+	posts, err := conn.FindAllPosts()
+	if err != nil {
+		t.Fatal("Couldn't find posts", err)
+	}
+
+	base := "https://blog.example.com"
+	f, err := FromPosts("Blog", "", base+"/", base+"/feed.atom", base, posts, Options{Limit: 2})
+	if err != nil {
+		t.Fatal("Couldn't make feed", err)
+	}
+	if len(f.Items) != 2 {
+		t.Fatalf("Expected the feed to be limited to 2 items, was %d", len(f.Items))
+	}
+	if !f.Items[0].Published.After(f.Items[1].Published) || !f.Updated.Equal(f.Items[0].Updated) {
+		t.Errorf("Expected the most recent posts first, was %+v", f.Items)
+	}
+	item := f.Items[0]
+	if item.ImageURL != base+"/img/cat.png" || !strings.HasPrefix(item.Link, base+"/post/") {
+		t.Errorf("Expected absolute links, was %+v", item)
+	}
+	if item.Author != "feeder" || len(item.Tags) != 1 || !strings.Contains(item.Content, "<strong>") {
+		t.Errorf("Wrong item, was %+v", item)
+	}
+	if item.Summary != "Bold" {
+		t.Errorf("Expected a plain text summary, was %q", item.Summary)
+	}
+
+	f, err = FromPosts("Blog", "", base+"/", base+"/feed.json", base, posts, Options{Summary: true})
+	if err != nil {
+		t.Fatal("Couldn't make feed", err)
+	}
+	if len(f.Items) < 3 {
+		t.Errorf("Expected every post without a limit, was %d", len(f.Items))
+	}
+	if f.Items[0].Content != "" || f.Items[0].Summary == "" {
+		t.Errorf("Expected only summaries, was %+v", f.Items[0])
+	}
+}
+
+func setupPGConnection() *model.DBConnection {
+	modelurl := "user=antoine dbname=test sslmode=disable"
+	conn, _ := model.NewConnection(model.NewPostgreser(modelurl))
+	return conn
+}
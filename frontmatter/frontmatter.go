@@ -0,0 +1,296 @@
+// Package frontmatter reads and writes posts as Markdown files that start
+// with front matter, the way Jekyll and Hugo keep them, so that a folder of
+// files can be where posts are written.
+package frontmatter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// File is a post as a Markdown file.
+type File struct {
+	// the id of the post on the blog, 0 for posts that aren't there yet
+	Id     int64
+	Title  string
+	Date   time.Time
+	Labels []string
+	Image  string
+	Slug   string
+	// drafts aren't imported
+	Draft   bool
+	Content string
+}
+
+// How dates are written in front matter, the first being the one Write
+// uses.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Parse reads a file that starts with YAML front matter between `---`
+// lines, or TOML front matter between `+++` lines.  Only the keys of File
+// are read, `tags` and `categories` being labels too; the others, like
+// layouts, are ignored.  Nested values aren't understood.
+func Parse(content []byte) (*File, error) {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), len(content)+1)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("frontmatter: the file is empty")
+	}
+
+	var values map[string][]string
+	var end int
+	var err error
+	switch strings.TrimSpace(lines[0]) {
+	case "---":
+		values, end, err = parseYAML(lines[1:])
+	case "+++":
+		values, end, err = parseTOML(lines[1:])
+	default:
+		return nil, fmt.Errorf("frontmatter: the file doesn't start with --- or +++")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{Content: strings.TrimSpace(strings.Join(lines[end+2:], "\n"))}
+	for key, v := range values {
+		switch key {
+		case "id":
+			if f.Id, err = strconv.ParseInt(v[0], 10, 64); err != nil {
+				return nil, fmt.Errorf("frontmatter: id %q isn't a number", v[0])
+			}
+		case "title":
+			f.Title = v[0]
+		case "date":
+			if f.Date, err = ParseDate(v[0]); err != nil {
+				return nil, err
+			}
+		case "image":
+			f.Image = v[0]
+		case "slug":
+			// slugs name files, so they can't be paths
+			if v[0] != "" && Slugify(v[0]) != v[0] {
+				return nil, fmt.Errorf("frontmatter: slug %q isn't one, like %q", v[0], Slugify(v[0]))
+			}
+			f.Slug = v[0]
+		case "draft":
+			f.Draft = v[0] == "true"
+		}
+	}
+	// in the same order whatever the order of the keys
+	for _, key := range []string{"labels", "categories", "tags"} {
+		for _, label := range values[key] {
+			f.addLabel(label)
+		}
+	}
+	return f, nil
+}
+
+func (f *File) addLabel(label string) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return
+	}
+	for _, l := range f.Labels {
+		if l == label {
+			return
+		}
+	}
+	f.Labels = append(f.Labels, label)
+}
+
+// ParseDate reads the dates front matter has, those without a time zone
+// being taken as UTC.
+func ParseDate(value string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("frontmatter: %q isn't a date", value)
+}
+
+// Reads YAML up to the closing `---`, returning the values by key and the
+// index of that line.  Values are scalars, or lists written either
+// inline or one `- item` per line.
+func parseYAML(lines []string) (map[string][]string, int, error) {
+	values := make(map[string][]string)
+	var list string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "---" || trimmed == "...":
+			return values, i, nil
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+			continue
+		case strings.HasPrefix(trimmed, "- ") || trimmed == "-":
+			if list != "" {
+				values[list] = append(values[list], unquote(strings.TrimSpace(trimmed[1:])))
+			}
+			continue
+		case line != trimmed:
+			// nested in a key that isn't a list
+			continue
+		}
+
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			return nil, 0, fmt.Errorf("frontmatter: line %d isn't `key: value`", i+2)
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:colon]))
+		value := strings.TrimSpace(line[colon+1:])
+		list = ""
+		if value == "" {
+			list = key
+			continue
+		}
+		if strings.HasPrefix(value, "[") {
+			for !strings.HasSuffix(value, "]") && i+1 < len(lines) {
+				i++
+				value += " " + strings.TrimSpace(lines[i])
+			}
+			values[key] = splitList(value)
+			continue
+		}
+		values[key] = []string{unquote(value)}
+	}
+	return nil, 0, fmt.Errorf("frontmatter: the front matter isn't closed by ---")
+}
+
+// Reads TOML up to the closing `+++`, like parseYAML.  Tables end the
+// values read, since their keys aren't those of the post.
+func parseTOML(lines []string) (map[string][]string, int, error) {
+	values := make(map[string][]string)
+	inTable := false
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		switch {
+		case trimmed == "+++":
+			return values, i, nil
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+			continue
+		case strings.HasPrefix(trimmed, "["):
+			inTable = true
+			continue
+		case inTable:
+			continue
+		}
+
+		equal := strings.Index(trimmed, "=")
+		if equal < 0 {
+			return nil, 0, fmt.Errorf("frontmatter: line %d isn't `key = value`", i+2)
+		}
+		key := strings.ToLower(strings.TrimSpace(trimmed[:equal]))
+		value := strings.TrimSpace(trimmed[equal+1:])
+		if strings.HasPrefix(value, "[") {
+			for !strings.HasSuffix(value, "]") && i+1 < len(lines) {
+				i++
+				value += " " + strings.TrimSpace(lines[i])
+			}
+			values[key] = splitList(value)
+			continue
+		}
+		values[key] = []string{unquote(value)}
+	}
+	return nil, 0, fmt.Errorf("frontmatter: the front matter isn't closed by +++")
+}
+
+// Splits `[a, "b, c"]` in its items.
+func splitList(value string) []string {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	var items []string
+	var quote rune
+	start := 0
+	for i, r := range value {
+		switch {
+		case quote != 0 && r == quote && (i == 0 || value[i-1] != '\\'):
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == ',':
+			items = append(items, value[start:i])
+			start = i + 1
+		}
+	}
+	items = append(items, value[start:])
+
+	var list []string
+	for _, item := range items {
+		if item = unquote(strings.TrimSpace(item)); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// The value of a quoted string, or of a bare one without its comment.
+func unquote(value string) string {
+	switch {
+	case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+		if s, err := strconv.Unquote(value); err == nil {
+			return s
+		}
+		return value[1 : len(value)-1]
+	case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+		return strings.Replace(value[1:len(value)-1], "''", "'", -1)
+	}
+	if comment := strings.Index(value, " #"); comment >= 0 {
+		value = value[:comment]
+	}
+	return strings.TrimSpace(value)
+}
+
+// Write writes the file with YAML front matter.
+func (f *File) Write(w io.Writer) error {
+	var b bytes.Buffer
+	b.WriteString("---\n")
+	if f.Id != 0 {
+		fmt.Fprintf(&b, "id: %d\n", f.Id)
+	}
+	fmt.Fprintf(&b, "title: %s\n", strconv.Quote(f.Title))
+	if !f.Date.IsZero() {
+		fmt.Fprintf(&b, "date: %s\n", f.Date.UTC().Format(dateLayouts[0]))
+	}
+	if f.Slug != "" {
+		fmt.Fprintf(&b, "slug: %s\n", strconv.Quote(f.Slug))
+	}
+	if f.Image != "" {
+		fmt.Fprintf(&b, "image: %s\n", strconv.Quote(f.Image))
+	}
+	if len(f.Labels) != 0 {
+		quoted := make([]string, len(f.Labels))
+		for i, label := range f.Labels {
+			quoted[i] = strconv.Quote(label)
+		}
+		fmt.Fprintf(&b, "labels: [%s]\n", strings.Join(quoted, ", "))
+	}
+	if f.Draft {
+		b.WriteString("draft: true\n")
+	}
+	b.WriteString("---\n\n")
+	b.WriteString(strings.TrimSpace(f.Content))
+	b.WriteString("\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
@@ -0,0 +1,132 @@
+package frontmatter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseYAML(t *testing.T) {
+	f, err := Parse([]byte(`---
+layout: post
+title: "Hello: \"World\""
+date: 2014-03-02 10:00:00 -0500
+tags: [go, 'web, http']
+categories:
+  - go
+  - news
+image: /cover.png # the cover
+params:
+  title: nested
+---
+
+**Hi**
+`))
+	if err != nil {
+		t.Fatal("Couldn't parse", err)
+	}
+	if f.Title != `Hello: "World"` || f.Image != "/cover.png" || f.Content != "**Hi**" {
+		t.Errorf("Expected the values of the front matter, was %+v", f)
+	}
+	if !f.Date.Equal(time.Date(2014, 3, 2, 15, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the date in UTC, was %v", f.Date)
+	}
+	if strings.Join(f.Labels, ",") != "go,news,web, http" {
+		t.Errorf("Expected categories then tags as labels, was %q", f.Labels)
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	f, err := Parse([]byte(`+++
+title = "Hello"
+date = 2014-03-02T10:00:00Z
+slug = 'hello-world'
+draft = true
+tags = [
+  "go",
+  "web",
+]
+
+[params]
+title = "nested"
++++
+Content`))
+	if err != nil {
+		t.Fatal("Couldn't parse", err)
+	}
+	if f.Title != "Hello" || f.Slug != "hello-world" || !f.Draft || f.Content != "Content" ||
+		strings.Join(f.Labels, ",") != "go,web" {
+		t.Errorf("Expected the values of the front matter, was %+v", f)
+	}
+}
+
+func TestParseRefusesOtherFiles(t *testing.T) {
+	cases := []struct {
+		content string
+		err     string
+	}{
+		{``, "empty"},
+		{"# Title\n", "doesn't start"},
+		{"---\ntitle: Hi\n", "isn't closed"},
+		{"---\njust text\n---\n", "isn't `key: value`"},
+		{"---\ndate: yesterday\n---\n", "isn't a date"},
+		{"---\nslug: ../../etc/evil\n---\n", "isn't one"},
+		{"---\nslug: Hello World\n---\n", "isn't one"},
+	}
+	for _, c := range cases {
+		_, err := Parse([]byte(c.content))
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("Expected an error about %q, was %v", c.err, err)
+		}
+	}
+}
+
+func TestWriteParse(t *testing.T) {
+	f := &File{
+		Id:      3,
+		Title:   "Quotes \" and: colons",
+		Date:    time.Date(2014, 3, 2, 10, 0, 0, 0, time.UTC),
+		Labels:  []string{"go", "a, b"},
+		Image:   "/cover.png",
+		Slug:    "quotes",
+		Content: "---\nNot front matter\n",
+	}
+	var b bytes.Buffer
+	if err := f.Write(&b); err != nil {
+		t.Fatal("Couldn't write", err)
+	}
+	read, err := Parse(b.Bytes())
+	if err != nil {
+		t.Fatal("Couldn't parse back", err, b.String())
+	}
+	if read.Id != f.Id || read.Title != f.Title || !read.Date.Equal(f.Date) || read.Image != f.Image ||
+		read.Slug != f.Slug || read.Content != "---\nNot front matter" || strings.Join(read.Labels, "|") != "go|a, b" {
+		t.Errorf("Expected %+v, was %+v", f, read)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	cases := map[string]string{
+		"Hello, World!":     "hello-world",
+		"  Déjà vu  ":       "déjà-vu",
+		"Go 1.2 is out":     "go-1-2-is-out",
+		"!!!":               "",
+		"snake_case--title": "snake-case-title",
+	}
+	for title, expected := range cases {
+		if slug := Slugify(title); slug != expected {
+			t.Errorf("Expected %q for %q, was %q", expected, title, slug)
+		}
+	}
+}
+
+func TestParseFileSlugifiesNames(t *testing.T) {
+	f, err := parseFile("posts/2014-03-02-Hello World.md", []byte("---\ntitle: Hi\n---\n"))
+	if err != nil {
+		t.Fatal("Couldn't parse", err)
+	}
+	if f.Slug != "hello-world" || !f.Date.Equal(time.Date(2014, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the slug and date of the name, was %+v", f)
+	}
+}
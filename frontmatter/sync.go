@@ -0,0 +1,483 @@
+package frontmatter
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/aybabtme/goblog/model"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Jekyll names posts after their date, i.e. `2014-03-02-hello.md`.
+var datedName = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(.+)$`)
+
+// Options tells Import what to do with the files.
+type Options struct {
+	// who writes the posts created from files; without one, only posts
+	// already on the blog can be synced
+	Author *model.Author
+	// update the posts already on the blog to what their file says,
+	// instead of leaving them as they are
+	Sync bool
+	// only tell what would be done
+	DryRun bool
+	// tells if a post already on the blog can be updated, or deleted when
+	// deleting is true; without it, every post can
+	Allow func(post *model.Post, deleting bool) bool
+}
+
+// Report tells what Import did, or would do, with each file, by its path
+// in the directory.
+type Report struct {
+	Created   []string
+	Updated   []string
+	Unchanged []string
+	// drafts, and files already on the blog when not syncing
+	Skipped []string
+	// files removed, or that became drafts when syncing, whose post was
+	// deleted
+	Deleted []string
+	// files that couldn't be imported, like those of posts Options.Allow
+	// refused, and why
+	Failed []string
+}
+
+func (r *Report) fail(name string, format string, args ...interface{}) {
+	r.Failed = append(r.Failed, name+": "+fmt.Sprintf(format, args...))
+}
+
+// Import reads the Markdown files in dir and its subdirectories as posts.
+//
+// A file is the post of its id if it has one and it's still on the blog,
+// or else the post of its slug.  A file without a slug has that of its
+// name, less the date Jekyll puts in front, and a file without a date has
+// that date.  Files whose slug isn't one, like `../post`, fail.  Other
+// files are created as posts of opts.Author.  With opts.Sync, the title,
+// content, date, image, labels and slug of posts already on the blog
+// become those of their file, and those whose file became a draft are
+// deleted.  Posts without a file are kept.
+func Import(conn *model.DBConnection, dir string, opts Options) (*Report, error) {
+	im, err := NewImporter(conn, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !IsPostFile(path) {
+			return nil
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		im.File(name, content)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return im.Report(), nil
+}
+
+// IsPostFile tells if the file at path is a post, by its extension.
+func IsPostFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".md" || ext == ".markdown"
+}
+
+// Importer imports files one at a time, from wherever they come from, the
+// way Import does.  It reads the posts of the blog once, so it's only good
+// for a batch of files.
+type Importer struct {
+	conn   *model.DBConnection
+	opts   Options
+	report *Report
+
+	posts map[int64]*model.Post
+	// post ids by slug
+	slugs map[string]int64
+	// the file each post was read from, to refuse two files for a post
+	seen map[int64]string
+}
+
+// NewImporter makes an importer for a batch of files.
+func NewImporter(conn *model.DBConnection, opts Options) (*Importer, error) {
+	im := &Importer{
+		conn:   conn,
+		opts:   opts,
+		report: &Report{},
+		posts:  make(map[int64]*model.Post),
+		slugs:  make(map[string]int64),
+		seen:   make(map[int64]string),
+	}
+	if err := im.loadPosts(); err != nil {
+		return nil, err
+	}
+	return im, nil
+}
+
+// Report tells what was done with the files so far.
+func (im *Importer) Report() *Report {
+	return im.report
+}
+
+func (im *Importer) loadPosts() error {
+	posts, err := im.conn.FindAllPosts()
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		im.posts[posts[i].Id()] = &posts[i]
+	}
+	slugs, err := im.conn.PostSlugs()
+	if err != nil {
+		return err
+	}
+	for id, slug := range slugs {
+		im.slugs[slug] = id
+	}
+	return nil
+}
+
+// Reads a file, giving it the slug and date of its name if it has none.
+func parseFile(name string, content []byte) (*File, error) {
+	f, err := Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	base := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	if m := datedName.FindStringSubmatch(base); m != nil {
+		base = m[2]
+		if f.Date.IsZero() {
+			f.Date, _ = ParseDate(m[1])
+		}
+	}
+	if f.Slug == "" {
+		f.Slug = Slugify(base)
+	}
+	return f, nil
+}
+
+// The post of a file, by its id or else by its slug.
+func (im *Importer) find(f *File) (*model.Post, bool) {
+	if post, ok := im.posts[f.Id]; ok {
+		return post, true
+	}
+	id, ok := im.slugs[f.Slug]
+	if !ok {
+		return nil, false
+	}
+	post, ok := im.posts[id]
+	return post, ok
+}
+
+// File imports the file named name, returning its post, or nil if it
+// wasn't imported.
+func (im *Importer) File(name string, content []byte) *model.Post {
+	f, err := parseFile(name, content)
+	if err != nil {
+		im.report.fail(name, "%v", err)
+		return nil
+	}
+
+	switch {
+	case f.Draft && im.opts.Sync:
+		// a post whose file became a draft isn't published anymore
+		if post, ok := im.find(f); ok {
+			if other, ok := im.seen[post.Id()]; ok {
+				im.report.fail(name, "post %d is already the one of %s", post.Id(), other)
+				return nil
+			}
+			im.seen[post.Id()] = name
+			im.destroy(name, post)
+			return nil
+		}
+		im.report.Skipped = append(im.report.Skipped, name+": it's a draft")
+		return nil
+	case f.Draft:
+		im.report.Skipped = append(im.report.Skipped, name+": it's a draft")
+		return nil
+	case f.Title == "":
+		im.report.fail(name, "it has no title")
+		return nil
+	case f.Date.IsZero():
+		im.report.fail(name, "it has no date")
+		return nil
+	}
+
+	post, ok := im.find(f)
+	if !ok {
+		return im.create(name, f)
+	}
+	if other, ok := im.seen[post.Id()]; ok {
+		im.report.fail(name, "post %d is already the one of %s", post.Id(), other)
+		return nil
+	}
+	im.seen[post.Id()] = name
+	if !im.opts.Sync {
+		im.report.Skipped = append(im.report.Skipped, fmt.Sprintf("%s: it's post %d already", name, post.Id()))
+		return nil
+	}
+	if !im.allowed(post, false) {
+		im.report.fail(name, "post %d can't be updated by whoever changed the file", post.Id())
+		return nil
+	}
+	return im.update(name, post, f)
+}
+
+// Delete deletes the post of the file named name, that was removed,
+// content being what it was.  Posts that another file of the batch is
+// for, like a file that moved, are kept.  It returns the post deleted, or
+// nil if there was none.
+func (im *Importer) Delete(name string, content []byte) *model.Post {
+	f, err := parseFile(name, content)
+	if err != nil {
+		im.report.fail(name, "%v", err)
+		return nil
+	}
+	post, ok := im.find(f)
+	if !ok {
+		// never imported, like drafts
+		return nil
+	}
+	if _, ok := im.seen[post.Id()]; ok {
+		return nil
+	}
+	return im.destroy(name, post)
+}
+
+// Tells if opts.Allow lets the post be updated, or deleted.
+func (im *Importer) allowed(post *model.Post, deleting bool) bool {
+	return im.opts.Allow == nil || im.opts.Allow(post, deleting)
+}
+
+// Deletes the post of the file named name, forgetting its slug.
+func (im *Importer) destroy(name string, post *model.Post) *model.Post {
+	if !im.allowed(post, true) {
+		im.report.fail(name, "post %d can't be deleted by whoever changed the file", post.Id())
+		return nil
+	}
+	if !im.opts.DryRun {
+		if err := post.Destroy(); err != nil {
+			im.report.fail(name, "couldn't delete post %d: %v", post.Id(), err)
+			return nil
+		}
+	}
+	delete(im.posts, post.Id())
+	for slug, id := range im.slugs {
+		if id == post.Id() {
+			delete(im.slugs, slug)
+		}
+	}
+	im.report.Deleted = append(im.report.Deleted, name)
+	return post
+}
+
+func (im *Importer) create(name string, f *File) *model.Post {
+	if im.opts.Author == nil {
+		im.report.fail(name, "it's a new post, and no author was given to write it")
+		return nil
+	}
+	if _, taken := im.slugs[f.Slug]; taken {
+		im.report.fail(name, "slug %q is taken", f.Slug)
+		return nil
+	}
+
+	post := im.conn.NewPost(im.opts.Author, f.Title, f.Content, f.Image, f.Date)
+	if !im.opts.DryRun {
+		if err := post.Save(); err != nil {
+			im.report.fail(name, "%v", err)
+			return nil
+		}
+		if err := post.SetSlug(f.Slug); err != nil {
+			im.report.fail(name, "couldn't give slug %q: %v", f.Slug, err)
+			// without its slug, the next import would create it again
+			if err := post.Destroy(); err != nil {
+				im.report.fail(name, "couldn't delete post %d: %v", post.Id(), err)
+			}
+			return nil
+		}
+		for _, label := range f.Labels {
+			if _, err := post.AddLabel(label); err != nil {
+				im.report.fail(name, "couldn't add label %q: %v", label, err)
+			}
+		}
+	}
+	im.posts[post.Id()] = post
+	im.seen[post.Id()] = name
+	im.slugs[f.Slug] = post.Id()
+	im.report.Created = append(im.report.Created, name)
+	return post
+}
+
+func (im *Importer) update(name string, post *model.Post, f *File) *model.Post {
+	slug, err := post.Slug()
+	if err != nil {
+		im.report.fail(name, "%v", err)
+		return nil
+	}
+	if id, taken := im.slugs[f.Slug]; taken && id != post.Id() {
+		im.report.fail(name, "slug %q is taken by post %d", f.Slug, id)
+		return nil
+	}
+	labels, err := post.Labels()
+	if err != nil {
+		im.report.fail(name, "%v", err)
+		return nil
+	}
+
+	changed := post.Title() != f.Title ||
+		strings.TrimSpace(post.Content()) != f.Content ||
+		post.ImageURL() != f.Image ||
+		!post.Date().Equal(f.Date) ||
+		slug != f.Slug
+	var removed []model.Label
+	has := make(map[string]bool)
+	for _, label := range labels {
+		has[label.Name()] = true
+		if !contains(f.Labels, label.Name()) {
+			removed = append(removed, label)
+		}
+	}
+	var added []string
+	for _, label := range f.Labels {
+		if !has[label] {
+			added = append(added, label)
+		}
+	}
+	if !changed && len(removed) == 0 && len(added) == 0 {
+		im.report.Unchanged = append(im.report.Unchanged, name)
+		return post
+	}
+
+	if !im.opts.DryRun {
+		post.SetTitle(f.Title)
+		post.SetContent(f.Content)
+		post.SetImageURL(f.Image)
+		post.SetDate(f.Date)
+		if err := post.Update(); err != nil {
+			im.report.fail(name, "%v", err)
+			return nil
+		}
+		if slug != f.Slug {
+			if err := post.SetSlug(f.Slug); err != nil {
+				im.report.fail(name, "couldn't give slug %q: %v", f.Slug, err)
+				return nil
+			}
+		}
+		for i := range removed {
+			if err := post.RemoveLabel(&removed[i]); err != nil {
+				im.report.fail(name, "couldn't remove label %q: %v", removed[i].Name(), err)
+			}
+		}
+		for _, label := range added {
+			if _, err := post.AddLabel(label); err != nil {
+				im.report.fail(name, "couldn't add label %q: %v", label, err)
+			}
+		}
+	}
+	delete(im.slugs, slug)
+	im.slugs[f.Slug] = post.Id()
+	im.report.Updated = append(im.report.Updated, name)
+	return post
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Export writes every post in dir as `slug.md`, returning how many were
+// written.  Posts without a slug, or with one that isn't, are named after
+// their title; their file keeps their id, so that importing it finds them.
+func Export(conn *model.DBConnection, dir string) (int, error) {
+	posts, err := conn.FindAllPosts()
+	if err != nil {
+		return 0, err
+	}
+	slugs, err := conn.PostSlugs()
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].Id() < posts[j].Id() })
+
+	taken := make(map[string]bool)
+	for _, slug := range slugs {
+		taken[slug] = true
+	}
+	for i := range posts {
+		post := &posts[i]
+		slug, ok := slugs[post.Id()]
+		// slugs given before they were checked could name files elsewhere
+		if !ok || Slugify(slug) != slug {
+			slug = Slugify(post.Title())
+			if slug == "" || taken[slug] {
+				slug = strings.TrimPrefix(slug+"-", "-") + strconv.FormatInt(post.Id(), 10)
+			}
+			taken[slug] = true
+		}
+
+		labels, err := post.Labels()
+		if err != nil {
+			return i, err
+		}
+		f := &File{
+			Id:      post.Id(),
+			Title:   post.Title(),
+			Date:    post.Date(),
+			Image:   post.ImageURL(),
+			Slug:    slug,
+			Content: post.Content(),
+		}
+		for _, label := range labels {
+			f.Labels = append(f.Labels, label.Name())
+		}
+
+		var b bytes.Buffer
+		if err := f.Write(&b); err != nil {
+			return i, err
+		}
+		if err := os.WriteFile(filepath.Join(dir, slug+".md"), b.Bytes(), 0644); err != nil {
+			return i, err
+		}
+	}
+	return len(posts), nil
+}
+
+// Slugify makes a slug of a title, i.e. `hello-world` of "Hello, World!".
+func Slugify(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() != 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
@@ -0,0 +1,143 @@
+package frontmatter
+
+import (
+	"github.com/aybabtme/goblog/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportImport(t *testing.T) {
+	exportImport(t, setupPGConnection())
+}
+
+func exportImport(t *testing.T, conn *model.DBConnection) {
+	defer conn.DeleteConnection()
+
+	date := time.Date(2014, 3, 2, 10, 0, 0, 0, time.UTC)
+	user := conn.NewUser("writer", date, 0, "g+writer", "", "", "writer@example.com")
+	author := conn.NewAuthor(user)
+	if err := author.Save(); err != nil {
+		t.Fatal("Couldn't save author", err)
+	}
+	post := conn.NewPost(author, "Hello, World", "Content", "", date)
+	if err := post.Save(); err != nil {
+		t.Fatal("Couldn't save post", err)
+	}
+	post.AddLabel("go")
+
+	dir, err := os.MkdirTemp("", "goblog-posts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if n, err := Export(conn, dir); err != nil || n != 1 {
+		t.Fatalf("Expected to export the post, was %d, %v", n, err)
+	}
+	exported := filepath.Join(dir, "hello-world.md")
+	if _, err := os.Stat(exported); err != nil {
+		t.Fatal("Expected the post to be named after its title", err)
+	}
+
+	// the exported post is edited, and a new one is written
+	f := &File{Id: post.Id(), Title: "Hello", Date: date, Labels: []string{"web"}, Slug: "hello", Content: "Edited"}
+	writeFile(t, exported, f)
+	writeFile(t, filepath.Join(dir, "2014-03-03-second.md"), &File{Title: "Second", Content: "New"})
+	writeFile(t, filepath.Join(dir, "later.md"), &File{Title: "Later", Date: date, Draft: true})
+
+	report, err := Import(conn, dir, Options{Author: author})
+	if err != nil {
+		t.Fatal("Couldn't import", err)
+	}
+	if len(report.Created) != 1 || len(report.Skipped) != 2 || len(report.Updated) != 0 {
+		t.Errorf("Expected only the new post to be created, was %+v", report)
+	}
+	second, err := conn.FindPostBySlug("second")
+	if err != nil || !second.Date().Equal(time.Date(2014, 3, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the post dated from its file name, was %v, %v", second, err)
+	}
+
+	report, err = Import(conn, dir, Options{Sync: true})
+	if err != nil {
+		t.Fatal("Couldn't sync", err)
+	}
+	if len(report.Updated) != 1 || len(report.Unchanged) != 1 || len(report.Failed) != 0 {
+		t.Errorf("Expected the edited post to be updated, was %+v", report)
+	}
+	synced, err := conn.FindPostBySlug("hello")
+	if err != nil || synced.Id() != post.Id() || synced.Title() != "Hello" || synced.Content() != "Edited" {
+		t.Fatalf("Expected the post to be synced, was %v, %v", synced, err)
+	}
+	if labels, _ := synced.Labels(); len(labels) != 1 || labels[0].Name() != "web" {
+		t.Errorf("Expected the labels of the file, was %+v", labels)
+	}
+
+	// a post whose file becomes a draft is taken down
+	writeFile(t, filepath.Join(dir, "2014-03-03-second.md"), &File{Title: "Second", Content: "New", Draft: true})
+	report, err = Import(conn, dir, Options{Sync: true})
+	if err != nil {
+		t.Fatal("Couldn't sync", err)
+	}
+	if len(report.Deleted) != 1 || len(report.Updated) != 0 {
+		t.Errorf("Expected the post of the draft to be deleted, was %+v", report)
+	}
+	if _, err := conn.FindPostBySlug("second"); err == nil {
+		t.Error("Expected the post of the draft to be gone")
+	}
+
+	// slugs that aren't ones are refused, and never name files
+	writeFile(t, filepath.Join(dir, "evil.md"), &File{Title: "Evil", Date: date, Slug: "../evil"})
+	report, err = Import(conn, dir, Options{Author: author})
+	if err != nil {
+		t.Fatal("Couldn't import", err)
+	}
+	if len(report.Failed) != 1 || len(report.Created) != 0 {
+		t.Errorf("Expected the file with a path for slug to fail, was %+v", report)
+	}
+	if err := synced.SetSlug("../evil"); err == nil {
+		t.Error("Expected a path to be refused as a slug")
+	}
+	os.Remove(filepath.Join(dir, "evil.md"))
+
+	// Jekyll files of the same day are posts of the same date
+	writeFile(t, filepath.Join(dir, "2014-03-04-a.md"), &File{Title: "A", Labels: []string{"a"}})
+	writeFile(t, filepath.Join(dir, "2014-03-04-b.md"), &File{Title: "B", Labels: []string{"b"}})
+	report, err = Import(conn, dir, Options{Author: author})
+	if err != nil || len(report.Created) != 2 || len(report.Failed) != 0 {
+		t.Fatalf("Expected both posts to be created, was %+v, %v", report, err)
+	}
+	for _, slug := range []string{"a", "b"} {
+		post, err := conn.FindPostBySlug(slug)
+		if err != nil {
+			t.Fatalf("Expected the post of %s.md, %v", slug, err)
+		}
+		labels, _ := post.Labels()
+		if post.Title() != strings.ToUpper(slug) || len(labels) != 1 || labels[0].Name() != slug {
+			t.Errorf("Expected the slug and labels of %s.md on its post, was %q, %+v", slug, post.Title(), labels)
+		}
+	}
+	report, err = Import(conn, dir, Options{Author: author})
+	if err != nil || len(report.Created) != 0 {
+		t.Errorf("Expected nothing created again, was %+v, %v", report, err)
+	}
+}
+
+func writeFile(t *testing.T, path string, f *File) {
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := f.Write(file); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setupPGConnection() *model.DBConnection {
+	modelurl := "user=antoine dbname=test sslmode=disable"
+	conn, _ := model.NewConnection(model.NewPostgreser(modelurl))
+	return conn
+}
@@ -0,0 +1,254 @@
+// Package gitsync keeps posts up to date with the Markdown files of a git
+// repository, so that publishing a post is pushing it to that repository.
+package gitsync
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/aybabtme/goblog/auth"
+	"github.com/aybabtme/goblog/frontmatter"
+	"github.com/aybabtme/goblog/model"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Repo is a branch of a git repository on disk, bare or checked out, whose
+// Markdown files are posts, written the way frontmatter reads them.
+type Repo struct {
+	Dir string
+	// the branch followed, HEAD by default
+	Branch string
+	// the directory of the repository the posts are in, all of it by default
+	Path string
+}
+
+// Commit is a commit that was synced, and what it did to the posts.
+type Commit struct {
+	Hash   string
+	Email  string
+	Date   time.Time
+	Report *frontmatter.Report
+}
+
+// Sync brings the posts up to date with the commits made on the branch
+// since the last sync, or with every commit the first time, one commit at
+// a time.
+//
+// Posts are created for the files a commit adds, attributed to the author
+// whose user has the email of the commit.  Files changed update their post
+// and files removed or renamed delete it, if that user can update or
+// delete the post.  Each post created or updated gets a revision recording
+// the commit.  Merges are synced as a whole, like the commits of the
+// branch they follow.  Files that can't be imported, like those of people
+// who aren't authors or of posts they can't change, are in the reports of
+// their commit; they don't stop the sync.
+func Sync(conn *model.DBConnection, repo Repo) ([]Commit, error) {
+	if repo.Branch == "" {
+		repo.Branch = "HEAD"
+	}
+	source, err := repo.source()
+	if err != nil {
+		return nil, err
+	}
+	since, err := conn.SyncedCommit(source)
+	if err != nil {
+		return nil, err
+	}
+	hashes, err := repo.commits(since)
+	if err != nil {
+		return nil, err
+	}
+
+	var synced []Commit
+	for _, hash := range hashes {
+		commit, err := repo.sync(conn, hash)
+		if err != nil {
+			return synced, err
+		}
+		if err := conn.SetSyncedCommit(source, hash); err != nil {
+			return synced, err
+		}
+		synced = append(synced, *commit)
+	}
+	return synced, nil
+}
+
+// What the sync of that branch and directory is recorded as.
+func (r Repo) source() (string, error) {
+	dir, err := filepath.Abs(r.Dir)
+	if err != nil {
+		return "", err
+	}
+	return dir + ":" + r.Branch + ":" + r.Path, nil
+}
+
+func (r Repo) git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", r.Dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("gitsync: git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// The commits of the branch after since, from the oldest, following only
+// the first parent of merges.  All of them if since isn't on the branch
+// anymore, like after a forced push.
+func (r Repo) commits(since string) ([]string, error) {
+	out, err := r.git("rev-parse", "--verify", r.Branch+"^{commit}")
+	if err != nil {
+		return nil, err
+	}
+	head := strings.TrimSpace(string(out))
+	if head == since {
+		return nil, nil
+	}
+
+	revs := head
+	if since != "" {
+		if _, err := r.git("merge-base", "--is-ancestor", since, head); err == nil {
+			revs = since + ".." + head
+		}
+	}
+	out, err = r.git("rev-list", "--reverse", "--first-parent", revs)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// A file a commit changed.
+type change struct {
+	// A, M, D, R or C, like git says it
+	status byte
+	path   string
+	// where a file renamed or copied comes from
+	from string
+}
+
+// The files the commit changed from its first parent.
+func (r Repo) changes(hash string, parents []string) ([]change, error) {
+	args := []string{"diff-tree", "-r", "-z", "--name-status", "-M", "--no-commit-id"}
+	if len(parents) == 0 {
+		args = append(args, "--root", hash)
+	} else {
+		args = append(args, parents[0], hash)
+	}
+	out, err := r.git(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []change
+	fields := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		c := change{status: fields[i][0], path: fields[i+1]}
+		if c.status == 'R' || c.status == 'C' {
+			if i+2 >= len(fields) {
+				break
+			}
+			c.from, c.path = c.path, fields[i+2]
+			i++
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+// Tells if the file at path is a post.
+func (r Repo) isPost(path string) bool {
+	if r.Path != "" && !strings.HasPrefix(path, strings.Trim(r.Path, "/")+"/") {
+		return false
+	}
+	return frontmatter.IsPostFile(path)
+}
+
+func (r Repo) sync(conn *model.DBConnection, hash string) (*Commit, error) {
+	out, err := r.git("show", "-s", "--format=%P%x00%ae%x00%aI", hash)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Split(strings.TrimSpace(string(out)), "\x00")
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("gitsync: can't read commit %s", hash)
+	}
+	date, err := time.Parse(time.RFC3339, fields[2])
+	if err != nil {
+		return nil, err
+	}
+	commit := &Commit{Hash: hash, Email: fields[1], Date: date.UTC()}
+	changes, err := r.changes(hash, strings.Fields(fields[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	// whoever isn't a user of the blog changes nothing, and people who
+	// aren't authors can still edit the posts they may
+	user, _ := conn.FindUserByEmail(commit.Email)
+	opts := frontmatter.Options{
+		Sync: true,
+		Allow: func(post *model.Post, deleting bool) bool {
+			if deleting {
+				return auth.Can(user, auth.Delete, post)
+			}
+			return auth.Can(user, auth.Update, post)
+		},
+	}
+	if user != nil {
+		if author, err := conn.FindAuthorByUserId(user.Id()); err == nil {
+			opts.Author = author
+		}
+	}
+	im, err := frontmatter.NewImporter(conn, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// files written before files removed, so that a post whose file moved
+	// isn't deleted
+	var removed []change
+	for _, c := range changes {
+		switch {
+		case c.status == 'D' && r.isPost(c.path):
+			removed = append(removed, c)
+			continue
+		case c.status == 'D':
+			continue
+		}
+		// the post of a file that moved is kept if its new file is still
+		// for it, like exported files that have its id
+		if c.status == 'R' && r.isPost(c.from) {
+			removed = append(removed, change{status: 'D', path: c.from})
+		}
+		if !r.isPost(c.path) {
+			continue
+		}
+
+		content, err := r.git("cat-file", "blob", hash+":"+c.path)
+		if err != nil {
+			return nil, err
+		}
+		post := im.File(c.path, content)
+		if post == nil {
+			continue
+		}
+		if err := post.AddRevision(hash, commit.Email, commit.Date); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, c := range removed {
+		content, err := r.git("cat-file", "blob", hash+"^:"+c.path)
+		if err != nil {
+			return nil, err
+		}
+		im.Delete(c.path, content)
+	}
+
+	commit.Report = im.Report()
+	return commit, nil
+}
@@ -0,0 +1,190 @@
+package gitsync
+
+import (
+	"github.com/aybabtme/goblog/model"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Makes a repository to commit posts in, skipping the test without git.
+func setupRepo(t *testing.T) (Repo, func()) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	dir, err := os.MkdirTemp("", "goblog-git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := Repo{Dir: dir, Branch: "HEAD", Path: "posts"}
+	run(t, repo, "init", "-q")
+	return repo, func() { os.RemoveAll(dir) }
+}
+
+func run(t *testing.T, repo Repo, args ...string) {
+	cmd := exec.Command("git", append([]string{"-C", repo.Dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Writer", "GIT_AUTHOR_EMAIL=writer@example.com",
+		"GIT_COMMITTER_NAME=Writer", "GIT_COMMITTER_EMAIL=writer@example.com")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+}
+
+func write(t *testing.T, repo Repo, path, content string) {
+	path = filepath.Join(repo.Dir, path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func commit(t *testing.T, repo Repo, message string) string {
+	run(t, repo, "add", "-A")
+	run(t, repo, "commit", "-q", "-m", message)
+	out, err := repo.git("rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestCommitsAndChanges(t *testing.T) {
+	repo, cleanup := setupRepo(t)
+	defer cleanup()
+
+	write(t, repo, "posts/hello.md", "---\ntitle: Hello\ndate: 2014-03-02\n---\nHi\n")
+	write(t, repo, "README.md", "Not a post\n")
+	first := commit(t, repo, "First post")
+	run(t, repo, "mv", "posts/hello.md", "posts/hello-world.md")
+	write(t, repo, "posts/second.md", "---\ntitle: Second\ndate: 2014-03-03\n---\nAgain\n")
+	second := commit(t, repo, "Rename and add")
+
+	commits, err := repo.commits("")
+	if err != nil || strings.Join(commits, ",") != first+","+second {
+		t.Fatalf("Expected both commits from the oldest, was %v, %v", commits, err)
+	}
+	if commits, err := repo.commits(first); err != nil || len(commits) != 1 || commits[0] != second {
+		t.Errorf("Expected the commit after the first, was %v, %v", commits, err)
+	}
+	if commits, err := repo.commits(second); err != nil || len(commits) != 0 {
+		t.Errorf("Expected no commit to sync, was %v, %v", commits, err)
+	}
+	// a commit the branch doesn't have anymore
+	if commits, err := repo.commits("0123456789abcdef0123456789abcdef01234567"); err != nil || len(commits) != 2 {
+		t.Errorf("Expected every commit, was %v, %v", commits, err)
+	}
+
+	changes, err := repo.changes(first, nil)
+	if err != nil || len(changes) != 2 {
+		t.Fatalf("Expected the two files of the first commit, was %+v, %v", changes, err)
+	}
+	changes, err = repo.changes(second, []string{first})
+	if err != nil || len(changes) != 2 {
+		t.Fatalf("Expected a rename and an addition, was %+v, %v", changes, err)
+	}
+	for _, c := range changes {
+		switch c.path {
+		case "posts/hello-world.md":
+			if c.status != 'R' || c.from != "posts/hello.md" {
+				t.Errorf("Expected a rename, was %+v", c)
+			}
+		case "posts/second.md":
+			if c.status != 'A' {
+				t.Errorf("Expected an addition, was %+v", c)
+			}
+		default:
+			t.Errorf("Unexpected change %+v", c)
+		}
+	}
+
+	if !repo.isPost("posts/a.md") || repo.isPost("README.md") || repo.isPost("posts/a.png") {
+		t.Error("Expected only the Markdown files of the path to be posts")
+	}
+}
+
+func TestSync(t *testing.T) {
+	repo, cleanup := setupRepo(t)
+	defer cleanup()
+	sync(t, setupPGConnection(), repo)
+}
+
+func sync(t *testing.T, conn *model.DBConnection, repo Repo) {
+	defer conn.DeleteConnection()
+
+	user := conn.NewUser("writer", time.Now(), 0, "g+writer", "", "", "writer@example.com")
+	if err := conn.NewAuthor(user).Save(); err != nil {
+		t.Fatal("Couldn't save author", err)
+	}
+
+	write(t, repo, "posts/hello.md", "---\ntitle: Hello\ndate: 2014-03-02\n---\nHi\n")
+	first := commit(t, repo, "First post")
+	commits, err := Sync(conn, repo)
+	if err != nil || len(commits) != 1 || len(commits[0].Report.Created) != 1 {
+		t.Fatalf("Expected the post to be created, was %+v, %v", commits, err)
+	}
+	post, err := conn.FindPostBySlug("hello")
+	if err != nil || post.Author().User().Email() != "writer@example.com" {
+		t.Fatalf("Expected the post of the writer, was %v, %v", post, err)
+	}
+
+	write(t, repo, "posts/hello.md", "---\ntitle: Hello\ndate: 2014-03-02\n---\nEdited\n")
+	second := commit(t, repo, "Edit")
+	if commits, err := Sync(conn, repo); err != nil || len(commits) != 1 || len(commits[0].Report.Updated) != 1 {
+		t.Fatalf("Expected the post to be updated, was %+v, %v", commits, err)
+	}
+	if commits, err := Sync(conn, repo); err != nil || len(commits) != 0 {
+		t.Errorf("Expected nothing new to sync, was %+v, %v", commits, err)
+	}
+	revisions, err := post.Revisions()
+	if err != nil || len(revisions) != 2 || revisions[0].Commit() != first || revisions[1].Commit() != second {
+		t.Errorf("Expected a revision by commit, was %+v, %v", revisions, err)
+	}
+
+	// a file renamed to another slug is another post
+	run(t, repo, "mv", "posts/hello.md", "posts/hello-world.md")
+	commit(t, repo, "Rename")
+	commits, err = Sync(conn, repo)
+	if err != nil || len(commits) != 1 || len(commits[0].Report.Created) != 1 || len(commits[0].Report.Deleted) != 1 {
+		t.Fatalf("Expected the post to be replaced, was %+v, %v", commits, err)
+	}
+	if _, err := conn.FindPostById(post.Id()); err == nil {
+		t.Error("Expected the post of the old name to be gone")
+	}
+	renamed, err := conn.FindPostBySlug("hello-world")
+	if err != nil {
+		t.Fatal("Expected the post of the new name", err)
+	}
+
+	// people who can't change a post don't
+	write(t, repo, "posts/hello-world.md", "---\ntitle: Hello\ndate: 2014-03-02\n---\nDefaced\n")
+	run(t, repo, "add", "-A")
+	run(t, repo, "commit", "-q", "--author", "Stranger <stranger@example.com>", "-m", "Deface")
+	commits, err = Sync(conn, repo)
+	if err != nil || len(commits) != 1 || len(commits[0].Report.Failed) != 1 || len(commits[0].Report.Updated) != 0 {
+		t.Fatalf("Expected the edit to be refused, was %+v, %v", commits, err)
+	}
+	if found, err := conn.FindPostById(renamed.Id()); err != nil || found.Content() != "Edited" {
+		t.Errorf("Expected the post as it was, was %v, %v", found, err)
+	}
+
+	run(t, repo, "rm", "-q", "posts/hello-world.md")
+	commit(t, repo, "Remove")
+	if commits, err := Sync(conn, repo); err != nil || len(commits) != 1 || len(commits[0].Report.Deleted) != 1 {
+		t.Fatalf("Expected the post to be deleted, was %+v, %v", commits, err)
+	}
+	if _, err := conn.FindPostById(renamed.Id()); err == nil {
+		t.Error("Expected the post to be gone")
+	}
+}
+
+func setupPGConnection() *model.DBConnection {
+	modelurl := "user=antoine dbname=test sslmode=disable"
+	conn, _ := model.NewConnection(model.NewPostgreser(modelurl))
+	return conn
+}
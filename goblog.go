@@ -2,8 +2,12 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"github.com/aybabtme/goblog/auth"
+	"github.com/aybabtme/goblog/ctlr"
+	"github.com/aybabtme/goblog/media"
 	"github.com/aybabtme/goblog/model"
+	"github.com/aybabtme/goblog/view"
 	"github.com/aybabtme/gypsum"
 	"log"
 	"math/rand"
@@ -17,6 +21,7 @@ import (
 
 var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to file")
 var debug = flag.Bool("debug", false, "write random data on the database before starting the blog")
+var dev = flag.Bool("dev", false, "reload templates when they change and show template errors in the browser")
 var createAdmin = flag.Bool("create-admin", false, "interactively creates an admin user before starting the blog")
 
 func main() {
@@ -31,6 +36,14 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
+	if flag.NArg() != 0 {
+		if err := runCommand(flag.Args()); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	modelurl := os.Getenv("DATABASE_URL")
 	if modelurl == "" {
 		log.Println("Need a database to connect to!\n" +
@@ -44,6 +57,13 @@ func main() {
 		return
 	}
 
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Println("Couldn't configure the blog.")
+		panic(err)
+	}
+	cfg.Port = port
+
 	conn, err := setupDatabase(modelurl)
 	if err != nil {
 		log.Println("Couldn't connect to database.")
@@ -68,11 +88,99 @@ func main() {
 
 	log.Println("Starting router")
 	var r Router
-	if err := r.Start(port, conn); err != nil {
+	if err := r.Start(conn, cfg); err != nil {
 		panic(err)
 	}
 }
 
+// Reads how the blog is set up from the environment, but for its port.
+func loadConfig() (Config, error) {
+	cfg := Config{
+		SessionKeys: auth.ParseSessionKeys(os.Getenv("SESSION_KEYS")),
+		Dev:         *dev,
+		AssetsDir:   os.Getenv("ASSETS_DIR"),
+		Site: ctlr.Site{
+			Title:       os.Getenv("SITE_TITLE"),
+			Description: os.Getenv("SITE_DESCRIPTION"),
+			URL:         os.Getenv("SITE_URL"),
+		},
+	}
+	if cfg.AssetsDir == "" && *dev {
+		// when working on the templates, they're in the repository
+		cfg.AssetsDir = "."
+	}
+	if dir := os.Getenv("THEME"); dir != "" {
+		theme, err := view.LoadTheme(dir)
+		if err != nil {
+			return cfg, fmt.Errorf("couldn't load theme in %s: %v", dir, err)
+		}
+		cfg.Theme = theme
+	}
+
+	if path := os.Getenv("ROBOTS_TXT"); path != "" {
+		rules, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("couldn't read robots.txt rules in %s: %v", path, err)
+		}
+		cfg.Site.Robots = string(rules)
+	}
+
+	store, err := loadMediaStore()
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Media = store
+	cfg.MediaCacheDir = os.Getenv("MEDIA_CACHE_DIR")
+	if cfg.MediaCacheDir == "" {
+		cfg.MediaCacheDir = "cache"
+	}
+	cfg.MediaMaxSize = media.DefaultMaxSize
+	if mb := os.Getenv("MEDIA_MAX_MB"); mb != "" {
+		n, err := strconv.ParseInt(mb, 10, 64)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("MEDIA_MAX_MB isn't a number of megabytes: %q", mb)
+		}
+		cfg.MediaMaxSize = n << 20
+	}
+
+	if list := os.Getenv("MARKDOWN"); list != "" {
+		options, err := model.ParseMarkdownOptions(list)
+		if err != nil {
+			return cfg, fmt.Errorf("MARKDOWN: %v", err)
+		}
+		cfg.Markdown = &options
+	}
+	return cfg, nil
+}
+
+// Uploads go to the S3 bucket MEDIA_S3_BUCKET when it's set, else in the
+// directory MEDIA_DIR.
+func loadMediaStore() (media.MediaStore, error) {
+	if bucket := os.Getenv("MEDIA_S3_BUCKET"); bucket != "" {
+		endpoint := os.Getenv("MEDIA_S3_ENDPOINT")
+		if endpoint == "" {
+			endpoint = "https://s3.amazonaws.com"
+		}
+		return media.NewS3Store(media.S3Config{
+			Endpoint:  endpoint,
+			Region:    os.Getenv("MEDIA_S3_REGION"),
+			Bucket:    bucket,
+			AccessKey: os.Getenv("MEDIA_S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("MEDIA_S3_SECRET_KEY"),
+			PublicURL: os.Getenv("MEDIA_S3_PUBLIC_URL"),
+		})
+	}
+	dir := os.Getenv("MEDIA_DIR")
+	if dir == "" {
+		dir = "uploads"
+	}
+	store, err := media.NewLocalStore(dir, "/media/")
+	if err != nil {
+		return nil, fmt.Errorf("couldn't keep uploads in %s: %v", dir, err)
+	}
+	return store, nil
+}
+
 func setupDatabase(modelurl string) (*model.DBConnection, error) {
 	postgres := model.NewPostgreser(modelurl)
 	conn, err := model.NewConnection(postgres)
@@ -0,0 +1,276 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+)
+
+// The orientation a camera saved a JPEG in, from 1 for upright to 8, as
+// its EXIF tag tells, or 1 when it has none.  See
+// https://www.exif.org/Exif2-2.PDF, page 18.
+func orientation(jpeg []byte) int {
+	if len(jpeg) < 4 || jpeg[0] != 0xFF || jpeg[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(jpeg); {
+		if jpeg[i] != 0xFF {
+			return 1
+		}
+		marker := jpeg[i+1]
+		length := int(binary.BigEndian.Uint16(jpeg[i+2:]))
+		// the segments with metadata are before the image data
+		if marker == 0xDA || length < 2 || i+2+length > len(jpeg) {
+			return 1
+		}
+		segment := jpeg[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < entries; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		// a SHORT, whose value is in the first bytes of the entry's
+		if order.Uint16(tiff[entry:]) == 0x0112 && order.Uint16(tiff[entry+2:]) == 3 {
+			if o := int(order.Uint16(tiff[entry+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// Turns img upright, as it was taken in the orientation o.
+func orient(img image.Image, o int) image.Image {
+	if o <= 1 || o > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		for dx := 0; dx < dw; dx++ {
+			var x, y int
+			switch o {
+			case 2: // mirrored
+				x, y = w-1-dx, dy
+			case 3: // upside down
+				x, y = w-1-dx, h-1-dy
+			case 4: // upside down and mirrored
+				x, y = dx, h-1-dy
+			case 5: // on its left side and mirrored
+				x, y = dy, dx
+			case 6: // on its left side
+				x, y = dy, h-1-dx
+			case 7: // on its right side and mirrored
+				x, y = w-1-dy, h-1-dx
+			case 8: // on its right side
+				x, y = w-1-dy, dx
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], src.Pix[src.PixOffset(x, y):][:4])
+		}
+	}
+	return dst
+}
+
+// Strips the metadata of a picture of that type, like where it was taken
+// and with what, keeping what's needed to show it: its color profile and
+// the orientation of JPEGs.  Pictures that can't be read are kept as they
+// are.
+func stripMetadata(contentType string, picture []byte) []byte {
+	var stripped []byte
+	switch contentType {
+	case "image/jpeg":
+		stripped = stripJPEG(picture)
+	case "image/png":
+		stripped = stripPNG(picture)
+	case "image/webp":
+		stripped = stripWebP(picture)
+	}
+	if stripped == nil {
+		return picture
+	}
+	return stripped
+}
+
+// Keeps the segments of a JPEG but those of applications, less JFIF, ICC
+// profiles and Adobe's, and comments.  The orientation is kept in an EXIF
+// segment of its own.  Returns nil if it's not a JPEG.
+func stripJPEG(jpeg []byte) []byte {
+	if len(jpeg) < 4 || jpeg[0] != 0xFF || jpeg[1] != 0xD8 {
+		return nil
+	}
+	out := []byte{0xFF, 0xD8}
+	if o := orientation(jpeg); o != 1 {
+		out = append(out, orientationSegment(o)...)
+	}
+	for i := 2; i < len(jpeg); {
+		if i+2 > len(jpeg) || jpeg[i] != 0xFF {
+			return nil
+		}
+		marker := jpeg[i+1]
+		if marker == 0xFF {
+			// fill byte
+			i++
+			continue
+		}
+		// the image data follows, up to the end
+		if marker == 0xDA {
+			return append(out, jpeg[i:]...)
+		}
+		if i+4 > len(jpeg) {
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(jpeg[i+2:]))
+		if length < 2 || i+2+length > len(jpeg) {
+			return nil
+		}
+		segment := jpeg[i : i+2+length]
+		i += 2 + length
+
+		if keepSegment(marker, segment[4:]) {
+			out = append(out, segment...)
+		}
+	}
+	return nil
+}
+
+// Tells if a JPEG segment is needed to show the picture.
+func keepSegment(marker byte, data []byte) bool {
+	switch {
+	case marker == 0xFE:
+		// a comment
+		return false
+	case marker == 0xE0, marker == 0xEE:
+		// JFIF, and Adobe's color transform
+		return true
+	case marker == 0xE2:
+		return bytes.HasPrefix(data, []byte("ICC_PROFILE\x00"))
+	case marker > 0xE0 && marker <= 0xEF:
+		// EXIF, XMP, IPTC and the like
+		return false
+	}
+	return true
+}
+
+// An EXIF segment telling only the orientation o.
+func orientationSegment(o int) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	// one entry, a SHORT
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3)
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, uint16(o))
+	// padding of the value, then no next IFD
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	exif := append([]byte("Exif\x00\x00"), tiff...)
+
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(exif)+2))
+	return append(segment, exif...)
+}
+
+// PNG chunks with text, EXIF or the time the picture was made.
+var pngMetadata = map[string]bool{"tEXt": true, "zTXt": true, "iTXt": true, "eXIf": true, "tIME": true}
+
+// Keeps the chunks of a PNG but those of metadata.  Returns nil if it's
+// not a PNG.
+func stripPNG(png []byte) []byte {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(png, []byte(signature)) {
+		return nil
+	}
+	out := []byte(signature)
+	for i := len(signature); i < len(png); {
+		if i+8 > len(png) {
+			return nil
+		}
+		length := int(binary.BigEndian.Uint32(png[i:]))
+		kind := string(png[i+4 : i+8])
+		// the length, type, data and CRC
+		end := i + 12 + length
+		if length < 0 || end > len(png) || end < i {
+			return nil
+		}
+		if !pngMetadata[kind] {
+			out = append(out, png[i:end]...)
+		}
+		i = end
+		if kind == "IEND" {
+			return out
+		}
+	}
+	return nil
+}
+
+// Keeps the chunks of a WebP but its EXIF and XMP ones, telling it has
+// none anymore.  Returns nil if it's not a WebP.
+func stripWebP(webp []byte) []byte {
+	if len(webp) < 12 || string(webp[:4]) != "RIFF" || string(webp[8:12]) != "WEBP" {
+		return nil
+	}
+	out := append([]byte{}, webp[:12]...)
+	for i := 12; i < len(webp); {
+		if i+8 > len(webp) {
+			return nil
+		}
+		kind := string(webp[i : i+4])
+		length := int(binary.LittleEndian.Uint32(webp[i+4:]))
+		// chunks are padded to an even length
+		end := i + 8 + length + length%2
+		if length < 0 || end > len(webp) || end < i {
+			return nil
+		}
+		chunk := webp[i:end]
+		i = end
+		switch kind {
+		case "EXIF", "XMP ":
+			continue
+		case "VP8X":
+			if length < 1 {
+				return nil
+			}
+			chunk = append([]byte{}, chunk...)
+			// the flags of EXIF and XMP
+			chunk[8] &^= 0x08 | 0x04
+		}
+		out = append(out, chunk...)
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out
+}
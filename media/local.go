@@ -0,0 +1,80 @@
+package media
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore keeps files in a directory, the blog serving them under its
+// prefix.
+type LocalStore struct {
+	dir    string
+	prefix string
+}
+
+// NewLocalStore keeps files in dir, creating it if needed, for the blog to
+// serve them under prefix, like "/media/".
+func NewLocalStore(dir string, prefix string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &LocalStore{dir: dir, prefix: prefix}, nil
+}
+
+func (s *LocalStore) path(name string) (string, error) {
+	if !ValidName(name) {
+		return "", fmt.Errorf("media: invalid name %q", name)
+	}
+	return filepath.Join(s.dir, name), nil
+}
+
+// Put writes the file next to where it goes and then moves it there, so
+// that nobody reads half of it.
+func (s *LocalStore) Put(name string, content io.Reader, size int64, contentType string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".upload-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalStore) Open(name string) (io.ReadCloser, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (s *LocalStore) Delete(name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (s *LocalStore) URL(name string) string {
+	return s.prefix + name
+}
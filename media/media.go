@@ -0,0 +1,127 @@
+// Package media keeps the files authors upload for their posts, in a store
+// that can be the local disk or an S3-compatible bucket.
+package media
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// MediaStore keeps uploaded files by name.
+type MediaStore interface {
+	// Put stores size bytes of content under name, replacing what was
+	// there.
+	Put(name string, content io.Reader, size int64, contentType string) error
+	// Open reads what's stored under name.
+	Open(name string) (io.ReadCloser, error)
+	Delete(name string) error
+	// URL is where visitors get what's stored under name.
+	URL(name string) string
+}
+
+// DefaultMaxSize is the size of the largest upload, unless configured
+// otherwise.
+const DefaultMaxSize = 10 << 20
+
+// The types of files that can be uploaded, and their extension.  Types
+// that browsers run, like SVG or HTML, aren't there.
+var types = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+var (
+	ErrTooLarge        = errors.New("media: the file is too large")
+	ErrUnsupportedType = errors.New("media: that type of file can't be uploaded")
+)
+
+// The names Upload gives, the only ones the stores accept.
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// ValidName tells if name can be that of a file in a store.
+func ValidName(name string) bool {
+	return len(name) <= 255 && validName.MatchString(name) && !strings.Contains(name, "..")
+}
+
+// NameOf is the name of the file of store at url, if that's where one is.
+func NameOf(store MediaStore, url string) (string, bool) {
+	name := path.Base(url)
+	if store == nil || !ValidName(name) || store.URL(name) != url {
+		return "", false
+	}
+	return name, true
+}
+
+// Upload stores what r reads, filename being what the uploader named it.
+// Files larger than maxSize bytes and files of unsupported types, as
+// sniffed from their content rather than as their name says, are refused.
+// Pictures lose their metadata, like where they were taken, since they're
+// served as they are too.  It returns the name the file got, unique and
+// safe to put in URLs, its type and its size once stored.
+func Upload(store MediaStore, filename string, r io.Reader, maxSize int64) (name, contentType string, size int64, err error) {
+	content, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return "", "", 0, err
+	}
+	if int64(len(content)) > maxSize {
+		return "", "", 0, ErrTooLarge
+	}
+
+	contentType = http.DetectContentType(content)
+	ext, ok := types[contentType]
+	if !ok {
+		return "", "", 0, ErrUnsupportedType
+	}
+
+	content = stripMetadata(contentType, content)
+
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", 0, err
+	}
+	name = hex.EncodeToString(random)
+	if base := cleanName(filename); base != "" {
+		name += "-" + base
+	}
+	name += ext
+
+	size = int64(len(content))
+	if err := store.Put(name, bytes.NewReader(content), size, contentType); err != nil {
+		return "", "", 0, fmt.Errorf("media: couldn't store %s: %v", name, err)
+	}
+	return name, contentType, size, nil
+}
+
+// What's left of filename, without its extension, that's safe in a name.
+func cleanName(filename string) string {
+	base := path.Base(strings.Replace(filename, `\`, "/", -1))
+	base = strings.ToLower(strings.TrimSuffix(base, path.Ext(base)))
+	var b strings.Builder
+	dash := false
+	for _, r := range base {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() != 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		} else {
+			dash = true
+		}
+		if b.Len() >= 40 {
+			break
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,101 @@
+package media
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// The first bytes of a PNG, enough for it to be sniffed.
+var tinyPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+
+func setupLocalStore(t *testing.T) (*LocalStore, func()) {
+	dir, err := os.MkdirTemp("", "goblog-media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewLocalStore(dir, "/media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, func() { os.RemoveAll(dir) }
+}
+
+func TestUpload(t *testing.T) {
+	store, cleanup := setupLocalStore(t)
+	defer cleanup()
+
+	name, contentType, size, err := Upload(store, `C:\Photos\My Cat (1).JPG`, bytes.NewReader(tinyPNG), 1024)
+	if err != nil {
+		t.Fatal("Couldn't upload", err)
+	}
+	if contentType != "image/png" || size != int64(len(tinyPNG)) {
+		t.Errorf("Expected a PNG of %d bytes, was %q of %d", len(tinyPNG), contentType, size)
+	}
+	// the type comes from the content, not from the name
+	if !strings.HasSuffix(name, "-my-cat-1.png") || !ValidName(name) {
+		t.Errorf("Expected a safe name ending like the file, was %q", name)
+	}
+	if store.URL(name) != "/media/"+name {
+		t.Errorf("Expected the file under /media/, was %q", store.URL(name))
+	}
+
+	file, err := store.Open(name)
+	if err != nil {
+		t.Fatal("Couldn't open the file", err)
+	}
+	content, _ := io.ReadAll(file)
+	file.Close()
+	if !bytes.Equal(content, tinyPNG) {
+		t.Error("Expected the file to be stored as uploaded")
+	}
+
+	other, _, _, err := Upload(store, `C:\Photos\My Cat (1).JPG`, bytes.NewReader(tinyPNG), 1024)
+	if err != nil || other == name {
+		t.Errorf("Expected another name for the same file, was %q, %v", other, err)
+	}
+
+	if err := store.Delete(name); err != nil {
+		t.Fatal("Couldn't delete the file", err)
+	}
+	if _, err := store.Open(name); err == nil {
+		t.Error("Expected the file to be gone")
+	}
+}
+
+func TestUploadRefuses(t *testing.T) {
+	store, cleanup := setupLocalStore(t)
+	defer cleanup()
+
+	if _, _, _, err := Upload(store, "cat.png", bytes.NewReader(tinyPNG), 10); err != ErrTooLarge {
+		t.Errorf("Expected a file too large, was %v", err)
+	}
+	tests := map[string]string{
+		"evil.png":  `<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`,
+		"page.jpg":  "<html><body>Hello</body></html>",
+		"notes.txt": "Just text",
+	}
+	for filename, content := range tests {
+		if _, _, _, err := Upload(store, filename, strings.NewReader(content), 1024); err != ErrUnsupportedType {
+			t.Errorf("Expected %s to be refused, was %v", filename, err)
+		}
+	}
+	if files, _ := os.ReadDir(store.dir); len(files) != 0 {
+		t.Errorf("Expected nothing stored, was %v", files)
+	}
+}
+
+func TestValidName(t *testing.T) {
+	for _, name := range []string{"a1-cat.png", "0f.jpg"} {
+		if !ValidName(name) {
+			t.Errorf("Expected %q to be valid", name)
+		}
+	}
+	for _, name := range []string{"", "../etc/passwd", "a/b.png", ".hidden", "a..png", "Cat.png", "a b.png"} {
+		if ValidName(name) {
+			t.Errorf("Expected %q to be invalid", name)
+		}
+	}
+}
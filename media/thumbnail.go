@@ -0,0 +1,308 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+// Size is a width pictures are resized to, for pages to pick the one they
+// show them at.
+type Size struct {
+	Name  string
+	Width int
+}
+
+// Sizes are those pictures are resized to, from the smallest.
+var Sizes = []Size{
+	{"thumb", 200},
+	{"medium", 800},
+	{"large", 1600},
+}
+
+// FindSize is the size of that name.
+func FindSize(name string) (Size, bool) {
+	for _, size := range Sizes {
+		if size.Name == name {
+			return size, true
+		}
+	}
+	return Size{}, false
+}
+
+// Pictures larger than this, in pixels, aren't resized: decoding them would
+// take more memory than they're worth.
+const maxPixels = 50000000
+
+// ErrNotResizable is for files that are shown as they are, like PDF files,
+// animated GIFs, or pictures too large to decode.
+var ErrNotResizable = errors.New("media: that file can't be resized")
+
+// Thumbnailer resizes the pictures of a store, and keeps what it made in a
+// directory so that it's done once.  Resized pictures are turned upright
+// and lose their metadata, like where they were taken.
+type Thumbnailer struct {
+	store MediaStore
+	dir   string
+	// the cwebp tool, to make WebP pictures, when it's installed
+	cwebp string
+	// each variant is made once at a time, so that many visitors arriving
+	// at once don't resize the same picture together, while other
+	// pictures are resized meanwhile
+	mu     sync.Mutex
+	making map[string]*variantLock
+}
+
+// The lock of a variant, and how many are waiting on it.
+type variantLock struct {
+	sync.Mutex
+	waiting int
+}
+
+// Locks the variant file, returning what unlocks it.
+func (t *Thumbnailer) lock(file string) func() {
+	t.mu.Lock()
+	l, ok := t.making[file]
+	if !ok {
+		l = &variantLock{}
+		t.making[file] = l
+	}
+	l.waiting++
+	t.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		t.mu.Lock()
+		if l.waiting--; l.waiting == 0 {
+			delete(t.making, file)
+		}
+		t.mu.Unlock()
+	}
+}
+
+// NewThumbnailer resizes the pictures of store, keeping them in dir.  WebP
+// pictures are made when cwebp, from https://developers.google.com/speed/webp,
+// is installed.
+func NewThumbnailer(store MediaStore, dir string) (*Thumbnailer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	cwebp, _ := exec.LookPath("cwebp")
+	return &Thumbnailer{
+		store:  store,
+		dir:    dir,
+		cwebp:  cwebp,
+		making: make(map[string]*variantLock),
+	}, nil
+}
+
+// CanWebP tells if the thumbnailer makes WebP pictures.
+func (t *Thumbnailer) CanWebP() bool {
+	return t.cwebp != ""
+}
+
+// Variant is the file of the picture name resized to size, in WebP if
+// asked and possible, and its type.  It's made the first time it's asked.
+func (t *Thumbnailer) Variant(name string, size Size, webp bool) (file string, contentType string, err error) {
+	if !ValidName(name) {
+		return "", "", fmt.Errorf("media: invalid name %q", name)
+	}
+	file = filepath.Join(t.dir, size.Name, name)
+	contentType = variantType(name)
+	if webp && t.CanWebP() {
+		file += ".webp"
+		contentType = "image/webp"
+	}
+
+	defer t.lock(file)()
+	if _, err := os.Stat(file); err == nil {
+		return file, contentType, nil
+	}
+
+	resized, err := t.resize(name, size)
+	if err != nil {
+		return "", "", err
+	}
+	if contentType == "image/webp" {
+		resized, err = t.toWebP(resized)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	if err := writeAtomically(file, resized); err != nil {
+		return "", "", err
+	}
+	return file, contentType, nil
+}
+
+// Warm makes every variant of the picture name, so that the first
+// visitors don't wait for them.
+func (t *Thumbnailer) Warm(name string) error {
+	for _, size := range Sizes {
+		if _, _, err := t.Variant(name, size, false); err != nil {
+			return err
+		}
+		if t.CanWebP() {
+			if _, _, err := t.Variant(name, size, true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Forget removes the variants of the picture name, once it's deleted.
+func (t *Thumbnailer) Forget(name string) error {
+	if !ValidName(name) {
+		return fmt.Errorf("media: invalid name %q", name)
+	}
+	for _, size := range Sizes {
+		for _, file := range []string{name, name + ".webp"} {
+			file = filepath.Join(t.dir, size.Name, file)
+			unlock := t.lock(file)
+			err := os.Remove(file)
+			unlock()
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Photos stay JPEG, the others become PNG to keep their transparency.
+func variantType(name string) string {
+	if path.Ext(name) == ".jpg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+// Resizable tells if the file name is a picture that's resized.  GIFs
+// aren't, that would lose their animation.
+func Resizable(name string) bool {
+	switch path.Ext(name) {
+	case ".jpg", ".png", ".webp":
+		return true
+	}
+	return false
+}
+
+func (t *Thumbnailer) resize(name string, size Size) ([]byte, error) {
+	if !Resizable(name) {
+		return nil, ErrNotResizable
+	}
+	file, err := t.store.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	original, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(original))
+	if err != nil || config.Width*config.Height > maxPixels {
+		return nil, ErrNotResizable
+	}
+	img, _, err := image.Decode(bytes.NewReader(original))
+	if err != nil {
+		return nil, ErrNotResizable
+	}
+	img = Resize(img, orientation(original), size.Width)
+
+	var buf bytes.Buffer
+	if variantType(name) == "image/jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	return buf.Bytes(), err
+}
+
+// Resize turns img, taken in the EXIF orientation o, upright and makes it
+// width pixels wide, keeping its proportions.  Pictures narrower than that
+// keep their size.
+func Resize(img image.Image, o int, width int) image.Image {
+	b := img.Bounds()
+	// the width once upright
+	w, h := b.Dx(), b.Dy()
+	if o >= 5 {
+		w, h = h, w
+	}
+	if w > width {
+		h = h * width / w
+		w = width
+		if h < 1 {
+			h = 1
+		}
+	}
+	if o >= 5 {
+		w, h = h, w
+	}
+
+	resized := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), img, b, draw.Src, nil)
+	return orient(resized, o)
+}
+
+func (t *Thumbnailer) toWebP(picture []byte) ([]byte, error) {
+	in, err := os.CreateTemp(t.dir, ".cwebp-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(in.Name())
+	_, err = in.Write(picture)
+	if closeErr := in.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := in.Name() + ".webp"
+	defer os.Remove(out)
+	cmd := exec.Command(t.cwebp, "-quiet", "-q", "80", "-metadata", "none", in.Name(), "-o", out)
+	if msg, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("media: cwebp: %v: %s", err, msg)
+	}
+	return os.ReadFile(out)
+}
+
+// Writes the file next to where it goes and then moves it there, so that
+// nobody reads half of it.
+func writeAtomically(name string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".variant-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
@@ -0,0 +1,275 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A w by h picture, red on its top left corner, blue elsewhere.
+func picture(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{0, 0, 255, 255})
+		}
+	}
+	for y := 0; y < h/4; y++ {
+		for x := 0; x < w/4; x++ {
+			img.Set(x, y, color.RGBA{255, 0, 0, 255})
+		}
+	}
+	return img
+}
+
+func isRed(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r > 0xc000 && g < 0x4000 && b < 0x4000
+}
+
+// A JPEG of img, with an EXIF segment telling the orientation o.
+func jpegWithOrientation(t *testing.T, img image.Image, o int) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3)
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, uint16(o))
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	exif := append([]byte("Exif\x00\x00"), tiff...)
+
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(exif)+2))
+	segment = append(segment, exif...)
+
+	original := buf.Bytes()
+	return append(append(append([]byte{}, original[:2]...), segment...), original[2:]...)
+}
+
+func TestOrientation(t *testing.T) {
+	img := picture(8, 4)
+	for o := 1; o <= 8; o++ {
+		if actual := orientation(jpegWithOrientation(t, img, o)); actual != o {
+			t.Errorf("Expected orientation %d, was %d", o, actual)
+		}
+	}
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, img, nil)
+	if actual := orientation(buf.Bytes()); actual != 1 {
+		t.Errorf("Expected pictures without EXIF upright, was %d", actual)
+	}
+	if actual := orientation([]byte("\xFF\xD8\xFF\xE1\xFF\xFF")); actual != 1 {
+		t.Errorf("Expected broken EXIF to be ignored, was %d", actual)
+	}
+}
+
+func TestStripMetadata(t *testing.T) {
+	photo := jpegWithOrientation(t, picture(8, 4), 6)
+	// a comment and XMP telling where it was taken
+	xmp := append([]byte("http://ns.adobe.com/xap/1.0/\x00"), "GPSLatitude 48.85"...)
+	extra := []byte{0xFF, 0xFE, 0x00, 0x0A}
+	extra = append(extra, "Montreal"...)
+	extra = append(extra, 0xFF, 0xE1)
+	extra = binary.BigEndian.AppendUint16(extra, uint16(len(xmp)+2))
+	extra = append(extra, xmp...)
+	photo = append(append(append([]byte{}, photo[:2]...), extra...), photo[2:]...)
+
+	stripped := stripMetadata("image/jpeg", photo)
+	if bytes.Contains(stripped, []byte("GPS")) || bytes.Contains(stripped, []byte("Montreal")) {
+		t.Error("Expected the JPEG without its metadata")
+	}
+	if orientation(stripped) != 6 {
+		t.Errorf("Expected the JPEG to keep its orientation, was %d", orientation(stripped))
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Error("Expected the JPEG to still be one", err)
+	}
+
+	var buf bytes.Buffer
+	png.Encode(&buf, picture(4, 4))
+	text := []byte("Author\x00Me")
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(text)))
+	chunk = append(append(chunk, "tEXt"...), text...)
+	chunk = append(chunk, 0, 0, 0, 0)
+	// right after the IHDR chunk
+	withText := append(append(append([]byte{}, buf.Bytes()[:33]...), chunk...), buf.Bytes()[33:]...)
+	stripped = stripMetadata("image/png", withText)
+	if !bytes.Equal(stripped, buf.Bytes()) {
+		t.Error("Expected the PNG without its text")
+	}
+
+	exif := []byte("EXIF\x04\x00\x00\x00GPS!")
+	vp8x := []byte("VP8X\x0a\x00\x00\x00\x08\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+	webp := append(append([]byte("RIFF\x00\x00\x00\x00WEBP"), vp8x...), exif...)
+	binary.LittleEndian.PutUint32(webp[4:], uint32(len(webp)-8))
+	stripped = stripMetadata("image/webp", webp)
+	if bytes.Contains(stripped, []byte("GPS")) || stripped[20] != 0 ||
+		binary.LittleEndian.Uint32(stripped[4:]) != uint32(len(stripped)-8) {
+		t.Errorf("Expected the WebP without its EXIF, was %q", stripped)
+	}
+
+	if broken := []byte("\xFF\xD8\xFF\xE1\xFF\xFF"); !bytes.Equal(stripMetadata("image/jpeg", broken), broken) {
+		t.Error("Expected pictures that can't be read to be kept as they are")
+	}
+}
+
+func TestResize(t *testing.T) {
+	img := picture(400, 200)
+
+	resized := Resize(img, 1, 200)
+	if resized.Bounds().Dx() != 200 || resized.Bounds().Dy() != 100 {
+		t.Errorf("Expected 200x100, was %v", resized.Bounds())
+	}
+	if !isRed(resized.At(5, 5)) || isRed(resized.At(195, 95)) {
+		t.Error("Expected the picture to keep its look")
+	}
+
+	// pictures aren't made larger
+	if same := Resize(img, 1, 1600); same.Bounds().Dx() != 400 {
+		t.Errorf("Expected the picture to keep its size, was %v", same.Bounds())
+	}
+
+	// taken on its left side, the red corner ends on the top right
+	upright := Resize(img, 6, 50)
+	if upright.Bounds().Dx() != 50 || upright.Bounds().Dy() != 100 {
+		t.Fatalf("Expected 50x100 once upright, was %v", upright.Bounds())
+	}
+	if !isRed(upright.At(45, 5)) || isRed(upright.At(5, 5)) {
+		t.Error("Expected the picture to be turned clockwise")
+	}
+	// and on its right side, on the bottom left
+	upright = Resize(img, 8, 50)
+	if !isRed(upright.At(5, 95)) || isRed(upright.At(5, 5)) {
+		t.Error("Expected the picture to be turned counterclockwise")
+	}
+}
+
+func TestThumbnailer(t *testing.T) {
+	store, cleanup := setupLocalStore(t)
+	defer cleanup()
+	cache, err := os.MkdirTemp("", "goblog-thumbnails")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cache)
+	thumbnails, err := NewThumbnailer(store, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	photo := jpegWithOrientation(t, picture(800, 400), 6)
+	name, _, _, err := Upload(store, "photo.jpg", bytes.NewReader(photo), 1<<20)
+	if err != nil {
+		t.Fatal("Couldn't upload", err)
+	}
+	if original, err := store.Open(name); err == nil {
+		content, _ := io.ReadAll(original)
+		original.Close()
+		if orientation(content) != 6 {
+			t.Error("Expected the original to keep its orientation")
+		}
+	}
+
+	thumb, _ := FindSize("thumb")
+	file, contentType, err := thumbnails.Variant(name, thumb, false)
+	if err != nil || contentType != "image/jpeg" {
+		t.Fatalf("Expected a JPEG thumbnail, was %q, %v", contentType, err)
+	}
+	content, _ := os.ReadFile(file)
+	if orientation(content) != 1 || bytes.Contains(content, []byte("Exif")) {
+		t.Error("Expected the thumbnail upright and without metadata")
+	}
+	config, err := jpeg.DecodeConfig(bytes.NewReader(content))
+	if err != nil || config.Width != 200 || config.Height != 400 {
+		t.Errorf("Expected 200x400 once upright, was %+v, %v", config, err)
+	}
+
+	// made once, even when the original is gone
+	store.Delete(name)
+	if again, _, err := thumbnails.Variant(name, thumb, false); err != nil || again != file {
+		t.Errorf("Expected the thumbnail from the cache, was %q, %v", again, err)
+	}
+	if err := thumbnails.Forget(name); err != nil {
+		t.Fatal("Couldn't forget the thumbnails", err)
+	}
+	if _, err := os.Stat(file); err == nil {
+		t.Error("Expected the thumbnail to be gone")
+	}
+
+	var buf bytes.Buffer
+	png.Encode(&buf, picture(20, 20))
+	small, _, _, _ := Upload(store, "icon.png", &buf, 1<<20)
+	if err := thumbnails.Warm(small); err != nil {
+		t.Fatal("Couldn't resize", err)
+	}
+	for _, size := range Sizes {
+		if _, err := os.Stat(filepath.Join(cache, size.Name, small)); err != nil {
+			t.Errorf("Expected the %s variant to be made, %v", size.Name, err)
+		}
+	}
+
+	if _, _, err := thumbnails.Variant("../etc/passwd", thumb, false); err == nil {
+		t.Error("Expected invalid names to be refused")
+	}
+	gif := []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;")
+	animated, _, _, _ := Upload(store, "dance.gif", bytes.NewReader(gif), 1<<20)
+	if _, _, err := thumbnails.Variant(animated, thumb, false); err != ErrNotResizable {
+		t.Errorf("Expected GIFs to be shown as they are, was %v", err)
+	}
+}
+
+func TestThumbnailerConcurrently(t *testing.T) {
+	store, cleanup := setupLocalStore(t)
+	defer cleanup()
+	cache, err := os.MkdirTemp("", "goblog-thumbnails")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cache)
+	thumbnails, err := NewThumbnailer(store, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for i := 0; i < 2; i++ {
+		var buf bytes.Buffer
+		png.Encode(&buf, picture(400, 400))
+		name, _, _, err := Upload(store, "square.png", &buf, 1<<20)
+		if err != nil {
+			t.Fatal("Couldn't upload", err)
+		}
+		names = append(names, name)
+	}
+
+	// visitors asking for the same variants, and others, at once
+	medium, _ := FindSize("medium")
+	files := make(chan string, 8)
+	for i := 0; i < cap(files); i++ {
+		go func(name string) {
+			file, _, err := thumbnails.Variant(name, medium, false)
+			if err != nil {
+				t.Error("Couldn't resize", err)
+			}
+			files <- file
+		}(names[i%len(names)])
+	}
+	made := make(map[string]bool)
+	for i := 0; i < cap(files); i++ {
+		made[<-files] = true
+	}
+	if len(made) != len(names) || len(thumbnails.making) != 0 {
+		t.Errorf("Expected a variant by picture and no lock left, was %v, %d", made, len(thumbnails.making))
+	}
+}
@@ -0,0 +1,238 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+/*
+ * SQL stuff
+ */
+
+// Actors and targets aren't foreign keys: the trail must outlive the users
+// it talks about.
+var createAuditEntryTable string = `
+CREATE TABLE IF NOT EXISTS AuditEntry(
+   audit_id		SERIAL PRIMARY KEY,
+   actor_id		INTEGER NOT NULL,
+   actor_name	VARCHAR(255) NOT NULL,
+   action		VARCHAR(64) NOT NULL,
+   target_id	INTEGER NOT NULL,
+   detail		TEXT NOT NULL,
+   date			TIMESTAMP NOT NULL
+)`
+
+var dropAuditEntryTable string = `
+DROP TABLE AuditEntry;
+`
+
+var insertAuditEntry string = `
+INSERT INTO AuditEntry(
+	actor_id,
+	actor_name,
+	action,
+	target_id,
+	detail,
+	date)
+VALUES( $1, $2, $3, $4, $5, $6 )
+RETURNING audit_id`
+
+var queryForAllAuditEntry string = `
+SELECT
+	E.audit_id,
+	E.actor_id,
+	E.actor_name,
+	E.action,
+	E.target_id,
+	E.detail,
+	E.date
+FROM
+	AuditEntry AS E
+ORDER BY
+	E.date DESC`
+
+var queryAuditEntryForTargetId string = `
+SELECT
+	E.audit_id,
+	E.actor_id,
+	E.actor_name,
+	E.action,
+	E.target_id,
+	E.detail,
+	E.date
+FROM
+	AuditEntry AS E
+WHERE
+	E.target_id = $1
+ORDER BY
+	E.date DESC`
+
+// Records an administrative action done by a user on another user.
+type AuditEntry struct {
+	id        int64
+	actorId   int64
+	actorName string
+	action    string
+	targetId  int64
+	detail    string
+	date      time.Time
+	conn      *DBConnection
+}
+
+func (e *AuditEntry) Id() int64 {
+	return e.id
+}
+
+func (e *AuditEntry) ActorId() int64 {
+	return e.actorId
+}
+
+// The username of the actor at the time of the action.
+func (e *AuditEntry) ActorName() string {
+	return e.actorName
+}
+
+func (e *AuditEntry) Action() string {
+	return e.action
+}
+
+func (e *AuditEntry) TargetId() int64 {
+	return e.targetId
+}
+
+func (e *AuditEntry) Detail() string {
+	return e.detail
+}
+
+func (e *AuditEntry) Date() time.Time {
+	return e.date
+}
+
+/*
+ *  SQL Stuff
+ */
+
+func (conn *DBConnection) createAuditEntryTable() {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Error on open of database", err)
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec(createAuditEntryTable)
+	if err != nil {
+		fmt.Printf("Error creating AuditEntry table, driver \"%s\", modelname \"%s\", query = %s\n",
+			vendor.Driver(), vendor.Name(), createAuditEntryTable)
+		fmt.Println(err)
+		return
+	}
+}
+
+func (conn *DBConnection) dropAuditEntryTable() {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Error on open of database", err)
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec(dropAuditEntryTable)
+	if err != nil {
+		fmt.Println("Error droping table:", err)
+	}
+}
+
+// Creates an audit entry.  It is NOT saved, you must call "Save" on it.
+func (conn *DBConnection) NewAuditEntry(actor *User, action string,
+	targetId int64, detail string, date time.Time) *AuditEntry {
+	return &AuditEntry{
+		id:        -1,
+		actorId:   actor.Id(),
+		actorName: actor.Username(),
+		action:    action,
+		targetId:  targetId,
+		detail:    detail,
+		date:      date,
+		conn:      conn,
+	}
+}
+
+// Finds all the audit entries, most recent first.
+func (conn *DBConnection) FindAllAuditEntries() ([]AuditEntry, error) {
+	return conn.queryAuditEntries(queryForAllAuditEntry)
+}
+
+// Finds the audit entries about a user, most recent first.
+func (conn *DBConnection) FindAuditEntriesByTargetId(targetId int64) ([]AuditEntry, error) {
+	return conn.queryAuditEntries(queryAuditEntryForTargetId, targetId)
+}
+
+func (conn *DBConnection) queryAuditEntries(query string, args ...interface{}) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("queryAuditEntries 1:", err)
+		return entries, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		fmt.Println("queryAuditEntries 2:", err)
+		return entries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e := AuditEntry{conn: conn}
+		err := rows.Scan(&e.id,
+			&e.actorId,
+			&e.actorName,
+			&e.action,
+			&e.targetId,
+			&e.detail,
+			&e.date)
+		if err != nil {
+			fmt.Println("queryAuditEntries 3:", err)
+			return entries, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+/*
+ *  Operations on AuditEntry
+ */
+
+// Saves the entry to the database.  Entries can't be changed once saved.
+func (e *AuditEntry) Save() error {
+	vendor := e.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("AuditEntry Save 1:", err)
+		return err
+	}
+	defer db.Close()
+
+	err = db.QueryRow(insertAuditEntry,
+		e.actorId,
+		e.actorName,
+		e.action,
+		e.targetId,
+		e.detail,
+		e.date).Scan(&e.id)
+	if err != nil {
+		fmt.Println("AuditEntry Save 2:", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSaveAuditEntry(t *testing.T) {
+	saveAuditEntry(t, setupPGConnection())
+}
+
+func saveAuditEntry(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	actor := generateUser(conn, 1)
+	actor.Save()
+	target := generateUser(conn, 2)
+	target.Save()
+
+	entry := conn.NewAuditEntry(actor, "ban", target.Id(), "spam", time.Now().UTC())
+	if entry.Id() != -1 {
+		t.Error("Id should be of -1 at this point")
+	}
+
+	if err := entry.Save(); err != nil {
+		t.Error("Save failed", err)
+	}
+
+	if entry.Id() != 1 {
+		t.Error("Id should be 1 at this point")
+	}
+}
+
+func TestFindAuditEntriesByTargetId(t *testing.T) {
+	findAuditEntriesByTargetId(t, setupPGConnection())
+}
+
+func findAuditEntriesByTargetId(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	actor := generateUser(conn, 1)
+	actor.Save()
+	target := generateUser(conn, 2)
+	target.Save()
+
+	var entryCount = 5
+	for i := 0; i < entryCount; i++ {
+		conn.NewAuditEntry(actor, "role", target.Id(),
+			fmt.Sprintf("change #%d", i),
+			time.Now().UTC()).Save()
+	}
+	conn.NewAuditEntry(actor, "ban", actor.Id(), "self", time.Now().UTC()).Save()
+
+	entries, err := conn.FindAuditEntriesByTargetId(target.Id())
+	if err != nil {
+		t.Error("Couldn't query audit entries", err)
+		return
+	}
+
+	if len(entries) != entryCount {
+		t.Errorf("Expected <%d> entries but was <%d>", entryCount, len(entries))
+		return
+	}
+
+	if entries[0].Detail() != fmt.Sprintf("change #%d", entryCount-1) {
+		t.Errorf("Most recent entry should come first, was <%s>", entries[0].Detail())
+	}
+
+	all, err := conn.FindAllAuditEntries()
+	if err != nil || len(all) != entryCount+1 {
+		t.Errorf("Expected <%d> entries in all but was <%d>, %v", entryCount+1, len(all), err)
+	}
+}
@@ -38,6 +38,20 @@ WHERE
 	A.author_id = $1
 	AND A.user_id = U.user_id`
 
+var findAuthorByUserId string = `
+SELECT
+   A.author_id,
+   U.username,
+   U.registration_date,
+   U.timezone,
+   U.email
+FROM
+	Author AS A,
+	BlogUser AS U
+WHERE
+	A.user_id = $1
+	AND A.user_id = U.user_id`
+
 var deleteAuthorById string = `
 DELETE FROM
 	Author
@@ -58,6 +72,28 @@ FROM
 WHERE
 	A.user_id = U.user_id`
 
+var queryPageOfAuthors string = queryForAllAuthor + `
+ORDER BY
+	A.author_id
+LIMIT $1 OFFSET $2`
+
+var countAuthors string = `
+SELECT
+	COUNT(A.author_id)
+FROM
+	Author AS A`
+
+var queryLastPostDateByAuthor string = `
+SELECT
+	P.author_id,
+	MAX(P.date)
+FROM
+	Post AS P
+WHERE
+	P.author_id IS NOT NULL
+GROUP BY
+	P.author_id`
+
 var queryAuthorForUserId string = `
 SELECT
 	A.author_id
@@ -74,13 +110,27 @@ SELECT
 	P.title,
 	P.content,
 	P.image_url,
-	P.date
+	P.date,
+	P.updated
 FROM
 	Post AS P
 WHERE
 	P.author_id = $1
 `
 
+var queryPageOfPostsOfAuthorId string = queryForAllPostsOfAuthorId + `
+ORDER BY
+	P.post_id
+LIMIT $2 OFFSET $3`
+
+var countPostsOfAuthorId string = `
+SELECT
+	COUNT(P.post_id)
+FROM
+	Post AS P
+WHERE
+	P.author_id = $1`
+
 // Represents an author of the blog
 type Author struct {
 	id   int64
@@ -97,6 +147,20 @@ func (a *Author) User() *User {
 }
 
 func (a *Author) Posts() ([]Post, error) {
+	return a.findPosts(queryForAllPostsOfAuthorId, a.id)
+}
+
+// Finds limit posts of the author, from the one at offset, oldest first.
+func (a *Author) PostsPage(limit, offset int) ([]Post, error) {
+	return a.findPosts(queryPageOfPostsOfAuthorId, a.id, limit, offset)
+}
+
+// Counts the posts of the author.
+func (a *Author) PostCount() (int, error) {
+	return a.conn.count(countPostsOfAuthorId, a.id)
+}
+
+func (a *Author) findPosts(query string, args ...interface{}) ([]Post, error) {
 	vendor := a.conn.databaser
 	db, err := sql.Open(vendor.Driver(), vendor.Name())
 	if err != nil {
@@ -105,9 +169,9 @@ func (a *Author) Posts() ([]Post, error) {
 	}
 	defer db.Close()
 
-	stmt, err := db.Prepare(queryForAllPostsOfAuthorId)
+	stmt, err := db.Prepare(query)
 	if err != nil {
-		fmt.Printf("Couldn't prepare statement: %s", queryForAllPostsOfAuthorId)
+		fmt.Printf("Couldn't prepare statement: %s", query)
 		fmt.Println(err)
 		return nil, err
 	}
@@ -115,7 +179,7 @@ func (a *Author) Posts() ([]Post, error) {
 
 	var posts []Post
 
-	rows, err := stmt.Query(a.id)
+	rows, err := stmt.Query(args...)
 	if err != nil {
 		fmt.Println("Couldn't read rows from statement", err)
 		return posts, err
@@ -129,9 +193,10 @@ func (a *Author) Posts() ([]Post, error) {
 		var content string
 		var imageURL string
 		var date time.Time
-		rows.Scan(&id, &authorId, &title, &content, &imageURL, &date)
+		var updated time.Time
+		err := rows.Scan(&id, &authorId, &title, &content, &imageURL, &date, &updated)
 		if err != nil {
-			fmt.Println("Error while scanning comments", err)
+			fmt.Println("Error while scanning posts", err)
 			return posts, err
 		}
 		p := Post{
@@ -141,6 +206,7 @@ func (a *Author) Posts() ([]Post, error) {
 			content:  content,
 			imageURL: imageURL,
 			date:     date,
+			updated:  updated,
 			conn:     a.conn,
 		}
 		posts = append(posts, p)
@@ -200,20 +266,34 @@ func (conn *DBConnection) NewAuthor(user *User) *Author {
 // an error stating no rows matched the request if no authors are known
 // to this blog.
 func (conn *DBConnection) FindAllAuthors() ([]Author, error) {
+	return conn.findAuthors(queryForAllAuthor)
+}
+
+// Finds limit authors, from the one at offset, oldest first.
+func (conn *DBConnection) FindAuthorsPage(limit, offset int) ([]Author, error) {
+	return conn.findAuthors(queryPageOfAuthors, limit, offset)
+}
+
+// Counts the authors of the blog.
+func (conn *DBConnection) CountAuthors() (int, error) {
+	return conn.count(countAuthors)
+}
+
+func (conn *DBConnection) findAuthors(query string, args ...interface{}) ([]Author, error) {
 
 	var authors []Author
 	var vendor = conn.databaser
 
 	model, err := sql.Open(vendor.Driver(), vendor.Name())
 	if err != nil {
-		fmt.Println("FindAllAuthors 1:", err)
+		fmt.Println("findAuthors 1:", err)
 		return authors, err
 	}
 	defer model.Close()
 
-	rows, err := model.Query(queryForAllAuthor)
+	rows, err := model.Query(query, args...)
 	if err != nil {
-		fmt.Println("FindAllAuthors 2:", err)
+		fmt.Println("findAuthors 2:", err)
 		return authors, err
 	}
 	defer rows.Close()
@@ -302,6 +382,62 @@ func (conn *DBConnection) FindAuthorById(id int64) (*Author, error) {
 	return a, nil
 }
 
+// Returns the author attached to the given user id.  If the user is not
+// an author, a nil value is returned with an error.
+func (conn *DBConnection) FindAuthorByUserId(userId int64) (*Author, error) {
+
+	var a *Author
+	var modelvendor = conn.databaser
+
+	model, err := sql.Open(modelvendor.Driver(), modelvendor.Name())
+	if err != nil {
+		fmt.Println("FindAuthorByUserId 1:", err)
+		return a, err
+	}
+	defer model.Close()
+
+	stmt, err := model.Prepare(findAuthorByUserId)
+	if err != nil {
+		fmt.Println("FindAuthorByUserId 2:", err)
+		return a, err
+	}
+	defer stmt.Close()
+
+	var id int64
+	var username string
+	var date time.Time
+	var timezone int
+	var email string
+
+	err = stmt.QueryRow(userId).Scan(&id,
+		&username,
+		&date,
+		&timezone,
+		&email)
+
+	if err != nil {
+		// normal if the user isn't an author
+		return a, err
+	}
+
+	u := &User{
+		id:               userId,
+		username:         username,
+		registrationDate: date,
+		timezone:         timezone,
+		email:            email,
+		conn:             conn,
+	}
+
+	a = &Author{
+		id:   id,
+		user: u,
+		conn: conn,
+	}
+
+	return a, nil
+}
+
 /*
 *  Operations on Author
  */
@@ -325,10 +461,12 @@ func (a *Author) Save() error {
 	}
 	defer stmt.Close()
 
-	err = a.user.Save()
-	if err != nil {
-		fmt.Println("Save 3:", err)
-		return err
+	if a.user.Id() == -1 {
+		err = a.user.Save()
+		if err != nil {
+			fmt.Println("Save 3:", err)
+			return err
+		}
 	}
 
 	_, err = stmt.Exec(a.User().Id())
@@ -377,3 +515,34 @@ func (a *Author) Destroy() error {
 
 	return nil
 }
+
+// Returns the date of the most recent post of each author, by author id.
+// Authors who never posted aren't there.
+func (conn *DBConnection) LastPostDateByAuthor() (map[int64]time.Time, error) {
+	dates := make(map[int64]time.Time)
+	vendor := conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("LastPostDateByAuthor 1:", err)
+		return dates, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(queryLastPostDateByAuthor)
+	if err != nil {
+		fmt.Println("LastPostDateByAuthor 2:", err)
+		return dates, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var date time.Time
+		if err := rows.Scan(&id, &date); err != nil {
+			return dates, err
+		}
+		dates[id] = date
+	}
+	return dates, rows.Err()
+}
@@ -124,6 +124,36 @@ func findByIdAuthor(t *testing.T, conn *DBConnection) {
 	}
 }
 
+func TestFindByUserIdAuthor(t *testing.T) {
+	findByUserIdAuthor(t, setupPGConnection())
+}
+
+func findByUserIdAuthor(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+	for i := int64(1); i < 10; i++ {
+		expected := generateAuthor(conn, i)
+
+		actual, err := conn.FindAuthorByUserId(expected.User().Id())
+
+		if err != nil {
+			t.Errorf("Error while querying author %d: %v", i, err)
+			return
+		}
+
+		if actual.Id() != expected.Id() {
+			t.Errorf("Expected <%d> but was <%d>\n",
+				expected.Id(), actual.Id())
+			return
+		}
+	}
+
+	user := generateUser(conn, 100)
+	user.Save()
+	if author, err := conn.FindAuthorByUserId(user.Id()); author != nil || err == nil {
+		t.Error("A user that isn't an author shouldn't be found as one")
+	}
+}
+
 func TestFindAllAuthor(t *testing.T) {
 	findAllAuthor(t, setupPGConnection())
 }
@@ -274,3 +304,33 @@ func findAllAuthorPosts(t *testing.T, conn *DBConnection) {
 		}
 	}
 }
+
+func TestLastPostDateByAuthor(t *testing.T) {
+	lastPostDateByAuthor(t, setupPGConnection())
+}
+
+func lastPostDateByAuthor(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	user := generateUser(conn, 0)
+	var author = conn.NewAuthor(user)
+	_ = author.Save()
+	idle := conn.NewAuthor(generateUser(conn, 1))
+	_ = idle.Save()
+
+	last := time.Date(2014, 3, 2, 0, 0, 0, 0, time.UTC)
+	for _, date := range []time.Time{last.Add(-time.Hour), last, last.Add(-2 * time.Hour)} {
+		_ = conn.NewPost(author, "Title", "Content", "", date).Save()
+	}
+
+	dates, err := conn.LastPostDateByAuthor()
+	if err != nil {
+		t.Fatal("Couldn't query for dates", err)
+	}
+	if !dates[author.Id()].Equal(last) {
+		t.Errorf("Expected <%v> but was <%v>", last, dates[author.Id()])
+	}
+	if _, ok := dates[idle.Id()]; ok {
+		t.Error("Authors without posts have no date")
+	}
+}
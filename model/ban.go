@@ -0,0 +1,152 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+/*
+ * SQL stuff
+ */
+var createUserBanTable string = `
+CREATE TABLE IF NOT EXISTS UserBan(
+   user_id 		INTEGER PRIMARY KEY,
+   reason 		TEXT NOT NULL,
+   date 			TIMESTAMP NOT NULL,
+   CONSTRAINT fk_userban_user_id
+   	FOREIGN KEY(user_id) REFERENCES BlogUser(user_id) ON DELETE CASCADE
+)`
+
+var dropUserBanTable string = `
+DROP TABLE UserBan;
+`
+
+var insertUserBan string = `
+INSERT INTO UserBan(user_id, reason, date) VALUES ( $1, $2, $3 )`
+
+var deleteUserBanForUserId string = `
+DELETE FROM
+	UserBan
+WHERE
+	UserBan.user_id = $1`
+
+var findBanByUserId string = `
+SELECT
+	B.reason,
+	B.date
+FROM
+	UserBan AS B
+WHERE
+	B.user_id = $1`
+
+// Bans the user from the blog.  Banned users can't log in.  Banning an
+// already banned user replaces the reason of the ban.
+func (u *User) Ban(reason string, date time.Time) error {
+	vendor := u.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Ban 1:", err)
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(deleteUserBanForUserId, u.id); err != nil {
+		fmt.Println("Ban 2:", err)
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec(insertUserBan, u.id, reason, date); err != nil {
+		fmt.Println("Ban 3:", err)
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Lifts the ban of the user, if any.
+func (u *User) Unban() error {
+	vendor := u.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Unban 1:", err)
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(deleteUserBanForUserId, u.id)
+	if err != nil {
+		fmt.Println("Unban 2:", err)
+		return err
+	}
+	return nil
+}
+
+// Tells if the user is banned, and why.
+func (u *User) Banned() (bool, string, error) {
+	vendor := u.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Banned 1:", err)
+		return false, "", err
+	}
+	defer db.Close()
+
+	var reason string
+	var date time.Time
+	err = db.QueryRow(findBanByUserId, u.id).Scan(&reason, &date)
+	if err == sql.ErrNoRows {
+		return false, "", nil
+	}
+	if err != nil {
+		fmt.Println("Banned 2:", err)
+		return false, "", err
+	}
+	return true, reason, nil
+}
+
+/*
+ *  SQL Stuff
+ */
+
+func (conn *DBConnection) createUserBanTable() {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Error on open of database", err)
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec(createUserBanTable)
+	if err != nil {
+		fmt.Printf("Error creating UserBan table, driver \"%s\", modelname \"%s\", query = %s\n",
+			vendor.Driver(), vendor.Name(), createUserBanTable)
+		fmt.Println(err)
+		return
+	}
+}
+
+func (conn *DBConnection) dropUserBanTable() {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Error on open of database", err)
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec(dropUserBanTable)
+	if err != nil {
+		fmt.Println("Error droping table:", err)
+	}
+}
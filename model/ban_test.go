@@ -0,0 +1,43 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBanUser(t *testing.T) {
+	banUser(t, setupPGConnection())
+}
+
+func banUser(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	user := generateUser(conn, 1)
+	user.Save()
+
+	if banned, _, err := user.Banned(); banned || err != nil {
+		t.Error("A new user shouldn't be banned", err)
+	}
+
+	if err := user.Ban("spam", time.Now().UTC()); err != nil {
+		t.Error("Couldn't ban user", err)
+		return
+	}
+
+	banned, reason, err := user.Banned()
+	if !banned || err != nil {
+		t.Error("User should be banned", err)
+	}
+	if reason != "spam" {
+		t.Errorf("Expected <spam> but was <%s>", reason)
+	}
+
+	if err := user.Unban(); err != nil {
+		t.Error("Couldn't unban user", err)
+		return
+	}
+
+	if banned, _, err := user.Banned(); banned || err != nil {
+		t.Error("User shouldn't be banned anymore", err)
+	}
+}
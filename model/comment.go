@@ -3,7 +3,7 @@ package model
 import (
 	"database/sql"
 	"fmt"
-	"github.com/russross/blackfriday"
+	"html/template"
 	"time"
 )
 
@@ -20,12 +20,22 @@ CREATE TABLE IF NOT EXISTS Comment(
    date			TIMESTAMP NOT NULL,
    up_vote		INTEGER NOT NULL,
    down_vote	INTEGER NOT NULL,
+   parent_id	INTEGER,
+   approved		BOOLEAN NOT NULL DEFAULT TRUE,
    CONSTRAINT fk_comment_user_id
    	FOREIGN KEY (user_id) REFERENCES BlogUser(user_id) ON DELETE CASCADE,
    CONSTRAINT fk_comment_post_id
-   	FOREIGN KEY (post_id) REFERENCES Post(post_id) ON DELETE CASCADE
+   	FOREIGN KEY (post_id) REFERENCES Post(post_id) ON DELETE CASCADE,
+   CONSTRAINT fk_comment_parent_id
+   	FOREIGN KEY (parent_id) REFERENCES Comment(comment_id) ON DELETE SET NULL
 )`
 
+// Comments made before they could reply or wait for approval were neither.
+var addCommentThreadColumns string = `
+ALTER TABLE Comment ADD COLUMN IF NOT EXISTS parent_id INTEGER
+	CONSTRAINT fk_comment_parent_id REFERENCES Comment(comment_id) ON DELETE SET NULL;
+ALTER TABLE Comment ADD COLUMN IF NOT EXISTS approved BOOLEAN NOT NULL DEFAULT TRUE`
+
 var dropCommentTable string = `
 DROP TABLE Comment;`
 
@@ -36,17 +46,33 @@ INSERT INTO Comment(
 	content,
 	date,
 	up_vote,
-	down_vote )
-VALUES( $1, $2, $3, $4, $5, $6 )`
+	down_vote,
+	parent_id,
+	approved )
+VALUES( $1, $2, $3, $4, $5, $6, $7, $8 )
+RETURNING comment_id`
+
+var updateCommentForId string = `
+UPDATE Comment
+SET
+	content = $1,
+	up_vote = $2,
+	down_vote = $3,
+	approved = $4
+WHERE
+	comment_id = $5;`
 
 var findCommentById string = `
 SELECT
+	C.comment_id,
 	C.user_id,
 	C.post_id,
 	C.content,
 	C.date,
 	C.up_vote,
-	C.down_vote
+	C.down_vote,
+	C.parent_id,
+	C.approved
 FROM
 	Comment as C
 WHERE
@@ -64,17 +90,11 @@ SELECT
 	C.content,
 	C.date,
 	C.up_vote,
-	C.down_vote
-FROM Comment AS C`
-
-var queryCommentIdFromDetails string = `
-SELECT
-	C.comment_id
-FROM
-	Comment AS C
-WHERE
-	C.date = $1
-`
+	C.down_vote,
+	C.parent_id,
+	C.approved
+FROM Comment AS C
+ORDER BY C.comment_id`
 
 // Represents a comment on a post.  Comments are made by Users.
 type Comment struct {
@@ -85,6 +105,10 @@ type Comment struct {
 	date     time.Time
 	upVote   int64
 	downVote int64
+	// the comment it replies to, 0 for none
+	parentId int64
+	// only approved comments are shown, the others wait for moderation
+	approved bool
 	conn     *DBConnection
 }
 
@@ -92,6 +116,14 @@ func (c *Comment) Id() int64 {
 	return c.id
 }
 
+func (c *Comment) UserId() int64 {
+	return c.userId
+}
+
+func (c *Comment) PostId() int64 {
+	return c.postId
+}
+
 func (c *Comment) User() (*User, error) {
 	return c.conn.FindUserById(c.userId)
 }
@@ -104,8 +136,8 @@ func (c *Comment) Content() string {
 	return c.content
 }
 
-func (c *Comment) ContentMarkdown() string {
-	return string(blackfriday.MarkdownCommon([]byte(c.content)))
+func (c *Comment) ContentMarkdown() template.HTML {
+	return commentMarkdown.Render(c.content)
 }
 
 func (c *Comment) SetContent(content string) {
@@ -136,6 +168,46 @@ func (c *Comment) SetDownVote(count int64) {
 	c.downVote = count
 }
 
+// ParentId is the id of the comment this one replies to, 0 for none.
+func (c *Comment) ParentId() int64 {
+	return c.parentId
+}
+
+// SetParentId makes a comment that isn't saved yet a reply.
+func (c *Comment) SetParentId(id int64) {
+	c.parentId = id
+}
+
+func (c *Comment) Approved() bool {
+	return c.approved
+}
+
+func (c *Comment) SetApproved(approved bool) {
+	c.approved = approved
+}
+
+// What queries select comments with, the columns of queryForAllComment in
+// that order.
+type commentRow interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanComment(row commentRow, conn *DBConnection) (Comment, error) {
+	c := Comment{conn: conn}
+	var parentId sql.NullInt64
+	err := row.Scan(&c.id,
+		&c.userId,
+		&c.postId,
+		&c.content,
+		&c.date,
+		&c.upVote,
+		&c.downVote,
+		&parentId,
+		&c.approved)
+	c.parentId = parentId.Int64
+	return c, err
+}
+
 /*
  * SQL stuff
  */
@@ -163,6 +235,11 @@ func (persist *DBConnection) createCommentTable() {
 		fmt.Println(err)
 		return
 	}
+
+	_, err = model.Exec(addCommentThreadColumns)
+	if err != nil {
+		fmt.Println("Error adding replies and approval to comments:", err)
+	}
 }
 
 func (persist *DBConnection) dropCommentTable() {
@@ -194,6 +271,7 @@ func (conn *DBConnection) NewComment(userId int64, postId int64, content string,
 		date:     date,
 		upVote:   0,
 		downVote: 0,
+		approved: true,
 		conn:     conn,
 	}
 }
@@ -220,23 +298,10 @@ func (conn *DBConnection) FindAllComments() ([]Comment, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var id int64
-		var userId int64
-		var postId int64
-		var content string
-		var date time.Time
-		var upVote int64
-		var downVote int64
-		rows.Scan(&id, &userId, &postId, &content, &date, &upVote, &downVote)
-		c := Comment{
-			id:       id,
-			userId:   userId,
-			postId:   postId,
-			content:  content,
-			date:     date,
-			upVote:   upVote,
-			downVote: downVote,
-			conn:     conn,
+		c, err := scanComment(rows, conn)
+		if err != nil {
+			fmt.Println("FindAllComments 3:", err)
+			return comments, err
 		}
 		comments = append(comments, c)
 	}
@@ -265,28 +330,12 @@ func (conn *DBConnection) FindCommentById(id int64) (*Comment, error) {
 	}
 	defer stmt.Close()
 
-	var userId int64
-	var postId int64
-	var content string
-	var date time.Time
-	var upVote int64
-	var downVote int64
-	err = stmt.QueryRow(id).Scan(&userId, &postId, &content, &date, &upVote, &downVote)
+	found, err := scanComment(stmt.QueryRow(id), conn)
 	if err != nil {
 		// normal if the comment doesnt exist
 		return c, err
 	}
-
-	c = &Comment{
-		id:       id,
-		userId:   userId,
-		postId:   postId,
-		content:  content,
-		date:     date,
-		upVote:   upVote,
-		downVote: downVote,
-		conn:     conn,
-	}
+	c = &found
 
 	return c, nil
 }
@@ -313,23 +362,42 @@ func (c *Comment) Save() error {
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(c.userId, c.postId, c.content, c.date, c.upVote, c.downVote)
+	parentId := sql.NullInt64{Int64: c.parentId, Valid: c.parentId != 0}
+	// the id of the comment inserted, which comments of the same second
+	// don't tell
+	err = stmt.QueryRow(c.userId, c.postId, c.content, c.date, c.upVote, c.downVote, parentId, c.approved).Scan(&c.id)
 	if err != nil {
 		fmt.Println("Save 3:", err)
 		return err
 	}
+	return nil
+}
 
-	// query the ID we inserted
-	idStmt, err := db.Prepare(queryCommentIdFromDetails)
+// Updates the content, votes and approval of a comment that was saved
+// already.
+// Returns an error if something went wrong.
+func (c *Comment) Update() error {
+	model := c.conn.databaser
+	db, err := sql.Open(model.Driver(), model.Name())
 	if err != nil {
-		fmt.Println("Save 5:", err)
+		fmt.Println("Comment Update 1:", err)
 		return err
 	}
-	defer idStmt.Close()
+	defer db.Close()
 
-	row := idStmt.QueryRow(c.date)
+	stmt, err := db.Prepare(updateCommentForId)
+	if err != nil {
+		fmt.Println("Comment Update 2:", err)
+		return err
+	}
+	defer stmt.Close()
 
-	return row.Scan(&c.id)
+	_, err = stmt.Exec(c.content, c.upVote, c.downVote, c.approved, c.id)
+	if err != nil {
+		fmt.Println("Comment Update 3:", err)
+		return err
+	}
+	return nil
 }
 
 // Deletes the comment from the database.  Returns an error if something
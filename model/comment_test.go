@@ -105,6 +105,39 @@ func destroyComment(t *testing.T, conn *DBConnection) {
 	}
 }
 
+func TestUpdateComment(t *testing.T) {
+	updateComment(t, setupPGConnection())
+}
+
+func updateComment(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	var user, post = generateUserAndPost(conn, 0)
+	var comment = conn.NewComment(
+		user.Id(),
+		post.Id(),
+		"First thought",
+		time.Now().UTC())
+	if err := comment.Save(); err != nil {
+		t.Fatal("Save failed", err)
+	}
+
+	comment.SetContent("Second thought")
+	comment.SetUpVote(3)
+	if err := comment.Update(); err != nil {
+		t.Fatal("Update failed", err)
+	}
+
+	actual, err := conn.FindCommentById(comment.Id())
+	if err != nil {
+		t.Fatal("Couldn't find the comment back", err)
+	}
+	if actual.Content() != "Second thought" || actual.UpVote() != 3 {
+		t.Errorf("Expected the new content and votes, was <%s> and %d",
+			actual.Content(), actual.UpVote())
+	}
+}
+
 func TestFindByIdComment(t *testing.T) {
 	findByIdComment(t, setupPGConnection())
 }
@@ -204,3 +237,80 @@ func commentIdIncrements(t *testing.T, conn *DBConnection) {
 		}
 	}
 }
+
+func TestCommentReplyAndApproval(t *testing.T) {
+	commentReplyAndApproval(t, setupPGConnection())
+}
+
+func commentReplyAndApproval(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	var user, post = generateUserAndPost(conn, 0)
+	var date = time.Now().UTC()
+	var pending = conn.NewComment(user.Id(), post.Id(), "Buy now", date)
+	pending.SetApproved(false)
+	if err := pending.Save(); err != nil {
+		t.Fatal("Couldn't save comment", err)
+	}
+	var reply = conn.NewComment(user.Id(), post.Id(), "Don't", date.Add(time.Second))
+	reply.SetParentId(pending.Id())
+	if err := reply.Save(); err != nil {
+		t.Fatal("Couldn't save reply", err)
+	}
+
+	found, err := conn.FindCommentById(reply.Id())
+	if err != nil || found.ParentId() != pending.Id() || !found.Approved() {
+		t.Errorf("Expected an approved reply to <%d>, was %+v (%v)", pending.Id(), found, err)
+	}
+
+	comments, err := post.Comments()
+	if err != nil || len(comments) != 1 || comments[0].Id() != reply.Id() {
+		t.Errorf("Expected only the approved comment, was %+v (%v)", comments, err)
+	}
+	if count, _ := post.CommentCount(false); count != 1 {
+		t.Errorf("Expected a comment waiting for moderation, was %d", count)
+	}
+
+	pending.SetApproved(true)
+	if err := pending.Update(); err != nil {
+		t.Fatal("Couldn't approve comment", err)
+	}
+	if comments, _ := post.Comments(); len(comments) != 2 {
+		t.Errorf("Expected the approved comments, was %+v", comments)
+	}
+
+	// replies outlive what they reply to
+	if err := pending.Destroy(); err != nil {
+		t.Fatal("Couldn't delete comment", err)
+	}
+	found, err = conn.FindCommentById(reply.Id())
+	if err != nil || found.ParentId() != 0 {
+		t.Errorf("Expected the reply to be left alone, was %+v (%v)", found, err)
+	}
+}
+
+func TestSaveCommentsOfSameDate(t *testing.T) {
+	saveCommentsOfSameDate(t, setupPGConnection())
+}
+
+func saveCommentsOfSameDate(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	var user, post = generateUserAndPost(conn, 0)
+	var date = time.Date(2014, 3, 2, 10, 0, 0, 0, time.UTC)
+	var first = conn.NewComment(user.Id(), post.Id(), "First", date)
+	var second = conn.NewComment(user.Id(), post.Id(), "Second", date)
+	if err := first.Save(); err != nil {
+		t.Fatal("Couldn't save comment", err)
+	}
+	if err := second.Save(); err != nil {
+		t.Fatal("Couldn't save comment", err)
+	}
+	if first.Id() == second.Id() {
+		t.Fatalf("Expected comments of the same date to have their own id, both were %d", first.Id())
+	}
+	found, err := conn.FindCommentById(second.Id())
+	if err != nil || found.Content() != "Second" {
+		t.Errorf("Expected the second comment by its id, was %+v, %v", found, err)
+	}
+}
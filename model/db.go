@@ -2,7 +2,9 @@ package model
 
 import (
 	"database/sql"
+	"fmt"
 	_ "github.com/bmizerany/pq"
+	"strings"
 )
 
 //
@@ -28,11 +30,20 @@ func NewConnection(modelaser DBVendor) (*DBConnection, error) {
 	// Order matters, topologically sorted since tables are
 	// inter dependent
 	conn.createUserTable()
+	conn.createUserRoleTable()
+	conn.createUserBanTable()
+	conn.createSessionTable()
+	conn.createApiTokenTable()
 	conn.createAuthorTable()
 	conn.createPostTable()
+	conn.createPostSlugTable()
+	conn.createPostRevisionTable()
 	conn.createLabelTable()
 	conn.createLabelPostRelation()
 	conn.createCommentTable()
+	conn.createAuditEntryTable()
+	conn.createSyncedCommitTable()
+	conn.createMediaTable()
 	return conn, nil
 }
 
@@ -43,15 +54,55 @@ func (conn *DBConnection) DeleteConnection() {
 	// Order matters, topologically sorted since tables are
 	// inter dependent
 
+	conn.dropMediaTable()
+	conn.dropSyncedCommitTable()
+	conn.dropAuditEntryTable()
 	conn.dropCommentTable()
 	conn.dropLabelPostRelation()
 	conn.dropLabelTable()
+	conn.dropPostRevisionTable()
+	conn.dropPostSlugTable()
 	conn.dropPostTable()
 	conn.dropAuthorTable()
+	conn.dropApiTokenTable()
+	conn.dropSessionTable()
+	conn.dropUserBanTable()
+	conn.dropUserRoleTable()
 	conn.dropUserTable()
 
 }
 
+// Runs query, which selects a COUNT, and returns it.  It's how lists know
+// how many pages they have.
+func (conn *DBConnection) count(query string, args ...interface{}) (int, error) {
+	vendor := conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("count 1:", err)
+		return 0, err
+	}
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow(query, args...).Scan(&count); err != nil {
+		fmt.Println("count 2:", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// The placeholders of n arguments, `$1, $2, ...`, for queries on lists.
+func placeholders(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		if i > 1 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "$%d", i)
+	}
+	return b.String()
+}
+
 // Interface to abstract between different drivers (SQLite or Postgres)
 type DBVendor interface {
 	// not exported because only used within package
@@ -27,6 +27,14 @@ var queryForAllLabel string = `
 SELECT L.label_id, L.name
 FROM Label AS L`
 
+var queryPageOfLabels string = queryForAllLabel + `
+ORDER BY L.label_id
+LIMIT $1 OFFSET $2`
+
+var countLabels string = `
+SELECT COUNT(L.label_id)
+FROM Label AS L`
+
 var renameLabelById string = `
 UPDATE Label
 SET name = $1
@@ -92,19 +100,33 @@ func (conn *DBConnection) dropLabelTable() {
 
 // Finds all the labels in the database
 func (conn *DBConnection) FindAllLabels() ([]Label, error) {
+	return conn.findLabels(queryForAllLabel)
+}
+
+// Finds limit labels, from the one at offset, oldest first.
+func (conn *DBConnection) FindLabelsPage(limit, offset int) ([]Label, error) {
+	return conn.findLabels(queryPageOfLabels, limit, offset)
+}
+
+// Counts the labels in the database.
+func (conn *DBConnection) CountLabels() (int, error) {
+	return conn.count(countLabels)
+}
+
+func (conn *DBConnection) findLabels(query string, args ...interface{}) ([]Label, error) {
 	var labels []Label
 	var vendor = conn.databaser
 
 	model, err := sql.Open(vendor.Driver(), vendor.Name())
 	if err != nil {
-		fmt.Println("FindAllLabels 1:", err)
+		fmt.Println("findLabels 1:", err)
 		return labels, err
 	}
 	defer model.Close()
 
-	rows, err := model.Query(queryForAllLabel)
+	rows, err := model.Query(query, args...)
 	if err != nil {
-		fmt.Println("FindAllLabels 2:", err)
+		fmt.Println("findLabels 2:", err)
 		return labels, err
 	}
 	defer rows.Close()
@@ -116,6 +138,7 @@ func (conn *DBConnection) FindAllLabels() ([]Label, error) {
 		l := Label{
 			id:   id,
 			name: name,
+			conn: conn,
 		}
 		labels = append(labels, l)
 	}
@@ -0,0 +1,269 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/russross/blackfriday"
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// MarkdownOptions are what posts get on top of common Markdown.
+type MarkdownOptions struct {
+	// colors the fenced code blocks of a known language, like ```go, with
+	// the classes css/markdown.css styles
+	Highlight bool
+	// Pandoc footnotes, like [^1]
+	Footnotes bool
+	// gives headings an id and a link to themselves
+	Anchors bool
+	// replaces a [TOC] paragraph with the table of contents
+	TOC bool
+	// turns list items starting with [ ] or [x] into checkboxes
+	TaskLists bool
+}
+
+// AllMarkdown are the options posts get unless told otherwise.
+var AllMarkdown = MarkdownOptions{
+	Highlight: true,
+	Footnotes: true,
+	Anchors:   true,
+	TOC:       true,
+	TaskLists: true,
+}
+
+// ParseMarkdownOptions reads options from a list like "highlight,toc", of
+// highlight, footnotes, anchors, toc and tasks.  "none" is none of them.
+func ParseMarkdownOptions(list string) (MarkdownOptions, error) {
+	var options MarkdownOptions
+	for _, name := range strings.Split(list, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "highlight":
+			options.Highlight = true
+		case "footnotes":
+			options.Footnotes = true
+		case "anchors":
+			options.Anchors = true
+		case "toc":
+			options.TOC = true
+		case "tasks":
+			options.TaskLists = true
+		case "none", "":
+		default:
+			return options, fmt.Errorf("unknown Markdown option %q", name)
+		}
+	}
+	return options, nil
+}
+
+// MarkdownRenderer renders Markdown to HTML safe to put in a page as is.
+type MarkdownRenderer struct {
+	options    MarkdownOptions
+	flags      int
+	extensions int
+	parameters blackfriday.HtmlRendererParameters
+	// Allowlist of the HTML that rendered Markdown may hold.  Anything
+	// else, like scripts, event handlers or javascript: links, is dropped.
+	policy *bluemonday.Policy
+}
+
+const commonFlags = blackfriday.HTML_USE_XHTML |
+	blackfriday.HTML_USE_SMARTYPANTS |
+	blackfriday.HTML_SMARTYPANTS_FRACTIONS |
+	blackfriday.HTML_SMARTYPANTS_DASHES |
+	blackfriday.HTML_SMARTYPANTS_LATEX_DASHES
+
+const commonExtensions = blackfriday.EXTENSION_NO_INTRA_EMPHASIS |
+	blackfriday.EXTENSION_TABLES |
+	blackfriday.EXTENSION_FENCED_CODE |
+	blackfriday.EXTENSION_AUTOLINK |
+	blackfriday.EXTENSION_STRIKETHROUGH |
+	blackfriday.EXTENSION_SPACE_HEADERS |
+	blackfriday.EXTENSION_HEADER_IDS |
+	blackfriday.EXTENSION_BACKSLASH_LINE_BREAK |
+	blackfriday.EXTENSION_DEFINITION_LISTS
+
+// NewMarkdownRenderer renders the Markdown of posts, with options.
+func NewMarkdownRenderer(options MarkdownOptions) *MarkdownRenderer {
+	r := &MarkdownRenderer{
+		options:    options,
+		flags:      commonFlags,
+		extensions: commonExtensions,
+		parameters: blackfriday.HtmlRendererParameters{
+			// so that headings don't take the ids of the layout's
+			HeaderIDPrefix:             "h-",
+			FootnoteReturnLinkContents: "&#8617;",
+		},
+		policy: bluemonday.UGCPolicy(),
+	}
+	r.policy.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#.-]+$`)).OnElements("code")
+	if options.Footnotes {
+		r.extensions |= blackfriday.EXTENSION_FOOTNOTES
+		r.flags |= blackfriday.HTML_FOOTNOTE_RETURN_LINKS
+		r.policy.AllowAttrs("class").Matching(regexp.MustCompile(`^footnote-ref$`)).OnElements("sup")
+		r.policy.AllowAttrs("class").Matching(regexp.MustCompile(`^footnotes$`)).OnElements("div")
+		r.policy.AllowAttrs("class").Matching(regexp.MustCompile(`^footnote-return$`)).OnElements("a")
+	}
+	if options.Anchors || options.TOC {
+		r.extensions |= blackfriday.EXTENSION_AUTO_HEADER_IDS
+	}
+	if options.Highlight {
+		r.policy.AllowAttrs("class").Matching(regexp.MustCompile(`^chroma$`)).OnElements("pre")
+		r.policy.AllowAttrs("class").Matching(chromaClass).OnElements("span")
+	}
+	return r
+}
+
+// Renders the Markdown of comments: no HTML, pictures, headings or any of
+// the options, and links that search engines don't follow.
+func newCommentRenderer() *MarkdownRenderer {
+	policy := bluemonday.NewPolicy()
+	policy.AllowElements("p", "br", "em", "strong", "del", "code", "pre", "blockquote", "ul", "ol", "li")
+	policy.AllowStandardURLs()
+	policy.AllowAttrs("href").OnElements("a")
+	policy.RequireNoFollowOnLinks(true)
+	return &MarkdownRenderer{
+		flags: commonFlags |
+			blackfriday.HTML_SKIP_HTML |
+			blackfriday.HTML_SKIP_IMAGES |
+			blackfriday.HTML_SAFELINK |
+			blackfriday.HTML_NOFOLLOW_LINKS,
+		extensions: blackfriday.EXTENSION_NO_INTRA_EMPHASIS |
+			blackfriday.EXTENSION_FENCED_CODE |
+			blackfriday.EXTENSION_AUTOLINK |
+			blackfriday.EXTENSION_STRIKETHROUGH |
+			blackfriday.EXTENSION_BACKSLASH_LINE_BREAK,
+		policy: policy,
+	}
+}
+
+// Render renders content, then drops what isn't safe.
+func (r *MarkdownRenderer) Render(content string) template.HTML {
+	var renderer blackfriday.Renderer = blackfriday.HtmlRendererWithParameters(r.flags, "", "", r.parameters)
+	if r.options.Highlight {
+		renderer = highlighter{renderer}
+	}
+	unsafe := blackfriday.MarkdownOptions([]byte(content), renderer, blackfriday.Options{Extensions: r.extensions})
+	html := string(r.policy.SanitizeBytes(unsafe))
+
+	// what's added from here is made of what's been sanitized
+	if r.options.TOC && strings.Contains(html, tocMarker) {
+		html = strings.Replace(html, tocMarker, tableOfContents(html), 1)
+	}
+	if r.options.Anchors {
+		html = heading.ReplaceAllString(html, `<h$1 id="$2">$3 <a class="anchor" href="#$2" title="Permalink">#</a></h$1>`)
+	}
+	if r.options.TaskLists {
+		html = task.ReplaceAllStringFunc(html, checkbox)
+	}
+	return template.HTML(html)
+}
+
+var postMarkdown = NewMarkdownRenderer(AllMarkdown)
+
+var commentMarkdown = newCommentRenderer()
+
+// SetupMarkdown sets what posts are rendered with.
+func SetupMarkdown(options MarkdownOptions) {
+	postMarkdown = NewMarkdownRenderer(options)
+}
+
+// The classes chroma gives to the tokens it colors, like "kd" or "s2".
+var chromaClass = regexp.MustCompile(`^(line|cl|[a-z][a-z0-9]{0,2})$`)
+
+var highlightFormatter = chromahtml.New(chromahtml.WithClasses(true))
+
+// Colors fenced code blocks, falling back on plain ones for the languages
+// it doesn't know.
+type highlighter struct {
+	blackfriday.Renderer
+}
+
+func (h highlighter) BlockCode(out *bytes.Buffer, text []byte, info string) {
+	lang := strings.Fields(info)
+	if len(lang) == 0 {
+		h.Renderer.BlockCode(out, text, info)
+		return
+	}
+	lexer := lexers.Get(lang[0])
+	if lexer == nil {
+		h.Renderer.BlockCode(out, text, info)
+		return
+	}
+	tokens, err := chroma.Coalesce(lexer).Tokenise(nil, string(text))
+	if err != nil {
+		h.Renderer.BlockCode(out, text, info)
+		return
+	}
+	var code bytes.Buffer
+	if err := highlightFormatter.Format(&code, styles.Fallback, tokens); err != nil {
+		h.Renderer.BlockCode(out, text, info)
+		return
+	}
+	if out.Len() > 0 {
+		out.WriteByte('\n')
+	}
+	out.Write(code.Bytes())
+	out.WriteByte('\n')
+}
+
+const tocMarker = "<p>[TOC]</p>"
+
+var (
+	heading = regexp.MustCompile(`<h([1-6]) id="([^"]+)">(.*?)</h[1-6]>`)
+	tag     = regexp.MustCompile(`<[^>]*>`)
+	task    = regexp.MustCompile(`<li>(<p>)?\[([ xX])\] `)
+)
+
+// The headings of html as nested lists of links to them.
+func tableOfContents(html string) string {
+	headings := heading.FindAllStringSubmatch(html, -1)
+	if len(headings) == 0 {
+		return ""
+	}
+	top := 6
+	for _, h := range headings {
+		if level := int(h[1][0] - '0'); level < top {
+			top = level
+		}
+	}
+
+	var toc strings.Builder
+	toc.WriteString(`<nav class="toc">`)
+	depth := 0
+	for _, h := range headings {
+		level := int(h[1][0]-'0') - top + 1
+		if level > depth {
+			for ; depth < level; depth++ {
+				toc.WriteString("<ul><li>")
+			}
+		} else {
+			toc.WriteString("</li>")
+			for ; depth > level; depth-- {
+				toc.WriteString("</ul></li>")
+			}
+			toc.WriteString("<li>")
+		}
+		fmt.Fprintf(&toc, `<a href="#%s">%s</a>`, h[2], strings.TrimSpace(tag.ReplaceAllString(h[3], "")))
+	}
+	for ; depth > 0; depth-- {
+		toc.WriteString("</li></ul>")
+	}
+	toc.WriteString("</nav>")
+	return toc.String()
+}
+
+func checkbox(item string) string {
+	m := task.FindStringSubmatch(item)
+	checked := ""
+	if m[2] != " " {
+		checked = ` checked="checked"`
+	}
+	return `<li class="task">` + m[1] + `<input type="checkbox" disabled="disabled"` + checked + " /> "
+}
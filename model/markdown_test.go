@@ -0,0 +1,103 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdownSanitizes(t *testing.T) {
+	cases := []struct {
+		markdown  string
+		forbidden string
+	}{
+		{`<script>alert("xss")</script>`, "<script"},
+		{`<img src="x" onerror="alert('xss')">`, "onerror"},
+		{`[click me](javascript:alert('xss'))`, "javascript:"},
+		{`<a href="javascript:alert('xss')">click me</a>`, "javascript:"},
+		{`<iframe src="http://evil.example.com"></iframe>`, "<iframe"},
+		{`<p style="background:url(javascript:alert('xss'))">hi</p>`, "style="},
+		{`<span class="evil hidden">hi</span>`, "evil"},
+		{"# <img src=x onerror=alert(1)>\n\n[TOC]", "onerror="},
+		{"```html\n<script>alert(1)</script>\n```", "<script"},
+	}
+
+	for name, renderer := range map[string]*MarkdownRenderer{"posts": postMarkdown, "comments": commentMarkdown} {
+		for i, c := range cases {
+			html := string(renderer.Render(c.markdown))
+			if strings.Contains(strings.ToLower(html), c.forbidden) {
+				t.Errorf("Case #%d of %s, <%s> should have been dropped from <%s>", i, name, c.forbidden, html)
+			}
+		}
+	}
+}
+
+func TestRenderMarkdownKeepsMarkdown(t *testing.T) {
+	html := string(postMarkdown.Render("# Title\n\nSome *emphasis* and a [link](http://example.com).\n\n    code"))
+
+	for _, expected := range []string{
+		`<h1 id="h-title">Title <a class="anchor" href="#h-title"`,
+		"<em>emphasis</em>",
+		`<a href="http://example.com"`,
+		"<pre><code>code",
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Expected <%s> in <%s>", expected, html)
+		}
+	}
+}
+
+func TestRenderMarkdownOptions(t *testing.T) {
+	markdown := "[TOC]\n\n# Intro\n\nSee the note[^1].\n\n## Setup\n\n```go\nfunc main() {}\n```\n\n" +
+		"```nosuchlanguage\nplain\n```\n\n## Tasks\n\n- [x] done\n- [ ] todo\n\n[^1]: A note."
+
+	html := string(postMarkdown.Render(markdown))
+	for _, expected := range []string{
+		`<nav class="toc"><ul><li><a href="#h-intro">Intro</a><ul><li><a href="#h-setup">Setup</a></li><li><a href="#h-tasks">Tasks</a></li></ul></li></ul></nav>`,
+		`<pre class="chroma"><code><span class="line"><span class="cl"><span class="kd">func</span>`,
+		"<pre><code class=\"language-nosuchlanguage\">plain\n</code></pre>",
+		`<sup class="footnote-ref" id="fnref:1"><a href="#fn:1"`,
+		`<li id="fn:1">A note.`,
+		`<li class="task"><input type="checkbox" disabled="disabled" checked="checked" /> done</li>`,
+		`<li class="task"><input type="checkbox" disabled="disabled" /> todo</li>`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Expected <%s> in <%s>", expected, html)
+		}
+	}
+	if strings.Contains(html, "[TOC]") {
+		t.Errorf("Expected the [TOC] marker to be replaced in <%s>", html)
+	}
+
+	plain := string(NewMarkdownRenderer(MarkdownOptions{}).Render(markdown))
+	for _, unexpected := range []string{`class="toc"`, `class="chroma"`, `class="anchor"`, `<input`, `footnote-ref`} {
+		if strings.Contains(plain, unexpected) {
+			t.Errorf("Expected no <%s> without the options, in <%s>", unexpected, plain)
+		}
+	}
+}
+
+func TestRenderCommentMarkdown(t *testing.T) {
+	html := string(commentMarkdown.Render("# Title\n\n<b>bold</b> ![cat](http://example.com/cat.png) [link](http://example.com)\n\n- [ ] not a task\n\n[TOC]"))
+
+	for _, unexpected := range []string{"<h1", "<b>", "<img", "<input", `class="toc"`} {
+		if strings.Contains(html, unexpected) {
+			t.Errorf("Expected no <%s> in comments, in <%s>", unexpected, html)
+		}
+	}
+	if !strings.Contains(html, `<a href="http://example.com" rel="nofollow">link</a>`) {
+		t.Errorf("Expected links search engines don't follow, in <%s>", html)
+	}
+}
+
+func TestParseMarkdownOptions(t *testing.T) {
+	options, err := ParseMarkdownOptions("highlight, TOC")
+	if err != nil || options != (MarkdownOptions{Highlight: true, TOC: true}) {
+		t.Errorf("Expected highlighting and a TOC, was %+v, %v", options, err)
+	}
+	if options, err := ParseMarkdownOptions("none"); err != nil || options != (MarkdownOptions{}) {
+		t.Errorf("Expected no options, was %+v, %v", options, err)
+	}
+	if _, err := ParseMarkdownOptions("highlight,emoji"); err == nil {
+		t.Error("Expected unknown options to be refused")
+	}
+}
@@ -0,0 +1,275 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+/*
+ * SQL stuff
+ */
+
+// The name is the key of the file in the media store, the URL where
+// visitors get it.
+var createMediaTable string = `
+CREATE TABLE IF NOT EXISTS Media(
+   media_id		SERIAL PRIMARY KEY,
+   user_id		INTEGER NOT NULL,
+   name			VARCHAR(255) UNIQUE NOT NULL,
+   url			VARCHAR(1024) NOT NULL,
+   content_type	VARCHAR(255) NOT NULL,
+   size			INTEGER NOT NULL,
+   uploaded		TIMESTAMP NOT NULL,
+   CONSTRAINT fk_media_user_id
+   	FOREIGN KEY(user_id) REFERENCES BlogUser(user_id) ON DELETE CASCADE
+)`
+
+var dropMediaTable string = `
+DROP TABLE Media;
+`
+
+var insertMedia string = `
+INSERT INTO Media(
+	user_id,
+	name,
+	url,
+	content_type,
+	size,
+	uploaded)
+VALUES( $1, $2, $3, $4, $5, $6 )
+RETURNING media_id`
+
+var queryForAllMedia string = `
+SELECT
+	M.media_id,
+	M.user_id,
+	M.name,
+	M.url,
+	M.content_type,
+	M.size,
+	M.uploaded
+FROM
+	Media AS M
+ORDER BY
+	M.uploaded DESC`
+
+var findMediaById string = `
+SELECT
+	M.media_id,
+	M.user_id,
+	M.name,
+	M.url,
+	M.content_type,
+	M.size,
+	M.uploaded
+FROM
+	Media AS M
+WHERE
+	M.media_id = $1`
+
+var deleteMediaById string = `
+DELETE FROM
+	Media
+WHERE
+	Media.media_id = $1`
+
+// A file a user uploaded, like a picture for a post.
+type Media struct {
+	id          int64
+	userId      int64
+	name        string
+	url         string
+	contentType string
+	size        int64
+	uploaded    time.Time
+	conn        *DBConnection
+}
+
+func (m *Media) Id() int64 {
+	return m.id
+}
+
+// The user who uploaded the file
+func (m *Media) UserId() int64 {
+	return m.userId
+}
+
+// The name of the file in the media store
+func (m *Media) Name() string {
+	return m.name
+}
+
+// Where visitors get the file
+func (m *Media) URL() string {
+	return m.url
+}
+
+func (m *Media) ContentType() string {
+	return m.contentType
+}
+
+// Tells if the file is a picture, that posts can show.
+func (m *Media) IsImage() bool {
+	return len(m.contentType) > 6 && m.contentType[:6] == "image/"
+}
+
+// The size of the file, in bytes
+func (m *Media) Size() int64 {
+	return m.size
+}
+
+func (m *Media) Uploaded() time.Time {
+	return m.uploaded
+}
+
+/*
+ *  SQL Stuff
+ */
+
+func (conn *DBConnection) createMediaTable() {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Error on open of database", err)
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec(createMediaTable)
+	if err != nil {
+		fmt.Printf("Error creating Media table, driver \"%s\", modelname \"%s\", query = %s\n",
+			vendor.Driver(), vendor.Name(), createMediaTable)
+		fmt.Println(err)
+		return
+	}
+}
+
+func (conn *DBConnection) dropMediaTable() {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Error on open of database", err)
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec(dropMediaTable)
+	if err != nil {
+		fmt.Println("Error droping table:", err)
+	}
+}
+
+// Creates a media for a file in the media store.  It is NOT saved, you must
+// call "Save" on it.
+func (conn *DBConnection) NewMedia(userId int64, name string, url string,
+	contentType string, size int64, uploaded time.Time) *Media {
+	return &Media{
+		id:          -1,
+		userId:      userId,
+		name:        name,
+		url:         url,
+		contentType: contentType,
+		size:        size,
+		uploaded:    uploaded,
+		conn:        conn,
+	}
+}
+
+// Finds all the media, most recent first.
+func (conn *DBConnection) FindAllMedia() ([]Media, error) {
+	return conn.queryMedia(queryForAllMedia)
+}
+
+func (conn *DBConnection) FindMediaById(id int64) (*Media, error) {
+	media, err := conn.queryMedia(findMediaById, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(media) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &media[0], nil
+}
+
+func (conn *DBConnection) queryMedia(query string, args ...interface{}) ([]Media, error) {
+	var media []Media
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("queryMedia 1:", err)
+		return media, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		fmt.Println("queryMedia 2:", err)
+		return media, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m := Media{conn: conn}
+		err := rows.Scan(&m.id,
+			&m.userId,
+			&m.name,
+			&m.url,
+			&m.contentType,
+			&m.size,
+			&m.uploaded)
+		if err != nil {
+			fmt.Println("queryMedia 3:", err)
+			return media, err
+		}
+		media = append(media, m)
+	}
+	return media, nil
+}
+
+/*
+ *  Operations on Media
+ */
+
+// Saves a new media to the database.
+func (m *Media) Save() error {
+	vendor := m.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Media Save 1:", err)
+		return err
+	}
+	defer db.Close()
+
+	err = db.QueryRow(insertMedia,
+		m.userId,
+		m.name,
+		m.url,
+		m.contentType,
+		m.size,
+		m.uploaded).Scan(&m.id)
+	if err != nil {
+		fmt.Println("Media Save 2:", err)
+	}
+	return err
+}
+
+// Deletes the media from the database, not its file from the media store.
+func (m *Media) Destroy() error {
+	vendor := m.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Media Destroy 1:", err)
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(deleteMediaById, m.id)
+	if err != nil {
+		fmt.Println("Media Destroy 2:", err)
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSaveMedia(t *testing.T) {
+	saveMedia(t, setupPGConnection())
+}
+
+func saveMedia(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	user := generateUser(conn, 1)
+	user.Save()
+
+	date := time.Date(2014, 3, 2, 10, 0, 0, 0, time.UTC)
+	first := conn.NewMedia(user.Id(), "a1-cat.png", "/media/a1-cat.png", "image/png", 1024, date)
+	if err := first.Save(); err != nil {
+		t.Fatal("Couldn't save media", err)
+	}
+	second := conn.NewMedia(user.Id(), "b2-talk.pdf", "/media/b2-talk.pdf", "application/pdf", 2048, date.Add(time.Hour))
+	if err := second.Save(); err != nil {
+		t.Fatal("Couldn't save media", err)
+	}
+	if err := conn.NewMedia(user.Id(), "a1-cat.png", "", "image/png", 1, date).Save(); err == nil {
+		t.Error("Two media shouldn't have the same name")
+	}
+
+	found, err := conn.FindMediaById(first.Id())
+	if err != nil {
+		t.Fatal("Couldn't find media", err)
+	}
+	if found.Name() != "a1-cat.png" || found.URL() != "/media/a1-cat.png" || found.Size() != 1024 ||
+		found.UserId() != user.Id() || !found.Uploaded().Equal(date) || !found.IsImage() {
+		t.Errorf("Expected the media saved, was %+v", found)
+	}
+
+	all, err := conn.FindAllMedia()
+	if err != nil || len(all) != 2 || all[0].Id() != second.Id() || all[0].IsImage() {
+		t.Errorf("Expected the most recent media first, was %+v, %v", all, err)
+	}
+
+	if err := first.Destroy(); err != nil {
+		t.Fatal("Couldn't destroy media", err)
+	}
+	if _, err := conn.FindMediaById(first.Id()); err == nil {
+		t.Error("Expected the media to be gone")
+	}
+}
@@ -3,7 +3,7 @@ package model
 import (
 	"database/sql"
 	"fmt"
-	"github.com/russross/blackfriday"
+	"html/template"
 	"time"
 )
 
@@ -18,10 +18,18 @@ CREATE TABLE IF NOT EXISTS Post(
    content		TEXT NOT NULL,
    image_url	VARCHAR(255) NOT NULL,
    date			TIMESTAMP NOT NULL,
+   updated		TIMESTAMP NOT NULL,
    CONSTRAINT fk_post_authorid
    	FOREIGN KEY (author_id) REFERENCES Author(author_id) ON DELETE SET NULL
 )`
 
+// Posts made before they had an updated date were last updated when they
+// were made, as far as anyone knows.
+var addPostUpdatedColumn string = `
+ALTER TABLE Post ADD COLUMN IF NOT EXISTS updated TIMESTAMP;
+UPDATE Post SET updated = date WHERE updated IS NULL;
+ALTER TABLE Post ALTER COLUMN updated SET NOT NULL`
+
 var dropPostTable string = `
 DROP TABLE Post;
 `
@@ -32,8 +40,10 @@ INSERT INTO Post(
 	title,
 	content,
 	image_url,
-	date)
-VALUES( $1, $2, $3, $4, $5)`
+	date,
+	updated)
+VALUES( $1, $2, $3, $4, $5, $6)
+RETURNING post_id`
 
 var updatePostForId string = `
 UPDATE Post
@@ -42,9 +52,10 @@ SET
 	title = $2,
 	content = $3,
 	image_url = $4,
-	date = $5
+	date = $5,
+	updated = $6
 WHERE
-	post_id = $6;`
+	post_id = $7;`
 
 var findPostById string = `
 SELECT
@@ -53,6 +64,7 @@ SELECT
 	P.content,
 	P.image_URL,
 	P.date,
+	P.updated,
    A.user_id,
    U.username,
    U.registration_date,
@@ -81,6 +93,7 @@ SELECT
 	P.content,
 	P.image_url,
 	P.date,
+	P.updated,
 	A.user_id,
    U.username,
    U.registration_date,
@@ -94,6 +107,24 @@ WHERE
 	P.author_id = A.author_id
 	AND A.user_id = U.user_id`
 
+// The posts of ids, their placeholders in place of %s.
+var queryPostsForIds string = queryForAllPost + `
+	AND P.post_id IN (%s)`
+
+var queryPageOfPosts string = queryForAllPost + `
+ORDER BY
+	P.post_id
+LIMIT $1 OFFSET $2`
+
+var countPosts string = `
+SELECT
+	COUNT(P.post_id)
+FROM
+	Post AS P,
+	Author AS A
+WHERE
+	P.author_id = A.author_id`
+
 // Relations
 var queryForAllCommentsOfPostId string = `
 SELECT
@@ -103,11 +134,28 @@ SELECT
 	C.content,
 	C.date,
 	C.up_vote,
-	C.down_vote
+	C.down_vote,
+	C.parent_id,
+	C.approved
 FROM
 	Comment as C
 WHERE
-	C.post_id = $1`
+	C.post_id = $1
+	AND C.approved = $2`
+
+var queryPageOfCommentsOfPostId string = queryForAllCommentsOfPostId + `
+ORDER BY
+	C.comment_id
+LIMIT $3 OFFSET $4`
+
+var countCommentsOfPostId string = `
+SELECT
+	COUNT(C.comment_id)
+FROM
+	Comment AS C
+WHERE
+	C.post_id = $1
+	AND C.approved = $2`
 
 var queryForAllLabelsOfPostId string = `
 SELECT
@@ -120,15 +168,6 @@ WHERE
 	LP.post_id = $1
 	AND LP.label_id = P.id`
 
-var queryPostIdFromDate string = `
-SELECT
-	P.post_id
-FROM
-	Post AS P
-WHERE
-	P.date = $1
-`
-
 // Represents a post in the blog
 type Post struct {
 	id       int64
@@ -136,8 +175,10 @@ type Post struct {
 	title    string
 	content  string
 	imageURL string
-	date     time.Time
-	conn     *DBConnection
+	// when it was written, and when it was last changed
+	date    time.Time
+	updated time.Time
+	conn    *DBConnection
 }
 
 func (p *Post) Id() int64 {
@@ -160,8 +201,8 @@ func (p *Post) Content() string {
 	return p.content
 }
 
-func (p *Post) ContentMarkdown() string {
-	return string(blackfriday.MarkdownCommon([]byte(p.content)))
+func (p *Post) ContentMarkdown() template.HTML {
+	return renderWithShortcodes(p, p.content, postMarkdown.Render)
 }
 
 func (p *Post) SetContent(content string) {
@@ -184,7 +225,35 @@ func (p *Post) SetDate(time time.Time) {
 	p.date = time
 }
 
+// Updated is when the post was last changed, which is its date until it
+// is.
+func (p *Post) Updated() time.Time {
+	return p.updated
+}
+
+// SetUpdated tells when a post that isn't saved yet was last changed, like
+// one restored from a backup.  Update makes it now.
+func (p *Post) SetUpdated(updated time.Time) {
+	p.updated = updated
+}
+
+// The approved comments of the post, those visitors see.
 func (p *Post) Comments() ([]Comment, error) {
+	return p.findComments(queryForAllCommentsOfPostId, p.id, true)
+}
+
+// Finds limit comments of the post, approved or waiting for moderation,
+// from the one at offset, oldest first.
+func (p *Post) CommentsPage(approved bool, limit, offset int) ([]Comment, error) {
+	return p.findComments(queryPageOfCommentsOfPostId, p.id, approved, limit, offset)
+}
+
+// Counts the comments of the post, approved or waiting for moderation.
+func (p *Post) CommentCount(approved bool) (int, error) {
+	return p.conn.count(countCommentsOfPostId, p.id, approved)
+}
+
+func (p *Post) findComments(query string, args ...interface{}) ([]Comment, error) {
 	model := p.conn.databaser
 	db, err := sql.Open(model.Driver(), model.Name())
 	if err != nil {
@@ -193,9 +262,9 @@ func (p *Post) Comments() ([]Comment, error) {
 	}
 	defer db.Close()
 
-	stmt, err := db.Prepare(queryForAllCommentsOfPostId)
+	stmt, err := db.Prepare(query)
 	if err != nil {
-		fmt.Printf("Couldn't prepare statement: %s", queryForAllCommentsOfPostId)
+		fmt.Printf("Couldn't prepare statement: %s", query)
 		fmt.Println(err)
 		return nil, err
 	}
@@ -203,7 +272,7 @@ func (p *Post) Comments() ([]Comment, error) {
 
 	var comments []Comment
 
-	rows, err := stmt.Query(p.id)
+	rows, err := stmt.Query(args...)
 	if err != nil {
 		fmt.Println("Couldn't read rows from statement", err)
 		return comments, err
@@ -211,28 +280,11 @@ func (p *Post) Comments() ([]Comment, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var id int64
-		var userId int64
-		var postId int64
-		var content string
-		var date time.Time
-		var upVote int64
-		var downVote int64
-		rows.Scan(&id, &userId, &postId, &content, &date, &upVote, &downVote)
+		c, err := scanComment(rows, p.conn)
 		if err != nil {
 			fmt.Println("Error while scanning comments", err)
 			return comments, err
 		}
-		c := Comment{
-			id:       id,
-			userId:   userId,
-			postId:   postId,
-			content:  content,
-			date:     date,
-			upVote:   upVote,
-			downVote: downVote,
-			conn:     p.conn,
-		}
 		comments = append(comments, c)
 	}
 
@@ -266,6 +318,11 @@ func (conn *DBConnection) createPostTable() {
 		fmt.Println(err)
 		return
 	}
+
+	_, err = db.Exec(addPostUpdatedColumn)
+	if err != nil {
+		fmt.Println("Error adding the updated date of posts:", err)
+	}
 }
 
 func (conn *DBConnection) dropPostTable() {
@@ -295,26 +352,42 @@ func (conn *DBConnection) NewPost(author *Author, title string, content string,
 		content:  content,
 		imageURL: imageURL,
 		date:     date,
+		updated:  date,
 		conn:     conn,
 	}
 }
 
 // Finds all the posts in the database
 func (conn *DBConnection) FindAllPosts() ([]Post, error) {
+	return conn.findPosts(queryForAllPost)
+}
+
+// Finds limit posts, from the one at offset, oldest first.
+func (conn *DBConnection) FindPostsPage(limit, offset int) ([]Post, error) {
+	return conn.findPosts(queryPageOfPosts, limit, offset)
+}
+
+// Counts the posts in the database.
+func (conn *DBConnection) CountPosts() (int, error) {
+	return conn.count(countPosts)
+}
+
+// Finds the posts of query, which selects the columns of queryForAllPost.
+func (conn *DBConnection) findPosts(query string, args ...interface{}) ([]Post, error) {
 
 	var posts []Post
 	var model = conn.databaser
 
 	db, err := sql.Open(model.Driver(), model.Name())
 	if err != nil {
-		fmt.Println("FindAllPosts 1:", err)
+		fmt.Println("findPosts 1:", err)
 		return posts, err
 	}
 	defer db.Close()
 
-	rows, err := db.Query(queryForAllPost)
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		fmt.Println("FindAllPosts 2:", err)
+		fmt.Println("findPosts 2:", err)
 		return posts, err
 	}
 	defer rows.Close()
@@ -326,6 +399,7 @@ func (conn *DBConnection) FindAllPosts() ([]Post, error) {
 		var content string
 		var imageURL string
 		var date time.Time
+		var updated time.Time
 		var userId int64
 		var username string
 		var registDate time.Time
@@ -337,6 +411,7 @@ func (conn *DBConnection) FindAllPosts() ([]Post, error) {
 			&content,
 			&imageURL,
 			&date,
+			&updated,
 			&userId,
 			&username,
 			&registDate,
@@ -367,6 +442,7 @@ func (conn *DBConnection) FindAllPosts() ([]Post, error) {
 			content:  content,
 			imageURL: imageURL,
 			date:     date,
+			updated:  updated,
 			conn:     conn,
 		}
 		posts = append(posts, p)
@@ -400,6 +476,7 @@ func (conn *DBConnection) FindPostById(id int64) (*Post, error) {
 	var content string
 	var imageURL string
 	var date time.Time
+	var updated time.Time
 	var userId int64
 	var username string
 	var registDate time.Time
@@ -411,6 +488,7 @@ func (conn *DBConnection) FindPostById(id int64) (*Post, error) {
 		&content,
 		&imageURL,
 		&date,
+		&updated,
 		&userId,
 		&username,
 		&registDate,
@@ -443,6 +521,7 @@ func (conn *DBConnection) FindPostById(id int64) (*Post, error) {
 		content:  content,
 		imageURL: imageURL,
 		date:     date,
+		updated:  updated,
 		conn:     conn,
 	}
 
@@ -471,25 +550,16 @@ func (p *Post) Save() error {
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(p.author.Id(), p.title, p.content, p.imageURL, p.date)
+	// the id of the post inserted, which posts of the same date don't tell
+	err = stmt.QueryRow(p.author.Id(), p.title, p.content, p.imageURL, p.date, p.updated).Scan(&p.id)
 	if err != nil {
 		fmt.Println("Save 3:", err)
 		return err
 	}
-
-	// query the ID we inserted
-	idStmt, err := db.Prepare(queryPostIdFromDate)
-	if err != nil {
-		fmt.Println("Save 5:", err)
-		return err
-	}
-	defer idStmt.Close()
-
-	row := idStmt.QueryRow(p.Date())
-
-	return row.Scan(&p.id)
+	return nil
 }
 
+// Saves the changes to the post, which was updated now.
 func (p *Post) Update() error {
 	vendor := p.conn.databaser
 	db, err := sql.Open(vendor.Driver(), vendor.Name())
@@ -506,11 +576,13 @@ func (p *Post) Update() error {
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(p.author.Id(), p.title, p.content, p.imageURL, p.date, p.id)
+	updated := time.Now().UTC()
+	_, err = stmt.Exec(p.author.Id(), p.title, p.content, p.imageURL, p.date, updated, p.id)
 	if err != nil {
 		fmt.Println("Save 3:", err)
 		return err
 	}
+	p.updated = updated
 	return nil
 }
 
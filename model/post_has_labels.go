@@ -39,6 +39,7 @@ SELECT
 	P.content,
 	P.image_url,
 	P.date,
+	P.updated,
 	A.user_id,
    U.username,
    U.registration_date,
@@ -53,7 +54,32 @@ WHERE
 	LP.label_id = $1
 	AND LP.post_id = P.post_id
 	AND P.author_id = A.author_id
-	AND A.author_id = U.user_id`
+	AND A.user_id = U.user_id`
+
+var queryPageOfPostsByLabelId string = findPostsByLabelId + `
+ORDER BY
+	P.post_id
+LIMIT $2 OFFSET $3`
+
+var countPostsByLabelId string = `
+SELECT
+	COUNT(LP.post_id)
+FROM
+	LabelPost AS LP
+WHERE
+	LP.label_id = $1`
+
+var queryLastPostDateByLabel string = `
+SELECT
+	LP.label_id,
+	MAX(P.date)
+FROM
+	LabelPost AS LP,
+	Post AS P
+WHERE
+	LP.post_id = P.post_id
+GROUP BY
+	LP.label_id`
 
 // used
 var findLabelsByPostId string = `
@@ -329,81 +355,46 @@ func (l *Label) Destroy() error {
 
 // Returns all the posts making reference to this label.
 func (l *Label) Posts() ([]Post, error) {
-	var posts []Post
+	return l.conn.findPosts(findPostsByLabelId, l.Id())
+}
 
-	vendor := l.conn.databaser
+// Returns limit posts making reference to this label, from the one at
+// offset, oldest first.
+func (l *Label) PostsPage(limit, offset int) ([]Post, error) {
+	return l.conn.findPosts(queryPageOfPostsByLabelId, l.Id(), limit, offset)
+}
+
+// Counts the posts making reference to this label.
+func (l *Label) PostCount() (int, error) {
+	return l.conn.count(countPostsByLabelId, l.Id())
+}
+
+// Returns the date of the most recent post of each label, by label id.
+// Labels without posts aren't there.
+func (conn *DBConnection) LastPostDateByLabel() (map[int64]time.Time, error) {
+	dates := make(map[int64]time.Time)
+	vendor := conn.databaser
 
 	db, err := openDatabase(&vendor)
 	if err != nil {
-		return posts, err
+		return dates, err
 	}
 	defer db.Close()
 
-	stmt, err := db.Prepare(findPostsByLabelId)
+	rows, err := db.Query(queryLastPostDateByLabel)
 	if err != nil {
-		return posts, err
-	}
-	defer stmt.Close()
-
-	rows, err := stmt.Query(l.Id())
-	if err != nil {
-		return posts, err
+		fmt.Println("LastPostDateByLabel:", err)
+		return dates, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var id int64
-		var authorId int64
-		var title string
-		var content string
-		var imageURL string
 		var date time.Time
-		var userId int64
-		var username string
-		var registDate time.Time
-		var timezone int
-		var email string
-
-		err := rows.Scan(&id,
-			&authorId,
-			&title,
-			&content,
-			&imageURL,
-			&date,
-			&userId,
-			&username,
-			&registDate,
-			&timezone,
-			&email)
-
-		if err != nil {
-			return posts, err
-		}
-		u := &User{
-			id:               userId,
-			username:         username,
-			registrationDate: registDate,
-			timezone:         timezone,
-			email:            email,
-			conn:             l.conn,
-		}
-
-		a := &Author{
-			id:   authorId,
-			user: u,
-			conn: l.conn,
+		if err := rows.Scan(&id, &date); err != nil {
+			return dates, err
 		}
-
-		p := Post{
-			id:       id,
-			author:   a,
-			title:    title,
-			content:  content,
-			imageURL: imageURL,
-			date:     date,
-			conn:     l.conn,
-		}
-		posts = append(posts, p)
+		dates[id] = date
 	}
-
-	return posts, nil
+	return dates, rows.Err()
 }
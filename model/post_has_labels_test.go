@@ -236,3 +236,37 @@ func allPostsOfLabel(t *testing.T, p *DBConnection) {
 		}
 	}
 }
+
+func TestLastPostDateByLabel(t *testing.T) {
+	lastPostDateByLabel(t, setupPGConnection())
+}
+
+func lastPostDateByLabel(t *testing.T, p *DBConnection) {
+	defer p.DeleteConnection()
+
+	var label Label
+	var last time.Time
+	for i := int64(1); i < int64(4); i++ {
+		post, err := generatePost(p, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		post.SetDate(time.Date(2014, 3, int(i), 0, 0, 0, 0, time.UTC))
+		if err := post.Update(); err != nil {
+			t.Fatal("Couldn't update post", err)
+		}
+		label, err = post.AddLabel("cat video")
+		if err != nil {
+			t.Fatal("Couldn't add label", err)
+		}
+		last = post.Date()
+	}
+
+	dates, err := p.LastPostDateByLabel()
+	if err != nil {
+		t.Fatal("Couldn't query for dates", err)
+	}
+	if !dates[label.Id()].Equal(last) {
+		t.Errorf("Expected <%v> but was <%v>", last, dates[label.Id()])
+	}
+}
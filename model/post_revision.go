@@ -0,0 +1,156 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+/*
+ * SQL stuff
+ */
+var createPostRevisionTable string = `
+CREATE TABLE IF NOT EXISTS PostRevision(
+   revision_id	SERIAL PRIMARY KEY,
+   post_id 		INTEGER NOT NULL,
+   commit_hash	VARCHAR(64) NOT NULL,
+   email			VARCHAR(255) NOT NULL,
+   date			TIMESTAMP NOT NULL,
+   CONSTRAINT fk_postrevision_post_id
+   	FOREIGN KEY(post_id) REFERENCES Post(post_id) ON DELETE CASCADE
+)`
+
+var dropPostRevisionTable string = `
+DROP TABLE PostRevision;
+`
+
+var insertPostRevision string = `
+INSERT INTO PostRevision(post_id, commit_hash, email, date) VALUES ( $1, $2, $3, $4 )`
+
+var queryForAllRevisionsOfPostId string = `
+SELECT
+	R.revision_id,
+	R.commit_hash,
+	R.email,
+	R.date
+FROM
+	PostRevision AS R
+WHERE
+	R.post_id = $1
+ORDER BY
+	R.date, R.revision_id`
+
+// A revision of a post, the commit of a file that changed it.
+type Revision struct {
+	id     int64
+	postId int64
+	commit string
+	email  string
+	date   time.Time
+}
+
+func (r *Revision) Id() int64 {
+	return r.id
+}
+
+func (r *Revision) PostId() int64 {
+	return r.postId
+}
+
+// The hash of the commit
+func (r *Revision) Commit() string {
+	return r.commit
+}
+
+// The email of who made the commit
+func (r *Revision) Email() string {
+	return r.email
+}
+
+func (r *Revision) Date() time.Time {
+	return r.date
+}
+
+// Records that the post was changed by a commit.  The post must be saved.
+func (p *Post) AddRevision(commit string, email string, date time.Time) error {
+	vendor := p.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("AddRevision 1:", err)
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(insertPostRevision, p.id, commit, email, date); err != nil {
+		fmt.Println("AddRevision 2:", err)
+		return err
+	}
+	return nil
+}
+
+// Returns the revisions of the post, from the oldest to the newest.
+func (p *Post) Revisions() ([]Revision, error) {
+	var revisions []Revision
+	vendor := p.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Revisions 1:", err)
+		return revisions, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(queryForAllRevisionsOfPostId, p.id)
+	if err != nil {
+		fmt.Println("Revisions 2:", err)
+		return revisions, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r := Revision{postId: p.id}
+		if err := rows.Scan(&r.id, &r.commit, &r.email, &r.date); err != nil {
+			return revisions, err
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
+
+/*
+ *  SQL Stuff
+ */
+
+func (conn *DBConnection) createPostRevisionTable() {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Error on open of database", err)
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec(createPostRevisionTable)
+	if err != nil {
+		fmt.Printf("Error creating PostRevision table, driver \"%s\", modelname \"%s\", query = %s\n",
+			vendor.Driver(), vendor.Name(), createPostRevisionTable)
+		fmt.Println(err)
+		return
+	}
+}
+
+func (conn *DBConnection) dropPostRevisionTable() {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Error on open of database", err)
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec(dropPostRevisionTable)
+	if err != nil {
+		fmt.Println("Error droping table:", err)
+	}
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPostRevisions(t *testing.T) {
+	postRevisions(t, setupPGConnection())
+}
+
+func postRevisions(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	post, err := generatePost(conn, 1)
+	if err != nil {
+		t.Fatal("Couldn't save post", err)
+	}
+	if revisions, err := post.Revisions(); err != nil || len(revisions) != 0 {
+		t.Errorf("Expected no revision, was %v, %v", revisions, err)
+	}
+
+	date := time.Date(2014, 3, 2, 10, 0, 0, 0, time.UTC)
+	if err := post.AddRevision("bbbb", "b@example.com", date.Add(time.Hour)); err != nil {
+		t.Fatal("Couldn't add revision", err)
+	}
+	if err := post.AddRevision("aaaa", "a@example.com", date); err != nil {
+		t.Fatal("Couldn't add revision", err)
+	}
+
+	revisions, err := post.Revisions()
+	if err != nil || len(revisions) != 2 {
+		t.Fatalf("Expected 2 revisions, was %v, %v", revisions, err)
+	}
+	if revisions[0].Commit() != "aaaa" || revisions[0].Email() != "a@example.com" ||
+		!revisions[0].Date().Equal(date) || revisions[1].Commit() != "bbbb" {
+		t.Errorf("Expected the revisions from the oldest, was %+v", revisions)
+	}
+}
+
+func TestSyncedCommit(t *testing.T) {
+	syncedCommit(t, setupPGConnection())
+}
+
+func syncedCommit(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	if commit, err := conn.SyncedCommit("repo:master"); err != nil || commit != "" {
+		t.Errorf("Expected no commit but was <%s>, %v", commit, err)
+	}
+	for _, expected := range []string{"aaaa", "bbbb"} {
+		if err := conn.SetSyncedCommit("repo:master", expected); err != nil {
+			t.Fatal("Couldn't set commit", err)
+		}
+		if commit, err := conn.SyncedCommit("repo:master"); err != nil || commit != expected {
+			t.Errorf("Expected <%s> but was <%s>, %v", expected, commit, err)
+		}
+	}
+}
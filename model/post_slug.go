@@ -0,0 +1,304 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidSlug is returned when giving a post a slug that isn't one.
+var ErrInvalidSlug = errors.New("model: slugs are lower case letters and digits between single dashes, like hello-world")
+
+/*
+ * SQL stuff
+ */
+var createPostSlugTable string = `
+CREATE TABLE IF NOT EXISTS PostSlug(
+   post_id 		INTEGER PRIMARY KEY,
+   slug 			VARCHAR(255) NOT NULL UNIQUE,
+   CONSTRAINT fk_postslug_post_id
+   	FOREIGN KEY(post_id) REFERENCES Post(post_id) ON DELETE CASCADE
+)`
+
+var dropPostSlugTable string = `
+DROP TABLE PostSlug;
+`
+
+var insertPostSlug string = `
+INSERT INTO PostSlug(post_id, slug) VALUES ( $1, $2 )`
+
+var deletePostSlugForPostId string = `
+DELETE FROM
+	PostSlug
+WHERE
+	PostSlug.post_id = $1`
+
+var findSlugByPostId string = `
+SELECT
+	S.slug
+FROM
+	PostSlug AS S
+WHERE
+	S.post_id = $1`
+
+var findPostIdBySlug string = `
+SELECT
+	S.post_id
+FROM
+	PostSlug AS S
+WHERE
+	S.slug = $1`
+
+// The posts of slugs, their placeholders in place of %s.
+var queryPostIdsForSlugs string = `
+SELECT
+	S.post_id,
+	S.slug
+FROM
+	PostSlug AS S
+WHERE
+	S.slug IN (%s)`
+
+var queryForAllPostSlugs string = `
+SELECT
+	S.post_id,
+	S.slug
+FROM
+	PostSlug AS S`
+
+// Returns the slug of the post, the name it has outside of the blog, like
+// the file it's written in.  Posts that were never given one have none.
+func (p *Post) Slug() (string, error) {
+	vendor := p.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Slug 1:", err)
+		return "", err
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare(findSlugByPostId)
+	if err != nil {
+		fmt.Println("Slug 2:", err)
+		return "", err
+	}
+	defer stmt.Close()
+
+	var slug string
+	err = stmt.QueryRow(p.id).Scan(&slug)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		fmt.Println("Slug 3:", err)
+		return "", err
+	}
+	return slug, nil
+}
+
+// ValidSlug tells if slug is one, lower case letters and digits between
+// single dashes.  Slugs name files, so nothing else is safe in them.
+func ValidSlug(slug string) bool {
+	if slug == "" || strings.ToLower(slug) != slug {
+		return false
+	}
+	for _, word := range strings.Split(slug, "-") {
+		if word == "" {
+			return false
+		}
+		for _, r := range word {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Gives a slug to the post, or takes it away if slug is empty.  The post
+// must be saved, and no other post can have the same slug.  Slugs that
+// aren't valid are refused with ErrInvalidSlug.
+func (p *Post) SetSlug(slug string) error {
+	if slug != "" && !ValidSlug(slug) {
+		return ErrInvalidSlug
+	}
+	vendor := p.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("SetSlug 1:", err)
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(deletePostSlugForPostId, p.id); err != nil {
+		fmt.Println("SetSlug 2:", err)
+		tx.Rollback()
+		return err
+	}
+
+	if slug != "" {
+		if _, err := tx.Exec(insertPostSlug, p.id, slug); err != nil {
+			fmt.Println("SetSlug 3:", err)
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Finds the post that has the given slug
+func (conn *DBConnection) FindPostBySlug(slug string) (*Post, error) {
+	vendor := conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("FindPostBySlug 1:", err)
+		return nil, err
+	}
+	defer db.Close()
+
+	var id int64
+	if err := db.QueryRow(findPostIdBySlug, slug).Scan(&id); err != nil {
+		// normal if no post has that slug
+		return nil, err
+	}
+	return conn.FindPostById(id)
+}
+
+// Returns the slug of every post that has one, by post id.
+func (conn *DBConnection) PostSlugs() (map[int64]string, error) {
+	slugs := make(map[int64]string)
+	vendor := conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("PostSlugs 1:", err)
+		return slugs, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(queryForAllPostSlugs)
+	if err != nil {
+		fmt.Println("PostSlugs 2:", err)
+		return slugs, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var slug string
+		if err := rows.Scan(&id, &slug); err != nil {
+			return slugs, err
+		}
+		slugs[id] = slug
+	}
+	return slugs, rows.Err()
+}
+
+// Finds the posts of refs, ids or slugs, with a query for the slugs and
+// one for the posts, however many there are.  Returns them by ref, refs of
+// no post being left out.
+func (conn *DBConnection) findPostsByRef(refs []string) (map[string]*Post, error) {
+	found := make(map[string]*Post)
+	var ids, slugs []interface{}
+	// the refs of each post id
+	refsOf := make(map[int64][]string)
+	for _, ref := range refs {
+		if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+			ids = append(ids, id)
+			refsOf[id] = append(refsOf[id], ref)
+		} else {
+			slugs = append(slugs, ref)
+		}
+	}
+
+	if len(slugs) > 0 {
+		vendor := conn.databaser
+		db, err := sql.Open(vendor.Driver(), vendor.Name())
+		if err != nil {
+			fmt.Println("findPostsByRef 1:", err)
+			return found, err
+		}
+		defer db.Close()
+
+		rows, err := db.Query(fmt.Sprintf(queryPostIdsForSlugs, placeholders(len(slugs))), slugs...)
+		if err != nil {
+			fmt.Println("findPostsByRef 2:", err)
+			return found, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id int64
+			var slug string
+			if err := rows.Scan(&id, &slug); err != nil {
+				return found, err
+			}
+			ids = append(ids, id)
+			refsOf[id] = append(refsOf[id], slug)
+		}
+		if err := rows.Err(); err != nil {
+			return found, err
+		}
+	}
+	if len(ids) == 0 {
+		return found, nil
+	}
+
+	posts, err := conn.findPosts(fmt.Sprintf(queryPostsForIds, placeholders(len(ids))), ids...)
+	if err != nil {
+		return found, err
+	}
+	for i := range posts {
+		for _, ref := range refsOf[posts[i].Id()] {
+			found[ref] = &posts[i]
+		}
+	}
+	return found, nil
+}
+
+/*
+ *  SQL Stuff
+ */
+
+func (conn *DBConnection) createPostSlugTable() {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Error on open of database", err)
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec(createPostSlugTable)
+	if err != nil {
+		fmt.Printf("Error creating PostSlug table, driver \"%s\", modelname \"%s\", query = %s\n",
+			vendor.Driver(), vendor.Name(), createPostSlugTable)
+		fmt.Println(err)
+		return
+	}
+}
+
+func (conn *DBConnection) dropPostSlugTable() {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Error on open of database", err)
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec(dropPostSlugTable)
+	if err != nil {
+		fmt.Println("Error droping table:", err)
+	}
+}
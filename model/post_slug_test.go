@@ -0,0 +1,74 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestPostSlug(t *testing.T) {
+	postSlug(t, setupPGConnection())
+}
+
+func postSlug(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	post, err := generatePost(conn, 1)
+	if err != nil {
+		t.Fatal("Couldn't save post", err)
+	}
+	if slug, err := post.Slug(); err != nil || slug != "" {
+		t.Errorf("Expected no slug but was <%s>, %v", slug, err)
+	}
+
+	if err := post.SetSlug("hello-world"); err != nil {
+		t.Fatal("Couldn't set slug", err)
+	}
+	if slug, err := post.Slug(); err != nil || slug != "hello-world" {
+		t.Errorf("Expected <hello-world> but was <%s>, %v", slug, err)
+	}
+	found, err := conn.FindPostBySlug("hello-world")
+	if err != nil || found.Id() != post.Id() {
+		t.Errorf("Expected post %d by its slug, was %v, %v", post.Id(), found, err)
+	}
+	if slugs, err := conn.PostSlugs(); err != nil || slugs[post.Id()] != "hello-world" {
+		t.Errorf("Expected the slug by post id, was %v, %v", slugs, err)
+	}
+
+	other, err := generatePost(conn, 2)
+	if err != nil {
+		t.Fatal("Couldn't save post", err)
+	}
+	if err := other.SetSlug("hello-world"); err == nil {
+		t.Error("Two posts shouldn't have the same slug")
+	}
+
+	for _, slug := range []string{"../evil", "Hello", "hello--world", "-hello", "a/b"} {
+		if err := post.SetSlug(slug); err != ErrInvalidSlug {
+			t.Errorf("Expected <%s> to be refused, was %v", slug, err)
+		}
+	}
+
+	if err := post.SetSlug(""); err != nil {
+		t.Fatal("Couldn't remove slug", err)
+	}
+	if _, err := conn.FindPostBySlug("hello-world"); err == nil {
+		t.Error("Expected the slug to be gone")
+	}
+}
+
+func TestValidSlug(t *testing.T) {
+	for slug, valid := range map[string]bool{
+		"hello-world": true,
+		"été-2014":    true,
+		"":            false,
+		"Hello":       false,
+		"-hello":      false,
+		"hello-":      false,
+		"hello--you":  false,
+		"../evil":     false,
+		"a.md":        false,
+	} {
+		if ValidSlug(slug) != valid {
+			t.Errorf("Expected ValidSlug(%q) to be %v", slug, valid)
+		}
+	}
+}
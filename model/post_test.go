@@ -152,6 +152,74 @@ func findAllPost(t *testing.T, conn *DBConnection) {
 	}
 }
 
+func TestFindPostsPage(t *testing.T) {
+	findPostsPage(t, setupPGConnection())
+}
+
+func findPostsPage(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	for i := int64(1); i <= 5; i++ {
+		var author = generateAuthor(conn, i)
+		var post = conn.NewPost(author,
+			fmt.Sprintf("Title #%d", i),
+			fmt.Sprintf("Content #%d", i),
+			fmt.Sprintf("ImageUrl #%d", i),
+			time.Now().UTC())
+		post.Save()
+	}
+
+	total, err := conn.CountPosts()
+	if err != nil || total != 5 {
+		t.Errorf("Expected 5 posts, was <%d> (%v)", total, err)
+	}
+
+	posts, err := conn.FindPostsPage(2, 2)
+	if err != nil {
+		t.Fatal("Couldn't query a page of posts", err)
+	}
+	if len(posts) != 2 || posts[0].Id() != 3 || posts[1].Id() != 4 {
+		t.Errorf("Expected posts 3 and 4, was %v", posts)
+	}
+
+	comments, err := posts[0].CommentsPage(true, 10, 0)
+	if err != nil || len(comments) != 0 {
+		t.Errorf("Expected no comments, was %v (%v)", comments, err)
+	}
+}
+
+func TestPostUpdated(t *testing.T) {
+	postUpdated(t, setupPGConnection())
+}
+
+func postUpdated(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	date := time.Date(2014, 3, 2, 10, 0, 0, 0, time.UTC)
+	post := conn.NewPost(generateAuthor(conn, 1), "Title", "Content", "", date)
+	if err := post.Save(); err != nil {
+		t.Fatal("Couldn't save post", err)
+	}
+	if !post.Updated().Equal(date) {
+		t.Errorf("Expected a new post updated at <%v>, was <%v>", date, post.Updated())
+	}
+
+	post.SetContent("Changed")
+	if err := post.Update(); err != nil {
+		t.Fatal("Couldn't update post", err)
+	}
+	found, err := conn.FindPostById(post.Id())
+	if err != nil {
+		t.Fatal("Couldn't find post", err)
+	}
+	if !found.Date().Equal(date) {
+		t.Errorf("Expected the date to stay <%v>, was <%v>", date, found.Date())
+	}
+	if !found.Updated().After(date) {
+		t.Errorf("Expected the post updated after <%v>, was <%v>", date, found.Updated())
+	}
+}
+
 func TestIdIncrements(t *testing.T) {
 	postIdIncrements(t, setupPGConnection())
 }
@@ -217,3 +285,29 @@ func findAllPostComments(t *testing.T, connist *DBConnection) {
 		}
 	}
 }
+
+func TestSavePostsOfSameDate(t *testing.T) {
+	savePostsOfSameDate(t, setupPGConnection())
+}
+
+func savePostsOfSameDate(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	author := generateAuthor(conn, 1)
+	date := time.Date(2014, 3, 2, 0, 0, 0, 0, time.UTC)
+	first := conn.NewPost(author, "First", "Content", "", date)
+	second := conn.NewPost(author, "Second", "Content", "", date)
+	if err := first.Save(); err != nil {
+		t.Fatal("Couldn't save post", err)
+	}
+	if err := second.Save(); err != nil {
+		t.Fatal("Couldn't save post", err)
+	}
+	if first.Id() == second.Id() {
+		t.Fatalf("Expected posts of the same date to have their own id, both were %d", first.Id())
+	}
+	found, err := conn.FindPostById(second.Id())
+	if err != nil || found.Title() != "Second" {
+		t.Errorf("Expected the second post by its id, was %v, %v", found, err)
+	}
+}
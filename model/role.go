@@ -0,0 +1,205 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+/*
+ * SQL stuff
+ */
+var createUserRoleTable string = `
+CREATE TABLE IF NOT EXISTS UserRole(
+   user_id 		INTEGER PRIMARY KEY,
+   role 			VARCHAR(32) NOT NULL,
+   CONSTRAINT fk_userrole_user_id
+   	FOREIGN KEY(user_id) REFERENCES BlogUser(user_id) ON DELETE CASCADE
+)`
+
+var dropUserRoleTable string = `
+DROP TABLE UserRole;
+`
+
+var insertUserRole string = `
+INSERT INTO UserRole(user_id, role) VALUES ( $1, $2 )`
+
+var deleteUserRoleForUserId string = `
+DELETE FROM
+	UserRole
+WHERE
+	UserRole.user_id = $1`
+
+var findRoleByUserId string = `
+SELECT
+	R.role
+FROM
+	UserRole AS R
+WHERE
+	R.user_id = $1`
+
+// The role of a user decides what the user is allowed to do on the blog.
+type Role string
+
+const (
+	// Can comment on posts
+	RoleCommenter Role = "commenter"
+	// Can also write posts, but not delete them
+	RoleContributor Role = "contributor"
+	// Can also delete the posts they wrote
+	RoleAuthor Role = "author"
+	// Can edit and delete any post, comment or label
+	RoleEditor Role = "editor"
+	// Can do anything, including managing users
+	RoleAdmin Role = "admin"
+)
+
+// All the roles, from the least to the most privileged.
+var Roles = []Role{
+	RoleCommenter,
+	RoleContributor,
+	RoleAuthor,
+	RoleEditor,
+	RoleAdmin,
+}
+
+// Tells if the role is one of the known roles.
+func (r Role) Valid() bool {
+	for _, known := range Roles {
+		if r == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Tells if users of that role write posts, and thus need to be an Author.
+func (r Role) Writes() bool {
+	return r != RoleCommenter
+}
+
+// Returns the role of the user.  Users that were never given a role are
+// authors if they have an Author entry, commenters otherwise.  It's looked
+// up once for each User, like for each request.
+func (u *User) Role() (Role, error) {
+	if u.role != "" {
+		return u.role, nil
+	}
+	vendor := u.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Role 1:", err)
+		return RoleCommenter, err
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare(findRoleByUserId)
+	if err != nil {
+		fmt.Println("Role 2:", err)
+		return RoleCommenter, err
+	}
+	defer stmt.Close()
+
+	var role string
+	err = stmt.QueryRow(u.id).Scan(&role)
+	if err == sql.ErrNoRows {
+		if _, err := u.conn.FindAuthorByUserId(u.id); err == nil {
+			u.role = RoleAuthor
+		} else {
+			u.role = RoleCommenter
+		}
+		return u.role, nil
+	}
+	if err != nil {
+		fmt.Println("Role 3:", err)
+		return RoleCommenter, err
+	}
+	u.role = Role(role)
+	return u.role, nil
+}
+
+// Gives a role to the user.  The user must be saved.  If the role writes
+// posts and the user isn't an Author yet, an Author is created for it.
+func (u *User) SetRole(role Role) error {
+	if !role.Valid() {
+		return fmt.Errorf("unknown role <%s>", role)
+	}
+
+	if role.Writes() {
+		if _, err := u.conn.FindAuthorByUserId(u.id); err != nil {
+			if err := u.conn.NewAuthor(u).Save(); err != nil {
+				fmt.Println("SetRole 1:", err)
+				return err
+			}
+		}
+	}
+
+	vendor := u.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("SetRole 2:", err)
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(deleteUserRoleForUserId, u.id); err != nil {
+		fmt.Println("SetRole 3:", err)
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec(insertUserRole, u.id, string(role)); err != nil {
+		fmt.Println("SetRole 4:", err)
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	u.role = role
+	return nil
+}
+
+/*
+ *  SQL Stuff
+ */
+
+func (conn *DBConnection) createUserRoleTable() {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Error on open of database", err)
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec(createUserRoleTable)
+	if err != nil {
+		fmt.Printf("Error creating UserRole table, driver \"%s\", modelname \"%s\", query = %s\n",
+			vendor.Driver(), vendor.Name(), createUserRoleTable)
+		fmt.Println(err)
+		return
+	}
+}
+
+func (conn *DBConnection) dropUserRoleTable() {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Error on open of database", err)
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec(dropUserRoleTable)
+	if err != nil {
+		fmt.Println("Error droping table:", err)
+	}
+}
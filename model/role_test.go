@@ -0,0 +1,96 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestDefaultRole(t *testing.T) {
+	defaultRole(t, setupPGConnection())
+}
+
+func defaultRole(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	user := generateUser(conn, 1)
+	user.Save()
+	if role, err := user.Role(); err != nil || role != RoleCommenter {
+		t.Errorf("Expected <%s> but was <%s>, %v", RoleCommenter, role, err)
+	}
+
+	author := generateAuthor(conn, 2)
+	if role, err := author.User().Role(); err != nil || role != RoleAuthor {
+		t.Errorf("Expected <%s> but was <%s>, %v", RoleAuthor, role, err)
+	}
+}
+
+func TestSetRole(t *testing.T) {
+	setRole(t, setupPGConnection())
+}
+
+func setRole(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	user := generateUser(conn, 1)
+	user.Save()
+
+	for _, expected := range Roles {
+		if err := user.SetRole(expected); err != nil {
+			t.Errorf("Couldn't set role <%s>: %v", expected, err)
+			return
+		}
+		actual, err := user.Role()
+		if err != nil {
+			t.Error("Couldn't get role", err)
+			return
+		}
+		if actual != expected {
+			t.Errorf("Expected <%s> but was <%s>", expected, actual)
+		}
+	}
+
+	// Admins write posts, so they must be authors
+	if _, err := conn.FindAuthorByUserId(user.Id()); err != nil {
+		t.Error("User with a writing role should be an author", err)
+	}
+
+	if err := user.SetRole(Role("overlord")); err == nil {
+		t.Error("Unknown roles should be refused")
+	}
+}
+
+func TestRoleLoadedOnce(t *testing.T) {
+	roleLoadedOnce(t, setupPGConnection())
+}
+
+func roleLoadedOnce(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	user := generateUser(conn, 1)
+	user.Save()
+	if err := user.SetRole(RoleEditor); err != nil {
+		t.Fatal("Couldn't set role", err)
+	}
+
+	// like the same user in another request
+	other, err := conn.FindUserById(user.Id())
+	if err != nil {
+		t.Fatal("Couldn't find user", err)
+	}
+	if role, _ := other.Role(); role != RoleEditor {
+		t.Errorf("Expected <%s> but was <%s>", RoleEditor, role)
+	}
+	if err := user.SetRole(RoleContributor); err != nil {
+		t.Fatal("Couldn't set role", err)
+	}
+	if role, _ := user.Role(); role != RoleContributor {
+		t.Errorf("Expected the role set to be kept, was <%s>", role)
+	}
+	if role, _ := other.Role(); role != RoleEditor {
+		t.Errorf("Expected the role to be looked up once, was <%s>", role)
+	}
+	if again, _ := conn.FindUserById(user.Id()); again != nil {
+		if role, _ := again.Role(); role != RoleContributor {
+			t.Errorf("Expected <%s> but was <%s>", RoleContributor, role)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+/*
+ * SQL stuff
+ */
+
+// The session id column holds a hash of the id found in the cookie, so
+// that reading the table isn't enough to steal sessions.
+var createSessionTable string = `
+CREATE TABLE IF NOT EXISTS Session(
+   session_id	VARCHAR(64) PRIMARY KEY,
+   user_id		INTEGER,
+   data			TEXT NOT NULL,
+   user_agent	VARCHAR(255) NOT NULL,
+   created		TIMESTAMP NOT NULL,
+   last_seen	TIMESTAMP NOT NULL,
+   expiry		TIMESTAMP NOT NULL,
+   CONSTRAINT fk_session_user_id
+   	FOREIGN KEY(user_id) REFERENCES BlogUser(user_id) ON DELETE CASCADE
+)`
+
+var dropSessionTable string = `
+DROP TABLE Session;
+`
+
+var insertSession string = `
+INSERT INTO Session(
+	session_id,
+	user_id,
+	data,
+	user_agent,
+	created,
+	last_seen,
+	expiry)
+VALUES( $1, $2, $3, $4, $5, $6, $7 )`
+
+var updateSessionForId string = `
+UPDATE Session
+SET
+	user_id = $1,
+	data = $2,
+	last_seen = $3,
+	expiry = $4
+WHERE
+	session_id = $5`
+
+var findSessionById string = `
+SELECT
+	S.user_id,
+	S.data,
+	S.user_agent,
+	S.created,
+	S.last_seen,
+	S.expiry
+FROM
+	Session AS S
+WHERE
+	S.session_id = $1`
+
+var querySessionsForUserId string = `
+SELECT
+	S.session_id,
+	S.user_id,
+	S.data,
+	S.user_agent,
+	S.created,
+	S.last_seen,
+	S.expiry
+FROM
+	Session AS S
+WHERE
+	S.user_id = $1
+ORDER BY
+	S.last_seen DESC`
+
+var deleteSessionById string = `
+DELETE FROM
+	Session
+WHERE
+	Session.session_id = $1`
+
+var deleteSessionsForUserId string = `
+DELETE FROM
+	Session
+WHERE
+	Session.user_id = $1`
+
+var deleteSessionsExpiredBefore string = `
+DELETE FROM
+	Session
+WHERE
+	Session.expiry < $1
+	OR Session.last_seen < $2`
+
+// A browser session, kept on the server.  Until a user logs in, a session
+// belongs to no user and UserId is 0.
+type Session struct {
+	id        string
+	userId    int64
+	data      string
+	userAgent string
+	created   time.Time
+	lastSeen  time.Time
+	expiry    time.Time
+	conn      *DBConnection
+}
+
+// The hashed id of the session.
+func (s *Session) Id() string {
+	return s.id
+}
+
+func (s *Session) UserId() int64 {
+	return s.userId
+}
+
+func (s *Session) SetUserId(id int64) {
+	s.userId = id
+}
+
+// The encoded values of the session.
+func (s *Session) Data() string {
+	return s.data
+}
+
+func (s *Session) SetData(data string) {
+	s.data = data
+}
+
+func (s *Session) UserAgent() string {
+	return s.userAgent
+}
+
+func (s *Session) Created() time.Time {
+	return s.created
+}
+
+func (s *Session) LastSeen() time.Time {
+	return s.lastSeen
+}
+
+func (s *Session) SetLastSeen(date time.Time) {
+	s.lastSeen = date
+}
+
+// The time after which the session isn't valid, however active it is.
+func (s *Session) Expiry() time.Time {
+	return s.expiry
+}
+
+func (s *Session) SetExpiry(date time.Time) {
+	s.expiry = date
+}
+
+/*
+ *  SQL Stuff
+ */
+
+func (conn *DBConnection) createSessionTable() {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Error on open of database", err)
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec(createSessionTable)
+	if err != nil {
+		fmt.Printf("Error creating Session table, driver \"%s\", modelname \"%s\", query = %s\n",
+			vendor.Driver(), vendor.Name(), createSessionTable)
+		fmt.Println(err)
+		return
+	}
+}
+
+func (conn *DBConnection) dropSessionTable() {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Error on open of database", err)
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec(dropSessionTable)
+	if err != nil {
+		fmt.Println("Error droping table:", err)
+	}
+}
+
+// Creates a session.  It is NOT saved, you must call "Save" on it.
+func (conn *DBConnection) NewSession(id string, userAgent string,
+	created time.Time, expiry time.Time) *Session {
+	return &Session{
+		id:        id,
+		userAgent: userAgent,
+		created:   created,
+		lastSeen:  created,
+		expiry:    expiry,
+		conn:      conn,
+	}
+}
+
+// Finds the session with the given hashed id.
+func (conn *DBConnection) FindSessionById(id string) (*Session, error) {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("FindSessionById 1:", err)
+		return nil, err
+	}
+	defer db.Close()
+
+	var userId sql.NullInt64
+	s := &Session{id: id, conn: conn}
+	err = db.QueryRow(findSessionById, id).Scan(&userId,
+		&s.data,
+		&s.userAgent,
+		&s.created,
+		&s.lastSeen,
+		&s.expiry)
+	if err != nil {
+		// normal if the session doesn't exist
+		return nil, err
+	}
+	s.userId = userId.Int64
+	return s, nil
+}
+
+// Finds the sessions of a user, the most recently seen first.
+func (conn *DBConnection) FindSessionsByUserId(userId int64) ([]Session, error) {
+	var sessions []Session
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("FindSessionsByUserId 1:", err)
+		return sessions, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(querySessionsForUserId, userId)
+	if err != nil {
+		fmt.Println("FindSessionsByUserId 2:", err)
+		return sessions, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id sql.NullInt64
+		s := Session{conn: conn}
+		err := rows.Scan(&s.id,
+			&id,
+			&s.data,
+			&s.userAgent,
+			&s.created,
+			&s.lastSeen,
+			&s.expiry)
+		if err != nil {
+			fmt.Println("FindSessionsByUserId 3:", err)
+			return sessions, err
+		}
+		s.userId = id.Int64
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
+
+// Destroys all the sessions of a user, logging the user out everywhere.
+func (conn *DBConnection) DestroySessionsByUserId(userId int64) error {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("DestroySessionsByUserId 1:", err)
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(deleteSessionsForUserId, userId)
+	if err != nil {
+		fmt.Println("DestroySessionsByUserId 2:", err)
+	}
+	return err
+}
+
+// Destroys the sessions past their expiry, or not seen since idleSince.
+func (conn *DBConnection) DestroyExpiredSessions(now time.Time, idleSince time.Time) error {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("DestroyExpiredSessions 1:", err)
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(deleteSessionsExpiredBefore, now, idleSince)
+	if err != nil {
+		fmt.Println("DestroyExpiredSessions 2:", err)
+	}
+	return err
+}
+
+/*
+ *  Operations on Session
+ */
+
+func (s *Session) nullUserId() sql.NullInt64 {
+	return sql.NullInt64{Int64: s.userId, Valid: s.userId > 0}
+}
+
+// Saves a new session to the database.
+func (s *Session) Save() error {
+	vendor := s.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Session Save 1:", err)
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(insertSession,
+		s.id,
+		s.nullUserId(),
+		s.data,
+		s.userAgent,
+		s.created,
+		s.lastSeen,
+		s.expiry)
+	if err != nil {
+		fmt.Println("Session Save 2:", err)
+	}
+	return err
+}
+
+// Updates the user, data, last seen time and expiry of a saved session.
+func (s *Session) Update() error {
+	vendor := s.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Session Update 1:", err)
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(updateSessionForId,
+		s.nullUserId(),
+		s.data,
+		s.lastSeen,
+		s.expiry,
+		s.id)
+	if err != nil {
+		fmt.Println("Session Update 2:", err)
+	}
+	return err
+}
+
+// Deletes the session from the database.  Its cookie isn't valid anymore.
+func (s *Session) Destroy() error {
+	vendor := s.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Session Destroy 1:", err)
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(deleteSessionById, s.id)
+	if err != nil {
+		fmt.Println("Session Destroy 2:", err)
+	}
+	return err
+}
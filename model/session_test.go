@@ -0,0 +1,96 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func generateSession(conn *DBConnection, i int64, userId int64) *Session {
+	now := time.Now().UTC()
+	s := conn.NewSession(fmt.Sprintf("%064d", i), "Mozilla/5.0", now, now.Add(time.Hour))
+	s.SetUserId(userId)
+	s.SetData("some data")
+	return s
+}
+
+func TestSaveSession(t *testing.T) {
+	saveSession(t, setupPGConnection())
+}
+
+func saveSession(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	// sessions of anonymous visitors have no user
+	expected := generateSession(conn, 1, 0)
+	if err := expected.Save(); err != nil {
+		t.Error("Save failed", err)
+		return
+	}
+
+	actual, err := conn.FindSessionById(expected.Id())
+	if err != nil {
+		t.Error("Couldn't find session back", err)
+		return
+	}
+
+	if actual.UserId() != 0 || actual.Data() != expected.Data() {
+		t.Errorf("Expected <%d, %s> but was <%d, %s>",
+			0, expected.Data(), actual.UserId(), actual.Data())
+	}
+
+	user := generateUser(conn, 1)
+	user.Save()
+	actual.SetUserId(user.Id())
+	if err := actual.Update(); err != nil {
+		t.Error("Update failed", err)
+		return
+	}
+
+	sessions, err := conn.FindSessionsByUserId(user.Id())
+	if err != nil || len(sessions) != 1 {
+		t.Errorf("Expected <1> session of user but was <%d>, %v", len(sessions), err)
+	}
+}
+
+func TestDestroySessions(t *testing.T) {
+	destroySessions(t, setupPGConnection())
+}
+
+func destroySessions(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	user := generateUser(conn, 1)
+	user.Save()
+
+	for i := int64(1); i <= 3; i++ {
+		generateSession(conn, i, user.Id()).Save()
+	}
+
+	if err := conn.DestroySessionsByUserId(user.Id()); err != nil {
+		t.Error("Couldn't destroy sessions of user", err)
+	}
+
+	sessions, _ := conn.FindSessionsByUserId(user.Id())
+	if len(sessions) != 0 {
+		t.Errorf("Expected <0> sessions but was <%d>", len(sessions))
+	}
+
+	old := generateSession(conn, 4, user.Id())
+	old.SetExpiry(time.Now().UTC().Add(-time.Minute))
+	old.Save()
+	fresh := generateSession(conn, 5, user.Id())
+	fresh.Save()
+
+	now := time.Now().UTC()
+	if err := conn.DestroyExpiredSessions(now, now.Add(-time.Hour)); err != nil {
+		t.Error("Couldn't destroy expired sessions", err)
+	}
+
+	if _, err := conn.FindSessionById(old.Id()); err == nil {
+		t.Error("Expired session should be destroyed")
+	}
+	if _, err := conn.FindSessionById(fresh.Id()); err != nil {
+		t.Error("Fresh session shouldn't be destroyed", err)
+	}
+}
@@ -0,0 +1,365 @@
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Shortcode renders a `{{< name args >}}` of a post to HTML, which is put
+// in the post as is: it must escape what it's given.
+type Shortcode func(call *ShortcodeCall) (template.HTML, error)
+
+// ShortcodeCall is a shortcode as it's written in a post.
+type ShortcodeCall struct {
+	Name string
+	// the arguments without a name, like 12 in `{{< post 12 >}}`
+	Args []string
+	// the named ones, like src in `{{< figure src="/cat.png" >}}`
+	Params map[string]string
+	// what's between `{{< name >}}` and `{{< /name >}}`, rendered
+	Inner template.HTML
+	// the post it's in, nil when it's in none
+	Post *Post
+	// what renders it, which finds the posts it links to
+	expander *shortcodeExpander
+}
+
+// Arg is the argument named key, or else the one without a name at i.
+func (c *ShortcodeCall) Arg(i int, key string) string {
+	if value, ok := c.Params[key]; ok {
+		return value
+	}
+	if i < len(c.Args) {
+		return c.Args[i]
+	}
+	return ""
+}
+
+var (
+	shortcodesMu sync.RWMutex
+	shortcodes   = map[string]Shortcode{
+		"figure":  figureShortcode,
+		"callout": calloutShortcode,
+		"note":    calloutShortcode,
+		"youtube": youtubeShortcode,
+		"post":    postShortcode,
+	}
+)
+
+var shortcodeName = regexp.MustCompile(`^[a-zA-Z][\w-]*$`)
+
+// RegisterShortcode makes `{{< name >}}` render with shortcode, in place of
+// the one that had that name, if any.
+func RegisterShortcode(name string, shortcode Shortcode) {
+	if !shortcodeName.MatchString(name) {
+		panic(fmt.Sprintf("model: invalid shortcode name %q", name))
+	}
+	shortcodesMu.Lock()
+	defer shortcodesMu.Unlock()
+	shortcodes[name] = shortcode
+}
+
+func findShortcode(name string) (Shortcode, bool) {
+	shortcodesMu.RLock()
+	defer shortcodesMu.RUnlock()
+	shortcode, ok := shortcodes[name]
+	return shortcode, ok
+}
+
+var (
+	// `{{< name args >}}`, or `{{< /name >}}` for the end of one
+	shortcodeTag = regexp.MustCompile(`\{\{<\s*(/?)([a-zA-Z][\w-]*)((?:\s+(?:[\w-]+=)?(?:"[^"]*"|[^\s"]+))*?)\s*>\}\}`)
+	shortcodeArg = regexp.MustCompile(`(?:([\w-]+)=)?(?:"([^"]*)"|([^\s"]+))`)
+	// `{{</* name */>}}`, written for `{{< name >}}` to be shown as is
+	shortcodeEscape = regexp.MustCompile(`\{\{</\*(.*?)\*/>\}\}`)
+)
+
+// Renders content with render, its shortcodes in place.  They're taken out
+// before and put back after, so that neither Markdown nor the sanitizer
+// mangles them.  Those that fail, or that don't exist, are left as written.
+func renderWithShortcodes(post *Post, content string, render func(string) template.HTML) template.HTML {
+	s := &shortcodeExpander{post: post, render: render}
+	content = shortcodeEscape.ReplaceAllStringFunc(content, func(escaped string) string {
+		inner := shortcodeEscape.FindStringSubmatch(escaped)[1]
+		return s.placeholder(template.HTML(template.HTMLEscapeString("{{<" + inner + ">}}")))
+	})
+	s.content = content
+
+	html := string(render(s.expand(content)))
+	// the last ones hold the placeholders of the first ones, like a callout
+	// holds what's in it
+	for i := len(s.snippets) - 1; i >= 0; i-- {
+		marker := placeholderFor(i)
+		// alone in their paragraph, they take its place
+		html = replaceInText(html, "<p>"+marker+"</p>", string(s.snippets[i]))
+		html = replaceInText(html, marker, string(s.snippets[i]))
+	}
+	return template.HTML(html)
+}
+
+type shortcodeExpander struct {
+	post     *Post
+	render   func(string) template.HTML
+	snippets []template.HTML
+	// the whole content, to find every post it links to at once
+	content string
+	// those posts by id or slug, once found
+	links    map[string]*Post
+	linksErr error
+}
+
+func placeholderFor(i int) string {
+	return fmt.Sprintf("goblogshortcode%dx", i)
+}
+
+func (s *shortcodeExpander) placeholder(snippet template.HTML) string {
+	s.snippets = append(s.snippets, snippet)
+	return placeholderFor(len(s.snippets) - 1)
+}
+
+// The content with its shortcodes rendered, and replaced by placeholders.
+func (s *shortcodeExpander) expand(content string) string {
+	var out strings.Builder
+	for content != "" {
+		tag := shortcodeTag.FindStringSubmatchIndex(content)
+		if tag == nil {
+			out.WriteString(content)
+			break
+		}
+		out.WriteString(content[:tag[0]])
+		written, rest := content[tag[0]:tag[1]], content[tag[1]:]
+		closing := content[tag[2]:tag[3]] == "/"
+		name := content[tag[4]:tag[5]]
+		shortcode, ok := findShortcode(name)
+		if closing || !ok {
+			out.WriteString(written)
+			content = rest
+			continue
+		}
+
+		call := newShortcodeCall(name, content[tag[6]:tag[7]])
+		call.Post = s.post
+		call.expander = s
+		if loc := endTag(rest, name); loc != nil {
+			call.Inner = s.render(s.expand(rest[:loc[0]]))
+			written += rest[:loc[1]]
+			rest = rest[loc[1]:]
+		}
+
+		snippet, err := shortcode(call)
+		if err != nil {
+			fmt.Println("Shortcode", name+":", err)
+			out.WriteString(written)
+		} else {
+			out.WriteString(s.placeholder(snippet))
+		}
+		content = rest
+	}
+	return out.String()
+}
+
+// A call of the shortcode name with the arguments as written.
+func newShortcodeCall(name, args string) *ShortcodeCall {
+	call := &ShortcodeCall{Name: name, Params: make(map[string]string)}
+	for _, arg := range shortcodeArg.FindAllStringSubmatch(args, -1) {
+		value := arg[2] + arg[3]
+		if arg[1] != "" {
+			call.Params[arg[1]] = value
+		} else {
+			call.Args = append(call.Args, value)
+		}
+	}
+	return call
+}
+
+// Where the `{{< /name >}}` closing a shortcode name is in what follows it,
+// or nil.  Shortcodes of the same name in it have their own, like a note in
+// a note.
+func endTag(rest, name string) []int {
+	depth := 0
+	for offset := 0; offset < len(rest); {
+		tag := shortcodeTag.FindStringSubmatchIndex(rest[offset:])
+		if tag == nil {
+			return nil
+		}
+		closing := tag[3] > tag[2]
+		named := rest[offset+tag[4]:offset+tag[5]] == name
+		start, end := offset+tag[0], offset+tag[1]
+		offset = end
+		switch {
+		case !named:
+		case !closing:
+			depth++
+		case depth > 0:
+			depth--
+		default:
+			return []int{start, end}
+		}
+	}
+	return nil
+}
+
+// The post of ref, an id or a slug, that the content links to.  Every post
+// the content links to is found the first time, so that a page of links
+// isn't a query by link.
+func (s *shortcodeExpander) linkedPost(ref string) (*Post, error) {
+	if s.links == nil && s.linksErr == nil {
+		var refs []string
+		for _, tag := range shortcodeTag.FindAllStringSubmatch(s.content, -1) {
+			if tag[1] == "" && tag[2] == "post" {
+				if ref := newShortcodeCall(tag[2], tag[3]).Arg(0, "id"); ref != "" {
+					refs = append(refs, ref)
+				}
+			}
+		}
+		s.links, s.linksErr = s.post.conn.findPostsByRef(refs)
+	}
+	if s.linksErr != nil {
+		return nil, s.linksErr
+	}
+	post, ok := s.links[ref]
+	if !ok {
+		return nil, fmt.Errorf("no post %q", ref)
+	}
+	return post, nil
+}
+
+// Replaces old with new in html, but not inside tags, where a snippet would
+// become attributes.
+func replaceInText(html, old, new string) string {
+	var out strings.Builder
+	for html != "" {
+		open := strings.IndexByte(html, '<')
+		if open < 0 {
+			open = len(html)
+		}
+		// old may start with a tag, like "<p>"
+		if strings.HasPrefix(html[open:], old) {
+			out.WriteString(strings.ReplaceAll(html[:open], old, new))
+			out.WriteString(new)
+			html = html[open+len(old):]
+			continue
+		}
+		out.WriteString(strings.ReplaceAll(html[:open], old, new))
+		html = html[open:]
+		if html == "" {
+			break
+		}
+		end := strings.IndexByte(html, '>')
+		if end < 0 {
+			out.WriteString(html)
+			break
+		}
+		out.WriteString(html[:end+1])
+		html = html[end+1:]
+	}
+	return out.String()
+}
+
+var shortcodeTemplates = template.Must(template.New("shortcodes").Parse(`
+{{define "figure"}}<figure class="figure"><img src="{{.Src}}" alt="{{.Alt}}" />{{with .Caption}}<figcaption>{{.}}</figcaption>{{end}}</figure>{{end}}
+{{define "callout"}}<div class="callout callout-{{.Kind}} {{.Class}}">{{with .Title}}<strong>{{.}}</strong>{{end}}{{.Inner}}</div>{{end}}
+{{define "youtube"}}<div class="video"><iframe src="{{.Src}}" title="{{.Title}}" loading="lazy" frameborder="0" allow="encrypted-media; picture-in-picture" allowfullscreen></iframe></div>{{end}}
+{{define "post"}}<a href="{{.Href}}">{{.Text}}</a>{{end}}
+`))
+
+func executeShortcode(name string, data interface{}) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := shortcodeTemplates.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
+
+// `{{< figure src="/media/cat.png" caption="My cat" alt="A cat" >}}`
+func figureShortcode(call *ShortcodeCall) (template.HTML, error) {
+	src := call.Arg(0, "src")
+	if src == "" {
+		return "", errors.New("a figure needs a src")
+	}
+	caption := call.Arg(1, "caption")
+	alt := call.Params["alt"]
+	if alt == "" {
+		alt = caption
+	}
+	return executeShortcode("figure", struct{ Src, Alt, Caption string }{src, alt, caption})
+}
+
+// The kinds of callouts, with the Bootstrap alert they look like.
+var calloutKinds = map[string]string{
+	"note":    "alert alert-info",
+	"info":    "alert alert-info",
+	"tip":     "alert alert-success",
+	"warning": "alert",
+	"danger":  "alert alert-error",
+}
+
+// `{{< callout warning title="Careful" >}}Some **Markdown**{{< /callout >}}`,
+// or `{{< note >}}...{{< /note >}}` for a note.
+func calloutShortcode(call *ShortcodeCall) (template.HTML, error) {
+	kind := "note"
+	if call.Name == "callout" {
+		if k := call.Arg(0, "kind"); k != "" {
+			kind = k
+		}
+	}
+	class, ok := calloutKinds[kind]
+	if !ok {
+		return "", fmt.Errorf("no callout of kind %q", kind)
+	}
+	return executeShortcode("callout", struct {
+		Kind, Class, Title string
+		Inner              template.HTML
+	}{kind, class, call.Params["title"], call.Inner})
+}
+
+var youtubeID = regexp.MustCompile(`^[\w-]{6,20}$`)
+
+// `{{< youtube dQw4w9WgXcQ start=42 >}}`, from youtube-nocookie.com, where
+// YouTube doesn't track visitors until they play the video.
+func youtubeShortcode(call *ShortcodeCall) (template.HTML, error) {
+	id := call.Arg(0, "id")
+	if !youtubeID.MatchString(id) {
+		return "", fmt.Errorf("invalid YouTube video %q", id)
+	}
+	src := "https://www.youtube-nocookie.com/embed/" + id
+	if start := call.Params["start"]; start != "" {
+		seconds, err := strconv.Atoi(start)
+		if err != nil || seconds < 0 {
+			return "", fmt.Errorf("invalid start %q", start)
+		}
+		src += "?start=" + strconv.Itoa(seconds)
+	}
+	title := call.Params["title"]
+	if title == "" {
+		title = "YouTube video"
+	}
+	return executeShortcode("youtube", struct{ Src, Title string }{src, title})
+}
+
+// `{{< post 12 >}}` or `{{< post hello-world "that post" >}}`, a link to the
+// post of that id or slug, showing its title unless told otherwise.
+func postShortcode(call *ShortcodeCall) (template.HTML, error) {
+	ref := call.Arg(0, "id")
+	if ref == "" {
+		return "", errors.New("a post link needs an id or a slug")
+	}
+	if call.Post == nil || call.Post.conn == nil || call.expander == nil {
+		return "", errors.New("no blog to find the post in")
+	}
+	linked, err := call.expander.linkedPost(ref)
+	if err != nil {
+		return "", err
+	}
+	text := call.Arg(1, "text")
+	if text == "" {
+		text = linked.Title()
+	}
+	return executeShortcode("post", struct{ Href, Text string }{fmt.Sprintf("/post/%d", linked.Id()), text})
+}
@@ -0,0 +1,142 @@
+package model
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"testing"
+)
+
+func renderShortcodes(content string) string {
+	return string(renderWithShortcodes(nil, content, postMarkdown.Render))
+}
+
+func TestShortcodes(t *testing.T) {
+	cases := []struct {
+		markdown string
+		expected string
+	}{
+		{`{{< figure src="/media/cat.png" caption="My <cat>" >}}`,
+			`<figure class="figure"><img src="/media/cat.png" alt="My &lt;cat&gt;" /><figcaption>My &lt;cat&gt;</figcaption></figure>`},
+		{"{{< youtube dQw4w9WgXcQ start=42 >}}",
+			`<div class="video"><iframe src="https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ?start=42" title="YouTube video"`},
+		{"{{< callout warning title=\"Careful\" >}}\nSome **Markdown**\n{{< /callout >}}",
+			`<div class="callout callout-warning alert"><strong>Careful</strong><p>Some <strong>Markdown</strong></p>`},
+		{"{{< note >}}A {{< figure /cat.png >}} in a note{{< /note >}}",
+			`<div class="callout callout-note alert alert-info"><p>A <figure class="figure"><img src="/cat.png"`},
+		// a note in a note ends at its own end
+		{"{{< note >}}Out {{< note >}}In{{< /note >}} after{{< /note >}}",
+			`<div class="callout callout-note alert alert-info"><p>Out <div class="callout callout-note alert alert-info"><p>In</p>
+</div> after</p>
+</div>`},
+		{"Written as `{{</* youtube id */>}}`.",
+			"<p>Written as <code>{{&lt; youtube id &gt;}}</code>.</p>"},
+	}
+	for i, c := range cases {
+		if html := renderShortcodes(c.markdown); !strings.Contains(html, c.expected) {
+			t.Errorf("Case #%d, expected <%s> in <%s>", i, c.expected, html)
+		}
+	}
+}
+
+func TestShortcodesLeftAsWritten(t *testing.T) {
+	for _, markdown := range []string{
+		"{{< nosuchshortcode >}}",
+		"{{< youtube \"bad id\" >}}",
+		"{{< callout purple >}}hi{{< /callout >}}",
+		// there's no post to find others from
+		"{{< post 12 >}}",
+	} {
+		html := renderShortcodes(markdown)
+		if !strings.Contains(html, "{{&lt;") || strings.Contains(html, "goblogshortcode") {
+			t.Errorf("Expected <%s> as written, was <%s>", markdown, html)
+		}
+	}
+}
+
+func TestShortcodesAreSafe(t *testing.T) {
+	cases := []struct {
+		markdown  string
+		forbidden string
+	}{
+		{`{{< figure src="javascript:alert(1)" >}}`, "javascript:"},
+		{`{{< figure src="/cat.png" caption="<script>alert(1)</script>" >}}`, "<script"},
+		{`{{< callout title="<b onclick=alert(1)>" >}}hi{{< /callout >}}`, "<b"},
+		{"{{< note >}}<script>alert(1)</script>{{< /note >}}", "<script"},
+		// in an attribute, a snippet would make new ones
+		{`[x](goblogshortcode0x) {{< figure src="/cat.png" caption="a onmouseover=alert(1)" >}}`, `href="<`},
+	}
+	for i, c := range cases {
+		if html := renderShortcodes(c.markdown); strings.Contains(html, c.forbidden) {
+			t.Errorf("Case #%d, <%s> should have been dropped from <%s>", i, c.forbidden, html)
+		}
+	}
+}
+
+func TestRegisterShortcode(t *testing.T) {
+	RegisterShortcode("shout", func(call *ShortcodeCall) (template.HTML, error) {
+		return template.HTML(fmt.Sprintf("<marquee>%s</marquee>", template.HTMLEscapeString(strings.ToUpper(call.Arg(0, "text"))))), nil
+	})
+	defer func() {
+		shortcodesMu.Lock()
+		delete(shortcodes, "shout")
+		shortcodesMu.Unlock()
+	}()
+
+	if html := renderShortcodes(`Say {{< shout text="hi & bye" >}}!`); !strings.Contains(html, "<p>Say <marquee>HI &amp; BYE</marquee>!</p>") {
+		t.Errorf("Expected the registered shortcode, was <%s>", html)
+	}
+}
+
+func TestPostShortcode(t *testing.T) {
+	postShortcodeLinks(t, setupPGConnection())
+}
+
+func postShortcodeLinks(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	linked, err := generatePost(conn, 1)
+	if err != nil {
+		t.Fatal("Couldn't save post", err)
+	}
+	if err := linked.SetSlug("hello-world"); err != nil {
+		t.Fatal("Couldn't set slug", err)
+	}
+	post, err := generatePost(conn, 2)
+	if err != nil {
+		t.Fatal("Couldn't save post", err)
+	}
+
+	expected := fmt.Sprintf(`<a href="/post/%d">%s</a>`, linked.Id(), template.HTMLEscapeString(linked.Title()))
+	for _, content := range []string{
+		fmt.Sprintf("See {{< post %d >}}.", linked.Id()),
+		"See {{< post hello-world >}}.",
+	} {
+		post.SetContent(content)
+		if html := string(post.ContentMarkdown()); !strings.Contains(html, expected) {
+			t.Errorf("Expected <%s> in <%s>", expected, html)
+		}
+	}
+
+	post.SetContent(`See {{< post hello-world "that post" >}}.`)
+	if html := string(post.ContentMarkdown()); !strings.Contains(html, ">that post</a>") {
+		t.Errorf("Expected the link to show its text, was <%s>", html)
+	}
+
+	// links to the same posts, and to none, found at once
+	post.SetContent(fmt.Sprintf("{{< post %d >}}, {{< post hello-world >}} and {{< post nowhere >}}", linked.Id()))
+	html := string(post.ContentMarkdown())
+	if strings.Count(html, expected) != 2 || !strings.Contains(html, "{{&lt; post nowhere &gt;}}") {
+		t.Errorf("Expected two links and one left as written, was <%s>", html)
+	}
+}
+
+func TestEndTag(t *testing.T) {
+	rest := "a {{< note >}}b{{< /note >}} c{{< /note >}} d"
+	if loc := endTag(rest, "note"); loc == nil || rest[loc[1]:] != " d" {
+		t.Errorf("Expected the end of the outer note, was %v", loc)
+	}
+	if loc := endTag("{{< /callout >}}", "note"); loc != nil {
+		t.Errorf("Expected no end for another shortcode, was %v", loc)
+	}
+}
@@ -0,0 +1,128 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+/*
+ * SQL stuff
+ */
+var createSyncedCommitTable string = `
+CREATE TABLE IF NOT EXISTS SyncedCommit(
+   source 		VARCHAR(1024) PRIMARY KEY,
+   commit_hash	VARCHAR(64) NOT NULL
+)`
+
+var dropSyncedCommitTable string = `
+DROP TABLE SyncedCommit;
+`
+
+var insertSyncedCommit string = `
+INSERT INTO SyncedCommit(source, commit_hash) VALUES ( $1, $2 )`
+
+var deleteSyncedCommitForSource string = `
+DELETE FROM
+	SyncedCommit
+WHERE
+	SyncedCommit.source = $1`
+
+var findSyncedCommitBySource string = `
+SELECT
+	S.commit_hash
+FROM
+	SyncedCommit AS S
+WHERE
+	S.source = $1`
+
+// Returns the last commit of source, like a branch of a repository, that
+// the blog is up to date with.  Sources never synced have none.
+func (conn *DBConnection) SyncedCommit(source string) (string, error) {
+	vendor := conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("SyncedCommit 1:", err)
+		return "", err
+	}
+	defer db.Close()
+
+	var commit string
+	err = db.QueryRow(findSyncedCommitBySource, source).Scan(&commit)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		fmt.Println("SyncedCommit 2:", err)
+		return "", err
+	}
+	return commit, nil
+}
+
+// Records that the blog is up to date with commit of source.
+func (conn *DBConnection) SetSyncedCommit(source string, commit string) error {
+	vendor := conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("SetSyncedCommit 1:", err)
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(deleteSyncedCommitForSource, source); err != nil {
+		fmt.Println("SetSyncedCommit 2:", err)
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec(insertSyncedCommit, source, commit); err != nil {
+		fmt.Println("SetSyncedCommit 3:", err)
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+/*
+ *  SQL Stuff
+ */
+
+func (conn *DBConnection) createSyncedCommitTable() {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Error on open of database", err)
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec(createSyncedCommitTable)
+	if err != nil {
+		fmt.Printf("Error creating SyncedCommit table, driver \"%s\", modelname \"%s\", query = %s\n",
+			vendor.Driver(), vendor.Name(), createSyncedCommitTable)
+		fmt.Println(err)
+		return
+	}
+}
+
+func (conn *DBConnection) dropSyncedCommitTable() {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Error on open of database", err)
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec(dropSyncedCommitTable)
+	if err != nil {
+		fmt.Println("Error droping table:", err)
+	}
+}
@@ -0,0 +1,351 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+ * SQL stuff
+ */
+
+// The hash column holds a hash of the token given to the user, so that
+// reading the table isn't enough to use the tokens.
+var createApiTokenTable string = `
+CREATE TABLE IF NOT EXISTS ApiToken(
+   token_id		SERIAL PRIMARY KEY,
+   user_id		INTEGER NOT NULL,
+   name			VARCHAR(255) NOT NULL,
+   hash			VARCHAR(64) UNIQUE NOT NULL,
+   scopes		VARCHAR(255) NOT NULL,
+   created		TIMESTAMP NOT NULL,
+   last_used	TIMESTAMP,
+   expiry		TIMESTAMP,
+   CONSTRAINT fk_apitoken_user_id
+   	FOREIGN KEY(user_id) REFERENCES BlogUser(user_id) ON DELETE CASCADE
+)`
+
+var dropApiTokenTable string = `
+DROP TABLE ApiToken;
+`
+
+var insertApiToken string = `
+INSERT INTO ApiToken(
+	user_id,
+	name,
+	hash,
+	scopes,
+	created,
+	last_used,
+	expiry)
+VALUES( $1, $2, $3, $4, $5, $6, $7 )
+RETURNING token_id`
+
+var updateApiTokenLastUsedForId string = `
+UPDATE ApiToken
+SET
+	last_used = $1
+WHERE
+	token_id = $2`
+
+var findApiTokenByHash string = `
+SELECT
+	T.token_id,
+	T.user_id,
+	T.name,
+	T.hash,
+	T.scopes,
+	T.created,
+	T.last_used,
+	T.expiry
+FROM
+	ApiToken AS T
+WHERE
+	T.hash = $1`
+
+var findApiTokenById string = `
+SELECT
+	T.token_id,
+	T.user_id,
+	T.name,
+	T.hash,
+	T.scopes,
+	T.created,
+	T.last_used,
+	T.expiry
+FROM
+	ApiToken AS T
+WHERE
+	T.token_id = $1`
+
+var queryApiTokensForUserId string = `
+SELECT
+	T.token_id,
+	T.user_id,
+	T.name,
+	T.hash,
+	T.scopes,
+	T.created,
+	T.last_used,
+	T.expiry
+FROM
+	ApiToken AS T
+WHERE
+	T.user_id = $1
+ORDER BY
+	T.created DESC`
+
+var deleteApiTokenById string = `
+DELETE FROM
+	ApiToken
+WHERE
+	ApiToken.token_id = $1`
+
+// A token a user made for scripts to use the blog on their behalf.  It's
+// limited to its scopes, and to its expiry when it has one.
+type ApiToken struct {
+	id       int64
+	userId   int64
+	name     string
+	hash     string
+	scopes   []string
+	created  time.Time
+	lastUsed time.Time
+	expiry   time.Time
+	conn     *DBConnection
+}
+
+func (t *ApiToken) Id() int64 {
+	return t.id
+}
+
+func (t *ApiToken) UserId() int64 {
+	return t.userId
+}
+
+// What the user named the token after, like the script using it.
+func (t *ApiToken) Name() string {
+	return t.name
+}
+
+// The hash of the token, the token itself isn't kept.
+func (t *ApiToken) Hash() string {
+	return t.hash
+}
+
+func (t *ApiToken) Scopes() []string {
+	return t.scopes
+}
+
+func (t *ApiToken) Created() time.Time {
+	return t.created
+}
+
+// The last time the token was used, zero if it never was.
+func (t *ApiToken) LastUsed() time.Time {
+	return t.lastUsed
+}
+
+// The time after which the token isn't valid, zero if it never expires.
+func (t *ApiToken) Expiry() time.Time {
+	return t.expiry
+}
+
+// Tells if the token isn't valid anymore at the given time.
+func (t *ApiToken) Expired(now time.Time) bool {
+	return !t.expiry.IsZero() && now.After(t.expiry)
+}
+
+/*
+ *  SQL Stuff
+ */
+
+func (conn *DBConnection) createApiTokenTable() {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Error on open of database", err)
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec(createApiTokenTable)
+	if err != nil {
+		fmt.Printf("Error creating ApiToken table, driver \"%s\", modelname \"%s\", query = %s\n",
+			vendor.Driver(), vendor.Name(), createApiTokenTable)
+		fmt.Println(err)
+		return
+	}
+}
+
+func (conn *DBConnection) dropApiTokenTable() {
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("Error on open of database", err)
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec(dropApiTokenTable)
+	if err != nil {
+		fmt.Println("Error droping table:", err)
+	}
+}
+
+// Creates a token with the given hash.  It is NOT saved, you must call
+// "Save" on it.  A zero expiry never expires.
+func (conn *DBConnection) NewApiToken(userId int64, name string, hash string,
+	scopes []string, created time.Time, expiry time.Time) *ApiToken {
+	return &ApiToken{
+		id:      -1,
+		userId:  userId,
+		name:    name,
+		hash:    hash,
+		scopes:  scopes,
+		created: created,
+		expiry:  expiry,
+		conn:    conn,
+	}
+}
+
+// Finds the token with the given hash.
+func (conn *DBConnection) FindApiTokenByHash(hash string) (*ApiToken, error) {
+	return conn.findApiToken(findApiTokenByHash, hash)
+}
+
+func (conn *DBConnection) FindApiTokenById(id int64) (*ApiToken, error) {
+	return conn.findApiToken(findApiTokenById, id)
+}
+
+func (conn *DBConnection) findApiToken(query string, arg interface{}) (*ApiToken, error) {
+	tokens, err := conn.queryApiTokens(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &tokens[0], nil
+}
+
+// Finds the tokens of a user, the most recent first.
+func (conn *DBConnection) FindApiTokensByUserId(userId int64) ([]ApiToken, error) {
+	return conn.queryApiTokens(queryApiTokensForUserId, userId)
+}
+
+func (conn *DBConnection) queryApiTokens(query string, args ...interface{}) ([]ApiToken, error) {
+	var tokens []ApiToken
+	var vendor = conn.databaser
+
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("queryApiTokens 1:", err)
+		return tokens, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		fmt.Println("queryApiTokens 2:", err)
+		return tokens, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var scopes string
+		var lastUsed, expiry sql.NullTime
+		t := ApiToken{conn: conn}
+		err := rows.Scan(&t.id,
+			&t.userId,
+			&t.name,
+			&t.hash,
+			&scopes,
+			&t.created,
+			&lastUsed,
+			&expiry)
+		if err != nil {
+			fmt.Println("queryApiTokens 3:", err)
+			return tokens, err
+		}
+		if scopes != "" {
+			t.scopes = strings.Split(scopes, ",")
+		}
+		t.lastUsed = lastUsed.Time
+		t.expiry = expiry.Time
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+/*
+ *  Operations on ApiToken
+ */
+
+func nullTime(date time.Time) sql.NullTime {
+	return sql.NullTime{Time: date, Valid: !date.IsZero()}
+}
+
+// Saves a new token to the database.
+func (t *ApiToken) Save() error {
+	vendor := t.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("ApiToken Save 1:", err)
+		return err
+	}
+	defer db.Close()
+
+	err = db.QueryRow(insertApiToken,
+		t.userId,
+		t.name,
+		t.hash,
+		strings.Join(t.scopes, ","),
+		t.created,
+		nullTime(t.lastUsed),
+		nullTime(t.expiry)).Scan(&t.id)
+	if err != nil {
+		fmt.Println("ApiToken Save 2:", err)
+	}
+	return err
+}
+
+// Records that the token was used at the given time.
+func (t *ApiToken) Touch(date time.Time) error {
+	vendor := t.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("ApiToken Touch 1:", err)
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(updateApiTokenLastUsedForId, date, t.id)
+	if err != nil {
+		fmt.Println("ApiToken Touch 2:", err)
+		return err
+	}
+	t.lastUsed = date
+	return nil
+}
+
+// Deletes the token from the database.  It can't be used anymore.
+func (t *ApiToken) Destroy() error {
+	vendor := t.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		fmt.Println("ApiToken Destroy 1:", err)
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(deleteApiTokenById, t.id)
+	if err != nil {
+		fmt.Println("ApiToken Destroy 2:", err)
+	}
+	return err
+}
@@ -0,0 +1,100 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func generateApiToken(conn *DBConnection, i int64, userId int64, expiry time.Time) *ApiToken {
+	return conn.NewApiToken(userId,
+		fmt.Sprintf("Script #%d", i),
+		fmt.Sprintf("%064d", i),
+		[]string{"posts:write", "comments:read"},
+		time.Now().UTC(),
+		expiry)
+}
+
+func TestSaveApiToken(t *testing.T) {
+	saveApiToken(t, setupPGConnection())
+}
+
+func saveApiToken(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	user := generateUser(conn, 1)
+	user.Save()
+
+	// tokens without an expiry never expire
+	expected := generateApiToken(conn, 1, user.Id(), time.Time{})
+	if err := expected.Save(); err != nil {
+		t.Fatal("Save failed", err)
+	}
+
+	actual, err := conn.FindApiTokenByHash(expected.Hash())
+	if err != nil {
+		t.Fatal("Couldn't find token back", err)
+	}
+	if actual.Id() != expected.Id() || actual.Name() != expected.Name() {
+		t.Errorf("Expected <%d, %s> but was <%d, %s>",
+			expected.Id(), expected.Name(), actual.Id(), actual.Name())
+	}
+	if len(actual.Scopes()) != 2 || actual.Scopes()[0] != "posts:write" {
+		t.Errorf("Expected the scopes back, was %v", actual.Scopes())
+	}
+	if !actual.LastUsed().IsZero() || !actual.Expiry().IsZero() {
+		t.Errorf("Expected no last use nor expiry, was %v and %v",
+			actual.LastUsed(), actual.Expiry())
+	}
+
+	now := time.Now().UTC()
+	if err := actual.Touch(now); err != nil {
+		t.Fatal("Touch failed", err)
+	}
+	actual, _ = conn.FindApiTokenById(expected.Id())
+	if actual.LastUsed().IsZero() {
+		t.Error("Expected the token to be used")
+	}
+}
+
+func TestApiTokensOfUser(t *testing.T) {
+	apiTokensOfUser(t, setupPGConnection())
+}
+
+func apiTokensOfUser(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	user := generateUser(conn, 1)
+	user.Save()
+	for i := int64(1); i <= 3; i++ {
+		generateApiToken(conn, i, user.Id(), time.Now().UTC().Add(time.Hour)).Save()
+	}
+
+	tokens, err := conn.FindApiTokensByUserId(user.Id())
+	if err != nil || len(tokens) != 3 {
+		t.Fatalf("Expected <3> tokens of user but was <%d>, %v", len(tokens), err)
+	}
+
+	if err := tokens[0].Destroy(); err != nil {
+		t.Error("Destroy failed", err)
+	}
+	if _, err := conn.FindApiTokenById(tokens[0].Id()); err == nil {
+		t.Error("Destroyed token shouldn't be found")
+	}
+}
+
+func TestApiTokenExpired(t *testing.T) {
+	now := time.Now()
+	never := &ApiToken{}
+	if never.Expired(now) {
+		t.Error("Tokens without expiry never expire")
+	}
+	past := &ApiToken{expiry: now.Add(-time.Minute)}
+	if !past.Expired(now) {
+		t.Error("Token should be expired")
+	}
+	future := &ApiToken{expiry: now.Add(time.Minute)}
+	if future.Expired(now) {
+		t.Error("Token shouldn't be expired yet")
+	}
+}
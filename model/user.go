@@ -2,6 +2,8 @@ package model
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"time"
 )
@@ -40,6 +42,19 @@ INSERT INTO BlogUser(
 )
 VALUES( $1, $2, $3, $4, $5, $6, $7 )`
 
+var updateUserForId string = `
+UPDATE BlogUser
+SET
+	username = $1,
+	registration_date = $2,
+	timezone = $3,
+	oauth_id = $4,
+	access_token = $5,
+	refresh_token = $6,
+	email = $7
+WHERE
+	user_id = $8`
+
 var findUserById string = `
 SELECT
 	U.username,
@@ -69,6 +84,21 @@ FROM
 WHERE
 	U.oauth_id = $1`
 
+var findUserByEmail string = `
+SELECT
+	U.user_id,
+	U.username,
+	U.registration_date,
+	U.timezone,
+	U.oauth_id,
+	U.access_token,
+	U.refresh_token,
+	U.email
+FROM
+	BlogUser AS U
+WHERE
+	U.email = $1`
+
 var deleteUserById string = `
 DELETE FROM
 	BlogUser
@@ -88,6 +118,17 @@ SELECT
 FROM
 	BlogUser AS U`
 
+var queryPageOfUsers string = queryForAllUser + `
+ORDER BY
+	U.user_id
+LIMIT $1 OFFSET $2`
+
+var countUsers string = `
+SELECT
+	COUNT(U.user_id)
+FROM
+	BlogUser AS U`
+
 var queryUserForUsername string = `
 SELECT
 	U.user_id
@@ -97,6 +138,62 @@ WHERE
 	U.username = $1
 `
 
+var searchUsers string = `
+SELECT
+	U.user_id,
+	U.username,
+	U.registration_date,
+	U.timezone,
+	U.oauth_id,
+	U.access_token,
+	U.refresh_token,
+	U.email
+FROM
+	BlogUser AS U
+WHERE
+	U.username ILIKE $1
+	OR U.email ILIKE $1
+ORDER BY
+	U.user_id`
+
+var moveCommentsToUserId string = `
+UPDATE Comment
+SET user_id = $1
+WHERE user_id = $2`
+
+var movePostsToAuthorId string = `
+UPDATE Post
+SET author_id = $1
+WHERE author_id = $2`
+
+var countPostsOfUserId string = `
+SELECT
+	COUNT(P.post_id)
+FROM
+	Post AS P,
+	Author AS A
+WHERE
+	P.author_id = A.author_id
+	AND A.user_id = $1`
+
+var moveMediaToUserId string = `
+UPDATE Media
+SET user_id = $1
+WHERE user_id = $2`
+
+var countMediaOfUserId string = `
+SELECT
+	COUNT(M.media_id)
+FROM
+	Media AS M
+WHERE
+	M.user_id = $1`
+
+var moveAuthorToUserId string = `
+UPDATE Author
+SET user_id = $1
+WHERE user_id = $2`
+
 // Relations
 var queryForAllCommentsOfUserId string = `
 SELECT
@@ -106,11 +203,14 @@ SELECT
 	C.content,
 	C.date,
 	C.up_vote,
-	C.down_vote
+	C.down_vote,
+	C.parent_id,
+	C.approved
 FROM
 	Comment as C
 WHERE
-	C.user_id = $1`
+	C.user_id = $1
+	AND C.approved`
 
 // Represents a User of the blog
 type User struct {
@@ -122,7 +222,9 @@ type User struct {
 	oauthId          string
 	accessToken      string
 	refreshToken     string
-	conn             *DBConnection
+	// loaded the first time it's asked, since it's asked many times a request
+	role Role
+	conn *DBConnection
 }
 
 func (u *User) Id() int64 {
@@ -182,6 +284,7 @@ func (u *User) SetEmail(email string) {
 	u.email = email
 }
 
+// The approved comments of the user, those visitors see.
 func (u *User) Comments() ([]Comment, error) {
 	vendor := u.conn.databaser
 	db, err := sql.Open(vendor.Driver(), vendor.Name())
@@ -209,29 +312,11 @@ func (u *User) Comments() ([]Comment, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var id int64
-		var userId int64
-		var postId int64
-		var content string
-		var date time.Time
-		var upVote int64
-		var downVote int64
-
-		err := rows.Scan(&id, &userId, &postId, &content, &date, &upVote, &downVote)
+		c, err := scanComment(rows, u.conn)
 		if err != nil {
 			log.Println("model.User. Error while scanning comments", err)
 			return comments, err
 		}
-		c := Comment{
-			id:       id,
-			userId:   userId,
-			postId:   postId,
-			content:  content,
-			date:     date,
-			upVote:   upVote,
-			downVote: downVote,
-			conn:     u.conn,
-		}
 		comments = append(comments, c)
 	}
 
@@ -303,20 +388,34 @@ func (conn *DBConnection) NewUser(username string, regDate time.Time,
 
 // Finds all the users in the database
 func (conn *DBConnection) FindAllUsers() ([]User, error) {
+	return conn.findUsers(queryForAllUser)
+}
+
+// Finds limit users, from the one at offset, oldest first.
+func (conn *DBConnection) FindUsersPage(limit, offset int) ([]User, error) {
+	return conn.findUsers(queryPageOfUsers, limit, offset)
+}
+
+// Counts the users in the database.
+func (conn *DBConnection) CountUsers() (int, error) {
+	return conn.count(countUsers)
+}
+
+func (conn *DBConnection) findUsers(query string, args ...interface{}) ([]User, error) {
 
 	var users []User
 	var modelaser = conn.databaser
 
 	model, err := sql.Open(modelaser.Driver(), modelaser.Name())
 	if err != nil {
-		log.Println("model.User. FindAllUsers:", err)
+		log.Println("model.User. findUsers:", err)
 		return users, err
 	}
 	defer model.Close()
 
-	rows, err := model.Query(queryForAllUser)
+	rows, err := model.Query(query, args...)
 	if err != nil {
-		log.Println("model.User. FindAllUsers:", err)
+		log.Println("model.User. findUsers:", err)
 		return users, err
 	}
 	defer rows.Close()
@@ -360,6 +459,45 @@ func (conn *DBConnection) FindAllUsers() ([]User, error) {
 	return users, nil
 }
 
+// Finds the users whose username or email contain the query, ignoring case.
+func (conn *DBConnection) SearchUsers(query string) ([]User, error) {
+
+	var users []User
+	var modelaser = conn.databaser
+
+	model, err := sql.Open(modelaser.Driver(), modelaser.Name())
+	if err != nil {
+		log.Println("model.User. SearchUsers:", err)
+		return users, err
+	}
+	defer model.Close()
+
+	rows, err := model.Query(searchUsers, "%"+query+"%")
+	if err != nil {
+		log.Println("model.User. SearchUsers:", err)
+		return users, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		u := User{conn: conn}
+		err := rows.Scan(&u.id,
+			&u.username,
+			&u.registrationDate,
+			&u.timezone,
+			&u.oauthId,
+			&u.accessToken,
+			&u.refreshToken,
+			&u.email)
+		if err != nil {
+			return users, err
+		}
+		users = append(users, u)
+	}
+
+	return users, nil
+}
+
 // Finds a user that matches the given id
 func (conn *DBConnection) FindUserById(id int64) (*User, error) {
 
@@ -413,7 +551,9 @@ func (conn *DBConnection) FindUserById(id int64) (*User, error) {
 	return u, nil
 }
 
-// Finds a user that matches the given id
+// Finds a user that matches the id given by the OAuth provider.  This is
+// the provider's own identifier for the account (the "sub" of the
+// account), not the client id of this application.
 func (conn *DBConnection) FindUserByOAuthId(oauthId string) (*User, error) {
 
 	var modelaser = conn.databaser
@@ -469,6 +609,62 @@ func (conn *DBConnection) FindUserByOAuthId(oauthId string) (*User, error) {
 	return u, nil
 }
 
+// Finds a user that matches the given email address.  Emails are unique
+// to a user.
+func (conn *DBConnection) FindUserByEmail(email string) (*User, error) {
+
+	var modelaser = conn.databaser
+
+	model, err := sql.Open(modelaser.Driver(), modelaser.Name())
+	if err != nil {
+		log.Println("model.User. FindUserByEmail 1:", err)
+		return nil, err
+	}
+	defer model.Close()
+
+	stmt, err := model.Prepare(findUserByEmail)
+	if err != nil {
+		log.Println("model.User. FindUserByEmail 2:", err)
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var id int64
+	var username string
+	var date time.Time
+	var timezone int
+	var oauthId string
+	var accessToken string
+	var refreshToken string
+
+	err = stmt.QueryRow(email).Scan(&id,
+		&username,
+		&date,
+		&timezone,
+		&oauthId,
+		&accessToken,
+		&refreshToken,
+		&email)
+	if err != nil {
+		// normal if the User doesnt exist
+		return nil, err
+	}
+
+	u := &User{
+		id:               id,
+		username:         username,
+		registrationDate: date,
+		timezone:         timezone,
+		oauthId:          oauthId,
+		accessToken:      accessToken,
+		refreshToken:     refreshToken,
+		email:            email,
+		conn:             conn,
+	}
+
+	return u, nil
+}
+
 //
 // Operations on User
 //
@@ -517,8 +713,159 @@ func (u *User) Save() error {
 	return row.Scan(&u.id)
 }
 
-// Deletes the user from the database
+// Updates an already saved user with the values it currently holds.
+func (u *User) Update() error {
+
+	vendor := u.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		log.Println("model.User. Update 1:", err)
+		return err
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare(updateUserForId)
+	if err != nil {
+		log.Println("model.User. Update 2:", err)
+		return err
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(u.username,
+		u.registrationDate,
+		u.timezone,
+		u.oauthId,
+		u.accessToken,
+		u.refreshToken,
+		u.email,
+		u.id)
+	if err != nil {
+		log.Println("model.User. Update 3:", err)
+		return err
+	}
+	return nil
+}
+
+// Merges a duplicate account into this user.  The comments, posts and
+// uploads of the duplicate are given to this user, then the duplicate is
+// destroyed.
+func (u *User) Merge(dup *User) error {
+	if u.id == dup.id {
+		return fmt.Errorf("can't merge user id<%d> into itself", u.id)
+	}
+
+	author, _ := u.conn.FindAuthorByUserId(u.id)
+	dupAuthor, _ := u.conn.FindAuthorByUserId(dup.id)
+
+	vendor := u.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		log.Println("model.User. Merge 1:", err)
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println("model.User. Merge 2:", err)
+		return err
+	}
+
+	if _, err := tx.Exec(moveCommentsToUserId, u.id, dup.id); err != nil {
+		log.Println("model.User. Merge 3:", err)
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec(moveMediaToUserId, u.id, dup.id); err != nil {
+		log.Println("model.User. Merge 4:", err)
+		tx.Rollback()
+		return err
+	}
+
+	if dupAuthor != nil && author != nil {
+		_, err = tx.Exec(movePostsToAuthorId, author.Id(), dupAuthor.Id())
+	} else if dupAuthor != nil {
+		// this user wasn't an author, it takes over the duplicate's
+		_, err = tx.Exec(moveAuthorToUserId, u.id, dup.id)
+	}
+	if err != nil {
+		log.Println("model.User. Merge 5:", err)
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec(deleteUserById, dup.id); err != nil {
+		log.Println("model.User. Merge 6:", err)
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ErrUserHasPosts is for users that can't be deleted as they wrote posts,
+// which would lose their author.
+var ErrUserHasPosts = errors.New("the user wrote posts: merge the account into another one to keep them, or delete them first")
+
+// ErrUserHasMedia is for users that can't be deleted as they uploaded
+// files, which posts may show and which would be left in the media store.
+var ErrUserHasMedia = errors.New("the user uploaded files: merge the account into another one to keep them, or delete them first")
+
+// Counts the posts the user wrote, as an author.
+func (u *User) PostCount() (int, error) {
+	vendor := u.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		log.Println("model.User. PostCount 1:", err)
+		return 0, err
+	}
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow(countPostsOfUserId, u.id).Scan(&count); err != nil {
+		log.Println("model.User. PostCount 2:", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// Counts the files the user uploaded.
+func (u *User) MediaCount() (int, error) {
+	vendor := u.conn.databaser
+	db, err := sql.Open(vendor.Driver(), vendor.Name())
+	if err != nil {
+		log.Println("model.User. MediaCount 1:", err)
+		return 0, err
+	}
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow(countMediaOfUserId, u.id).Scan(&count); err != nil {
+		log.Println("model.User. MediaCount 2:", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// Deletes the user from the database, along with their comments.  Users
+// that wrote posts are refused with ErrUserHasPosts, and those who uploaded
+// files with ErrUserHasMedia.
 func (u User) Destroy() error {
+	posts, err := u.PostCount()
+	if err != nil {
+		return err
+	}
+	if posts > 0 {
+		return ErrUserHasPosts
+	}
+	media, err := u.MediaCount()
+	if err != nil {
+		return err
+	}
+	if media > 0 {
+		return ErrUserHasMedia
+	}
 
 	vendor := u.conn.databaser
 	db, err := sql.Open(vendor.Driver(), vendor.Name())
@@ -11,8 +11,9 @@ func generateUser(conn *DBConnection, i int64) *User {
 		fmt.Sprintf("Antoine #%d", i),
 		time.Now().UTC(),
 		-5,
-		"g+",
+		fmt.Sprintf("g+%d", i),
 		fmt.Sprintf("anAuthToken#%d", i),
+		fmt.Sprintf("aRefreshToken#%d", i),
 		fmt.Sprintf("a%d@b.com", i))
 	return user
 }
@@ -81,6 +82,59 @@ func destroyUser(t *testing.T, conn *DBConnection) {
 	}
 }
 
+func TestDestroyUserWithPosts(t *testing.T) {
+	destroyUserWithPosts(t, setupPGConnection())
+}
+
+func destroyUserWithPosts(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	author := generateAuthor(conn, 1)
+	user := author.User()
+	post := conn.NewPost(author, "A title", "Some content", "", time.Now().UTC())
+	if err := post.Save(); err != nil {
+		t.Fatal("Couldn't save post", err)
+	}
+	if count, err := user.PostCount(); err != nil || count != 1 {
+		t.Errorf("Expected <1> post but was <%d>, %v", count, err)
+	}
+
+	if err := user.Destroy(); err != ErrUserHasPosts {
+		t.Errorf("Expected the user to be refused, was %v", err)
+	}
+	if _, err := conn.FindUserById(user.Id()); err != nil {
+		t.Error("User should still exist", err)
+	}
+	if _, err := conn.FindPostById(post.Id()); err != nil {
+		t.Error("Post should still exist", err)
+	}
+
+	if err := post.Destroy(); err != nil {
+		t.Fatal("Couldn't destroy post", err)
+	}
+
+	media := conn.NewMedia(user.Id(), "cat.png", "/media/cat.png", "image/png", 10, time.Now().UTC())
+	if err := media.Save(); err != nil {
+		t.Fatal("Couldn't save media", err)
+	}
+	if err := user.Destroy(); err != ErrUserHasMedia {
+		t.Errorf("Expected the user who uploaded files to be refused, was %v", err)
+	}
+	if _, err := conn.FindMediaById(media.Id()); err != nil {
+		t.Error("Media should still exist", err)
+	}
+	if err := media.Destroy(); err != nil {
+		t.Fatal("Couldn't destroy media", err)
+	}
+
+	if err := user.Destroy(); err != nil {
+		t.Error("User without posts should be deleted", err)
+	}
+	if _, err := conn.FindUserById(user.Id()); err == nil {
+		t.Error("User shouldn't exist after destroy")
+	}
+}
+
 func TestFindByIdUser(t *testing.T) {
 	findByIdUser(t, setupPGConnection())
 }
@@ -205,3 +259,134 @@ func findAllUserComments(t *testing.T, conn *DBConnection) {
 		}
 	}
 }
+
+func TestUpdateUser(t *testing.T) {
+	updateUser(t, setupPGConnection())
+}
+
+func updateUser(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	user := generateUser(conn, 0)
+	if err := user.Save(); err != nil {
+		t.Error("Save failed", err)
+		return
+	}
+
+	user.SetOauthId("a google id")
+	user.SetToken("new access", "new refresh")
+	if err := user.Update(); err != nil {
+		t.Error("Update failed", err)
+		return
+	}
+
+	actual, err := conn.FindUserByOAuthId("a google id")
+	if err != nil {
+		t.Error("Couldn't find user by its new OAuth id", err)
+		return
+	}
+
+	if actual.Id() != user.Id() {
+		t.Errorf("Expected <%d> but was <%d>", user.Id(), actual.Id())
+	}
+
+	if actual.AccessToken() != "new access" {
+		t.Errorf("Expected <new access> but was <%s>", actual.AccessToken())
+	}
+}
+
+func TestFindByEmailUser(t *testing.T) {
+	findByEmailUser(t, setupPGConnection())
+}
+
+func findByEmailUser(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	for i := int64(1); i < 10; i++ {
+		expected := generateUser(conn, i)
+		expected.Save()
+
+		actual, err := conn.FindUserByEmail(expected.Email())
+		if err != nil {
+			t.Errorf("Error while querying User %d by email: %v", i, err)
+			return
+		}
+
+		if actual.Id() != expected.Id() {
+			t.Errorf("Expected <%d> but was <%d>\n", expected.Id(), actual.Id())
+		}
+	}
+}
+
+func TestSearchUser(t *testing.T) {
+	searchUser(t, setupPGConnection())
+}
+
+func searchUser(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	for i := int64(1); i <= 12; i++ {
+		generateUser(conn, i).Save()
+	}
+
+	// matches "Antoine #1", "#10", "#11" and "#12"
+	users, err := conn.SearchUsers("antoine #1")
+	if err != nil {
+		t.Error("Couldn't search users", err)
+		return
+	}
+
+	if len(users) != 4 {
+		t.Errorf("Expected <4> users but was <%d>", len(users))
+	}
+}
+
+func TestMergeUser(t *testing.T) {
+	mergeUser(t, setupPGConnection())
+}
+
+func mergeUser(t *testing.T, conn *DBConnection) {
+	defer conn.DeleteConnection()
+
+	keep := generateUser(conn, 1)
+	keep.Save()
+
+	dupAuthor := generateAuthor(conn, 2)
+	dup := dupAuthor.User()
+	post := conn.NewPost(dupAuthor, "A title", "Some content", "", time.Now().UTC())
+	post.Save()
+	conn.NewComment(dup.Id(), post.Id(), "a comment", time.Now().UTC()).Save()
+	media := conn.NewMedia(dup.Id(), "dup.png", "/media/dup.png", "image/png", 10, time.Now().UTC())
+	if err := media.Save(); err != nil {
+		t.Fatal("Couldn't save media", err)
+	}
+
+	if err := keep.Merge(dup); err != nil {
+		t.Error("Merge failed", err)
+		return
+	}
+
+	if _, err := conn.FindUserById(dup.Id()); err == nil {
+		t.Error("Duplicate user should be destroyed after merge")
+	}
+
+	comments, err := keep.Comments()
+	if err != nil || len(comments) != 1 {
+		t.Errorf("Expected <1> comment on kept user but was <%d>, %v", len(comments), err)
+	}
+
+	author, err := conn.FindAuthorByUserId(keep.Id())
+	if err != nil {
+		t.Error("Kept user should take over the duplicate's author", err)
+		return
+	}
+
+	posts, err := author.Posts()
+	if err != nil || len(posts) != 1 {
+		t.Errorf("Expected <1> post by kept user but was <%d>, %v", len(posts), err)
+	}
+
+	if found, err := conn.FindMediaById(media.Id()); err != nil || found.UserId() != keep.Id() {
+		t.Errorf("Expected the upload to be the kept user's, was %v, %v", found, err)
+	}
+}
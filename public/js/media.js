@@ -0,0 +1,91 @@
+// The picture picker of the compose page: uploads pictures, and puts
+// those picked in the content of the post or as its header image.
+(function () {
+  var picker = document.getElementById('media-picker');
+  if (!picker) {
+    return;
+  }
+  var content = document.getElementById('content');
+  var imageUrl = document.getElementById('imageUrl');
+  var list = document.getElementById('media-list');
+  var upload = document.getElementById('media-upload');
+  var status = document.getElementById('media-status');
+
+  // Puts text where the cursor is in the content.
+  function insert(text) {
+    var start = content.selectionStart, end = content.selectionEnd;
+    var before = content.value.substring(0, start);
+    if (before !== '' && before.slice(-1) !== '\n') {
+      text = '\n' + text;
+    }
+    content.value = before + text + content.value.substring(end);
+    content.selectionStart = content.selectionEnd = start + text.length;
+    content.focus();
+  }
+
+  list.addEventListener('click', function (e) {
+    var item = e.target.closest('li');
+    if (!item) {
+      return;
+    }
+    var url = item.getAttribute('data-url');
+    if (e.target.hasAttribute('data-insert')) {
+      insert('![](' + url + ')\n');
+    } else if (e.target.hasAttribute('data-header')) {
+      imageUrl.value = url;
+    }
+  });
+
+  function add(url) {
+    var item = document.createElement('li');
+    item.className = 'span2';
+    item.setAttribute('data-url', url);
+    var thumbnail = document.createElement('div');
+    thumbnail.className = 'thumbnail';
+    var img = document.createElement('img');
+    img.src = url;
+    img.alt = '';
+    img.style.maxHeight = '100px';
+    thumbnail.appendChild(img);
+    [['data-insert', 'Insert in content'], ['data-header', 'Use as header image']].forEach(function (b) {
+      var button = document.createElement('button');
+      button.type = 'button';
+      button.className = 'btn btn-mini';
+      button.setAttribute(b[0], '');
+      button.textContent = b[1];
+      thumbnail.appendChild(button);
+    });
+    item.appendChild(thumbnail);
+    list.insertBefore(item, list.firstChild);
+  }
+
+  upload.addEventListener('change', function () {
+    if (upload.files.length === 0) {
+      return;
+    }
+    var form = new FormData();
+    form.append('file', upload.files[0]);
+    var xhr = new XMLHttpRequest();
+    xhr.open('POST', '/media');
+    xhr.setRequestHeader('Accept', 'application/json');
+    xhr.setRequestHeader('X-CSRF-Token', picker.getAttribute('data-csrf'));
+    xhr.onload = function () {
+      var body = {};
+      try {
+        body = JSON.parse(xhr.responseText);
+      } catch (e) {}
+      if (xhr.status === 201) {
+        add(body.url);
+        status.textContent = 'Uploaded.';
+      } else {
+        status.textContent = (body.error && body.error.message) || 'The upload failed.';
+      }
+      upload.value = '';
+    };
+    xhr.onerror = function () {
+      status.textContent = 'The upload failed.';
+    };
+    status.textContent = 'Uploading...';
+    xhr.send(form);
+  });
+})();
@@ -1,16 +1,81 @@
 package main
 
 import (
+	"embed"
+	"github.com/aybabtme/goblog/api"
 	"github.com/aybabtme/goblog/auth"
 	"github.com/aybabtme/goblog/ctlr"
+	"github.com/aybabtme/goblog/media"
 	"github.com/aybabtme/goblog/model"
+	"github.com/aybabtme/goblog/view"
 	"github.com/gorilla/mux"
+	"io/fs"
 	"net/http"
+	"os"
+	"path/filepath"
 )
 
 type Router string
 
-func (r Router) Start(port string, conn *model.DBConnection) error {
+// Config is how the blog was set up through the environment.
+type Config struct {
+	Port        string
+	SessionKeys [][]byte
+	// reload templates and show their errors
+	Dev bool
+	// nil for the default look
+	Theme *view.Theme
+	// laid out like the repository, its files override the compiled ones
+	AssetsDir string
+	Site      ctlr.Site
+	// where uploads are kept, and the size of the largest in bytes
+	Media        media.MediaStore
+	MediaMaxSize int64
+	// where resized pictures are kept
+	MediaCacheDir string
+	// what posts' Markdown renders, nil for all of it
+	Markdown *model.MarkdownOptions
+}
+
+//go:embed public
+var public embed.FS
+
+func (r Router) Start(conn *model.DBConnection, cfg Config) error {
+	handler, _, stop, err := r.Handler(conn, cfg)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	return http.ListenAndServe(":"+cfg.Port, handler)
+}
+
+// Handler sets the blog up and returns what serves it, along with its
+// static files and what stops watching the templates and cleaning up the
+// sessions.
+func (r Router) Handler(conn *model.DBConnection, cfg Config) (http.Handler, *view.Assets, func(), error) {
+
+	stopSessions := auth.SetupSessions(conn, cfg.SessionKeys...)
+
+	assets, err := setupAssets(cfg)
+	if err != nil {
+		stopSessions()
+		return nil, nil, nil, err
+	}
+	view.SetupAssets(assets)
+
+	templateDir := ""
+	if cfg.AssetsDir != "" {
+		templateDir = filepath.Join(cfg.AssetsDir, "view", "template")
+	}
+	stopWatching, err := view.SetupTemplates(templateDir, cfg.Theme, cfg.Dev)
+	if err != nil {
+		stopSessions()
+		return nil, nil, nil, err
+	}
+	stop := func() {
+		stopWatching()
+		stopSessions()
+	}
 
 	controllers := []ctlr.Controller{
 		ctlr.NewIndexController(),
@@ -18,6 +83,12 @@ func (r Router) Start(port string, conn *model.DBConnection) error {
 		ctlr.NewAuthorListController(),
 		ctlr.NewUserController(),
 		ctlr.NewLabelController(),
+		ctlr.NewFeedController(),
+		ctlr.NewLabelFeedController(),
+		ctlr.NewAuthorFeedController(),
+		ctlr.NewSitemapController(),
+		ctlr.NewSitemapPageController(),
+		ctlr.NewRobotsController(),
 		ctlr.NewPostController(),
 		ctlr.NewPostComposeController(),
 		ctlr.NewPostSaveController(),
@@ -25,21 +96,90 @@ func (r Router) Start(port string, conn *model.DBConnection) error {
 		ctlr.NewPostDestroyController(),
 		ctlr.NewPostCommentController(),
 		ctlr.NewPostEditController(),
-		ctlr.NewPostIdController()}
+		ctlr.NewPostIdController(),
+		ctlr.NewMediaController(),
+		ctlr.NewMediaDestroyController(),
+		ctlr.NewMediaVariantController(),
+		ctlr.NewMediaFileController(),
+		ctlr.NewAdminUserListController(),
+		ctlr.NewAdminUserController(),
+		ctlr.NewAdminUserActionController(),
+		ctlr.NewAdminAuditController(),
+		ctlr.NewLogoutController(),
+		ctlr.NewSessionListController(),
+		ctlr.NewSessionRevokeController(),
+		ctlr.NewTokenListController(),
+		ctlr.NewTokenRevokeController()}
+
+	apiControllers := []ctlr.Controller{
+		api.NewPostListController(),
+		api.NewPostController(),
+		api.NewPostCommentListController(),
+		api.NewPostLabelListController(),
+		api.NewCommentController(),
+		api.NewLabelListController(),
+		api.NewLabelController(),
+		api.NewLabelPostListController(),
+		api.NewAuthorListController(),
+		api.NewAuthorController(),
+		api.NewAuthorPostListController(),
+		api.NewUserListController(),
+		api.NewUserController(),
+		api.NewCurrentUserController()}
+
+	ctlr.SetupSite(cfg.Site)
+	var thumbnails *media.Thumbnailer
+	if cfg.Media != nil {
+		thumbnails, err = media.NewThumbnailer(cfg.Media, cfg.MediaCacheDir)
+		if err != nil {
+			stop()
+			return nil, nil, nil, err
+		}
+	}
+	ctlr.SetupMedia(cfg.Media, cfg.MediaMaxSize, thumbnails)
+	view.SetupImages(cfg.Media)
+	if cfg.Markdown != nil {
+		model.SetupMarkdown(*cfg.Markdown)
+	} else {
+		model.SetupMarkdown(model.AllMarkdown)
+	}
+
+	// auth refuses requests with the same error pages as the controllers
+	auth.Fail = ctlr.Fail
 
 	muxer := mux.NewRouter()
-	for _, ctlr := range controllers {
-		muxer.HandleFunc(ctlr.Path(), ctlr.Controller(conn))
+	muxer.NotFoundHandler = ctlr.NotFoundHandler()
+	pipeline := ctlr.Pipeline(conn)
+	for _, c := range controllers {
+		muxer.HandleFunc(c.Path(), ctlr.Handler(conn, c, pipeline))
 	}
+	apiPipeline := api.Pipeline(conn)
+	for _, c := range apiControllers {
+		muxer.HandleFunc(c.Path(), ctlr.Handler(conn, c, apiPipeline))
+	}
+	muxer.PathPrefix(api.Prefix + "/").Handler(api.NotFoundHandler())
+
+	handler := http.NewServeMux()
 	// serve dynamic resources
-	http.Handle("/", muxer)
+	handler.Handle("/", muxer)
 	// serve static resources
-	http.Handle("/res/", http.StripPrefix("/res", http.FileServer(http.Dir("public/"))))
+	handler.Handle("/res/", http.StripPrefix("/res", assets))
 
 	// For user authentication
-	http.HandleFunc("/authorize", auth.AuthorizeOauth)
-	http.HandleFunc("/oauth2callback", auth.GetHandleOAuth2Callback(conn))
-	http.HandleFunc("/logout", auth.Logout(conn))
+	handler.HandleFunc("/authorize", auth.AuthorizeOauth)
+	handler.HandleFunc("/oauth2callback", auth.GetHandleOAuth2Callback(conn))
+
+	return handler, assets, stop, nil
+}
 
-	return http.ListenAndServe(":"+port, nil)
+func setupAssets(cfg Config) (*view.Assets, error) {
+	files, err := fs.Sub(public, "public")
+	if err != nil {
+		return nil, err
+	}
+	if cfg.AssetsDir == "" {
+		return view.NewAssets(files, cfg.Theme, cfg.Dev)
+	}
+	override := os.DirFS(filepath.Join(cfg.AssetsDir, "public"))
+	return view.NewAssets(files, cfg.Theme, cfg.Dev, override)
 }
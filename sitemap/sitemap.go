@@ -0,0 +1,101 @@
+// Package sitemap writes the map of the blog that search engines crawl,
+// split in many sitemaps listed by an index when it's too big for one.
+package sitemap
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+const Type = "application/xml"
+
+// How many URLs a sitemap can have, and how many sitemaps an index.
+const MaxURLs = 50000
+
+const namespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// URL is a page of the blog, or a sitemap in an index.  LastMod is left
+// out when zero.
+type URL struct {
+	Loc     string
+	LastMod time.Time
+}
+
+type urlSet struct {
+	XMLName xml.Name  `xml:"urlset"`
+	XMLNS   string    `xml:"xmlns,attr"`
+	URLs    []urlNode `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name  `xml:"sitemapindex"`
+	XMLNS    string    `xml:"xmlns,attr"`
+	Sitemaps []urlNode `xml:"sitemap"`
+}
+
+type urlNode struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Pages is how many sitemaps it takes to list that many URLs.
+func Pages(count int) int {
+	if count == 0 {
+		return 1
+	}
+	return (count + MaxURLs - 1) / MaxURLs
+}
+
+// Page returns the URLs of the nth sitemap, counting from 1, and false if
+// there's no such sitemap.
+func Page(urls []URL, n int) ([]URL, bool) {
+	if n < 1 || n > Pages(len(urls)) {
+		return nil, false
+	}
+	start := (n - 1) * MaxURLs
+	end := start + MaxURLs
+	if end > len(urls) {
+		end = len(urls)
+	}
+	return urls[start:end], true
+}
+
+// LastMod is the most recent modification of the URLs.
+func LastMod(urls []URL) time.Time {
+	var last time.Time
+	for _, u := range urls {
+		if u.LastMod.After(last) {
+			last = u.LastMod
+		}
+	}
+	return last
+}
+
+// WriteURLSet writes a sitemap of the URLs, at most MaxURLs of them.
+func WriteURLSet(w io.Writer, urls []URL) error {
+	return write(w, urlSet{XMLNS: namespace, URLs: nodes(urls)})
+}
+
+// WriteIndex writes an index of the sitemaps.
+func WriteIndex(w io.Writer, sitemaps []URL) error {
+	return write(w, sitemapIndex{XMLNS: namespace, Sitemaps: nodes(sitemaps)})
+}
+
+func nodes(urls []URL) []urlNode {
+	nodes := make([]urlNode, len(urls))
+	for i, u := range urls {
+		nodes[i].Loc = u.Loc
+		if !u.LastMod.IsZero() {
+			nodes[i].LastMod = u.LastMod.UTC().Format(time.RFC3339)
+		}
+	}
+	return nodes
+}
+
+func write(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(doc)
+}
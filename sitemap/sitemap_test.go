@@ -0,0 +1,113 @@
+package sitemap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPages(t *testing.T) {
+	cases := []struct {
+		count int
+		pages int
+	}{
+		{0, 1},
+		{1, 1},
+		{MaxURLs, 1},
+		{MaxURLs + 1, 2},
+		{3 * MaxURLs, 3},
+	}
+	for _, c := range cases {
+		if pages := Pages(c.count); pages != c.pages {
+			t.Errorf("Expected %d sitemaps for %d URLs, was %d", c.pages, c.count, pages)
+		}
+	}
+}
+
+func TestPage(t *testing.T) {
+	urls := make([]URL, MaxURLs+10)
+	for i := range urls {
+		urls[i].Loc = "/post/" + strconv.Itoa(i)
+	}
+
+	first, ok := Page(urls, 1)
+	if !ok || len(first) != MaxURLs || first[0].Loc != "/post/0" {
+		t.Errorf("Wrong first sitemap, had %d URLs", len(first))
+	}
+	second, ok := Page(urls, 2)
+	if !ok || len(second) != 10 || second[0].Loc != "/post/"+strconv.Itoa(MaxURLs) {
+		t.Errorf("Wrong second sitemap, had %d URLs", len(second))
+	}
+	for _, n := range []int{-1, 0, 3} {
+		if _, ok := Page(urls, n); ok {
+			t.Errorf("Expected no sitemap #%d", n)
+		}
+	}
+}
+
+func TestWriteURLSet(t *testing.T) {
+	date := time.Date(2014, 3, 2, 10, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	err := WriteURLSet(&buf, []URL{
+		{Loc: "https://blog.example.com/"},
+		{Loc: "https://blog.example.com/post/1?a=1&b=2", LastMod: date},
+	})
+	if err != nil {
+		t.Fatal("Couldn't write sitemap", err)
+	}
+
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+		URLs    []struct {
+			Loc     string `xml:"loc"`
+			LastMod string `xml:"lastmod"`
+		} `xml:"url"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal("Sitemap doesn't parse back", err, buf.String())
+	}
+	if len(doc.URLs) != 2 {
+		t.Fatalf("Expected 2 URLs, was %d", len(doc.URLs))
+	}
+	if doc.URLs[0].LastMod != "" {
+		t.Errorf("Expected no lastmod, was %q", doc.URLs[0].LastMod)
+	}
+	if doc.URLs[1].Loc != "https://blog.example.com/post/1?a=1&b=2" || doc.URLs[1].LastMod != "2014-03-02T10:00:00Z" {
+		t.Errorf("Wrong URL, was %+v", doc.URLs[1])
+	}
+}
+
+func TestWriteIndex(t *testing.T) {
+	date := time.Date(2014, 3, 2, 10, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	if err := WriteIndex(&buf, []URL{{Loc: "https://blog.example.com/sitemap-1.xml", LastMod: date}}); err != nil {
+		t.Fatal("Couldn't write index", err)
+	}
+
+	var doc struct {
+		XMLName  xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+		Sitemaps []struct {
+			Loc     string `xml:"loc"`
+			LastMod string `xml:"lastmod"`
+		} `xml:"sitemap"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal("Index doesn't parse back", err, buf.String())
+	}
+	if len(doc.Sitemaps) != 1 || doc.Sitemaps[0].LastMod != "2014-03-02T10:00:00Z" {
+		t.Errorf("Wrong index, was %+v", doc)
+	}
+}
+
+func TestLastMod(t *testing.T) {
+	date := time.Date(2014, 3, 2, 10, 0, 0, 0, time.UTC)
+	urls := []URL{{LastMod: date.Add(-time.Hour)}, {LastMod: date}, {}}
+	if !LastMod(urls).Equal(date) {
+		t.Errorf("Expected %v, was %v", date, LastMod(urls))
+	}
+	if !LastMod(nil).IsZero() {
+		t.Error("Expected no date without URLs")
+	}
+}
@@ -0,0 +1,178 @@
+package view
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Assets serves the static files under `/res/`.  Each file is also served
+// under a fingerprinted name, i.e. `css/footer.3a7bd3e2.css`, which changes
+// with its content and can thus be cached forever.  Templates get that name
+// with `{{asset "css/footer.css"}}`.
+type Assets struct {
+	sources []assetSource
+	dev     bool
+
+	// "css/footer.css" -> "css/footer.3a7bd3e2.css"
+	fingerprints map[string]string
+	// "css/footer.3a7bd3e2.css" -> "css/footer.css"
+	originals map[string]string
+}
+
+type assetSource struct {
+	prefix string
+	fsys   fs.FS
+}
+
+// How long browsers keep fingerprinted files.
+const assetMaxAge = 365 * 24 * time.Hour
+
+// NewAssets serves the files of public, overridden by those of the optional
+// overrides.  The files of a theme, when there's one, are served under
+// `theme/`.  In development mode, names aren't fingerprinted so that changed
+// files are picked up right away.
+func NewAssets(public fs.FS, theme *Theme, dev bool, overrides ...fs.FS) (*Assets, error) {
+	a := &Assets{
+		dev:          dev,
+		fingerprints: make(map[string]string),
+		originals:    make(map[string]string),
+	}
+	if theme != nil {
+		a.sources = append(a.sources, assetSource{"theme/", theme.Public()})
+	}
+	for _, override := range overrides {
+		a.sources = append(a.sources, assetSource{"", override})
+	}
+	a.sources = append(a.sources, assetSource{"", public})
+
+	for _, source := range a.sources {
+		err := fs.WalkDir(source.fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			name = source.prefix + name
+			if _, ok := a.fingerprints[name]; ok {
+				return nil
+			}
+			sum, err := hashFile(source.fsys, strings.TrimPrefix(name, source.prefix))
+			if err != nil {
+				return err
+			}
+			ext := path.Ext(name)
+			fingerprinted := strings.TrimSuffix(name, ext) + "." + sum + ext
+			a.fingerprints[name] = fingerprinted
+			a.originals[fingerprinted] = name
+			return nil
+		})
+		// a theme or an override don't need to have static files
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// Path is the URL of the named file, fingerprinted unless in development
+// mode or if the file doesn't exist.
+func (a *Assets) Path(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if fingerprinted, ok := a.fingerprints[name]; ok && !a.dev {
+		return "/res/" + fingerprinted
+	}
+	return "/res/" + name
+}
+
+// Names are those of every file served, fingerprinted or not, relative to
+// `/res/`.
+func (a *Assets) Names() []string {
+	var names []string
+	for name, fingerprinted := range a.fingerprints {
+		names = append(names, name)
+		if !a.dev {
+			names = append(names, fingerprinted)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ServeHTTP serves the requested file, with far-future cache headers when
+// asked for by its fingerprinted name.  Paths are relative to `/res/`.
+func (a *Assets) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	name := strings.TrimPrefix(path.Clean(req.URL.Path), "/")
+	if original, ok := a.originals[name]; ok {
+		name = original
+		rw.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		rw.Header().Set("Expires", time.Now().Add(assetMaxAge).UTC().Format(http.TimeFormat))
+	}
+
+	for _, source := range a.sources {
+		if !strings.HasPrefix(name, source.prefix) {
+			continue
+		}
+		file, err := source.fsys.Open(strings.TrimPrefix(name, source.prefix))
+		if err != nil {
+			continue
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil || info.IsDir() {
+			break
+		}
+		content, ok := file.(io.ReadSeeker)
+		if !ok {
+			break
+		}
+		http.ServeContent(rw, req, info.Name(), info.ModTime(), content)
+		return
+	}
+	rw.Header().Del("Cache-Control")
+	rw.Header().Del("Expires")
+	http.NotFound(rw, req)
+}
+
+/*
+ * Helpers
+ */
+
+// The assets the `asset` template func links to, set by SetupAssets.
+var assets *Assets
+
+// SetupAssets makes the templates link to the files served by a.
+func SetupAssets(a *Assets) {
+	assets = a
+}
+
+var funcs = template.FuncMap{
+	"asset": func(name string) string {
+		if assets == nil {
+			return "/res/" + strings.TrimPrefix(name, "/")
+		}
+		return assets.Path(name)
+	},
+	"image":  imageURL,
+	"srcset": srcset,
+}
+
+func hashFile(fsys fs.FS, name string) (string, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:8], nil
+}
@@ -0,0 +1,157 @@
+package view
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAssetsFingerprint(t *testing.T) {
+	a := newTestAssets(t, false)
+
+	fingerprinted := a.Path("css/site.css")
+	if fingerprinted == "/res/css/site.css" || !strings.HasPrefix(fingerprinted, "/res/css/site.") ||
+		!strings.HasSuffix(fingerprinted, ".css") {
+		t.Fatalf("Unexpected fingerprinted path <%s>", fingerprinted)
+	}
+	if a.Path("/css/site.css") != fingerprinted {
+		t.Errorf("Leading slash should not matter")
+	}
+	if a.Path("css/nothere.css") != "/res/css/nothere.css" {
+		t.Errorf("Unknown files should keep their name, was <%s>", a.Path("css/nothere.css"))
+	}
+
+	// a different content gets a different name
+	other, err := NewAssets(fstest.MapFS{
+		"css/site.css": &fstest.MapFile{Data: []byte("body { color: red; }")},
+	}, nil, false)
+	if err != nil {
+		t.Fatal("Couldn't load assets", err)
+	}
+	if other.Path("css/site.css") == fingerprinted {
+		t.Errorf("Fingerprint should change with the content")
+	}
+}
+
+func TestAssetsServeFingerprinted(t *testing.T) {
+	a := newTestAssets(t, false)
+
+	rec := serveAsset(a, strings.TrimPrefix(a.Path("css/site.css"), "/res"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d but was %d", http.StatusOK, rec.Code)
+	}
+	if rec.Body.String() != "body {}" {
+		t.Errorf("Unexpected content <%s>", rec.Body.String())
+	}
+	if !strings.Contains(rec.Header().Get("Cache-Control"), "max-age=31536000") {
+		t.Errorf("Expected far-future cache, got <%s>", rec.Header().Get("Cache-Control"))
+	}
+	if rec.Header().Get("Content-Type") != "text/css; charset=utf-8" {
+		t.Errorf("Unexpected content type <%s>", rec.Header().Get("Content-Type"))
+	}
+
+	// still served under the plain name, without caching it forever
+	rec = serveAsset(a, "/css/site.css")
+	if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "" {
+		t.Errorf("Unexpected plain response %d <%s>", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+
+	if rec := serveAsset(a, "/css/nothere.css"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d but was %d", http.StatusNotFound, rec.Code)
+	}
+	if rec := serveAsset(a, "/../css/site.css"); rec.Code != http.StatusOK {
+		t.Errorf("Expected cleaned path to be served, was %d", rec.Code)
+	}
+}
+
+func TestAssetsOverrideAndTheme(t *testing.T) {
+	themeDir := setupTheme(t, `{"name": "dark"}`)
+	defer os.RemoveAll(themeDir)
+	writeTemplate(t, themeDir, "public/dark.css", "body { background: black; }")
+	theme, _ := LoadTheme(themeDir)
+
+	override := fstest.MapFS{
+		"css/site.css": &fstest.MapFile{Data: []byte("overridden")},
+	}
+	a, err := NewAssets(testPublic(), theme, false, override)
+	if err != nil {
+		t.Fatal("Couldn't load assets", err)
+	}
+
+	if rec := serveAsset(a, "/css/site.css"); rec.Body.String() != "overridden" {
+		t.Errorf("Expected override, got <%s>", rec.Body.String())
+	}
+	if rec := serveAsset(a, "/js/site.js"); rec.Body.String() != "alert()" {
+		t.Errorf("Expected default file, got <%s>", rec.Body.String())
+	}
+	themed := a.Path("theme/dark.css")
+	if !strings.HasPrefix(themed, "/res/theme/dark.") {
+		t.Fatalf("Unexpected theme path <%s>", themed)
+	}
+	if rec := serveAsset(a, strings.TrimPrefix(themed, "/res")); rec.Code != http.StatusOK {
+		t.Errorf("Expected theme file to be served, was %d", rec.Code)
+	}
+}
+
+func TestAssetsDevMode(t *testing.T) {
+	a := newTestAssets(t, true)
+	if a.Path("css/site.css") != "/res/css/site.css" {
+		t.Errorf("Expected plain name in dev mode, was <%s>", a.Path("css/site.css"))
+	}
+}
+
+func TestAssetsNames(t *testing.T) {
+	a := newTestAssets(t, false)
+	names := strings.Join(a.Names(), " ")
+	fingerprinted := strings.TrimPrefix(a.Path("css/site.css"), "/res/")
+	if !strings.Contains(names, "css/site.css") || !strings.Contains(names, fingerprinted) {
+		t.Errorf("Expected both names of the file, was <%s>", names)
+	}
+
+	a = newTestAssets(t, true)
+	if names := strings.Join(a.Names(), " "); strings.Contains(names, fingerprinted) {
+		t.Errorf("Expected only plain names in dev mode, was <%s>", names)
+	}
+}
+
+func TestDefaultTemplatesEmbedded(t *testing.T) {
+	pages, err := parsePages([]fs.FS{DefaultTemplates()})
+	if err != nil {
+		t.Fatal("Couldn't parse embedded templates", err)
+	}
+	for _, name := range []string{"index", "post", "post_compose", "user", "author"} {
+		if pages[name] == nil {
+			t.Errorf("Expected page %q to be embedded", name)
+		}
+	}
+}
+
+//
+// Helpers
+//
+
+func testPublic() fstest.MapFS {
+	return fstest.MapFS{
+		"css/site.css": &fstest.MapFile{Data: []byte("body {}")},
+		"js/site.js":   &fstest.MapFile{Data: []byte("alert()")},
+	}
+}
+
+func newTestAssets(t *testing.T, dev bool) *Assets {
+	a, err := NewAssets(testPublic(), nil, dev)
+	if err != nil {
+		t.Fatal("Couldn't load assets", err)
+	}
+	return a
+}
+
+func serveAsset(a *Assets, path string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", path, nil)
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	return rec
+}
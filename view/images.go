@@ -0,0 +1,54 @@
+package view
+
+import (
+	"github.com/aybabtme/goblog/media"
+	"strconv"
+	"strings"
+)
+
+// Finds the name of the picture uploaded at a URL, set by SetupImages.
+var uploadName func(url string) (string, bool)
+
+// SetupImages makes the templates show the pictures uploaded to store
+// resized, from `/media/{size}/{name}`.  Other pictures are shown as they
+// are.
+func SetupImages(store media.MediaStore) {
+	if store == nil {
+		uploadName = nil
+		return
+	}
+	uploadName = func(url string) (string, bool) {
+		return media.NameOf(store, url)
+	}
+}
+
+// The URL of the picture at url resized to size, like "thumb", as in
+// `<img src="{{image .ImageURL "thumb"}}">`.
+func imageURL(url string, size string) string {
+	if uploadName == nil {
+		return url
+	}
+	name, ok := uploadName(url)
+	if _, known := media.FindSize(size); !ok || !known || !media.Resizable(name) {
+		return url
+	}
+	return "/media/" + size + "/" + name
+}
+
+// The sizes of the picture at url for browsers to pick from, as in
+// `<img src="..." srcset="{{srcset .ImageURL}}" sizes="100px">`, empty
+// when it isn't resized.
+func srcset(url string) string {
+	if uploadName == nil {
+		return ""
+	}
+	name, ok := uploadName(url)
+	if !ok || !media.Resizable(name) {
+		return ""
+	}
+	var candidates []string
+	for _, size := range media.Sizes {
+		candidates = append(candidates, "/media/"+size.Name+"/"+name+" "+strconv.Itoa(size.Width)+"w")
+	}
+	return strings.Join(candidates, ", ")
+}
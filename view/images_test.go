@@ -0,0 +1,39 @@
+package view
+
+import (
+	"github.com/aybabtme/goblog/media"
+	"os"
+	"testing"
+)
+
+func TestImageHelpers(t *testing.T) {
+	dir, err := os.MkdirTemp("", "goblog-media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, _ := media.NewLocalStore(dir, "/media/")
+	defer SetupImages(nil)
+	SetupImages(store)
+
+	if actual := imageURL("/media/a1-cat.jpg", "thumb"); actual != "/media/thumb/a1-cat.jpg" {
+		t.Errorf("Expected the thumbnail, was %q", actual)
+	}
+	expected := "/media/thumb/a1-cat.jpg 200w, /media/medium/a1-cat.jpg 800w, /media/large/a1-cat.jpg 1600w"
+	if actual := srcset("/media/a1-cat.jpg"); actual != expected {
+		t.Errorf("Expected every size, was %q", actual)
+	}
+
+	// pictures from elsewhere, or that aren't resized, are shown as they are
+	for _, url := range []string{"https://example.com/cat.jpg", "/media/a1-dance.gif", "/media/../cat.jpg", ""} {
+		if actual := imageURL(url, "thumb"); actual != url {
+			t.Errorf("Expected %q as it is, was %q", url, actual)
+		}
+		if actual := srcset(url); actual != "" {
+			t.Errorf("Expected no srcset for %q, was %q", url, actual)
+		}
+	}
+	if actual := imageURL("/media/a1-cat.jpg", "huge"); actual != "/media/a1-cat.jpg" {
+		t.Errorf("Expected unknown sizes to be ignored, was %q", actual)
+	}
+}
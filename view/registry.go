@@ -0,0 +1,269 @@
+package view
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registry parses every page template of a directory once and renders them
+// from any number of goroutines.  A page is `application.tmpl`, with all of
+// `base/*.tmpl`, and one of the other `*.tmpl` files of the directory.
+//
+// The files are looked up in a stack of layers: a themed registry looks in
+// the theme first, and falls back to the default templates for those the
+// theme doesn't override.
+//
+// In development mode, the directory is watched and changed templates are
+// swapped in as soon as they parse.  Until they do, the parse error is shown
+// in the browser instead of the page.
+type Registry struct {
+	// by priority, the default templates come last
+	layers []fs.FS
+	dev    bool
+
+	mu    sync.RWMutex
+	pages map[string]*template.Template
+	err   error
+}
+
+// Page is a handle on a template of a Registry.  It always renders the
+// latest version of the template held by the registry.
+type Page struct {
+	registry *Registry
+	name     string
+}
+
+func NewRegistry(fsys fs.FS, dev bool) (*Registry, error) {
+	r := &Registry{layers: []fs.FS{fsys}, dev: dev}
+	return r, r.Reload()
+}
+
+func NewThemedRegistry(fsys fs.FS, theme *Theme, dev bool) (*Registry, error) {
+	r := &Registry{layers: []fs.FS{theme.Templates(), fsys}, dev: dev}
+	return r, r.Reload()
+}
+
+// Reload parses all the templates again.  If any of them fails to parse, the
+// templates previously loaded are kept and the error is returned.
+func (r *Registry) Reload() error {
+	pages, err := parsePages(r.layers)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+	if err != nil {
+		return err
+	}
+	r.pages = pages
+	return nil
+}
+
+// Watch checks the template layers every interval and reloads the
+// templates when a file was added, removed or modified.  Calling the returned
+// func stops watching.
+func (r *Registry) Watch(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	last := layerStamp(r.layers)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			stamp := layerStamp(r.layers)
+			if stamp == last {
+				continue
+			}
+			last = stamp
+			if err := r.Reload(); err != nil {
+				log.Println("Registry, reloading templates:", err)
+			} else {
+				log.Println("Registry, reloaded templates")
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+func (r *Registry) Page(name string) *Page {
+	return &Page{registry: r, name: name}
+}
+
+// Render executes the named template into a buffer, and only writes the
+// result to w once the execution succeeded.  When w is a ResponseWriter, a
+// failure answers with a 500; in development mode, the error is shown.
+func (r *Registry) Render(w io.Writer, name string, data interface{}) error {
+	r.mu.RLock()
+	tmpl, ok := r.pages[name]
+	err := r.err
+	r.mu.RUnlock()
+
+	if err != nil && r.dev {
+		r.renderError(w, err)
+		return err
+	}
+	if !ok {
+		err = fmt.Errorf("no template named %q", name)
+		r.renderError(w, err)
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		r.renderError(w, err)
+		return err
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+func (r *Registry) renderError(w io.Writer, err error) {
+	rw, ok := w.(http.ResponseWriter)
+	if !ok {
+		return
+	}
+	if !r.dev {
+		http.Error(rw, http.StatusText(http.StatusInternalServerError),
+			http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.WriteHeader(http.StatusInternalServerError)
+	if execErr := errorPage.Execute(rw, err.Error()); execErr != nil {
+		log.Println("Registry, rendering error page:", execErr)
+	}
+}
+
+// Execute renders the page with the registry.  It has the signature of
+// template.Template's, so controllers use either the same way.
+func (p *Page) Execute(w io.Writer, data interface{}) error {
+	return p.registry.Render(w, p.name, data)
+}
+
+/*
+ * Helpers
+ */
+
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Template error</title></head>
+<body>
+   <h1>Template error</h1>
+   <pre>{{.}}</pre>
+   <p>Fix the template and reload this page.</p>
+</body>
+</html>
+`))
+
+func parsePages(layers []fs.FS) (map[string]*template.Template, error) {
+	names, err := templateFiles(layers, "*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	bases, err := templateFiles(layers, "base/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make(map[string]*template.Template)
+	for _, file := range names {
+		name := strings.TrimSuffix(file, ".tmpl")
+		if name == "application" {
+			continue
+		}
+		page, err := parsePage(layers, bases, file)
+		if err != nil {
+			return nil, err
+		}
+		pages[name] = page
+	}
+	return pages, nil
+}
+
+// Parses the files like template.ParseFiles would, each from the first layer
+// that has it.
+func parsePage(layers []fs.FS, bases []string, file string) (*template.Template, error) {
+	var page *template.Template
+	files := append(append([]string{"application.tmpl"}, bases...), file)
+	for _, name := range files {
+		content, err := readFile(layers, name)
+		if err != nil {
+			return nil, err
+		}
+		tmpl := page
+		if page == nil {
+			page = template.New(path.Base(name)).Funcs(funcs)
+			tmpl = page
+		} else {
+			tmpl = page.New(path.Base(name))
+		}
+		if _, err := tmpl.Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return page, nil
+}
+
+// The names of the files matching pattern in any of the layers.
+func templateFiles(layers []fs.FS, pattern string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for _, layer := range layers {
+		files, err := fs.Glob(layer, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if !seen[file] {
+				seen[file] = true
+				names = append(names, file)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Reads name from the first of the layers that has it.
+func readFile(layers []fs.FS, name string) ([]byte, error) {
+	for _, layer := range layers[:len(layers)-1] {
+		if content, err := fs.ReadFile(layer, name); err == nil {
+			return content, nil
+		}
+	}
+	return fs.ReadFile(layers[len(layers)-1], name)
+}
+
+// A summary of the files in the layers, which changes whenever one of them
+// is added, removed or modified.
+func layerStamp(layers []fs.FS) string {
+	var stamp bytes.Buffer
+	for i, layer := range layers {
+		fs.WalkDir(layer, ".", func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return nil
+			}
+			fmt.Fprintf(&stamp, "%d %s %d %d\n", i, path, info.Size(), info.ModTime().UnixNano())
+			return nil
+		})
+	}
+	return stamp.String()
+}
@@ -0,0 +1,182 @@
+package view
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRegistryRender(t *testing.T) {
+	dir := setupTemplateDir(t)
+	defer os.RemoveAll(dir)
+
+	r, err := NewRegistry(os.DirFS(dir), false)
+	if err != nil {
+		t.Fatal("Couldn't load registry", err)
+	}
+
+	var buf bytes.Buffer
+	if err := r.Page("hello").Execute(&buf, "world"); err != nil {
+		t.Fatal("Couldn't render", err)
+	}
+	if expected := "<main>Hello, world!</main><footer>bye</footer>"; buf.String() != expected {
+		t.Errorf("Expected <%s> but was <%s>", expected, buf.String())
+	}
+
+	if _, ok := r.pages["application"]; ok {
+		t.Error("Application template should not be a page")
+	}
+	if err := r.Render(&buf, "nothere", nil); err == nil {
+		t.Error("Expected an error for a missing template")
+	}
+}
+
+func TestRegistryRenderConcurrently(t *testing.T) {
+	dir := setupTemplateDir(t)
+	defer os.RemoveAll(dir)
+
+	r, err := NewRegistry(os.DirFS(dir), false)
+	if err != nil {
+		t.Fatal("Couldn't load registry", err)
+	}
+	page := r.Page("hello")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			if err := page.Execute(rec, "world"); err != nil {
+				t.Error("Couldn't render", err)
+			}
+			if !strings.Contains(rec.Body.String(), "Hello, world!") {
+				t.Errorf("Unexpected output <%s>", rec.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRegistryFailedExecutionWritesNothing(t *testing.T) {
+	dir := setupTemplateDir(t)
+	defer os.RemoveAll(dir)
+	writeTemplate(t, dir, "broken.tmpl", `{{define "content"}}before{{.Missing}}{{end}}`)
+
+	r, err := NewRegistry(os.DirFS(dir), false)
+	if err != nil {
+		t.Fatal("Couldn't load registry", err)
+	}
+
+	rec := httptest.NewRecorder()
+	if err := r.Render(rec, "broken", "not a struct"); err == nil {
+		t.Fatal("Expected an execution error")
+	}
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d but was %d", http.StatusInternalServerError, rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "before") {
+		t.Errorf("Partial page was written <%s>", rec.Body.String())
+	}
+}
+
+func TestRegistryHotReload(t *testing.T) {
+	dir := setupTemplateDir(t)
+	defer os.RemoveAll(dir)
+
+	r, err := NewRegistry(os.DirFS(dir), true)
+	if err != nil {
+		t.Fatal("Couldn't load registry", err)
+	}
+	stop := r.Watch(10 * time.Millisecond)
+	defer stop()
+	page := r.Page("hello")
+
+	// A parse error is shown instead of the page
+	writeTemplate(t, dir, "hello.tmpl", `{{define "content"}}Hello, {{.}{{end}}`)
+	rec := waitForRender(t, page, func(rec *httptest.ResponseRecorder) bool {
+		return rec.Code == http.StatusInternalServerError
+	})
+	if !strings.Contains(rec.Body.String(), "Template error") {
+		t.Errorf("Expected the parse error in <%s>", rec.Body.String())
+	}
+
+	// Once fixed, the new version is rendered
+	writeTemplate(t, dir, "hello.tmpl", `{{define "content"}}Bonjour, {{.}}!{{end}}`)
+	rec = waitForRender(t, page, func(rec *httptest.ResponseRecorder) bool {
+		return rec.Code == http.StatusOK
+	})
+	if !strings.Contains(rec.Body.String(), "Bonjour, world!") {
+		t.Errorf("Expected reloaded template in <%s>", rec.Body.String())
+	}
+}
+
+func TestRegistryKeepsTemplatesOnParseError(t *testing.T) {
+	dir := setupTemplateDir(t)
+	defer os.RemoveAll(dir)
+
+	r, err := NewRegistry(os.DirFS(dir), false)
+	if err != nil {
+		t.Fatal("Couldn't load registry", err)
+	}
+
+	writeTemplate(t, dir, "hello.tmpl", `{{define "content"}}{{if}}{{end}}`)
+	if err := r.Reload(); err == nil {
+		t.Fatal("Expected a parse error")
+	}
+
+	var buf bytes.Buffer
+	if err := r.Page("hello").Execute(&buf, "world"); err != nil {
+		t.Fatal("Couldn't render", err)
+	}
+	if !strings.Contains(buf.String(), "Hello, world!") {
+		t.Errorf("Expected previous template in <%s>", buf.String())
+	}
+}
+
+//
+// Helpers
+//
+
+func setupTemplateDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "goblog-templates")
+	if err != nil {
+		t.Fatal("Couldn't create template dir", err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "base"), 0755); err != nil {
+		t.Fatal("Couldn't create base dir", err)
+	}
+	writeTemplate(t, dir, "application.tmpl", `<main>{{template "content" .}}</main>{{template "footer" .}}`)
+	writeTemplate(t, dir, "base/footer.tmpl", `{{define "footer"}}<footer>bye</footer>{{end}}`)
+	writeTemplate(t, dir, "hello.tmpl", `{{define "content"}}Hello, {{.}}!{{end}}`)
+	return dir
+}
+
+func writeTemplate(t *testing.T, dir, name, content string) {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal("Couldn't write template", err)
+	}
+	// make sure the watcher sees a change even on coarse file systems
+	later := time.Now().Add(time.Duration(len(content)) * time.Second)
+	os.Chtimes(path, later, later)
+}
+
+func waitForRender(t *testing.T, page *Page, done func(*httptest.ResponseRecorder) bool) *httptest.ResponseRecorder {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		rec := httptest.NewRecorder()
+		page.Execute(rec, "world")
+		if done(rec) || time.Now().After(deadline) {
+			return rec
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
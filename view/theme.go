@@ -0,0 +1,98 @@
+package view
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Theme is a directory holding a `theme.json` file describing it, a
+// `template/` directory laid out like `view/template` and a `public/`
+// directory with its static assets.  Any template file it has overrides the
+// default one of the same name.
+type Theme struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Author      string `json:"author"`
+	Version     string `json:"version"`
+
+	dir string
+}
+
+// LoadTheme reads the theme in dir.
+func LoadTheme(dir string) (*Theme, error) {
+	file, err := os.Open(filepath.Join(dir, "theme.json"))
+	if err != nil {
+		return nil, fmt.Errorf("not a theme, %v", err)
+	}
+	defer file.Close()
+
+	theme := &Theme{dir: dir}
+	if err := json.NewDecoder(file).Decode(theme); err != nil {
+		return nil, fmt.Errorf("invalid theme.json, %v", err)
+	}
+	return theme, nil
+}
+
+func (t *Theme) Dir() string {
+	return t.dir
+}
+
+func (t *Theme) Templates() fs.FS {
+	return os.DirFS(filepath.Join(t.dir, "template"))
+}
+
+func (t *Theme) Public() fs.FS {
+	return os.DirFS(filepath.Join(t.dir, "public"))
+}
+
+// Validate checks that the theme has a name, that its templates parse along
+// with the default ones, and that every page still defines all the templates
+// the default page defines.
+func (t *Theme) Validate(defaults fs.FS) []error {
+	var errs []error
+	if t.Name == "" {
+		errs = append(errs, fmt.Errorf("theme.json doesn't give the theme a name"))
+	}
+
+	defaultPages, err := parsePages([]fs.FS{defaults})
+	if err != nil {
+		return append(errs, fmt.Errorf("default templates, %v", err))
+	}
+	themed, err := parsePages([]fs.FS{t.Templates(), defaults})
+	if err != nil {
+		return append(errs, err)
+	}
+
+	var names []string
+	for name := range defaultPages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, required := range definedNames(defaultPages[name]) {
+			if themed[name].Lookup(required) == nil {
+				errs = append(errs, fmt.Errorf("page %q doesn't define template %q", name, required))
+			}
+		}
+	}
+	return errs
+}
+
+// The names of the templates defined in tmpl, leaving out those named after
+// the files they were parsed from.
+func definedNames(tmpl *template.Template) []string {
+	var names []string
+	for _, defined := range tmpl.Templates() {
+		if filepath.Ext(defined.Name()) != ".tmpl" {
+			names = append(names, defined.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
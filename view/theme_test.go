@@ -0,0 +1,135 @@
+package view
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadTheme(t *testing.T) {
+	dir := setupTheme(t, `{"name": "dark", "author": "someone", "version": "1.0"}`)
+	defer os.RemoveAll(dir)
+
+	theme, err := LoadTheme(dir)
+	if err != nil {
+		t.Fatal("Couldn't load theme", err)
+	}
+	if theme.Name != "dark" || theme.Author != "someone" || theme.Version != "1.0" {
+		t.Errorf("Unexpected metadata %#v", theme)
+	}
+	if theme.Dir() != dir {
+		t.Errorf("Unexpected dir <%s>", theme.Dir())
+	}
+
+	if _, err := LoadTheme(os.TempDir()); err == nil {
+		t.Error("Expected an error for a directory without theme.json")
+	}
+}
+
+func TestThemeOverridesTemplates(t *testing.T) {
+	defaultDir := setupTemplateDir(t)
+	defer os.RemoveAll(defaultDir)
+	themeDir := setupTheme(t, `{"name": "dark"}`)
+	defer os.RemoveAll(themeDir)
+	writeTemplate(t, themeDir, "template/base/footer.tmpl", `{{define "footer"}}<footer>dark</footer>{{end}}`)
+
+	theme, _ := LoadTheme(themeDir)
+	r, err := NewThemedRegistry(os.DirFS(defaultDir), theme, false)
+	if err != nil {
+		t.Fatal("Couldn't load registry", err)
+	}
+
+	var buf bytes.Buffer
+	if err := r.Page("hello").Execute(&buf, "world"); err != nil {
+		t.Fatal("Couldn't render", err)
+	}
+	// footer comes from the theme, the rest from the default templates
+	if expected := "<main>Hello, world!</main><footer>dark</footer>"; buf.String() != expected {
+		t.Errorf("Expected <%s> but was <%s>", expected, buf.String())
+	}
+}
+
+func TestThemeAddsPages(t *testing.T) {
+	defaultDir := setupTemplateDir(t)
+	defer os.RemoveAll(defaultDir)
+	themeDir := setupTheme(t, `{"name": "dark"}`)
+	defer os.RemoveAll(themeDir)
+	writeTemplate(t, themeDir, "template/about.tmpl", `{{define "content"}}About {{.}}{{end}}`)
+
+	theme, _ := LoadTheme(themeDir)
+	r, err := NewThemedRegistry(os.DirFS(defaultDir), theme, false)
+	if err != nil {
+		t.Fatal("Couldn't load registry", err)
+	}
+
+	var buf bytes.Buffer
+	if err := r.Page("about").Execute(&buf, "us"); err != nil {
+		t.Fatal("Couldn't render", err)
+	}
+	if !strings.Contains(buf.String(), "About us") {
+		t.Errorf("Unexpected output <%s>", buf.String())
+	}
+}
+
+func TestValidateTheme(t *testing.T) {
+	dir := setupTheme(t, `{"name": "plain"}`)
+	defer os.RemoveAll(dir)
+	writeTemplate(t, dir, "template/base/style.tmpl",
+		`{{define "style"}}<link rel="stylesheet" href="/res/theme/plain.css">{{end}}`)
+
+	theme, _ := LoadTheme(dir)
+	if errs := theme.Validate(DefaultTemplates()); len(errs) != 0 {
+		t.Errorf("Expected a valid theme, got %v", errs)
+	}
+}
+
+func TestValidateThemeMissingTemplates(t *testing.T) {
+	dir := setupTheme(t, `{}`)
+	defer os.RemoveAll(dir)
+	// overrides the file but forgets to define "header"
+	writeTemplate(t, dir, "template/base/header.tmpl", `{{define "heading"}}{{end}}`)
+
+	theme, _ := LoadTheme(dir)
+	errs := theme.Validate(DefaultTemplates())
+	// no name, and every page misses "header"
+	if len(errs) < 2 {
+		t.Fatalf("Expected errors, got %v", errs)
+	}
+	for _, err := range errs[1:] {
+		if !strings.Contains(err.Error(), `"header"`) {
+			t.Errorf("Unexpected error %v", err)
+		}
+	}
+}
+
+func TestValidateThemeParseError(t *testing.T) {
+	dir := setupTheme(t, `{"name": "broken"}`)
+	defer os.RemoveAll(dir)
+	writeTemplate(t, dir, "template/index.tmpl", `{{define "content"}}{{if}}{{end}}`)
+
+	theme, _ := LoadTheme(dir)
+	if errs := theme.Validate(DefaultTemplates()); len(errs) != 1 {
+		t.Errorf("Expected the parse error, got %v", errs)
+	}
+}
+
+//
+// Helpers
+//
+
+func setupTheme(t *testing.T, metadata string) string {
+	dir, err := ioutil.TempDir("", "goblog-theme")
+	if err != nil {
+		t.Fatal("Couldn't create theme dir", err)
+	}
+	for _, sub := range []string{"template/base", "public"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal("Couldn't create theme dir", err)
+		}
+	}
+	writeTemplate(t, dir, "theme.json", metadata)
+	return dir
+}
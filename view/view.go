@@ -1,94 +1,170 @@
 package view
 
 import (
+	"embed"
 	"fmt"
-	"text/template"
+	"io/fs"
+	"os"
+	"time"
 )
 
 /*
  * Helpers
  */
-func getApplicationTemplate() (*template.Template, error) {
-	app, err := template.ParseFiles("view/template/application.tmpl")
-	if nil != err {
-		return nil, err
-	}
 
-	base, err := app.ParseGlob("view/template/base/*.tmpl")
-	if nil != err {
-		return nil, err
+//go:embed template
+var embedded embed.FS
+
+// DefaultTemplates are the templates compiled into the binary, laid out
+// like `view/template`.
+func DefaultTemplates() fs.FS {
+	templates, err := fs.Sub(embedded, "template")
+	if err != nil {
+		panic(err)
 	}
-	return base, nil
+	return templates
 }
 
-func getTemplateByName(templateName string) (*template.Template, error) {
-	base, err := getApplicationTemplate()
-	if nil != err {
-		fmt.Println("Cannot prepare application template.", err)
-		return nil, err
+// The registry all the Get*Template pages come from.  Unless SetupTemplates
+// says otherwise, it only has the default templates.
+var templates = &Registry{layers: []fs.FS{DefaultTemplates()}}
+
+// SetupTemplates loads the default templates, overridden by those found in
+// dir unless it's empty, themselves overridden by those of theme unless it's
+// nil.  In development mode, they are reloaded whenever they change, and
+// parse errors are shown in the browser rather than returned.
+func SetupTemplates(dir string, theme *Theme, dev bool) (stop func(), err error) {
+	templates.layers = []fs.FS{DefaultTemplates()}
+	if dir != "" {
+		templates.layers = append([]fs.FS{os.DirFS(dir)}, templates.layers...)
+	}
+	if theme != nil {
+		templates.layers = append([]fs.FS{theme.Templates()}, templates.layers...)
+	}
+	templates.dev = dev
+	err = templates.Reload()
+	if !dev {
+		return func() {}, err
+	}
+	if err != nil {
+		fmt.Println("Couldn't load templates, will retry when they change.", err)
 	}
+	return templates.Watch(time.Second), nil
+}
+
+func getPage(name string) *Page {
+	templates.mu.RLock()
+	loaded := templates.pages != nil
+	templates.mu.RUnlock()
 
-	content, err := base.ParseFiles(
-		fmt.Sprintf("view/template/%s.tmpl", templateName))
-	if nil != err {
-		fmt.Printf("Couldn't load %s template.\n %v", templateName, err)
-		return nil, err
+	if !loaded && !templates.dev {
+		if err := templates.Reload(); err != nil {
+			panic(err)
+		}
 	}
-	return content, nil
+	return templates.Page(name)
+}
+
+/*
+ * Errors
+ */
+
+func GetErrorTemplate() *Page {
+	return getPage("error")
 }
 
 /*
  * Index
  */
 
-func GetIndexTemplate() *template.Template {
-	return template.Must(getTemplateByName("index"))
+func GetIndexTemplate() *Page {
+	return getPage("index")
 }
 
 /*
  *	Posts
  */
 
-func GetPostListingTemplate() *template.Template {
-	return template.Must(getTemplateByName("post_listing"))
+func GetPostListingTemplate() *Page {
+	return getPage("post_listing")
+}
+
+func GetPostTemplate() *Page {
+	return getPage("post")
+}
+
+func GetPostComposeTemplate() *Page {
+	return getPage("post_compose")
+}
+
+func GetPostDestroyTemplate() *Page {
+	return getPage("post_destroy")
 }
 
-func GetPostTemplate() *template.Template {
-	return template.Must(getTemplateByName("post"))
+func GetMediaTemplate() *Page {
+	return getPage("media")
 }
 
-func GetPostComposeTemplate() *template.Template {
-	return template.Must(getTemplateByName("post_compose"))
+/*
+ * Session
+ */
+
+func GetLogoutTemplate() *Page {
+	return getPage("logout")
 }
 
-func GetPostDestroyTemplate() *template.Template {
-	return template.Must(getTemplateByName("post"))
+func GetSessionListTemplate() *Page {
+	return getPage("sessions")
+}
+
+func GetTokenListTemplate() *Page {
+	return getPage("tokens")
 }
 
 /*
  * Labels
  */
 
-func GetLabelTemplate() *template.Template {
-	return template.Must(getTemplateByName("label"))
+func GetLabelTemplate() *Page {
+	return getPage("label")
 }
 
 /*
  * Users
  */
 
-func GetUserTemplate() *template.Template {
-	return template.Must(getTemplateByName("user"))
+func GetUserTemplate() *Page {
+	return getPage("user")
 }
 
 /*
  * Authors
  */
 
-func GetAuthorTemplate() *template.Template {
-	return template.Must(getTemplateByName("author"))
+func GetAuthorTemplate() *Page {
+	return getPage("author")
+}
+
+func GetAuthorListTemplate() *Page {
+	return getPage("author_listing")
+}
+
+/*
+ * Admin
+ */
+
+func GetAdminUserListTemplate() *Page {
+	return getPage("admin_users")
+}
+
+func GetAdminUserTemplate() *Page {
+	return getPage("admin_user")
+}
+
+func GetAdminConfirmTemplate() *Page {
+	return getPage("admin_confirm")
 }
 
-func GetAuthorListTemplate() *template.Template {
-	return template.Must(getTemplateByName("author_listing"))
+func GetAdminAuditTemplate() *Page {
+	return getPage("admin_audit")
 }
@@ -0,0 +1,234 @@
+package view
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/aybabtme/goblog/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+// What a hostile user would put anywhere they can.
+const xssScript = `<script>alert("xss")</script>`
+
+var xssContent = "Some text\n\n" + xssScript + "\n\n" +
+	`[click me](javascript:alert("xss"))` + "\n\n" +
+	`<img src="x" onerror="alert('xss')">`
+
+// Would be in the page if any hostile input went through unescaped.
+var xssTells = []string{
+	`<script>alert`,
+	`"><script`,
+	`javascript:alert`,
+	`onerror=`,
+}
+
+// Has all the fields any template looks up, like the data the controllers
+// give them.
+type page struct {
+	CurrentUser      *model.User
+	CurrentAuthor    *model.Author
+	Site             site
+	Flashes          []string
+	Alternates       []alternate
+	Post             *model.Post
+	Posts            []model.Post
+	AllPosts         []model.Post
+	Labels           []model.Label
+	AllLabels        []model.Label
+	Name             string
+	Author           *model.Author
+	Authors          []model.Author
+	User             *model.User
+	CanEdit          bool
+	CanDelete        bool
+	CanComment       bool
+	CSRFToken        string
+	Query            string
+	Users            []adminRow
+	Target           adminRow
+	Roles            []model.Role
+	Role             string
+	Action           string
+	Entries          []model.AuditEntry
+	Sessions         []model.Session
+	CurrentSessionId string
+	Tokens           []model.ApiToken
+	Scopes           []string
+	NewToken         string
+	Now              time.Time
+	Media            []model.Media
+	Files            []mediaRow
+	MaxSizeMB        int64
+}
+
+type site struct {
+	Title       string
+	Description string
+}
+
+type alternate struct {
+	Type  string
+	Title string
+	Href  string
+}
+
+type mediaRow struct {
+	Media     *model.Media
+	CanDelete bool
+}
+
+type adminRow struct {
+	User      *model.User
+	Role      model.Role
+	IsAuthor  bool
+	Posts     int
+	Banned    bool
+	BanReason string
+}
+
+func TestTemplatesEscapeHostileInput(t *testing.T) {
+	templatesEscapeHostileInput(t, setupPGConnection())
+}
+
+func templatesEscapeHostileInput(t *testing.T, conn *model.DBConnection) {
+	defer conn.DeleteConnection()
+	if _, err := SetupTemplates("", nil, false); err != nil {
+		t.Fatal("Couldn't load templates", err)
+	}
+	data := hostilePage(t, conn)
+
+	pages := map[string]*Page{
+		"index":          GetIndexTemplate(),
+		"post_listing":   GetPostListingTemplate(),
+		"post":           GetPostTemplate(),
+		"post_compose":   GetPostComposeTemplate(),
+		"post_destroy":   GetPostDestroyTemplate(),
+		"label":          GetLabelTemplate(),
+		"user":           GetUserTemplate(),
+		"author":         GetAuthorTemplate(),
+		"author_listing": GetAuthorListTemplate(),
+		"logout":         GetLogoutTemplate(),
+		"sessions":       GetSessionListTemplate(),
+		"tokens":         GetTokenListTemplate(),
+		"media":          GetMediaTemplate(),
+		"admin_users":    GetAdminUserListTemplate(),
+		"admin_user":     GetAdminUserTemplate(),
+		"admin_audit":    GetAdminAuditTemplate(),
+	}
+	for name, tmpl := range pages {
+		render(t, name, tmpl, data)
+	}
+
+	confirm := GetAdminConfirmTemplate()
+	for _, action := range []string{"role", "ban", "unban", "merge", "delete"} {
+		data.Action = action
+		render(t, "admin_confirm "+action, confirm, data)
+	}
+}
+
+func render(t *testing.T, name string, tmpl *Page, data page) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		t.Errorf("Template <%s> failed: %v", name, err)
+		return
+	}
+	html := buf.String()
+	for _, tell := range xssTells {
+		if strings.Contains(html, tell) {
+			t.Errorf("Template <%s> let <%s> through:\n%s", name, tell, excerpt(html, tell))
+		}
+	}
+}
+
+func hostilePage(t *testing.T, conn *model.DBConnection) page {
+	now := time.Now().UTC()
+
+	user := conn.NewUser(`"><script>alert("xss")</script>`, now, -5,
+		"oauth-xss", "access", "refresh", `xss"@example.com`)
+	author := conn.NewAuthor(user)
+	if err := author.Save(); err != nil {
+		t.Fatal("Couldn't save author", err)
+	}
+
+	saved := conn.NewPost(author, xssScript, xssContent, `javascript:alert("xss")`, now)
+	if err := saved.Save(); err != nil {
+		t.Fatal("Couldn't save post", err)
+	}
+	saved.AddLabel(xssScript)
+	conn.NewComment(user.Id(), saved.Id(), xssContent, now).Save()
+	conn.NewAuditEntry(user, xssScript, user.Id(), xssScript, now).Save()
+
+	post, err := conn.FindPostById(saved.Id())
+	if err != nil {
+		t.Fatal("Couldn't find post back", err)
+	}
+	posts, _ := conn.FindAllPosts()
+	labels, _ := conn.FindAllLabels()
+	authors, _ := conn.FindAllAuthors()
+	entries, _ := conn.FindAllAuditEntries()
+	session := conn.NewSession(fmt.Sprintf("%064d", 1), xssScript, now, now.Add(time.Hour))
+	token := conn.NewApiToken(user.Id(), xssScript, fmt.Sprintf("%064d", 1),
+		[]string{xssScript}, now, now.Add(time.Hour))
+	row := adminRow{user, model.RoleAuthor, true, 2, true, xssScript}
+	picture := conn.NewMedia(user.Id(), "a1-xss.png", `javascript:alert("xss")`, "image/png", 1, now)
+
+	return page{
+		CurrentUser:      user,
+		CurrentAuthor:    author,
+		Site:             site{xssScript, xssScript},
+		Flashes:          []string{xssScript},
+		Alternates:       []alternate{{xssScript, xssScript, `javascript:alert("xss")`}},
+		Post:             post,
+		Posts:            posts,
+		AllPosts:         posts,
+		Labels:           labels,
+		AllLabels:        labels,
+		Name:             xssScript,
+		Author:           author,
+		Authors:          authors,
+		User:             user,
+		CanEdit:          true,
+		CanDelete:        true,
+		CanComment:       true,
+		CSRFToken:        `"><script>alert("xss")</script>`,
+		Query:            `"><script>alert("xss")</script>`,
+		Users:            []adminRow{row},
+		Target:           row,
+		Roles:            model.Roles,
+		Role:             xssScript,
+		Entries:          entries,
+		Sessions:         []model.Session{*session},
+		CurrentSessionId: session.Id(),
+		Tokens:           []model.ApiToken{*token},
+		Scopes:           []string{xssScript},
+		NewToken:         xssScript,
+		Now:              now,
+		Media:            []model.Media{*picture},
+		Files:            []mediaRow{{picture, true}},
+		MaxSizeMB:        10,
+	}
+}
+
+//
+// Helpers
+//
+
+func setupPGConnection() *model.DBConnection {
+	modelurl := "user=antoine dbname=test sslmode=disable"
+	conn, _ := model.NewConnection(model.NewPostgreser(modelurl))
+	return conn
+}
+
+func excerpt(html, tell string) string {
+	i := strings.Index(html, tell)
+	from, to := i-80, i+80
+	if from < 0 {
+		from = 0
+	}
+	if to > len(html) {
+		to = len(html)
+	}
+	return html[from:to]
+}
@@ -0,0 +1,144 @@
+package wxr
+
+import (
+	"bytes"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	blankLines      = regexp.MustCompile(`\n{3,}`)
+	markdownSpecial = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `_`, `\_`, "`", "\\`", `[`, `\[`, `]`, `\]`)
+)
+
+// toMarkdown converts the HTML of WordPress posts and comments to Markdown.
+// What Markdown has no syntax for, like tables or embeds, is kept as HTML.
+func toMarkdown(content string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(content), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		// keeping the HTML is better than losing the post
+		return content
+	}
+	var md markdownWriter
+	for _, node := range nodes {
+		md.node(node)
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(md.String(), "\n\n"))
+}
+
+type markdownWriter struct {
+	bytes.Buffer
+	// how deep in lists the writer is
+	lists int
+}
+
+func (md *markdownWriter) children(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		md.node(child)
+	}
+}
+
+// Writes the children of n in their own writer, to decorate them.
+func (md *markdownWriter) inner(n *html.Node) string {
+	inner := markdownWriter{lists: md.lists}
+	inner.children(n)
+	return inner.String()
+}
+
+func (md *markdownWriter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		md.WriteString(markdownSpecial.Replace(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	switch n.Data {
+	case "p", "div":
+		md.WriteString("\n\n" + strings.TrimSpace(md.inner(n)) + "\n\n")
+	case "br":
+		md.WriteString("  \n")
+	case "strong", "b":
+		md.WriteString("**" + md.inner(n) + "**")
+	case "em", "i":
+		md.WriteString("*" + md.inner(n) + "*")
+	case "code":
+		md.WriteString("`" + text(n) + "`")
+	case "pre":
+		md.WriteString("\n\n```\n" + strings.Trim(text(n), "\n") + "\n```\n\n")
+	case "a":
+		md.WriteString("[" + md.inner(n) + "](" + attr(n, "href") + ")")
+	case "img":
+		md.WriteString("![" + attr(n, "alt") + "](" + attr(n, "src") + ")")
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level, _ := strconv.Atoi(n.Data[1:])
+		md.WriteString("\n\n" + strings.Repeat("#", level) + " " + strings.TrimSpace(md.inner(n)) + "\n\n")
+	case "ul", "ol":
+		md.list(n)
+	case "blockquote":
+		quoted := blankLines.ReplaceAllString(strings.TrimSpace(md.inner(n)), "\n\n")
+		md.WriteString("\n\n")
+		for _, line := range strings.Split(quoted, "\n") {
+			md.WriteString(strings.TrimSpace("> "+line) + "\n")
+		}
+		md.WriteString("\n")
+	case "hr":
+		md.WriteString("\n\n---\n\n")
+	case "span":
+		md.children(n)
+	default:
+		html.Render(md, n)
+	}
+}
+
+func (md *markdownWriter) list(n *html.Node) {
+	indent := strings.Repeat("    ", md.lists)
+	md.lists++
+	defer func() { md.lists-- }()
+
+	md.WriteString("\n\n")
+	number := 0
+	for item := n.FirstChild; item != nil; item = item.NextSibling {
+		if item.Type != html.ElementNode || item.Data != "li" {
+			continue
+		}
+		number++
+		bullet := "- "
+		if n.Data == "ol" {
+			bullet = strconv.Itoa(number) + ". "
+		}
+		content := strings.TrimSpace(blankLines.ReplaceAllString(md.inner(item), "\n\n"))
+		md.WriteString(indent + bullet + content + "\n")
+	}
+	md.WriteString("\n")
+}
+
+// The text in n, as is.
+func text(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(text(child))
+	}
+	return b.String()
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
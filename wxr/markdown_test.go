@@ -0,0 +1,30 @@
+package wxr
+
+import (
+	"testing"
+)
+
+func TestToMarkdown(t *testing.T) {
+	cases := []struct {
+		html string
+		md   string
+	}{
+		{`Just text`, `Just text`},
+		{`<p>One</p><p>Two</p>`, "One\n\nTwo"},
+		{`<p>A <strong>bold</strong> and <em>odd</em> <a href="/x">link</a></p>`,
+			`A **bold** and *odd* [link](/x)`},
+		{`<h2>Title</h2><p>Text</p>`, "## Title\n\nText"},
+		{`<ul><li>one</li><li>two</li></ul>`, "- one\n- two"},
+		{`<ol><li>one</li><li>two<ul><li>inner</li></ul></li></ol>`, "1. one\n2. two\n\n    - inner"},
+		{`<blockquote><p>Quoted</p><p>twice</p></blockquote>`, "> Quoted\n>\n> twice"},
+		{`<pre><code>if a &lt; b {}</code></pre>`, "```\nif a < b {}\n```"},
+		{`Use <code>a_b</code> not a_b*`, "Use `a_b` not a\\_b\\*"},
+		{`<img src="/a.png" alt="A">`, `![A](/a.png)`},
+		{`<table><tr><td>kept</td></tr></table>`, `<table><tbody><tr><td>kept</td></tr></tbody></table>`},
+	}
+	for _, c := range cases {
+		if md := toMarkdown(c.html); md != c.md {
+			t.Errorf("Expected %q for %q, was %q", c.md, c.html, md)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+// Package wxr reads the WXR files WordPress exports, as archives that the
+// blog can import.
+package wxr
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/aybabtme/goblog/archive"
+	"github.com/aybabtme/goblog/model"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type rss struct {
+	Channel channel `xml:"channel"`
+}
+
+type channel struct {
+	Title   string   `xml:"title"`
+	Authors []author `xml:"author"`
+	Items   []item   `xml:"item"`
+}
+
+type author struct {
+	Id          int64  `xml:"author_id"`
+	Login       string `xml:"author_login"`
+	Email       string `xml:"author_email"`
+	DisplayName string `xml:"author_display_name"`
+}
+
+type item struct {
+	Title         string     `xml:"title"`
+	Creator       string     `xml:"creator"`
+	Content       string     `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Id            int64      `xml:"post_id"`
+	Date          string     `xml:"post_date"`
+	DateGMT       string     `xml:"post_date_gmt"`
+	Status        string     `xml:"status"`
+	Type          string     `xml:"post_type"`
+	AttachmentURL string     `xml:"attachment_url"`
+	Categories    []category `xml:"category"`
+	Meta          []meta     `xml:"postmeta"`
+	Comments      []comment  `xml:"comment"`
+}
+
+type category struct {
+	Domain   string `xml:"domain,attr"`
+	Nicename string `xml:"nicename,attr"`
+	Name     string `xml:",chardata"`
+}
+
+type meta struct {
+	Key   string `xml:"meta_key"`
+	Value string `xml:"meta_value"`
+}
+
+type comment struct {
+	Id       int64  `xml:"comment_id"`
+	Author   string `xml:"comment_author"`
+	Email    string `xml:"comment_author_email"`
+	Date     string `xml:"comment_date"`
+	DateGMT  string `xml:"comment_date_gmt"`
+	Content  string `xml:"comment_content"`
+	Approved string `xml:"comment_approved"`
+	Type     string `xml:"comment_type"`
+	Parent   int64  `xml:"comment_parent"`
+	UserId   int64  `xml:"comment_user_id"`
+}
+
+// How WordPress writes dates, in UTC for the _gmt ones.
+const dateLayout = "2006-01-02 15:04:05"
+
+// Pages become posts with this label.
+const PageLabel = "page"
+
+// The OAuth ids given to authors and commenters, who have no Google
+// account until they log in.
+const (
+	authorOauthPrefix    = "wordpress:"
+	commenterOauthPrefix = "wordpress-commenter:"
+)
+
+// Imported tells if a user of that OAuth id came from a WordPress export
+// and never logged in, so that the Google account of its email is its own.
+func Imported(oauthId string) bool {
+	return strings.HasPrefix(oauthId, authorOauthPrefix) ||
+		strings.HasPrefix(oauthId, commenterOauthPrefix)
+}
+
+// Read reads a WXR file as an archive of the blog, along with what in it
+// wasn't kept and why.  Authors become users who are authors, and those
+// who commented become commenters.  Only published posts and pages are
+// kept, along with their comments, approved or pending, and what those
+// reply to.  Spam and trashed comments aren't.
+func Read(r io.Reader) (*archive.Archive, []string, error) {
+	var doc rss
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("wxr: not a WordPress export: %v", err)
+	}
+	c := converter{
+		a: &archive.Archive{
+			Format:   archive.Format,
+			Version:  archive.Version,
+			Exported: time.Now().UTC(),
+			Sections: archive.Sections(),
+		},
+		authors:   make(map[string]int64),
+		authorIds: make(map[int64]int64),
+		users:     make(map[string]int64),
+		usernames: make(map[string]bool),
+		labels:    make(map[string]int64),
+		images:    make(map[int64]string),
+		comments:  make(map[int64]bool),
+	}
+	c.convert(&doc.Channel)
+	return c.a, c.skipped, nil
+}
+
+type converter struct {
+	a       *archive.Archive
+	skipped []string
+
+	// archive user ids by WordPress login, by WordPress user id, and by
+	// email or name of commenters
+	authors   map[string]int64
+	authorIds map[int64]int64
+	users     map[string]int64
+	usernames map[string]bool
+	labels    map[string]int64
+	// the URLs of attachments, by id
+	images map[int64]string
+	// the comments kept, by id
+	comments map[int64]bool
+}
+
+func (c *converter) skip(format string, args ...interface{}) {
+	c.skipped = append(c.skipped, fmt.Sprintf(format, args...))
+}
+
+func (c *converter) convert(ch *channel) {
+	for _, au := range ch.Authors {
+		c.addAuthor(au)
+	}
+	for _, it := range ch.Items {
+		if it.Type == "attachment" {
+			c.images[it.Id] = it.AttachmentURL
+		}
+	}
+
+	skippedTypes := make(map[string]int)
+	for _, it := range ch.Items {
+		switch {
+		case it.Type == "attachment":
+		case it.Type != "post" && it.Type != "page":
+			skippedTypes[it.Type]++
+		case it.Status != "publish":
+			c.skip("%s %d %q: it's %s, not published", it.Type, it.Id, it.Title, it.Status)
+		default:
+			c.addPost(it)
+		}
+	}
+
+	var types []string
+	for t := range skippedTypes {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		c.skip("%d item(s) of type %q: only posts and pages are kept", skippedTypes[t], t)
+	}
+}
+
+func (c *converter) addAuthor(au author) {
+	name := au.DisplayName
+	if name == "" {
+		name = au.Login
+	}
+	email := au.Email
+	if email == "" {
+		email = au.Login + "@wordpress.invalid"
+	}
+	userId := c.addUser(authorOauthPrefix+au.Login, name, email, model.RoleAuthor)
+	authorId := int64(len(c.a.Authors) + 1)
+	c.a.Authors = append(c.a.Authors, archive.Author{Id: authorId, UserId: userId})
+	c.authors[au.Login] = authorId
+	c.authorIds[au.Id] = userId
+	c.users[strings.ToLower(email)] = userId
+}
+
+// Adds a user with a username nobody else has, returning its id.
+func (c *converter) addUser(oauthId, name, email string, role model.Role) int64 {
+	username := name
+	for i := 2; c.usernames[username]; i++ {
+		username = name + " " + strconv.Itoa(i)
+	}
+	c.usernames[username] = true
+
+	id := int64(len(c.a.Users) + 1)
+	c.a.Users = append(c.a.Users, archive.User{
+		Id:           id,
+		Username:     username,
+		Registration: c.a.Exported,
+		OauthId:      oauthId,
+		Email:        email,
+		Role:         string(role),
+	})
+	return id
+}
+
+func (c *converter) addPost(it item) {
+	authorId, ok := c.authors[it.Creator]
+	if !ok {
+		c.skip("%s %d %q: its author %q isn't in the export", it.Type, it.Id, it.Title, it.Creator)
+		return
+	}
+	date, err := parseDate(it.DateGMT, it.Date)
+	if err != nil {
+		c.skip("%s %d %q: %v", it.Type, it.Id, it.Title, err)
+		return
+	}
+
+	post := archive.Post{
+		Id:       it.Id,
+		AuthorId: authorId,
+		Title:    it.Title,
+		Content:  toMarkdown(it.Content),
+		Date:     date,
+	}
+	for _, m := range it.Meta {
+		if m.Key == "_thumbnail_id" {
+			id, _ := strconv.ParseInt(m.Value, 10, 64)
+			post.ImageURL = c.images[id]
+		}
+	}
+	c.a.Posts = append(c.a.Posts, post)
+
+	if it.Type == "page" {
+		c.addLabel(it.Id, PageLabel)
+	}
+	for _, cat := range it.Categories {
+		// every post WordPress couldn't file is "Uncategorized"
+		if cat.Domain == "category" && cat.Nicename == "uncategorized" {
+			continue
+		}
+		if cat.Domain == "category" || cat.Domain == "post_tag" {
+			c.addLabel(it.Id, strings.TrimSpace(cat.Name))
+		}
+	}
+
+	// parents come before their replies
+	sort.SliceStable(it.Comments, func(i, j int) bool {
+		return it.Comments[i].Id < it.Comments[j].Id
+	})
+	for _, cm := range it.Comments {
+		c.addComment(it, cm)
+	}
+}
+
+func (c *converter) addLabel(postId int64, name string) {
+	if name == "" {
+		return
+	}
+	id, ok := c.labels[name]
+	if !ok {
+		id = int64(len(c.a.Labels) + 1)
+		c.labels[name] = id
+		c.a.Labels = append(c.a.Labels, archive.Label{Id: id, Name: name})
+	}
+	for _, pl := range c.a.PostLabels {
+		if pl.PostId == postId && pl.LabelId == id {
+			return
+		}
+	}
+	c.a.PostLabels = append(c.a.PostLabels, archive.PostLabel{PostId: postId, LabelId: id})
+}
+
+func (c *converter) addComment(it item, cm comment) {
+	switch {
+	case cm.Type == "pingback" || cm.Type == "trackback":
+		c.skip("comment %d on %q: it's a %s", cm.Id, it.Title, cm.Type)
+		return
+	case cm.Approved != "1" && cm.Approved != "0":
+		c.skip("comment %d on %q: it's %s, not approved", cm.Id, it.Title, cm.Approved)
+		return
+	}
+	date, err := parseDate(cm.DateGMT, cm.Date)
+	if err != nil {
+		c.skip("comment %d on %q: %v", cm.Id, it.Title, err)
+		return
+	}
+
+	userId, ok := c.authorIds[cm.UserId]
+	if !ok || cm.UserId == 0 {
+		userId = c.commenter(cm)
+	}
+	c.comments[cm.Id] = true
+
+	// replies to comments that weren't kept, like spam, become comments
+	var parentId int64
+	if c.comments[cm.Parent] {
+		parentId = cm.Parent
+	}
+	c.a.Comments = append(c.a.Comments, archive.Comment{
+		Id:       cm.Id,
+		UserId:   userId,
+		PostId:   it.Id,
+		Content:  toMarkdown(cm.Content),
+		Date:     date,
+		ParentId: parentId,
+		// "0" is pending
+		Approved: cm.Approved == "1",
+	})
+}
+
+// The user of someone who commented, by email or else by name.
+func (c *converter) commenter(cm comment) int64 {
+	key := strings.ToLower(strings.TrimSpace(cm.Email))
+	email := key
+	if key == "" {
+		key = "name:" + cm.Author
+		email = "commenter-" + strconv.Itoa(len(c.a.Users)+1) + "@wordpress.invalid"
+	}
+	if id, ok := c.users[key]; ok {
+		return id
+	}
+	name := strings.TrimSpace(cm.Author)
+	if name == "" {
+		name = "Anonymous"
+	}
+	id := c.addUser(commenterOauthPrefix+key, name, email, model.RoleCommenter)
+	c.users[key] = id
+	return id
+}
+
+// Dates in UTC, or else in the time of the blog taken as UTC.
+func parseDate(gmt, local string) (time.Time, error) {
+	for _, date := range []string{gmt, local} {
+		if date == "" || strings.HasPrefix(date, "0000") {
+			continue
+		}
+		return time.Parse(dateLayout, date)
+	}
+	return time.Time{}, fmt.Errorf("no date")
+}
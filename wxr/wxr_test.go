@@ -0,0 +1,191 @@
+package wxr
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const export = `<?xml version="1.0" encoding="UTF-8" ?>
+<rss version="2.0"
+	xmlns:excerpt="http://wordpress.org/export/1.2/excerpt/"
+	xmlns:content="http://purl.org/rss/1.0/modules/content/"
+	xmlns:dc="http://purl.org/dc/elements/1.1/"
+	xmlns:wp="http://wordpress.org/export/1.2/">
+<channel>
+	<title>A WordPress blog</title>
+	<wp:author>
+		<wp:author_id>1</wp:author_id>
+		<wp:author_login><![CDATA[admin]]></wp:author_login>
+		<wp:author_email><![CDATA[admin@example.com]]></wp:author_email>
+		<wp:author_display_name><![CDATA[Antoine]]></wp:author_display_name>
+	</wp:author>
+	<item>
+		<title>Cover</title>
+		<wp:post_id>9</wp:post_id>
+		<wp:post_type><![CDATA[attachment]]></wp:post_type>
+		<wp:status><![CDATA[inherit]]></wp:status>
+		<wp:attachment_url><![CDATA[http://example.com/cover.png]]></wp:attachment_url>
+	</item>
+	<item>
+		<title>Hello</title>
+		<dc:creator><![CDATA[admin]]></dc:creator>
+		<content:encoded><![CDATA[<p>Hi <strong>there</strong></p>]]></content:encoded>
+		<wp:post_id>10</wp:post_id>
+		<wp:post_date><![CDATA[2014-03-02 05:00:00]]></wp:post_date>
+		<wp:post_date_gmt><![CDATA[2014-03-02 10:00:00]]></wp:post_date_gmt>
+		<wp:status><![CDATA[publish]]></wp:status>
+		<wp:post_type><![CDATA[post]]></wp:post_type>
+		<category domain="category" nicename="uncategorized"><![CDATA[Uncategorized]]></category>
+		<category domain="post_tag" nicename="go"><![CDATA[go]]></category>
+		<wp:postmeta>
+			<wp:meta_key><![CDATA[_thumbnail_id]]></wp:meta_key>
+			<wp:meta_value><![CDATA[9]]></wp:meta_value>
+		</wp:postmeta>
+		<wp:comment>
+			<wp:comment_id>2</wp:comment_id>
+			<wp:comment_author><![CDATA[Antoine]]></wp:comment_author>
+			<wp:comment_author_email><![CDATA[admin@example.com]]></wp:comment_author_email>
+			<wp:comment_date_gmt><![CDATA[2014-03-02 12:00:00]]></wp:comment_date_gmt>
+			<wp:comment_content><![CDATA[Thanks!]]></wp:comment_content>
+			<wp:comment_approved><![CDATA[1]]></wp:comment_approved>
+			<wp:comment_parent>1</wp:comment_parent>
+			<wp:comment_user_id>1</wp:comment_user_id>
+		</wp:comment>
+		<wp:comment>
+			<wp:comment_id>1</wp:comment_id>
+			<wp:comment_author><![CDATA[Reader]]></wp:comment_author>
+			<wp:comment_author_email><![CDATA[Reader@example.com]]></wp:comment_author_email>
+			<wp:comment_date_gmt><![CDATA[2014-03-02 11:00:00]]></wp:comment_date_gmt>
+			<wp:comment_content><![CDATA[Nice post]]></wp:comment_content>
+			<wp:comment_approved><![CDATA[1]]></wp:comment_approved>
+			<wp:comment_parent>0</wp:comment_parent>
+			<wp:comment_user_id>0</wp:comment_user_id>
+		</wp:comment>
+		<wp:comment>
+			<wp:comment_id>4</wp:comment_id>
+			<wp:comment_author><![CDATA[Reader]]></wp:comment_author>
+			<wp:comment_author_email><![CDATA[reader@example.com]]></wp:comment_author_email>
+			<wp:comment_date_gmt><![CDATA[2014-03-02 14:00:00]]></wp:comment_date_gmt>
+			<wp:comment_content><![CDATA[One more thing]]></wp:comment_content>
+			<wp:comment_approved><![CDATA[0]]></wp:comment_approved>
+			<wp:comment_parent>0</wp:comment_parent>
+		</wp:comment>
+		<wp:comment>
+			<wp:comment_id>5</wp:comment_id>
+			<wp:comment_author><![CDATA[Reader]]></wp:comment_author>
+			<wp:comment_author_email><![CDATA[reader@example.com]]></wp:comment_author_email>
+			<wp:comment_date_gmt><![CDATA[2014-03-02 15:00:00]]></wp:comment_date_gmt>
+			<wp:comment_content><![CDATA[Don't buy]]></wp:comment_content>
+			<wp:comment_approved><![CDATA[1]]></wp:comment_approved>
+			<wp:comment_parent>3</wp:comment_parent>
+		</wp:comment>
+		<wp:comment>
+			<wp:comment_id>3</wp:comment_id>
+			<wp:comment_author><![CDATA[Spammer]]></wp:comment_author>
+			<wp:comment_date_gmt><![CDATA[2014-03-02 13:00:00]]></wp:comment_date_gmt>
+			<wp:comment_content><![CDATA[Buy]]></wp:comment_content>
+			<wp:comment_approved><![CDATA[spam]]></wp:comment_approved>
+		</wp:comment>
+	</item>
+	<item>
+		<title>About</title>
+		<dc:creator><![CDATA[admin]]></dc:creator>
+		<content:encoded><![CDATA[About me]]></content:encoded>
+		<wp:post_id>11</wp:post_id>
+		<wp:post_date><![CDATA[2014-01-01 08:00:00]]></wp:post_date>
+		<wp:post_date_gmt><![CDATA[0000-00-00 00:00:00]]></wp:post_date_gmt>
+		<wp:status><![CDATA[publish]]></wp:status>
+		<wp:post_type><![CDATA[page]]></wp:post_type>
+	</item>
+	<item>
+		<title>Later</title>
+		<dc:creator><![CDATA[admin]]></dc:creator>
+		<wp:post_id>12</wp:post_id>
+		<wp:status><![CDATA[draft]]></wp:status>
+		<wp:post_type><![CDATA[post]]></wp:post_type>
+	</item>
+	<item>
+		<title>Menu</title>
+		<wp:post_id>13</wp:post_id>
+		<wp:status><![CDATA[publish]]></wp:status>
+		<wp:post_type><![CDATA[nav_menu_item]]></wp:post_type>
+	</item>
+</channel>
+</rss>`
+
+func TestRead(t *testing.T) {
+	a, skipped, err := Read(strings.NewReader(export))
+	if err != nil {
+		t.Fatal("Couldn't read export", err)
+	}
+
+	if len(a.Users) != 2 || a.Users[0].Username != "Antoine" || a.Users[0].Role != "author" ||
+		a.Users[1].Email != "reader@example.com" || a.Users[1].Role != "commenter" {
+		t.Errorf("Expected the author and a commenter, was %+v", a.Users)
+	}
+	if len(a.Authors) != 1 || a.Authors[0].UserId != a.Users[0].Id {
+		t.Errorf("Expected one author, was %+v", a.Authors)
+	}
+
+	if len(a.Posts) != 2 {
+		t.Fatalf("Expected the post and the page, was %+v", a.Posts)
+	}
+	post := a.Posts[0]
+	if post.Content != "Hi **there**" || post.ImageURL != "http://example.com/cover.png" ||
+		!post.Date.Equal(time.Date(2014, 3, 2, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the post to be converted, was %+v", post)
+	}
+	if page := a.Posts[1]; !page.Date.Equal(time.Date(2014, 1, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the page dated from its local date, was %v", page.Date)
+	}
+	var labels []string
+	for _, pl := range a.PostLabels {
+		labels = append(labels, a.Labels[pl.LabelId-1].Name)
+	}
+	if strings.Join(labels, ",") != "go,page" {
+		t.Errorf("Expected the tag and the page label, was %v", labels)
+	}
+
+	if len(a.Comments) != 4 {
+		t.Fatalf("Expected the approved and pending comments, was %+v", a.Comments)
+	}
+	if a.Comments[0].Content != "Nice post" || a.Comments[0].UserId != a.Users[1].Id || !a.Comments[0].Approved {
+		t.Errorf("Expected the comment of the reader first, was %+v", a.Comments[0])
+	}
+	if a.Comments[1].Content != "Thanks!" || a.Comments[1].UserId != a.Users[0].Id || a.Comments[1].ParentId != 1 {
+		t.Errorf("Expected the reply of the author, was %+v", a.Comments[1])
+	}
+	if a.Comments[2].Content != "One more thing" || a.Comments[2].Approved {
+		t.Errorf("Expected the pending comment, was %+v", a.Comments[2])
+	}
+	if a.Comments[3].Content != "Don't buy" || a.Comments[3].ParentId != 0 {
+		t.Errorf("Expected the reply to spam without its parent, was %+v", a.Comments[3])
+	}
+
+	report := strings.Join(skipped, "\n")
+	for _, why := range []string{`comment 3 on "Hello": it's spam`, `post 12 "Later": it's draft`, `"nav_menu_item"`} {
+		if !strings.Contains(report, why) {
+			t.Errorf("Expected %q to be reported, was %v", why, skipped)
+		}
+	}
+}
+
+func TestReadRefusesOtherFiles(t *testing.T) {
+	if _, _, err := Read(strings.NewReader("not xml")); err == nil {
+		t.Error("Expected an error")
+	}
+}
+
+func TestImported(t *testing.T) {
+	for oauthId, imported := range map[string]bool{
+		"wordpress:admin":                     true,
+		"wordpress-commenter:bob@example.com": true,
+		"100000000000000000001":               false,
+		"":                                    false,
+	} {
+		if Imported(oauthId) != imported {
+			t.Errorf("Expected Imported(%q) to be %v", oauthId, imported)
+		}
+	}
+}